@@ -19,13 +19,18 @@ package acme
 import (
 	"context"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"net/http"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/acme/x/acme"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
@@ -34,7 +39,18 @@ import (
 
 const (
 	acmeChallengeHTTP01     = "http-01"
+	acmeChallengeDNS01      = "dns-01"
+	acmeChallengeTLSALPN01  = "tls-alpn-01"
 	acmeErrAcctDoesNotExist = "urn:ietf:params:acme:error:accountDoesNotExist"
+	acmeErrAlreadyRevoked   = "urn:ietf:params:acme:error:alreadyRevoked"
+)
+
+// Key types of the issued certificates
+const (
+	KeyTypeRSA2048 = "rsa2048"
+	KeyTypeRSA4096 = "rsa4096"
+	KeyTypeEC256   = "ec256"
+	KeyTypeEC384   = "ec384"
 )
 
 var (
@@ -52,14 +68,27 @@ func NewClient(logger types.Logger, resolver ClientResolver, account *Account) (
 	for i, email := range emails {
 		contact[i] = "mailto:" + email
 	}
+	httpClient, err := newHTTPClient(account.CABundle)
+	if err != nil {
+		return nil, err
+	}
+	var eab *acme.ExternalAccountBinding
+	if account.EABKeyID != "" {
+		eab = &acme.ExternalAccountBinding{
+			KID: account.EABKeyID,
+			Key: account.EABHMACKey,
+		}
+	}
 	client := &client{
 		client: &acme.Client{
 			DirectoryURL: account.Endpoint + "/directory",
+			HTTPClient:   httpClient,
 			Key:          key,
 			UserAgent:    acmeUserAgent,
 		},
 		ctx:         context.Background(),
 		contact:     contact,
+		eab:         eab,
 		endpoint:    account.Endpoint,
 		logger:      logger,
 		resolver:    resolver,
@@ -71,11 +100,40 @@ func NewClient(logger types.Logger, resolver ClientResolver, account *Account) (
 	return client, nil
 }
 
+// newHTTPClient returns the http client used to reach the acme server.
+// A nil client, which means http.DefaultClient, is returned if caBundle
+// is empty, so the system trust store is used.
+func newHTTPClient(caBundle []byte) (*http.Client, error) {
+	if len(caBundle) == 0 {
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caBundle) {
+		return nil, fmt.Errorf("acme: no valid certificate found in the CA bundle")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return &http.Client{Transport: transport}, nil
+}
+
 // Account ...
 type Account struct {
 	Emails      string
 	Endpoint    string
 	TermsAgreed bool
+	// EABKeyID and EABHMACKey are the key ID and the decoded HMAC key
+	// of the external account binding, used when creating the account.
+	EABKeyID   string
+	EABHMACKey []byte
+	// CABundle is a PEM encoded list of certificates used to verify
+	// the acme server instead of the system trust store.
+	CABundle []byte
+}
+
+// String ...
+func (a Account) String() string {
+	return fmt.Sprintf("{Emails:%s Endpoint:%s TermsAgreed:%t EABKeyID:%s CABundle:%t}",
+		a.Emails, a.Endpoint, a.TermsAgreed, a.EABKeyID, len(a.CABundle) > 0)
 }
 
 // ClientResolver ...
@@ -86,13 +144,42 @@ type ClientResolver interface {
 
 // Client ...
 type Client interface {
-	Sign(dnsnames []string) (crt, key []byte, err error)
+	RenewalWindow(crt *x509.Certificate) (*RenewalWindow, error)
+	Revoke(crt *x509.Certificate) error
+	Sign(dnsnames []string, opts SignOptions) (crt, key []byte, err error)
+}
+
+// RenewalWindow is the time window the acme server suggests a certificate
+// to be renewed, read from its ACME Renewal Information (ARI) endpoint.
+type RenewalWindow struct {
+	Start time.Time
+	End   time.Time
+	// RetryAfter is the time the renewal window should be read again,
+	// zero if the server does not suggest one.
+	RetryAfter time.Time
+}
+
+// SignOptions ...
+type SignOptions struct {
+	// Challenge is the challenge type used to authorize the domains,
+	// http-01 is used if empty.
+	Challenge string
+	// DNSProvider is used by the dns-01 challenge.
+	DNSProvider DNSProvider
+	// KeyType is the type of the private key of the certificate,
+	// rsa2048 is used if empty.
+	KeyType string
+	// PreferredChain is the issuer common name of the topmost certificate
+	// of the chain that should be used if the acme server offers
+	// alternate chains. The default chain is used if empty or not found.
+	PreferredChain string
 }
 
 type client struct {
 	client      *acme.Client
 	contact     []string
 	ctx         context.Context
+	eab         *acme.ExternalAccountBinding
 	endpoint    string
 	logger      types.Logger
 	resolver    ClientResolver
@@ -104,8 +191,9 @@ func (c *client) ensureAccount() error {
 		acmeErr, ok := err.(*acme.Error)
 		if ok && acmeErr.Type == acmeErrAcctDoesNotExist {
 			_, err = c.client.CreateAccount(c.ctx, &acme.Account{
-				Contact:     c.contact,
-				TermsAgreed: c.termsAgreed,
+				Contact:                c.contact,
+				TermsAgreed:            c.termsAgreed,
+				ExternalAccountBinding: c.eab,
 			})
 			if err != nil {
 				return err
@@ -128,7 +216,34 @@ func (c *client) ensureAccount() error {
 	return nil
 }
 
-func (c *client) Sign(dnsnames []string) (crt, key []byte, err error) {
+// RenewalWindow returns nil without an error if the acme server does not
+// support ARI.
+func (c *client) RenewalWindow(crt *x509.Certificate) (*RenewalWindow, error) {
+	info, err := c.client.FetchRenewalInfo(c.ctx, crt)
+	if err == acme.ErrNoRenewalInfo {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &RenewalWindow{
+		Start:      info.SuggestedWindow.Start,
+		End:        info.SuggestedWindow.End,
+		RetryAfter: info.RetryAfter,
+	}, nil
+}
+
+// Revoke revokes crt, which should be issued by the same account.
+// A certificate already revoked is not considered an error.
+func (c *client) Revoke(crt *x509.Certificate) error {
+	err := c.client.RevokeCert(c.ctx, nil, crt.Raw, acme.CRLReasonCessationOfOperation)
+	if acmeErr, ok := err.(*acme.Error); ok && acmeErr.Type == acmeErrAlreadyRevoked {
+		return nil
+	}
+	return err
+}
+
+func (c *client) Sign(dnsnames []string, opts SignOptions) (crt, key []byte, err error) {
 	if len(dnsnames) == 0 {
 		return crt, key, fmt.Errorf("dnsnames is empty")
 	}
@@ -136,52 +251,119 @@ func (c *client) Sign(dnsnames []string) (crt, key []byte, err error) {
 	if err != nil {
 		return crt, key, err
 	}
-	if err := c.authorize(dnsnames, order); err != nil {
+	if err := c.authorize(order, opts); err != nil {
 		return crt, key, err
 	}
 	csrTemplate := &x509.CertificateRequest{}
 	csrTemplate.Subject.CommonName = dnsnames[0]
 	csrTemplate.DNSNames = dnsnames
-	return c.signRequest(order, csrTemplate)
+	return c.signRequest(order, csrTemplate, opts)
 }
 
-func (c *client) authorize(dnsnames []string, order *acme.Order) error {
+func (c *client) authorize(order *acme.Order, opts SignOptions) error {
+	challengeType := opts.Challenge
+	if challengeType == "" {
+		challengeType = acmeChallengeHTTP01
+	}
+	if challengeType == acmeChallengeDNS01 && opts.DNSProvider == nil {
+		return fmt.Errorf("acme: dns provider is missing")
+	}
 	for _, authStr := range order.Authorizations {
 		auth, err := c.client.GetAuthorization(c.ctx, authStr)
 		if err != nil {
 			return err
 		}
-		for _, challenge := range auth.Challenges {
-			if challenge.Type == acmeChallengeHTTP01 {
-				checkURI := c.client.HTTP01ChallengePath(challenge.Token)
-				checkRes, err := c.client.HTTP01ChallengeResponse(challenge.Token)
-				if err != nil {
-					return err
-				}
-				if err := c.resolver.SetToken(auth.Identifier.Value, checkURI, checkRes); err != nil {
-					return err
-				}
-				_, err = c.client.AcceptChallenge(c.ctx, challenge)
-				if err != nil {
-					return err
-				}
-				_, err = c.client.WaitAuthorization(c.ctx, challenge.URL)
-				_ = c.resolver.SetToken(auth.Identifier.Value, checkURI, "")
-				if err != nil {
-					if acmeErr, ok := err.(acme.AuthorizationError); ok {
-						// acme client returns an empty Identifier.Value on acmeErr.Authorization
-						return fmt.Errorf("acme: authorization error: domain=%s status=%s", auth.Identifier.Value, acmeErr.Authorization.Status)
-					}
-					return err
-				}
+		if auth.Status == acme.StatusValid {
+			continue
+		}
+		var challenge *acme.Challenge
+		for _, ch := range auth.Challenges {
+			if ch.Type == challengeType {
+				challenge = ch
+				break
+			}
+		}
+		if challenge == nil {
+			return fmt.Errorf("acme: %s challenge not offered for domain %s", challengeType, auth.Identifier.Value)
+		}
+		switch challengeType {
+		case acmeChallengeDNS01:
+			err = c.authorizeDNS01(auth, challenge, opts.DNSProvider)
+		case acmeChallengeTLSALPN01:
+			err = c.authorizeTLSALPN01(auth, challenge)
+		default:
+			err = c.authorizeHTTP01(auth, challenge)
+		}
+		if err != nil {
+			if acmeErr, ok := err.(acme.AuthorizationError); ok {
+				// acme client returns an empty Identifier.Value on acmeErr.Authorization
+				return fmt.Errorf("acme: authorization error: domain=%s status=%s", auth.Identifier.Value, acmeErr.Authorization.Status)
 			}
+			return err
 		}
 	}
 	return nil
 }
 
-func (c *client) signRequest(order *acme.Order, csrTemplate *x509.CertificateRequest) (crt, key []byte, err error) {
-	keys, err := rsa.GenerateKey(rand.Reader, 2048)
+func (c *client) authorizeHTTP01(auth *acme.Authorization, challenge *acme.Challenge) error {
+	checkURI := c.client.HTTP01ChallengePath(challenge.Token)
+	checkRes, err := c.client.HTTP01ChallengeResponse(challenge.Token)
+	if err != nil {
+		return err
+	}
+	if err := c.resolver.SetToken(auth.Identifier.Value, checkURI, checkRes); err != nil {
+		return err
+	}
+	defer c.resolver.SetToken(auth.Identifier.Value, checkURI, "")
+	return c.acceptAndWait(challenge)
+}
+
+func (c *client) authorizeDNS01(auth *acme.Authorization, challenge *acme.Challenge, dnsProvider DNSProvider) error {
+	record, err := c.client.DNS01ChallengeRecord(challenge.Token)
+	if err != nil {
+		return err
+	}
+	fqdn := dns01FQDN(auth.Identifier.Value)
+	if err := dnsProvider.Present(fqdn, record); err != nil {
+		return err
+	}
+	defer func() {
+		if err := dnsProvider.CleanUp(fqdn, record); err != nil {
+			c.logger.Warn("acme: error removing dns-01 challenge record %s: %v", fqdn, err)
+		}
+	}()
+	if waiter, ok := dnsProvider.(dnsPropagationWaiter); ok {
+		if err := waiter.WaitPropagation(fqdn, record); err != nil {
+			return err
+		}
+	}
+	return c.acceptAndWait(challenge)
+}
+
+func (c *client) authorizeTLSALPN01(auth *acme.Authorization, challenge *acme.Challenge) error {
+	// the key authorization is shared with the acme server, which
+	// builds the challenge certificate on the acme-tls/1 handshake
+	keyAuth, err := c.client.HTTP01ChallengeResponse(challenge.Token)
+	if err != nil {
+		return err
+	}
+	if err := c.resolver.SetToken(auth.Identifier.Value, acmeTLSALPNProto, keyAuth); err != nil {
+		return err
+	}
+	defer c.resolver.SetToken(auth.Identifier.Value, acmeTLSALPNProto, "")
+	return c.acceptAndWait(challenge)
+}
+
+func (c *client) acceptAndWait(challenge *acme.Challenge) error {
+	if _, err := c.client.AcceptChallenge(c.ctx, challenge); err != nil {
+		return err
+	}
+	_, err := c.client.WaitAuthorization(c.ctx, challenge.URL)
+	return err
+}
+
+func (c *client) signRequest(order *acme.Order, csrTemplate *x509.CertificateRequest, opts SignOptions) (crt, key []byte, err error) {
+	keys, pemKey, err := generateKey(opts.KeyType)
 	if err != nil {
 		return crt, key, err
 	}
@@ -189,14 +371,20 @@ func (c *client) signRequest(order *acme.Order, csrTemplate *x509.CertificateReq
 	if err != nil {
 		return crt, key, err
 	}
-	rawCerts, err := c.client.FinalizeOrder(c.ctx, order.FinalizeURL, csr)
+	var rawCerts [][]byte
+	if opts.PreferredChain == "" {
+		rawCerts, err = c.client.FinalizeOrder(c.ctx, order.FinalizeURL, csr)
+	} else {
+		var chains [][][]byte
+		chains, err = c.client.FinalizeOrderChains(c.ctx, order.FinalizeURL, csr)
+		if err == nil {
+			rawCerts = c.preferredChain(chains, opts.PreferredChain)
+		}
+	}
 	if err != nil {
 		return crt, key, err
 	}
-	key = pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(keys),
-	})
+	key = pem.EncodeToMemory(pemKey)
 	for _, rawCert := range rawCerts {
 		crt = append(crt, pem.EncodeToMemory(&pem.Block{
 			Type:  "CERTIFICATE",
@@ -205,3 +393,68 @@ func (c *client) signRequest(order *acme.Order, csrTemplate *x509.CertificateReq
 	}
 	return crt, key, nil
 }
+
+// preferredChain returns the first chain whose topmost certificate was
+// issued by issuerCN, or the default chain if no one matches.
+func (c *client) preferredChain(chains [][][]byte, issuerCN string) [][]byte {
+	for _, chain := range chains {
+		top, err := x509.ParseCertificate(chain[len(chain)-1])
+		if err == nil && top.Issuer.CommonName == issuerCN {
+			return chain
+		}
+	}
+	c.logger.Warn("acme: preferred chain '%s' was not offered by the acme server, using the default one", issuerCN)
+	return chains[0]
+}
+
+func generateKey(keyType string) (crypto.Signer, *pem.Block, error) {
+	switch keyType {
+	case "", KeyTypeRSA2048, KeyTypeRSA4096:
+		bits := 2048
+		if keyType == KeyTypeRSA4096 {
+			bits = 4096
+		}
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}, nil
+	case KeyTypeEC256, KeyTypeEC384:
+		curve := elliptic.P256()
+		if keyType == KeyTypeEC384 {
+			curve = elliptic.P384()
+		}
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key, &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported key type: %s", keyType)
+}
+
+// KeyType returns the key type of a private key, or an empty
+// string if the key type isn't supported.
+func KeyType(key crypto.Signer) string {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		switch k.N.BitLen() {
+		case 2048:
+			return KeyTypeRSA2048
+		case 4096:
+			return KeyTypeRSA4096
+		}
+	case *ecdsa.PrivateKey:
+		switch k.Curve.Params().BitSize {
+		case 256:
+			return KeyTypeEC256
+		case 384:
+			return KeyTypeEC384
+		}
+	}
+	return ""
+}
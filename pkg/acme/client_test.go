@@ -18,10 +18,22 @@ package acme
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -58,7 +70,7 @@ func TestSign(t *testing.T) {
 	}
 	// TODO test resulting crt
 	// TODO debug/fine logging in the Sign() steps
-	_, _, err = client.Sign([]string{domain})
+	_, _, err = client.Sign([]string{domain}, SignOptions{})
 	if err != nil {
 		t.Errorf("error signing certificate: %v", err)
 	}
@@ -67,11 +79,188 @@ func TestSign(t *testing.T) {
 	c.logger.CompareLogging("INFO acme: client account successfully retrieved")
 }
 
+func TestGenerateKey(t *testing.T) {
+	for _, keyType := range []string{KeyTypeRSA2048, KeyTypeEC256, KeyTypeEC384} {
+		key, pemKey, err := generateKey(keyType)
+		if err != nil {
+			t.Errorf("error generating %s key: %v", keyType, err)
+			continue
+		}
+		if actual := KeyType(key); actual != keyType {
+			t.Errorf("key type differs - expected: %s, actual: %s", keyType, actual)
+		}
+		if pemKey == nil || len(pemKey.Bytes) == 0 {
+			t.Errorf("missing pem encoded %s key", keyType)
+		}
+	}
+	if _, _, err := generateKey("dsa1024"); err == nil || err.Error() != "unsupported key type: dsa1024" {
+		t.Errorf("expected unsupported key type error, actual: %v", err)
+	}
+}
+
+// TestNewClientEAB creates an account on a local stand-in of an internal
+// acme server, which requires external account binding and uses a
+// certificate signed by a private CA.
+func TestNewClientEAB(t *testing.T) {
+	hmacKey := []byte("0123456789abcdef0123456789abcdef")
+	var requests []string
+	var server *httptest.Server
+	server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "nonce")
+		switch r.URL.Path {
+		case "/directory":
+			fmt.Fprintf(w, `{"newNonce":"%[1]s/nonce","newAccount":"%[1]s/account","meta":{"externalAccountRequired":true}}`, server.URL)
+			return
+		case "/nonce":
+			return
+		}
+		var jws struct{ Payload string }
+		_ = json.NewDecoder(r.Body).Decode(&jws)
+		payload, _ := base64.RawURLEncoding.DecodeString(jws.Payload)
+		var req struct {
+			OnlyReturnExisting     bool
+			ExternalAccountBinding *struct{ Protected, Payload, Signature string }
+		}
+		_ = json.Unmarshal(payload, &req)
+		w.Header().Set("Content-Type", "application/problem+json")
+		if req.OnlyReturnExisting {
+			requests = append(requests, "get account")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"type":"urn:ietf:params:acme:error:accountDoesNotExist"}`)
+			return
+		}
+		eab := req.ExternalAccountBinding
+		if eab == nil {
+			requests = append(requests, "new account without eab")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"type":"urn:ietf:params:acme:error:externalAccountRequired"}`)
+			return
+		}
+		head, _ := base64.RawURLEncoding.DecodeString(eab.Protected)
+		h := hmac.New(sha256.New, hmacKey)
+		h.Write([]byte(eab.Protected + "." + eab.Payload))
+		mac := "invalid"
+		if base64.RawURLEncoding.EncodeToString(h.Sum(nil)) == eab.Signature {
+			mac = "ok"
+		}
+		requests = append(requests, fmt.Sprintf("new account %s mac=%s", strings.Replace(string(head), server.URL, "<url>", 1), mac))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", server.URL+"/account/1")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"status":"valid","contact":["mailto:admin@d1.local"]}`)
+	}))
+	// missing the CA bundle is part of the test, hide the handshake errors
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	testCases := []struct {
+		account     Account
+		expRequests string
+		expError    string
+		logging     string
+	}{
+		// 0
+		{
+			account: Account{CABundle: caBundle, EABKeyID: "kid-1", EABHMACKey: hmacKey},
+			expRequests: `get account
+new account {"alg":"HS256","kid":"kid-1","url":"<url>/account"} mac=ok`,
+			logging: `INFO acme: terms agreed, new account created on ` + server.URL,
+		},
+		// 1
+		{
+			account:     Account{CABundle: caBundle},
+			expRequests: "get account\nnew account without eab",
+			expError:    "acme: urn:ietf:params:acme:error:externalAccountRequired: ",
+		},
+		// 2
+		{
+			account:  Account{EABKeyID: "kid-1", EABHMACKey: hmacKey},
+			expError: "x509: certificate signed by unknown authority",
+		},
+		// 3
+		{
+			account:  Account{CABundle: []byte("invalid")},
+			expError: "acme: no valid certificate found in the CA bundle",
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		requests = nil
+		test.account.Endpoint = server.URL
+		test.account.Emails = "admin@d1.local"
+		test.account.TermsAgreed = true
+		_, err := NewClient(c.logger, &clientResolver{logger: c.logger, key: key}, &test.account)
+		var errMsg string
+		if err != nil {
+			errMsg = err.Error()
+		}
+		// the unknown authority error is wrapped by the http client
+		if !strings.HasSuffix(errMsg, test.expError) || (errMsg == "") != (test.expError == "") {
+			t.Errorf("error differs on %d - expected: '%s', actual: '%s'", i, test.expError, errMsg)
+		}
+		if actual := strings.Join(requests, "\n"); actual != test.expRequests {
+			t.Errorf("requests differ on %d - expected:\n%s\nactual:\n%s", i, test.expRequests, actual)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestPreferredChain(t *testing.T) {
+	root1, root1Key := newTestCert(&x509.Certificate{Subject: pkix.Name{CommonName: "Root 1"}, IsCA: true}, nil, nil)
+	root2, root2Key := newTestCert(&x509.Certificate{Subject: pkix.Name{CommonName: "Root 2"}, IsCA: true}, nil, nil)
+	cross, _ := newTestCert(&x509.Certificate{Subject: pkix.Name{CommonName: "Root 2"}, IsCA: true}, root1, root1Key)
+	inter, _ := newTestCert(&x509.Certificate{Subject: pkix.Name{CommonName: "Intermediate"}, IsCA: true}, root2, root2Key)
+	chains := [][][]byte{
+		{[]byte("leaf"), inter.Raw, cross.Raw},
+		{[]byte("leaf"), inter.Raw},
+	}
+	testCases := []struct {
+		issuerCN string
+		expected int
+		logging  string
+	}{
+		// 0
+		{
+			issuerCN: "Root 1",
+			expected: 0,
+		},
+		// 1
+		{
+			issuerCN: "Root 2",
+			expected: 1,
+		},
+		// 2
+		{
+			issuerCN: "Root 3",
+			expected: 0,
+			logging:  "WARN acme: preferred chain 'Root 3' was not offered by the acme server, using the default one",
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		client := &client{logger: c.logger}
+		actual := client.preferredChain(chains, test.issuerCN)
+		if len(actual) != len(chains[test.expected]) {
+			t.Errorf("chain differs on %d - expected: %d, actual: %d certificates", i, len(chains[test.expected]), len(actual))
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 type clientResolver struct {
 	logger *types_helper.LoggerMock
+	key    crypto.Signer
 }
 
 func (c *clientResolver) GetKey() (crypto.Signer, error) {
+	if c.key != nil {
+		return c.key, nil
+	}
 	der, _ := base64.StdEncoding.DecodeString(clientkey)
 	key, _ := x509.ParsePKCS1PrivateKey(der)
 	return key, nil
@@ -0,0 +1,71 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acme
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	dnsProviderRFC2136 = "rfc2136"
+	dnsProviderWebhook = "webhook"
+)
+
+// DNSProvider ...
+type DNSProvider interface {
+	Present(fqdn, value string) error
+	CleanUp(fqdn, value string) error
+}
+
+// dnsPropagationWaiter is implemented by the providers that can check if
+// a new TXT record is already published by all the nameservers of its zone.
+type dnsPropagationWaiter interface {
+	WaitPropagation(fqdn, value string) error
+}
+
+// DNSResolver ...
+type DNSResolver interface {
+	GetDNSProviderConfig(secretName string) (map[string][]byte, error)
+}
+
+// NewDNSProvider creates a DNSProvider from a `<type>:<namespace>/<secret-name>`
+// provider config. The secret has the credentials and the remaining
+// configuration of the provider.
+func NewDNSProvider(resolver DNSResolver, provider string) (DNSProvider, error) {
+	typeSecret := strings.SplitN(provider, ":", 2)
+	if len(typeSecret) != 2 || typeSecret[1] == "" {
+		return nil, fmt.Errorf("invalid dns provider config, expected '<type>:<secret-name>': %s", provider)
+	}
+	config, err := resolver.GetDNSProviderConfig(typeSecret[1])
+	if err != nil {
+		return nil, err
+	}
+	switch typeSecret[0] {
+	case dnsProviderRFC2136:
+		return newRFC2136Provider(config)
+	case dnsProviderWebhook:
+		return newWebhookProvider(config)
+	}
+	return nil, fmt.Errorf("unsupported dns provider: %s", typeSecret[0])
+}
+
+// dns01FQDN returns the name of the TXT record of a dns-01 challenge.
+// Wildcard domains use the same record of their base domain.
+func dns01FQDN(domain string) string {
+	return "_acme-challenge." + strings.TrimSuffix(strings.TrimPrefix(domain, "*."), ".") + "."
+}
@@ -0,0 +1,507 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acme
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDNS01FQDN(t *testing.T) {
+	testCases := map[string]string{
+		"d1.local":   "_acme-challenge.d1.local.",
+		"d1.local.":  "_acme-challenge.d1.local.",
+		"*.d1.local": "_acme-challenge.d1.local.",
+	}
+	for domain, expected := range testCases {
+		if actual := dns01FQDN(domain); actual != expected {
+			t.Errorf("fqdn differs on %s - expected: %s, actual: %s", domain, expected, actual)
+		}
+	}
+}
+
+func TestNewDNSProvider(t *testing.T) {
+	resolver := &cache{dnsProvider: map[string]map[string][]byte{
+		"default/rfc2136":    {"nameserver": []byte("10.0.0.1"), "zone": []byte("d1.local")},
+		"default/rfc2136ns":  {"zone": []byte("d1.local")},
+		"default/rfc2136key": {"nameserver": []byte("10.0.0.1"), "zone": []byte("d1.local"), "tsig-key-name": []byte("k1")},
+		"default/rfc2136pt":  {"nameserver": []byte("10.0.0.1"), "zone": []byte("d1.local"), "propagation-timeout": []byte("2")},
+		"default/webhook":    {"url": []byte("http://dns.local/hook")},
+	}}
+	testCases := []struct {
+		provider string
+		expError string
+	}{
+		// 0
+		{
+			provider: "rfc2136:default/rfc2136",
+		},
+		// 1
+		{
+			provider: "webhook:default/webhook",
+		},
+		// 2
+		{
+			provider: "rfc2136:default/rfc2136ns",
+			expError: "rfc2136: nameserver and zone are mandatory",
+		},
+		// 3
+		{
+			provider: "rfc2136:default/rfc2136key",
+			expError: "rfc2136: missing or invalid base64 encoded tsig secret",
+		},
+		// 4
+		{
+			provider: "route53:default/webhook",
+			expError: "unsupported dns provider: route53",
+		},
+		// 5
+		{
+			provider: "rfc2136",
+			expError: "invalid dns provider config, expected '<type>:<secret-name>': rfc2136",
+		},
+		// 6
+		{
+			provider: "webhook:default/notfound",
+			expError: "secret not found: 'default/notfound'",
+		},
+		// 7
+		{
+			provider: "rfc2136:default/rfc2136pt",
+			expError: "rfc2136: invalid propagation timeout: 2",
+		},
+	}
+	for i, test := range testCases {
+		_, err := NewDNSProvider(resolver, test.provider)
+		var errMsg string
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if errMsg != test.expError {
+			t.Errorf("error differs on %d - expected: '%s', actual: '%s'", i, test.expError, errMsg)
+		}
+	}
+}
+
+func TestRFC2136(t *testing.T) {
+	secret := []byte("0123456789abcdef")
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error starting dns server: %v", err)
+	}
+	defer server.Close()
+	requests := make(chan string, 1)
+	go func() {
+		records := map[string]string{}
+		buf := make([]byte, 4096)
+		for {
+			n, addr, err := server.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := buf[:n]
+			var res []byte
+			if req[2]>>3 == 0 {
+				res = answerTXT(req, records)
+			} else {
+				update := parseUpdate(req, secret)
+				if update.class == dnsClassIN {
+					records[update.name] = update.txt
+				} else {
+					delete(records, update.name)
+				}
+				requests <- update.desc
+				res = signResponse(req, update, secret)
+			}
+			_, _ = server.WriteTo(res, addr)
+		}
+	}()
+
+	now := time.Unix(1600000000, 0)
+	provider, err := newRFC2136Provider(map[string][]byte{
+		"nameserver":    []byte(server.LocalAddr().String()),
+		"zone":          []byte("d1.local"),
+		"tsig-key-name": []byte("acme-key"),
+		"tsig-secret":   []byte(base64.StdEncoding.EncodeToString(secret)),
+		"ttl":           []byte("120"),
+	})
+	if err != nil {
+		t.Fatalf("error creating provider: %v", err)
+	}
+	provider.now = func() time.Time { return now }
+	provider.timeout = 5 * time.Second
+	provider.propagationTimeout = 100 * time.Millisecond
+	provider.pollInterval = 10 * time.Millisecond
+	provider.lookupNS = func(name string) ([]*net.NS, error) {
+		return nil, fmt.Errorf("no such host")
+	}
+
+	testCases := []struct {
+		remove   bool
+		fqdn     string
+		expected string
+		expError string
+	}{
+		// 0
+		{
+			fqdn:     "_acme-challenge.www.d1.local.",
+			expected: "zone=d1.local. name=_acme-challenge.www.d1.local. class=1 ttl=120 txt=token1 key=acme-key. alg=hmac-sha256. time=1600000000 mac=ok",
+		},
+		// 1
+		{
+			remove:   true,
+			fqdn:     "_acme-challenge.www.d1.local.",
+			expected: "zone=d1.local. name=_acme-challenge.www.d1.local. class=254 ttl=0 txt=token1 key=acme-key. alg=hmac-sha256. time=1600000000 mac=ok",
+		},
+		// 2
+		{
+			fqdn:     "_acme-challenge.refused.d1.local.",
+			expected: "zone=d1.local. name=_acme-challenge.refused.d1.local. class=1 ttl=120 txt=token1 key=acme-key. alg=hmac-sha256. time=1600000000 mac=ok",
+			expError: "rfc2136: error updating _acme-challenge.refused.d1.local.: REFUSED",
+		},
+		// 3
+		{
+			fqdn:     "_acme-challenge.unsigned.d1.local.",
+			expected: "zone=d1.local. name=_acme-challenge.unsigned.d1.local. class=1 ttl=120 txt=token1 key=acme-key. alg=hmac-sha256. time=1600000000 mac=ok",
+			expError: "rfc2136: unsigned response updating _acme-challenge.unsigned.d1.local.",
+		},
+		// 4
+		{
+			fqdn:     "_acme-challenge.badsig.d1.local.",
+			expected: "zone=d1.local. name=_acme-challenge.badsig.d1.local. class=1 ttl=120 txt=token1 key=acme-key. alg=hmac-sha256. time=1600000000 mac=ok",
+			expError: "rfc2136: invalid tsig signature of the response updating _acme-challenge.badsig.d1.local.",
+		},
+		// 5
+		{
+			fqdn:     "_acme-challenge.badtime.d1.local.",
+			expected: "zone=d1.local. name=_acme-challenge.badtime.d1.local. class=1 ttl=120 txt=token1 key=acme-key. alg=hmac-sha256. time=1600000000 mac=ok",
+			expError: "rfc2136: tsig time of the response out of the allowed window updating _acme-challenge.badtime.d1.local.",
+		},
+	}
+	for i, test := range testCases {
+		if test.remove {
+			err = provider.CleanUp(test.fqdn, "token1")
+		} else {
+			err = provider.Present(test.fqdn, "token1")
+		}
+		var errMsg string
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if errMsg != test.expError {
+			t.Errorf("error differs on %d - expected: '%s', actual: '%s'", i, test.expError, errMsg)
+		}
+		if actual := <-requests; actual != test.expected {
+			t.Errorf("request differs on %d - expected: '%s', actual: '%s'", i, test.expected, actual)
+		}
+	}
+
+	fqdn := "_acme-challenge.wait.d1.local."
+	if err := provider.Present(fqdn, "token2"); err != nil {
+		t.Errorf("error adding %s: %v", fqdn, err)
+	}
+	<-requests
+	if err := provider.WaitPropagation(fqdn, "token2"); err != nil {
+		t.Errorf("error waiting the propagation of %s: %v", fqdn, err)
+	}
+	expError := "rfc2136: timeout waiting the TXT record " + fqdn + " on nameserver(s): " + server.LocalAddr().String()
+	if err := provider.WaitPropagation(fqdn, "token3"); err == nil || err.Error() != expError {
+		t.Errorf("error differs waiting an outdated record - expected: '%s', actual: '%v'", expError, err)
+	}
+}
+
+// TestRFC2136Wire compares the signed update and verifies the signed response
+// against fixtures built independently, following RFC 2136 and RFC 8945.
+func TestRFC2136Wire(t *testing.T) {
+	testCases := []struct {
+		algorithm string
+		request   string
+		response  string
+	}{
+		// 0
+		{
+			algorithm: "hmac-sha256",
+			request: "123428000001000000010001026431056c6f63616c00000600010f5f61636d652d6368616c6c656e6765037777770264" +
+				"31056c6f63616c000010000100000078000706746f6b656e310861636d652d6b65790000fa00ff00000000003d0b686d" +
+				"61632d7368613235360000005f5e1000012c002052a1f7429ddc5257d1b2e2935ca3db22793646d802c18234ccf092b0" +
+				"9a526c93123400000000",
+			response: "1234a80000000000000000010861636d652d6b65790000fa00ff00000000003d0b686d61632d7368613235360000005f" +
+				"5e100a012c00203a4b55423b6f220a7fc14437a8a669d93836ca11414a84c1ed5b094066d5bbf3123400000000",
+		},
+		// 1
+		{
+			algorithm: "hmac-sha512",
+			request: "123428000001000000010001026431056c6f63616c00000600010f5f61636d652d6368616c6c656e6765037777770264" +
+				"31056c6f63616c000010000100000078000706746f6b656e310861636d652d6b65790000fa00ff00000000005d0b686d" +
+				"61632d7368613531320000005f5e1000012c0040d97b4e2e8e4c15c0fbd8d2f21c312383517f636fffece19af96b6cbd" +
+				"370b8db9a9d0019131654d8bf723d95b1477f6ba302df7b2bfbdb9c57620f7684feabef3123400000000",
+			response: "1234a80000000000000000010861636d652d6b65790000fa00ff00000000005d0b686d61632d7368613531320000005f" +
+				"5e100a012c004025dd6b512b0f83018898c7fa0e3940c6d195adf520fb0fbc8f2bb8c987e1cafaea894cb5193eff07e5" +
+				"ae5c7ec0458659b56e26de40cccc72ae7d9d929b9031b2123400000000",
+		},
+	}
+	fqdn := "_acme-challenge.www.d1.local."
+	for i, test := range testCases {
+		provider, err := newRFC2136Provider(map[string][]byte{
+			"nameserver":     []byte("127.0.0.1"),
+			"zone":           []byte("d1.local"),
+			"tsig-key-name":  []byte("acme-key"),
+			"tsig-algorithm": []byte(test.algorithm),
+			"tsig-secret":    []byte(base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))),
+			"ttl":            []byte("120"),
+		})
+		if err != nil {
+			t.Errorf("error creating provider on %d: %v", i, err)
+			continue
+		}
+		provider.now = func() time.Time { return time.Unix(1600000000, 0) }
+		msg, err := provider.buildUpdate(0x1234, fqdn, "token1", false)
+		if err != nil {
+			t.Errorf("error building update on %d: %v", i, err)
+			continue
+		}
+		signed, mac, err := provider.sign(msg, 0x1234)
+		if err != nil {
+			t.Errorf("error signing update on %d: %v", i, err)
+			continue
+		}
+		if actual := hex.EncodeToString(signed); actual != test.request {
+			t.Errorf("request differs on %d - expected: %s, actual: %s", i, test.request, actual)
+		}
+		response, _ := hex.DecodeString(test.response)
+		if err := provider.verify(response, mac, fqdn); err != nil {
+			t.Errorf("error verifying response on %d: %v", i, err)
+		}
+		response[len(response)-10] ^= 0xff
+		if err := provider.verify(response, mac, fqdn); err == nil {
+			t.Errorf("expected error verifying a changed response on %d", i)
+		}
+	}
+}
+
+// answerTXT responds a TXT query with the records added by the update requests.
+func answerTXT(req []byte, records map[string]string) []byte {
+	name, pos, _ := readName(req, 12)
+	res := append([]byte{}, req[:2]...)
+	txt, found := records[name]
+	if found {
+		res = append(res, 0x84, 0x00, 0, 1, 0, 1, 0, 0, 0, 0)
+	} else {
+		// NXDOMAIN
+		res = append(res, 0x84, 0x03, 0, 1, 0, 0, 0, 0, 0, 0)
+	}
+	res = append(res, req[12:pos+4]...)
+	if found {
+		// compressed pointer to the name of the question
+		res = append(res, 0xc0, 12)
+		res = appendUint16(res, dnsTypeTXT)
+		res = appendUint16(res, dnsClassIN)
+		res = appendUint32(res, 60)
+		res = appendUint16(res, uint16(len(txt)+1))
+		res = append(res, byte(len(txt)))
+		res = append(res, txt...)
+	}
+	return res
+}
+
+// signResponse builds the response of an update request, TSIG signed
+// unless the name of the record asks for a broken response.
+func signResponse(req []byte, update *testUpdate, secret []byte) []byte {
+	res := append([]byte{}, req[:4]...)
+	res[2] |= 0x80
+	res[3] = byte(update.rcode)
+	res = append(res, 0, 0, 0, 0, 0, 0, 0, 0)
+	if strings.HasPrefix(update.name, "_acme-challenge.unsigned.") {
+		return res
+	}
+	timeSigned := uint64(1600000000)
+	if strings.HasPrefix(update.name, "_acme-challenge.badtime.") {
+		timeSigned += 1000
+	}
+	keyName, _ := appendName(nil, "acme-key.")
+	algorithm, _ := appendName(nil, "hmac-sha256.")
+	h := hmac.New(sha256.New, secret)
+	h.Write(appendUint16(nil, uint16(len(update.mac))))
+	h.Write(update.mac)
+	h.Write(res)
+	h.Write(keyName)
+	h.Write([]byte{0, 255, 0, 0, 0, 0})
+	h.Write(algorithm)
+	h.Write(appendUint48(nil, timeSigned))
+	h.Write([]byte{1, 44, 0, 0, 0, 0})
+	mac := h.Sum(nil)
+	if strings.HasPrefix(update.name, "_acme-challenge.badsig.") {
+		mac[0] ^= 0xff
+	}
+	rdata := append([]byte{}, algorithm...)
+	rdata = appendUint48(rdata, timeSigned)
+	rdata = appendUint16(rdata, 300)
+	rdata = appendUint16(rdata, uint16(len(mac)))
+	rdata = append(rdata, mac...)
+	rdata = append(rdata, req[:2]...)
+	rdata = append(rdata, 0, 0, 0, 0)
+	res = append(res, keyName...)
+	res = appendUint16(res, dnsTypeTSIG)
+	res = appendUint16(res, dnsClassANY)
+	res = appendUint32(res, 0)
+	res = appendUint16(res, uint16(len(rdata)))
+	res = append(res, rdata...)
+	res[11] = 1
+	return res
+}
+
+type testUpdate struct {
+	desc  string
+	name  string
+	txt   string
+	class int
+	rcode int
+	mac   []byte
+}
+
+// parseUpdate decodes a TSIG signed update message created by rfc2136Provider
+// and returns a description of the request, the record it changes, its MAC
+// and the rcode of the response.
+func parseUpdate(msg, secret []byte) *testUpdate {
+	pos := 12
+	readName := func() string {
+		var labels []string
+		for msg[pos] != 0 {
+			l := int(msg[pos])
+			labels = append(labels, string(msg[pos+1:pos+1+l]))
+			pos += l + 1
+		}
+		pos++
+		return strings.Join(labels, ".") + "."
+	}
+	u16 := func() int {
+		v := binary.BigEndian.Uint16(msg[pos:])
+		pos += 2
+		return int(v)
+	}
+	u32 := func() int {
+		v := binary.BigEndian.Uint32(msg[pos:])
+		pos += 4
+		return int(v)
+	}
+	if msg[2]>>3 != dnsOpcodeUpdate || binary.BigEndian.Uint16(msg[10:]) != 1 {
+		return &testUpdate{desc: "invalid header", rcode: 1}
+	}
+	zone := readName()
+	pos += 4
+	name := readName()
+	_ = u16()
+	class := u16()
+	ttl := u32()
+	_ = u16()
+	txt := string(msg[pos+1 : pos+1+int(msg[pos])])
+	pos += 1 + int(msg[pos])
+
+	// TSIG
+	tsigStart := pos
+	key := readName()
+	keyEnd := pos
+	pos += 2 + 2 + 4 + 2
+	alg := readName()
+	algEnd := pos
+	timeSigned := int(binary.BigEndian.Uint16(msg[pos:]))<<32 | int(binary.BigEndian.Uint32(msg[pos+2:]))
+	pos += 6 + 2
+	macSize := u16()
+	mac := msg[pos : pos+macSize]
+
+	unsigned := append([]byte{}, msg[:tsigStart]...)
+	binary.BigEndian.PutUint16(unsigned[10:], 0)
+	h := hmac.New(sha256.New, secret)
+	h.Write(unsigned)
+	h.Write(msg[tsigStart:keyEnd])
+	h.Write([]byte{0, 255, 0, 0, 0, 0})
+	h.Write(msg[keyEnd+10 : algEnd+8])
+	h.Write([]byte{0, 0, 0, 0})
+	macStatus := "ok"
+	if !hmac.Equal(h.Sum(nil), mac) {
+		macStatus = "invalid"
+	}
+	rcode := 0
+	if strings.HasPrefix(name, "_acme-challenge.refused.") {
+		rcode = 5
+	}
+	return &testUpdate{
+		desc: fmt.Sprintf("zone=%s name=%s class=%d ttl=%d txt=%s key=%s alg=%s time=%d mac=%s",
+			zone, name, class, ttl, txt, key, alg, timeSigned, macStatus),
+		name:  name,
+		txt:   txt,
+		class: class,
+		rcode: rcode,
+		mac:   mac,
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := webhookRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, fmt.Sprintf("%s %s %s %s %s", r.Method, r.Header.Get("Authorization"), req.Action, req.FQDN, req.Value))
+		if strings.HasPrefix(req.FQDN, "_acme-challenge.fail.") {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, "zone not found")
+		}
+	}))
+	defer server.Close()
+	provider, err := newWebhookProvider(map[string][]byte{
+		"url":   []byte(server.URL),
+		"token": []byte("s3cr3t"),
+	})
+	if err != nil {
+		t.Fatalf("error creating provider: %v", err)
+	}
+
+	var errors []string
+	for _, err := range []error{
+		provider.Present("_acme-challenge.d1.local.", "token1"),
+		provider.CleanUp("_acme-challenge.d1.local.", "token1"),
+		provider.Present("_acme-challenge.fail.local.", "token2"),
+	} {
+		if err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+	expRequests := `POST Bearer s3cr3t present _acme-challenge.d1.local. token1
+POST Bearer s3cr3t cleanup _acme-challenge.d1.local. token1
+POST Bearer s3cr3t present _acme-challenge.fail.local. token2`
+	if actual := strings.Join(requests, "\n"); actual != expRequests {
+		t.Errorf("requests differ - expected:\n%s\nactual:\n%s", expRequests, actual)
+	}
+	expErrors := "webhook: present of _acme-challenge.fail.local. failed: 500 Internal Server Error zone not found"
+	if actual := strings.Join(errors, "\n"); actual != expErrors {
+		t.Errorf("errors differ - expected: '%s', actual: '%s'", expErrors, actual)
+	}
+}
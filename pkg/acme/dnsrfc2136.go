@@ -0,0 +1,529 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acme
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dns message constants, see RFC 1035, RFC 2136 and RFC 8945
+const (
+	dnsOpcodeUpdate = 5
+	dnsTypeSOA      = 6
+	dnsTypeTXT      = 16
+	dnsTypeTSIG     = 250
+	dnsClassIN      = 1
+	dnsClassNONE    = 254
+	dnsClassANY     = 255
+	dnsTSIGFudge    = 300
+)
+
+var dnsRcodes = map[int]string{
+	1:  "FORMERR",
+	2:  "SERVFAIL",
+	3:  "NXDOMAIN",
+	4:  "NOTIMP",
+	5:  "REFUSED",
+	6:  "YXDOMAIN",
+	7:  "YXRRSET",
+	8:  "NXRRSET",
+	9:  "NOTAUTH",
+	10: "NOTZONE",
+}
+
+var tsigErrors = map[int]string{
+	16: "BADSIG",
+	17: "BADKEY",
+	18: "BADTIME",
+	22: "BADTRUNC",
+}
+
+var tsigAlgorithms = map[string]func() hash.Hash{
+	"hmac-sha1.":   sha1.New,
+	"hmac-sha256.": sha256.New,
+	"hmac-sha512.": sha512.New,
+}
+
+// rfc2136Provider adds and removes TXT records using dynamic
+// updates (RFC 2136), optionally signed with TSIG (RFC 8945).
+type rfc2136Provider struct {
+	nameserver         string
+	zone               string
+	keyName            string
+	secret             []byte
+	algorithm          string
+	ttl                uint32
+	timeout            time.Duration
+	propagationTimeout time.Duration
+	pollInterval       time.Duration
+	lookupNS           func(name string) ([]*net.NS, error)
+	now                func() time.Time
+}
+
+func newRFC2136Provider(config map[string][]byte) (*rfc2136Provider, error) {
+	p := &rfc2136Provider{
+		nameserver:         string(config["nameserver"]),
+		zone:               string(config["zone"]),
+		keyName:            string(config["tsig-key-name"]),
+		algorithm:          string(config["tsig-algorithm"]),
+		ttl:                60,
+		timeout:            10 * time.Second,
+		propagationTimeout: 2 * time.Minute,
+		pollInterval:       5 * time.Second,
+		lookupNS:           net.LookupNS,
+		now:                time.Now,
+	}
+	if p.nameserver == "" || p.zone == "" {
+		return nil, fmt.Errorf("rfc2136: nameserver and zone are mandatory")
+	}
+	if _, _, err := net.SplitHostPort(p.nameserver); err != nil {
+		p.nameserver = net.JoinHostPort(p.nameserver, "53")
+	}
+	if !strings.HasSuffix(p.zone, ".") {
+		p.zone += "."
+	}
+	if ttl := string(config["ttl"]); ttl != "" {
+		value, err := strconv.ParseUint(ttl, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("rfc2136: invalid ttl: %s", ttl)
+		}
+		p.ttl = uint32(value)
+	}
+	if timeout := string(config["propagation-timeout"]); timeout != "" {
+		value, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("rfc2136: invalid propagation timeout: %s", timeout)
+		}
+		p.propagationTimeout = value
+	}
+	if p.keyName != "" {
+		if !strings.HasSuffix(p.keyName, ".") {
+			p.keyName += "."
+		}
+		if p.algorithm == "" {
+			p.algorithm = "hmac-sha256."
+		} else if !strings.HasSuffix(p.algorithm, ".") {
+			p.algorithm += "."
+		}
+		if _, found := tsigAlgorithms[p.algorithm]; !found {
+			return nil, fmt.Errorf("rfc2136: unsupported tsig algorithm: %s", p.algorithm)
+		}
+		secret, err := base64.StdEncoding.DecodeString(string(config["tsig-secret"]))
+		if err != nil || len(secret) == 0 {
+			return nil, fmt.Errorf("rfc2136: missing or invalid base64 encoded tsig secret")
+		}
+		p.secret = secret
+	}
+	return p, nil
+}
+
+func (p *rfc2136Provider) Present(fqdn, value string) error {
+	return p.update(fqdn, value, false)
+}
+
+func (p *rfc2136Provider) CleanUp(fqdn, value string) error {
+	return p.update(fqdn, value, true)
+}
+
+// WaitPropagation waits until the TXT record can be read from all the
+// nameservers of the zone, so the acme server doesn't fail the challenge
+// reading a secondary nameserver that wasn't updated yet.
+func (p *rfc2136Provider) WaitPropagation(fqdn, value string) error {
+	if p.propagationTimeout <= 0 {
+		return nil
+	}
+	pending := p.zoneNameservers()
+	deadline := time.Now().Add(p.propagationTimeout)
+	for {
+		var missing []string
+		for _, nameserver := range pending {
+			if !p.hasTXT(nameserver, fqdn, value) {
+				missing = append(missing, nameserver)
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+		if time.Now().Add(p.pollInterval).After(deadline) {
+			return fmt.Errorf("rfc2136: timeout waiting the TXT record %s on nameserver(s): %s", fqdn, strings.Join(missing, ","))
+		}
+		pending = missing
+		time.Sleep(p.pollInterval)
+	}
+}
+
+// zoneNameservers returns the configured nameserver and the NS records of
+// the zone. The configured nameserver is used alone if the zone cannot be
+// read, e.g. internal zones not published in the default resolver.
+func (p *rfc2136Provider) zoneNameservers() []string {
+	nameservers := []string{p.nameserver}
+	nsList, _ := p.lookupNS(p.zone)
+	for _, ns := range nsList {
+		nameserver := net.JoinHostPort(strings.TrimSuffix(ns.Host, "."), "53")
+		if nameserver != p.nameserver {
+			nameservers = append(nameservers, nameserver)
+		}
+	}
+	return nameservers
+}
+
+func (p *rfc2136Provider) hasTXT(nameserver, fqdn, value string) bool {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			dialer := net.Dialer{Timeout: p.timeout}
+			return dialer.DialContext(ctx, network, nameserver)
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	txtList, err := resolver.LookupTXT(ctx, fqdn)
+	if err != nil {
+		return false
+	}
+	for _, txt := range txtList {
+		if txt == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *rfc2136Provider) update(fqdn, value string, remove bool) error {
+	var idBytes [2]byte
+	if _, err := rand.Read(idBytes[:]); err != nil {
+		return err
+	}
+	id := binary.BigEndian.Uint16(idBytes[:])
+	msg, err := p.buildUpdate(id, fqdn, value, remove)
+	if err != nil {
+		return err
+	}
+	var mac []byte
+	if p.keyName != "" {
+		msg, mac, err = p.sign(msg, id)
+		if err != nil {
+			return err
+		}
+	}
+	conn, err := net.DialTimeout("udp", p.nameserver, p.timeout)
+	if err != nil {
+		return fmt.Errorf("rfc2136: %v", err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(p.timeout)); err != nil {
+		return fmt.Errorf("rfc2136: %v", err)
+	}
+	if _, err := conn.Write(msg); err != nil {
+		return fmt.Errorf("rfc2136: %v", err)
+	}
+	res := make([]byte, 4096)
+	n, err := conn.Read(res)
+	if err != nil {
+		return fmt.Errorf("rfc2136: %v", err)
+	}
+	if err := readUpdateResponse(res[:n], id, fqdn); err != nil {
+		return err
+	}
+	if p.keyName != "" {
+		return p.verify(res[:n], mac, fqdn)
+	}
+	return nil
+}
+
+func (p *rfc2136Provider) buildUpdate(id uint16, fqdn, value string, remove bool) ([]byte, error) {
+	if len(value) > 255 {
+		return nil, fmt.Errorf("rfc2136: TXT value too long")
+	}
+	class, ttl := uint16(dnsClassIN), p.ttl
+	if remove {
+		// delete an RR from an RRset, RFC 2136 section 2.5.4
+		class, ttl = dnsClassNONE, 0
+	}
+	// header: id, opcode, zocount, prcount, upcount, adcount
+	msg := appendUint16(nil, id)
+	msg = appendUint16(msg, dnsOpcodeUpdate<<11)
+	msg = appendUint16(msg, 1)
+	msg = appendUint16(msg, 0)
+	msg = appendUint16(msg, 1)
+	msg = appendUint16(msg, 0)
+	// zone section
+	msg, err := appendName(msg, p.zone)
+	if err != nil {
+		return nil, err
+	}
+	msg = appendUint16(msg, dnsTypeSOA)
+	msg = appendUint16(msg, dnsClassIN)
+	// update section
+	msg, err = appendName(msg, fqdn)
+	if err != nil {
+		return nil, err
+	}
+	msg = appendUint16(msg, dnsTypeTXT)
+	msg = appendUint16(msg, class)
+	msg = appendUint32(msg, ttl)
+	msg = appendUint16(msg, uint16(len(value)+1))
+	msg = append(msg, byte(len(value)))
+	msg = append(msg, value...)
+	return msg, nil
+}
+
+// sign adds a TSIG record to msg, and also returns its MAC, which is used
+// to verify the signature of the response.
+func (p *rfc2136Provider) sign(msg []byte, id uint16) (signed, sum []byte, err error) {
+	keyName, err := appendName(nil, strings.ToLower(p.keyName))
+	if err != nil {
+		return nil, nil, err
+	}
+	algorithm, _ := appendName(nil, p.algorithm)
+	timeSigned := uint64(p.now().Unix())
+	mac := hmac.New(tsigAlgorithms[p.algorithm], p.secret)
+	mac.Write(msg)
+	mac.Write(p.tsigVariables(timeSigned, dnsTSIGFudge, 0, nil))
+	sum = mac.Sum(nil)
+
+	rdata := append([]byte{}, algorithm...)
+	rdata = appendUint48(rdata, timeSigned)
+	rdata = appendUint16(rdata, dnsTSIGFudge)
+	rdata = appendUint16(rdata, uint16(len(sum)))
+	rdata = append(rdata, sum...)
+	rdata = appendUint16(rdata, id)
+	rdata = appendUint16(rdata, 0) // error
+	rdata = appendUint16(rdata, 0) // other len
+
+	signed = append([]byte{}, msg...)
+	signed = append(signed, keyName...)
+	signed = appendUint16(signed, dnsTypeTSIG)
+	signed = appendUint16(signed, dnsClassANY)
+	signed = appendUint32(signed, 0)
+	signed = appendUint16(signed, uint16(len(rdata)))
+	signed = append(signed, rdata...)
+	// one more record in the additional section
+	binary.BigEndian.PutUint16(signed[10:], binary.BigEndian.Uint16(signed[10:])+1)
+	return signed, sum, nil
+}
+
+// verify checks the TSIG record of a response, whose MAC also covers the
+// MAC of the request, see RFC 8945 section 5.3.
+func (p *rfc2136Provider) verify(res, reqMAC []byte, fqdn string) error {
+	tsig, err := readTSIG(res)
+	if err != nil {
+		return fmt.Errorf("rfc2136: %v updating %s", err, fqdn)
+	}
+	if !strings.EqualFold(tsig.keyName, p.keyName) || !strings.EqualFold(tsig.algorithm, p.algorithm) {
+		return fmt.Errorf("rfc2136: tsig key of the response differs updating %s", fqdn)
+	}
+	if tsig.err != 0 {
+		name, found := tsigErrors[tsig.err]
+		if !found {
+			name = strconv.Itoa(tsig.err)
+		}
+		return fmt.Errorf("rfc2136: tsig error updating %s: %s", fqdn, name)
+	}
+	// the response without its TSIG record and with the original id
+	unsigned := append([]byte{}, res[:tsig.start]...)
+	binary.BigEndian.PutUint16(unsigned, tsig.originalID)
+	binary.BigEndian.PutUint16(unsigned[10:], binary.BigEndian.Uint16(unsigned[10:])-1)
+	mac := hmac.New(tsigAlgorithms[p.algorithm], p.secret)
+	mac.Write(appendUint16(nil, uint16(len(reqMAC))))
+	mac.Write(reqMAC)
+	mac.Write(unsigned)
+	mac.Write(p.tsigVariables(tsig.timeSigned, tsig.fudge, uint16(tsig.err), tsig.otherData))
+	if !hmac.Equal(mac.Sum(nil), tsig.mac) {
+		return fmt.Errorf("rfc2136: invalid tsig signature of the response updating %s", fqdn)
+	}
+	now := uint64(p.now().Unix())
+	if now > tsig.timeSigned+uint64(tsig.fudge) || tsig.timeSigned > now+uint64(tsig.fudge) {
+		return fmt.Errorf("rfc2136: tsig time of the response out of the allowed window updating %s", fqdn)
+	}
+	return nil
+}
+
+// tsigVariables builds the TSIG variables, RFC 8945 section 4.3.3.
+func (p *rfc2136Provider) tsigVariables(timeSigned uint64, fudge, tsigErr uint16, otherData []byte) []byte {
+	keyName, _ := appendName(nil, strings.ToLower(p.keyName))
+	algorithm, _ := appendName(nil, p.algorithm)
+	vars := append([]byte{}, keyName...)
+	vars = appendUint16(vars, dnsClassANY)
+	vars = appendUint32(vars, 0)
+	vars = append(vars, algorithm...)
+	vars = appendUint48(vars, timeSigned)
+	vars = appendUint16(vars, fudge)
+	vars = appendUint16(vars, tsigErr)
+	vars = appendUint16(vars, uint16(len(otherData)))
+	return append(vars, otherData...)
+}
+
+func readUpdateResponse(res []byte, id uint16, fqdn string) error {
+	if len(res) < 12 || binary.BigEndian.Uint16(res) != id || res[2]&0x80 == 0 {
+		return fmt.Errorf("rfc2136: invalid response updating %s", fqdn)
+	}
+	if rcode := int(res[3] & 0x0f); rcode != 0 {
+		name, found := dnsRcodes[rcode]
+		if !found {
+			name = strconv.Itoa(rcode)
+		}
+		return fmt.Errorf("rfc2136: error updating %s: %s", fqdn, name)
+	}
+	return nil
+}
+
+type tsigRecord struct {
+	start      int
+	keyName    string
+	algorithm  string
+	timeSigned uint64
+	fudge      uint16
+	mac        []byte
+	originalID uint16
+	err        int
+	otherData  []byte
+}
+
+var errResponseInvalid = fmt.Errorf("invalid response")
+
+// readTSIG reads the TSIG record of a message, which should be the last
+// record of its additional section.
+func readTSIG(msg []byte) (*tsigRecord, error) {
+	if len(msg) < 12 {
+		return nil, errResponseInvalid
+	}
+	count := func(pos int) int {
+		return int(binary.BigEndian.Uint16(msg[pos:]))
+	}
+	pos := 12
+	var err error
+	for i := 0; i < count(4); i++ {
+		if _, pos, err = readName(msg, pos); err != nil {
+			return nil, err
+		}
+		pos += 4
+	}
+	start, rrType, rdata := -1, 0, 0
+	for i := 0; i < count(6)+count(8)+count(10); i++ {
+		start = pos
+		if _, pos, err = readName(msg, pos); err != nil {
+			return nil, err
+		}
+		if pos+10 > len(msg) {
+			return nil, errResponseInvalid
+		}
+		rrType, rdata = count(pos), pos+10
+		pos = rdata + count(pos+8)
+	}
+	if pos > len(msg) {
+		return nil, errResponseInvalid
+	}
+	if count(10) == 0 || rrType != dnsTypeTSIG {
+		return nil, fmt.Errorf("unsigned response")
+	}
+	tsig := &tsigRecord{start: start}
+	tsig.keyName, _, _ = readName(msg, start)
+	if tsig.algorithm, pos, err = readName(msg, rdata); err != nil {
+		return nil, err
+	}
+	if pos+10 > len(msg) {
+		return nil, errResponseInvalid
+	}
+	tsig.timeSigned = uint64(count(pos))<<32 | uint64(binary.BigEndian.Uint32(msg[pos+2:]))
+	tsig.fudge = uint16(count(pos + 6))
+	macEnd := pos + 10 + count(pos+8)
+	if macEnd+6 > len(msg) {
+		return nil, errResponseInvalid
+	}
+	tsig.mac = msg[pos+10 : macEnd]
+	tsig.originalID = uint16(count(macEnd))
+	tsig.err = count(macEnd + 2)
+	otherEnd := macEnd + 6 + count(macEnd+4)
+	if otherEnd > len(msg) {
+		return nil, errResponseInvalid
+	}
+	tsig.otherData = msg[macEnd+6 : otherEnd]
+	return tsig, nil
+}
+
+// readName reads a possibly compressed domain name, and returns it and
+// the position of the message just after the name.
+func readName(msg []byte, pos int) (name string, next int, err error) {
+	var labels []string
+	next = -1
+	for jumps := 0; pos < len(msg); {
+		l := int(msg[pos])
+		switch {
+		case l == 0:
+			if next < 0 {
+				next = pos + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		case l&0xc0 == 0xc0:
+			if pos+2 > len(msg) || jumps > 16 {
+				return "", 0, errResponseInvalid
+			}
+			if next < 0 {
+				next = pos + 2
+			}
+			pos = int(binary.BigEndian.Uint16(msg[pos:]) & 0x3fff)
+			jumps++
+		default:
+			if pos+1+l > len(msg) {
+				return "", 0, errResponseInvalid
+			}
+			labels = append(labels, string(msg[pos+1:pos+1+l]))
+			pos += 1 + l
+		}
+	}
+	return "", 0, errResponseInvalid
+}
+
+func appendName(b []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if len(label) == 0 || len(label) > 63 {
+				return nil, fmt.Errorf("rfc2136: invalid domain name: %s", name)
+			}
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+	return append(b, 0), nil
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint48(b []byte, v uint64) []byte {
+	return append(b, byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acme
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// webhookProvider delegates the TXT record management to an external
+// service, which receives a POST request with a json payload.
+type webhookProvider struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+type webhookRequest struct {
+	Action string `json:"action"`
+	FQDN   string `json:"fqdn"`
+	Value  string `json:"value"`
+}
+
+func newWebhookProvider(config map[string][]byte) (*webhookProvider, error) {
+	url := string(config["url"])
+	if url == "" {
+		return nil, fmt.Errorf("webhook: url is mandatory")
+	}
+	return &webhookProvider{
+		url:    url,
+		token:  string(config["token"]),
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (p *webhookProvider) Present(fqdn, value string) error {
+	return p.call("present", fqdn, value)
+}
+
+func (p *webhookProvider) CleanUp(fqdn, value string) error {
+	return p.call("cleanup", fqdn, value)
+}
+
+func (p *webhookProvider) call(action, fqdn, value string) error {
+	body, err := json.Marshal(&webhookRequest{
+		Action: action,
+		FQDN:   fqdn,
+		Value:  value,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", acmeUserAgent)
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 256))
+		return fmt.Errorf("webhook: %s of %s failed: %s %s", action, fqdn, res.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
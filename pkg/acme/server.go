@@ -17,22 +17,23 @@ limitations under the License.
 package acme
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
-	"os"
-	"os/user"
-	"strconv"
+	"time"
 
+	hautils "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/utils"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 )
 
 // NewServer ...
-func NewServer(logger types.Logger, socket string, resolver ServerResolver) Server {
+func NewServer(logger types.Logger, socket, tlsSocket string, resolver ServerResolver) Server {
 	return &server{
-		logger:   logger,
-		socket:   socket,
-		resolver: resolver,
+		logger:    logger,
+		socket:    socket,
+		tlsSocket: tlsSocket,
+		resolver:  resolver,
 	}
 }
 
@@ -47,10 +48,12 @@ type Server interface {
 }
 
 type server struct {
-	logger   types.Logger
-	resolver ServerResolver
-	server   *http.Server
-	socket   string
+	logger      types.Logger
+	resolver    ServerResolver
+	server      *http.Server
+	socket      string
+	tlsListener net.Listener
+	tlsSocket   string
 }
 
 func (s *server) Listen(stopCh chan struct{}) error {
@@ -62,31 +65,27 @@ func (s *server) Listen(stopCh chan struct{}) error {
 		if token == "" {
 			w.WriteHeader(http.StatusNotFound)
 			fmt.Fprintf(w, "404 not found\n")
-			s.logger.Warn("acme: url token not found: domain=%s uri=%s", host, uri)
+			s.logger.Warn("acme: url token not found: domain=%s uri=%s", types.LogHost(host), uri)
 			return
 		}
 		fmt.Fprintf(w, token)
-		s.logger.Info("acme: request token: domain=%s uri=%s", host, uri)
+		s.logger.Info("acme: request token: domain=%s uri=%s", types.LogHost(host), uri)
 	})
 	s.server = &http.Server{Addr: s.socket, Handler: handler}
-	l, err := net.Listen("unix", s.server.Addr)
+	l, err := hautils.ListenUnix(s.socket)
 	if err != nil {
 		return err
 	}
-	if user, err := user.Lookup("haproxy"); err == nil {
-		uid, e1 := strconv.Atoi(user.Uid)
-		gid, e2 := strconv.Atoi(user.Gid)
-		if e1 == nil && e2 == nil {
-			if err := os.Chown(s.socket, uid, gid); err != nil {
-				return err
-			}
-			if err := os.Chmod(s.socket, 0600); err != nil {
-				return err
-			}
-		}
-	}
 	s.logger.Info("acme: listening on unix socket: %s", s.socket)
 	go s.server.Serve(l)
+	if s.tlsSocket != "" {
+		s.tlsListener, err = hautils.ListenUnix(s.tlsSocket)
+		if err != nil {
+			return err
+		}
+		s.logger.Info("acme: listening tls-alpn-01 challenges on unix socket: %s", s.tlsSocket)
+		go s.serveTLSALPN()
+	}
 	go func() {
 		<-stopCh
 		if s.server == nil {
@@ -96,6 +95,41 @@ func (s *server) Listen(stopCh chan struct{}) error {
 		if err := s.server.Close(); err != nil {
 			s.logger.Error("acme: error closing socket: %v", err)
 		}
+		if s.tlsListener != nil {
+			if err := s.tlsListener.Close(); err != nil {
+				s.logger.Error("acme: error closing tls-alpn-01 socket: %v", err)
+			}
+		}
 	}()
 	return nil
 }
+
+// serveTLSALPN answers the acme-tls/1 handshakes with a challenge certificate
+// built from the key authorization shared by the client. Connections are
+// closed just after the handshake, as required by RFC 8737.
+func (s *server) serveTLSALPN() {
+	config := &tls.Config{
+		NextProtos: []string{acmeTLSALPNProto},
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			keyAuth := s.resolver.GetToken(hello.ServerName, acmeTLSALPNProto)
+			if keyAuth == "" {
+				s.logger.Warn("acme: tls-alpn-01 token not found: domain=%s", types.LogHost(hello.ServerName))
+				return nil, fmt.Errorf("token not found")
+			}
+			s.logger.Info("acme: request tls-alpn-01 token: domain=%s", types.LogHost(hello.ServerName))
+			return tlsALPN01ChallengeCert(hello.ServerName, keyAuth)
+		},
+	}
+	for {
+		conn, err := s.tlsListener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			tlsConn := tls.Server(conn, config)
+			_ = tlsConn.SetDeadline(time.Now().Add(10 * time.Second))
+			_ = tlsConn.Handshake()
+			_ = tlsConn.Close()
+		}()
+	}
+}
@@ -17,10 +17,14 @@ limitations under the License.
 package acme
 
 import (
-	"crypto/rsa"
+	"crypto"
+	"crypto/tls"
 	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"math/big"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 
@@ -38,58 +42,104 @@ func NewSigner(logger types.Logger, cache Cache, metrics types.Metrics) Signer {
 
 // Signer ...
 type Signer interface {
-	AcmeAccount(endpoint, emails string, termsAgreed bool)
-	AcmeConfig(expiring time.Duration)
+	AcmeAccount(account Account)
+	AcmeConfig(expiring time.Duration, expiringRatio float64, preferredChain string)
+	AcmeScheduler(scheduler Scheduler, checkPeriod time.Duration)
 	HasAccount() bool
 	Notify(item interface{}) error
+	// SignedSecrets returns the name of the secrets whose certificates
+	// were issued by the controller, excluding the dual ones.
+	SignedSecrets() []string
 }
 
 // Cache ...
 type Cache interface {
 	ClientResolver
+	DNSResolver
 	ServerResolver
 	SignerResolver
 }
 
+// Scheduler ...
+type Scheduler interface {
+	AddAfter(item interface{}, duration time.Duration)
+}
+
 // SignerResolver ...
 type SignerResolver interface {
 	GetTLSSecretContent(secretName string) *TLSSecret
 	SetTLSSecretContent(secretName string, pemCrt, pemKey []byte) error
+	DeleteTLSSecret(secretName string) error
+	LabelTLSSecret(secretName string, labels map[string]string) error
+	// GetSignedTLSSecrets returns the name of the secrets labeled with
+	// SignedLabel and not labeled with OrphanLabel.
+	GetSignedTLSSecrets() []string
+	// IsTLSSecretReferenced returns true if an ingress resource references
+	// secretName in its tls entries, despite its ingress class or the acme
+	// configuration.
+	IsTLSSecretReferenced(secretName string) bool
+	// RecordSecretEvent adds an event to the ingress resources that
+	// reference secretName.
+	RecordSecretEvent(secretName string, warning bool, reason, message string)
 }
 
 // TLSSecret ...
 type TLSSecret struct {
 	Crt *x509.Certificate
-	Key *rsa.PrivateKey
+	Key crypto.Signer
 }
 
+// DualSecretSuffix is appended to the secret name of a certificate to
+// build the secret name of its dual key type certificate.
+const DualSecretSuffix = "-dual"
+
+// Actions of an orphan certificate, see AcmeData.OrphanActions
+const (
+	OrphanActionDelete = "delete"
+	OrphanActionLabel  = "label"
+	OrphanActionRevoke = "revoke"
+)
+
+// OrphanLabel is added by the label orphan action to the secret of
+// a certificate which isn't used anymore.
+const OrphanLabel = "haproxy-ingress.github.io/acme-orphan"
+
+// SignedLabel is added to the secrets whose certificates were issued by
+// the controller, so the orphan actions can be applied to the secrets
+// removed while the controller wasn't running or wasn't the leader.
+const SignedLabel = "haproxy-ingress.github.io/acme-signed"
+
+// minCheckInterval is the shortest time between two checks of the same
+// certificate, so a misconfigured renewal window doesn't flood the acme
+// server with new orders.
+const minCheckInterval = time.Hour
+
 type signer struct {
-	logger      types.Logger
-	cache       Cache
-	metrics     types.Metrics
-	account     Account
-	client      Client
-	expiring    time.Duration
-	verifyCount int
+	logger         types.Logger
+	cache          Cache
+	metrics        types.Metrics
+	account        Account
+	client         Client
+	expiring       time.Duration
+	expiringRatio  float64
+	preferredChain string
+	scheduler      Scheduler
+	checkPeriod    time.Duration
+	verifyCount    int
 }
 
-func (s *signer) AcmeAccount(endpoint, emails string, termsAgreed bool) {
-	switch endpoint {
+func (s *signer) AcmeAccount(account Account) {
+	switch account.Endpoint {
 	case "v2", "v02":
-		endpoint = "https://acme-v02.api.letsencrypt.org"
+		account.Endpoint = "https://acme-v02.api.letsencrypt.org"
 	case "v2-staging", "v02-staging":
-		endpoint = "https://acme-staging-v02.api.letsencrypt.org"
-	}
-	account := Account{
-		Endpoint:    endpoint,
-		Emails:      emails,
-		TermsAgreed: termsAgreed,
+		account.Endpoint = "https://acme-staging-v02.api.letsencrypt.org"
 	}
 	if reflect.DeepEqual(s.account, account) {
 		return
 	}
 	s.client = nil
-	if endpoint == "" && emails == "" && !termsAgreed {
+	if account.Endpoint == "" && account.Emails == "" && !account.TermsAgreed {
 		return
 	}
 	s.logger.Info("loading account %+v", account)
@@ -102,74 +152,339 @@ func (s *signer) AcmeAccount(endpoint, emails string, termsAgreed bool) {
 	s.client = client
 }
 
-func (s *signer) AcmeConfig(expiring time.Duration) {
+func (s *signer) AcmeConfig(expiring time.Duration, expiringRatio float64, preferredChain string) {
 	s.expiring = expiring
+	s.expiringRatio = expiringRatio
+	s.preferredChain = preferredChain
+}
+
+func (s *signer) AcmeScheduler(scheduler Scheduler, checkPeriod time.Duration) {
+	s.scheduler = scheduler
+	s.checkPeriod = checkPeriod
 }
 
 func (s *signer) HasAccount() bool {
 	return s.client != nil
 }
 
+func (s *signer) SignedSecrets() []string {
+	names := s.cache.GetSignedTLSSecrets()
+	signed := make(map[string]bool, len(names))
+	for _, name := range names {
+		signed[name] = true
+	}
+	secrets := make([]string, 0, len(names))
+	for _, name := range names {
+		if strings.HasSuffix(name, DualSecretSuffix) && signed[strings.TrimSuffix(name, DualSecretSuffix)] {
+			// cleaned up along with its main secret
+			continue
+		}
+		secrets = append(secrets, name)
+	}
+	sort.Strings(secrets)
+	return secrets
+}
+
 func (s *signer) Notify(item interface{}) error {
 	if !s.HasAccount() {
 		return fmt.Errorf("acme: account was not properly initialized")
 	}
+	// item is `<secret>,[<key>=<value>,...]<domain>[,<domain>...]`
+	// key=value tokens configure the challenge, see AcmeCerts
+	// orphan certificates have only `orphan` and `since` tokens
 	cert := strings.Split(item.(string), ",")
 	secretName := cert[0]
-	domains := cert[1:]
-	err := s.verify(secretName, domains)
+	var domains, orphanActions []string
+	var challenge, provider, keyType, dualKeyType string
+	for _, token := range cert[1:] {
+		if kv := strings.SplitN(token, "=", 2); len(kv) == 2 {
+			switch kv[0] {
+			case "orphan":
+				orphanActions = append(orphanActions, kv[1])
+			case "challenge":
+				challenge = kv[1]
+			case "provider":
+				provider = kv[1]
+			case "keytype":
+				keyType = kv[1]
+			case "dualkeytype":
+				dualKeyType = kv[1]
+			}
+		} else {
+			domains = append(domains, token)
+		}
+	}
+	if len(orphanActions) > 0 {
+		// the acme config might be removed from an ingress which still
+		// serves the certificate, eg removing the cert-signer annotation
+		if s.cache.IsTLSSecretReferenced(secretName) {
+			s.logger.Info("acme: canceling orphan actions of secret %s, it is still referenced by an ingress", types.LogSecret(secretName))
+			return nil
+		}
+		return s.cleanup(secretName, orphanActions)
+	}
+	opts := SignOptions{Challenge: challenge, KeyType: keyType, PreferredChain: s.preferredChain}
+	switch challenge {
+	case "", acmeChallengeHTTP01, acmeChallengeTLSALPN01:
+	case acmeChallengeDNS01:
+		dnsProvider, err := NewDNSProvider(s.cache, provider)
+		if err != nil {
+			s.logger.Warn("acme: error reading dns provider of secret %s: %v", types.LogSecret(secretName), err)
+			return err
+		}
+		opts.DNSProvider = dnsProvider
+	default:
+		return fmt.Errorf("acme: unsupported challenge type of secret %s: %s", secretName, challenge)
+	}
+	nextCheck, err := s.verify(secretName, false, domains, opts)
+	if dualKeyType != "" {
+		// the dual certificate has its own order and secret
+		opts.KeyType = dualKeyType
+		nextCheckDual, errDual := s.verify(secretName, true, domains, opts)
+		if err == nil {
+			err = errDual
+		}
+		if !nextCheckDual.IsZero() && (nextCheck.IsZero() || nextCheckDual.Before(nextCheck)) {
+			nextCheck = nextCheckDual
+		}
+	}
+	if err == nil {
+		// failures are retried by the queue
+		s.schedule(item, secretName, nextCheck)
+	}
 	return err
 }
 
-func (s *signer) verify(secretName string, domains []string) (verifyErr error) {
-	duedate := time.Now().Add(s.expiring)
+// verify issues a new certificate if needed and returns when the
+// certificate should be checked again, or zero if unknown. The dual
+// certificate is stored in its own secret, events are always added
+// to the ingress resources that reference secretName.
+func (s *signer) verify(ingSecretName string, dual bool, domains []string, opts SignOptions) (nextCheck time.Time, verifyErr error) {
+	now := time.Now()
+	secretName := ingSecretName
+	if dual {
+		secretName += DualSecretSuffix
+	}
 	tls := s.cache.GetTLSSecretContent(secretName)
 	strdomains := strings.Join(domains, ",")
-	if tls == nil || tls.Crt.NotAfter.Before(duedate) || !match(domains, tls.Crt.DNSNames) {
+	keyType := opts.KeyType
+	if keyType == "" {
+		keyType = KeyTypeRSA2048
+	}
+	var renewAt, retryAfter time.Time
+	var ari bool
+	if tls != nil {
+		renewAt = s.renewalTime(tls.Crt)
+		if ariRenewAt, ariRetryAfter, found := s.ariRenewalTime(secretName, tls.Crt); found {
+			renewAt, retryAfter, ari = ariRenewAt, ariRetryAfter, true
+		}
+	}
+	if tls == nil || renewAt.Before(now) || !match(domains, tls.Crt.DNSNames) || KeyType(tls.Key) != keyType {
 		var collector func(domains string, success bool)
 		var reason string
 		if tls == nil {
 			collector = s.metrics.IncCertSigningMissing
 			reason = "certificate does not exist"
-		} else if tls.Crt.NotAfter.Before(duedate) {
+		} else if renewAt.Before(now) && ari {
+			collector = s.metrics.IncCertSigningExpiring
+			reason = fmt.Sprintf("acme server suggests renewal since %s", renewAt.String())
+		} else if renewAt.Before(now) {
 			collector = s.metrics.IncCertSigningExpiring
 			reason = fmt.Sprintf("certificate expires in %s", tls.Crt.NotAfter.String())
-		} else {
+		} else if !match(domains, tls.Crt.DNSNames) {
 			collector = s.metrics.IncCertSigningOutdated
 			reason = "added one or more domains to an existing certificate"
+		} else {
+			collector = s.metrics.IncCertSigningOutdated
+			reason = fmt.Sprintf("key type changed from %s to %s", KeyType(tls.Key), keyType)
 		}
 		s.verifyCount++
 		s.logger.Info("acme: authorizing: id=%d secret=%s domain(s)=%s endpoint=%s reason='%s'",
-			s.verifyCount, secretName, strdomains, s.account.Endpoint, reason)
-		crt, key, err := s.client.Sign(domains)
+			s.verifyCount, types.LogSecret(secretName), strdomains, s.account.Endpoint, reason)
+		crt, key, err := s.client.Sign(domains, opts)
+		if err == nil {
+			// an invalid chain is never stored, so a working
+			// certificate isn't overwritten by a broken one
+			if errChain := validateChain(crt, key, domains); errChain != nil {
+				err = fmt.Errorf("invalid certificate chain: %v", errChain)
+			}
+		}
 		if err == nil {
 			if errTLS := s.cache.SetTLSSecretContent(secretName, crt, key); errTLS == nil {
 				s.logger.Info("acme: new certificate issued: id=%d secret=%s domain(s)=%s",
-					s.verifyCount, secretName, strdomains)
+					s.verifyCount, types.LogSecret(secretName), strdomains)
+				s.cache.RecordSecretEvent(ingSecretName, false, "AcmeCertificateIssued",
+					fmt.Sprintf("new certificate issued: secret=%s domain(s)=%s reason='%s'", secretName, strdomains, reason))
+				if newCrt := parseLeaf(crt); newCrt != nil {
+					nextCheck = s.renewalTime(newCrt)
+				}
 			} else {
 				s.logger.Warn("acme: error storing new certificate: id=%d secret=%s domain(s)=%s error=%v",
-					s.verifyCount, secretName, strdomains, errTLS)
+					s.verifyCount, types.LogSecret(secretName), strdomains, errTLS)
+				s.cache.RecordSecretEvent(ingSecretName, true, "AcmeOrderFailed",
+					fmt.Sprintf("error storing new certificate: secret=%s domain(s)=%s error=%v", secretName, strdomains, errTLS))
 				verifyErr = errTLS
 			}
 		} else {
 			s.logger.Warn("acme: error signing new certificate: id=%d secret=%s domain(s)=%s error=%v",
-				s.verifyCount, secretName, strdomains, err)
+				s.verifyCount, types.LogSecret(secretName), strdomains, err)
+			s.cache.RecordSecretEvent(ingSecretName, true, "AcmeOrderFailed",
+				fmt.Sprintf("error signing new certificate: secret=%s domain(s)=%s error=%v", secretName, strdomains, err))
 			verifyErr = err
 		}
 		collector(strdomains, verifyErr == nil)
 	} else {
-		s.logger.InfoV(2, "acme: skipping sign, certificate is updated: secret=%s domain(s)=%s", secretName, strdomains)
+		s.logger.InfoV(2, "acme: skipping sign, certificate is updated: secret=%s domain(s)=%s", types.LogSecret(secretName), strdomains)
+		nextCheck = renewAt
+		if !retryAfter.IsZero() && retryAfter.Before(nextCheck) {
+			nextCheck = retryAfter
+		}
+	}
+	return nextCheck, verifyErr
+}
+
+// cleanup applies the orphan actions to the certificates of a secret that
+// isn't used anymore, including its dual certificate if it exists.
+func (s *signer) cleanup(secretName string, actions []string) error {
+	has := func(action string) bool {
+		for _, a := range actions {
+			if a == action {
+				return true
+			}
+		}
+		return false
+	}
+	for _, name := range []string{secretName, secretName + DualSecretSuffix} {
+		tls := s.cache.GetTLSSecretContent(name)
+		if tls == nil {
+			continue
+		}
+		if has(OrphanActionRevoke) && time.Now().Before(tls.Crt.NotAfter) {
+			if err := s.client.Revoke(tls.Crt); err != nil {
+				s.logger.Warn("acme: error revoking orphan certificate: secret=%s error=%v", types.LogSecret(name), err)
+				return err
+			}
+			s.logger.Info("acme: orphan certificate revoked: secret=%s serial=%s", types.LogSecret(name), tls.Crt.SerialNumber.Text(16))
+		}
+		if has(OrphanActionDelete) {
+			if err := s.cache.DeleteTLSSecret(name); err != nil {
+				s.logger.Warn("acme: error deleting orphan secret: secret=%s error=%v", types.LogSecret(name), err)
+				return err
+			}
+			s.logger.Info("acme: orphan secret deleted: secret=%s", types.LogSecret(name))
+		} else if has(OrphanActionLabel) {
+			if err := s.cache.LabelTLSSecret(name, map[string]string{OrphanLabel: "true"}); err != nil {
+				s.logger.Warn("acme: error labeling orphan secret: secret=%s error=%v", types.LogSecret(name), err)
+				return err
+			}
+			s.logger.Info("acme: orphan secret labeled: secret=%s", types.LogSecret(name))
+		}
+	}
+	return nil
+}
+
+// renewalTime returns the time crt should be renewed: expiring before
+// its expiration, or the expiringRatio of its lifetime, whichever is
+// shorter, so short lived certificates are also properly renewed.
+func (s *signer) renewalTime(crt *x509.Certificate) time.Time {
+	expiring := s.expiring
+	if s.expiringRatio > 0 {
+		lifetime := crt.NotAfter.Sub(crt.NotBefore)
+		if window := time.Duration(float64(lifetime) * s.expiringRatio); window < expiring {
+			expiring = window
+		}
+	}
+	return crt.NotAfter.Add(-expiring)
+}
+
+// ariRenewalTime reads the renewal window the acme server suggests to crt.
+// found is false if the server does not support ARI or the request failed.
+func (s *signer) ariRenewalTime(secretName string, crt *x509.Certificate) (renewAt, retryAfter time.Time, found bool) {
+	window, err := s.client.RenewalWindow(crt)
+	if err != nil {
+		s.logger.Warn("acme: error reading renewal info of secret %s, using the certificate lifetime: %v", types.LogSecret(secretName), err)
+		return renewAt, retryAfter, false
+	}
+	if window == nil {
+		return renewAt, retryAfter, false
+	}
+	// a stable point in the window, derived from the serial number,
+	// spreads the renewal of the certificates along the window
+	offset := new(big.Int).Mod(crt.SerialNumber, big.NewInt(1000)).Int64()
+	renewAt = window.Start.Add(window.End.Sub(window.Start) / 1000 * time.Duration(offset))
+	return renewAt, window.RetryAfter, true
+}
+
+// schedule adds item back to the queue so it is checked again at nextCheck.
+// Nothing is scheduled if nextCheck is zero or not sooner than checkPeriod,
+// the periodic check of all the certificates already covers it.
+func (s *signer) schedule(item interface{}, secretName string, nextCheck time.Time) {
+	if s.scheduler == nil || nextCheck.IsZero() {
+		return
+	}
+	delay := time.Until(nextCheck)
+	if delay < minCheckInterval {
+		delay = minCheckInterval
+	}
+	if s.checkPeriod > 0 && delay >= s.checkPeriod {
+		return
+	}
+	s.logger.InfoV(2, "acme: next check of secret %s in %s", types.LogSecret(secretName), delay.Round(time.Minute))
+	s.scheduler.AddAfter(item, delay)
+}
+
+// parseLeaf returns the first certificate of a PEM encoded chain,
+// nil if it cannot be parsed.
+func parseLeaf(pemCrt []byte) *x509.Certificate {
+	block, _ := pem.Decode(pemCrt)
+	if block == nil {
+		return nil
+	}
+	crt, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil
+	}
+	return crt
+}
+
+// validateChain checks that a PEM encoded chain starts with a leaf
+// certificate which matches the private key and covers all the domains,
+// and that every certificate is signed by the next one.
+func validateChain(pemCrt, pemKey []byte, domains []string) error {
+	keyPair, err := tls.X509KeyPair(pemCrt, pemKey)
+	if err != nil {
+		return err
+	}
+	chain := make([]*x509.Certificate, len(keyPair.Certificate))
+	for i, der := range keyPair.Certificate {
+		if chain[i], err = x509.ParseCertificate(der); err != nil {
+			return err
+		}
+	}
+	leaf := chain[0]
+	if time.Now().After(leaf.NotAfter) {
+		return fmt.Errorf("certificate expired on %s", leaf.NotAfter.String())
+	}
+	if !match(domains, leaf.DNSNames) {
+		return fmt.Errorf("certificate does not cover all the domains, found: %s", strings.Join(leaf.DNSNames, ","))
 	}
-	return verifyErr
+	for i := 1; i < len(chain); i++ {
+		if err := chain[i-1].CheckSignatureFrom(chain[i]); err != nil {
+			return fmt.Errorf("certificate '%s' is not signed by '%s': %v",
+				chain[i-1].Subject.CommonName, chain[i].Subject.CommonName, err)
+		}
+	}
+	return nil
 }
 
 // match return true if all hosts in hostnames (desired configuration)
-// are already in dnsnames (current certificate).
+// are already in dnsnames (current certificate), either literally or
+// covered by a wildcard dnsname.
 func match(domains, dnsnames []string) bool {
 	for _, domain := range domains {
 		found := false
 		for _, dns := range dnsnames {
-			if domain == dns {
+			if domain == dns || wildcardCovers(dns, domain) {
 				found = true
 			}
 		}
@@ -179,3 +494,13 @@ func match(domains, dnsnames []string) bool {
 	}
 	return true
 }
+
+// wildcardCovers returns true if wildcard is a `*.<domain>` dnsname that
+// matches domain. A wildcard covers only one label.
+func wildcardCovers(wildcard, domain string) bool {
+	if !strings.HasPrefix(wildcard, "*.") || strings.HasPrefix(domain, "*.") {
+		return false
+	}
+	dot := strings.Index(domain, ".")
+	return dot > 0 && domain[dot:] == wildcard[1:]
+}
@@ -18,8 +18,17 @@ package acme
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
 	"testing"
 	"time"
 
@@ -65,12 +74,46 @@ INFO acme: new certificate issued: id=1 secret=s1 domain(s)=d3.local`,
 INFO acme: authorizing: id=1 secret=s2 domain(s)=d1.local endpoint=https://acme-v2.local reason='certificate does not exist'
 INFO acme: new certificate issued: id=1 secret=s2 domain(s)=d1.local`,
 		},
+		// 4
+		{
+			input:     "s2,challenge=dns-01,provider=webhook:default/dns,d1.local",
+			expiresIn: 10 * 24 * time.Hour,
+			logging: `
+INFO acme: authorizing: id=1 secret=s2 domain(s)=d1.local endpoint=https://acme-v2.local reason='certificate does not exist'
+INFO acme: new certificate issued: id=1 secret=s2 domain(s)=d1.local`,
+		},
+		// 5
+		{
+			input:     "s1,keytype=ec256,d1.local",
+			expiresIn: 10 * 24 * time.Hour,
+			logging: `
+INFO acme: authorizing: id=1 secret=s1 domain(s)=d1.local endpoint=https://acme-v2.local reason='key type changed from rsa2048 to ec256'
+INFO acme: new certificate issued: id=1 secret=s1 domain(s)=d1.local`,
+		},
+		// 6
+		{
+			input:     "s1,keytype=rsa2048,dualkeytype=ec256,d1.local",
+			expiresIn: 10 * 24 * time.Hour,
+			logging: `
+INFO-V(2) acme: skipping sign, certificate is updated: secret=s1 domain(s)=d1.local
+INFO acme: authorizing: id=1 secret=s1-dual domain(s)=d1.local endpoint=https://acme-v2.local reason='certificate does not exist'
+INFO acme: new certificate issued: id=1 secret=s1-dual domain(s)=d1.local`,
+		},
+		// 7
+		{
+			input:     "s2,challenge=dns-01,provider=webhook:default/notfound,d1.local",
+			expiresIn: 10 * 24 * time.Hour,
+			logging: `
+WARN acme: error reading dns provider of secret s2: secret not found: 'default/notfound'`,
+		},
 	}
 	c := setup(t)
 	defer c.teardown()
 	crt, _ := base64.StdEncoding.DecodeString(dumbcrt)
 	x509, _ := x509.ParseCertificate(crt)
-	c.cache.tlsSecret["s1"] = &TLSSecret{Crt: x509}
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	c.cache.tlsSecret["s1"] = &TLSSecret{Crt: x509, Key: key}
+	c.cache.dnsProvider["default/dns"] = map[string][]byte{"url": []byte("http://dns.local/hook")}
 	for _, test := range testCases {
 		signer := c.newSigner()
 		signer.account.Endpoint = "https://acme-v2.local"
@@ -80,11 +123,419 @@ INFO acme: new certificate issued: id=1 secret=s2 domain(s)=d1.local`,
 	}
 }
 
+func TestNotifySchedule(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+	ariStart := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		notBefore   time.Time
+		notAfter    time.Time
+		ratio       float64
+		checkPeriod time.Duration
+		window      *RenewalWindow
+		windowErr   error
+		expDelay    time.Duration
+		logging     string
+	}{
+		// 0
+		{
+			notBefore:   now.Add(-10 * day),
+			notAfter:    now.Add(80 * day),
+			ratio:       0.33,
+			checkPeriod: day,
+			logging: `
+INFO-V(2) acme: skipping sign, certificate is updated: secret=s1 domain(s)=d1.local`,
+		},
+		// 1
+		{
+			notBefore: now.Add(-10 * day),
+			notAfter:  now.Add(80 * day),
+			ratio:     0.33,
+			expDelay:  1207*time.Hour + 12*time.Minute,
+			logging: `
+INFO-V(2) acme: skipping sign, certificate is updated: secret=s1 domain(s)=d1.local
+INFO-V(2) acme: next check of secret s1 in 1207h12m0s`,
+		},
+		// 2
+		{
+			notBefore:   now.Add(-12 * time.Hour),
+			notAfter:    now.Add(12 * time.Hour),
+			ratio:       0.33,
+			checkPeriod: day,
+			expDelay:    4*time.Hour + 5*time.Minute,
+			logging: `
+INFO-V(2) acme: skipping sign, certificate is updated: secret=s1 domain(s)=d1.local
+INFO-V(2) acme: next check of secret s1 in 4h5m0s`,
+		},
+		// 3
+		{
+			notBefore:   now.Add(-12 * time.Hour),
+			notAfter:    now.Add(12 * time.Hour),
+			checkPeriod: day,
+			logging: `
+INFO acme: authorizing: id=1 secret=s1 domain(s)=d1.local endpoint=https://acme-v2.local reason='certificate expires in ` + now.Add(12*time.Hour).UTC().Truncate(time.Second).String() + `'
+INFO acme: new certificate issued: id=1 secret=s1 domain(s)=d1.local`,
+		},
+		// 4
+		{
+			notBefore:   now.Add(-10 * day),
+			notAfter:    now.Add(80 * day),
+			ratio:       0.33,
+			checkPeriod: day,
+			window:      &RenewalWindow{Start: ariStart, End: ariStart.Add(2 * day)},
+			logging: `
+INFO acme: authorizing: id=1 secret=s1 domain(s)=d1.local endpoint=https://acme-v2.local reason='acme server suggests renewal since 2020-01-02 00:00:00 +0000 UTC'
+INFO acme: new certificate issued: id=1 secret=s1 domain(s)=d1.local`,
+		},
+		// 5
+		{
+			notBefore:   now.Add(-10 * day),
+			notAfter:    now.Add(80 * day),
+			ratio:       0.33,
+			checkPeriod: day,
+			window:      &RenewalWindow{Start: now.Add(40 * day), End: now.Add(42 * day), RetryAfter: now.Add(6 * time.Hour)},
+			expDelay:    6 * time.Hour,
+			logging: `
+INFO-V(2) acme: skipping sign, certificate is updated: secret=s1 domain(s)=d1.local
+INFO-V(2) acme: next check of secret s1 in 6h0m0s`,
+		},
+		// 6
+		{
+			notBefore: now.Add(-10 * day),
+			notAfter:  now.Add(80 * day),
+			ratio:     0.33,
+			window:    &RenewalWindow{Start: now.Add(40 * day), End: now.Add(42 * day)},
+			expDelay:  41 * day,
+			logging: `
+INFO-V(2) acme: skipping sign, certificate is updated: secret=s1 domain(s)=d1.local
+INFO-V(2) acme: next check of secret s1 in 984h0m0s`,
+		},
+		// 7
+		{
+			notBefore:   now.Add(-10 * day),
+			notAfter:    now.Add(80 * day),
+			ratio:       0.33,
+			checkPeriod: day,
+			windowErr:   fmt.Errorf("404 not found"),
+			logging: `
+WARN acme: error reading renewal info of secret s1, using the certificate lifetime: 404 not found
+INFO-V(2) acme: skipping sign, certificate is updated: secret=s1 domain(s)=d1.local`,
+		},
+	}
+	c := setup(t)
+	defer c.teardown()
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	for i, test := range testCases {
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1500),
+			DNSNames:     []string{"d1.local"},
+			NotBefore:    test.notBefore,
+			NotAfter:     test.notAfter,
+		}
+		der, _ := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+		crt, _ := x509.ParseCertificate(der)
+		c.cache.tlsSecret["s1"] = &TLSSecret{Crt: crt, Key: key}
+		scheduler := &schedulerMock{}
+		signer := c.newSigner()
+		signer.client = &clientMock{window: test.window, windowErr: test.windowErr}
+		signer.account.Endpoint = "https://acme-v2.local"
+		signer.AcmeConfig(30*day, test.ratio, "")
+		signer.AcmeScheduler(scheduler, test.checkPeriod)
+		signer.Notify("s1,d1.local")
+		if test.expDelay == 0 {
+			if len(scheduler.items) != 0 {
+				t.Errorf("scheduled items differ on %d - expected: [], actual: %v", i, scheduler.items)
+			}
+		} else if len(scheduler.items) != 1 || scheduler.items[0] != "s1,d1.local" {
+			t.Errorf("scheduled items differ on %d - expected: [s1,d1.local], actual: %v", i, scheduler.items)
+		} else if actual := scheduler.delays[0].Round(time.Minute); actual != test.expDelay {
+			t.Errorf("delay differs on %d - expected: %s, actual: %s", i, test.expDelay, actual)
+		}
+		c.logger.CompareLogging(test.logging)
+	}
+}
+
+func TestNotifyEvents(t *testing.T) {
+	testCases := []struct {
+		input     string
+		signErr   error
+		expEvents string
+	}{
+		// 0
+		{
+			input:     "s2,d1.local",
+			expEvents: `Normal s2 AcmeCertificateIssued new certificate issued: secret=s2 domain(s)=d1.local reason='certificate does not exist'`,
+		},
+		// 1
+		{
+			input:     "s2,d1.local",
+			signErr:   fmt.Errorf("order failed"),
+			expEvents: `Warning s2 AcmeOrderFailed error signing new certificate: secret=s2 domain(s)=d1.local error=order failed`,
+		},
+		// 2
+		{
+			input: "s2,dualkeytype=ec256,d1.local",
+			expEvents: `Normal s2 AcmeCertificateIssued new certificate issued: secret=s2 domain(s)=d1.local reason='certificate does not exist'
+Normal s2 AcmeCertificateIssued new certificate issued: secret=s2-dual domain(s)=d1.local reason='certificate does not exist'`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		signer := c.newSigner()
+		signer.client = &clientMock{signErr: test.signErr}
+		signer.Notify(test.input)
+		if actual := strings.Join(c.cache.events, "\n"); actual != test.expEvents {
+			t.Errorf("events differ on %d - expected:\n%s\nactual:\n%s", i, test.expEvents, actual)
+		}
+		c.logger.Logging = nil
+		c.teardown()
+	}
+}
+
+func TestNotifyOrphan(t *testing.T) {
+	testCases := []struct {
+		input      string
+		expired    bool
+		dual       bool
+		referenced bool
+		revokeErr  error
+		expRevoked string
+		expCache   string
+		logging    string
+	}{
+		// 0
+		{
+			input:      "s1,orphan=revoke,since=1600000000",
+			expRevoked: "1",
+			logging: `
+INFO acme: orphan certificate revoked: secret=s1 serial=1`,
+		},
+		// 1
+		{
+			input:      "s1,orphan=revoke,orphan=delete,since=1600000000",
+			dual:       true,
+			expRevoked: "1,2",
+			expCache:   "delete s1,delete s1-dual",
+			logging: `
+INFO acme: orphan certificate revoked: secret=s1 serial=1
+INFO acme: orphan secret deleted: secret=s1
+INFO acme: orphan certificate revoked: secret=s1-dual serial=2
+INFO acme: orphan secret deleted: secret=s1-dual`,
+		},
+		// 2
+		{
+			input:    "s1,orphan=revoke,orphan=label,since=1600000000",
+			expired:  true,
+			expCache: "label s1 map[haproxy-ingress.github.io/acme-orphan:true]",
+			logging: `
+INFO acme: orphan secret labeled: secret=s1`,
+		},
+		// 3
+		{
+			input:     "s1,orphan=revoke,orphan=delete,since=1600000000",
+			revokeErr: fmt.Errorf("unauthorized"),
+			logging: `
+WARN acme: error revoking orphan certificate: secret=s1 error=unauthorized`,
+		},
+		// 4
+		{
+			input: "s2,orphan=revoke,orphan=delete,since=1600000000",
+		},
+		// 5 - cert-signer annotation removed, tls secretName kept
+		{
+			input:      "s1,orphan=revoke,orphan=delete,since=1600000000",
+			dual:       true,
+			referenced: true,
+			logging: `
+INFO acme: canceling orphan actions of secret s1, it is still referenced by an ingress`,
+		},
+	}
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	for i, test := range testCases {
+		c := setup(t)
+		notAfter := time.Now().Add(24 * time.Hour)
+		if test.expired {
+			notAfter = time.Now().Add(-24 * time.Hour)
+		}
+		newCrt := func(serial int64) *x509.Certificate {
+			template := &x509.Certificate{
+				SerialNumber: big.NewInt(serial),
+				NotBefore:    notAfter.Add(-48 * time.Hour),
+				NotAfter:     notAfter,
+			}
+			der, _ := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+			crt, _ := x509.ParseCertificate(der)
+			return crt
+		}
+		c.cache.tlsSecret["s1"] = &TLSSecret{Crt: newCrt(1), Key: key}
+		if test.dual {
+			c.cache.tlsSecret["s1-dual"] = &TLSSecret{Crt: newCrt(2), Key: key}
+		}
+		c.cache.referenced = map[string]bool{"s1": test.referenced}
+		client := &clientMock{revokeErr: test.revokeErr}
+		signer := c.newSigner()
+		signer.client = client
+		err := signer.Notify(test.input)
+		if (err != nil) != (test.revokeErr != nil) {
+			t.Errorf("error differs on %d - expected: %v, actual: %v", i, test.revokeErr, err)
+		}
+		if actual := strings.Join(client.revoked, ","); actual != test.expRevoked {
+			t.Errorf("revoked differs on %d - expected: %s, actual: %s", i, test.expRevoked, actual)
+		}
+		if actual := strings.Join(c.cache.changes, ","); actual != test.expCache {
+			t.Errorf("cache changes differ on %d - expected: %s, actual: %s", i, test.expCache, actual)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestSignedSecrets(t *testing.T) {
+	c := &cache{signed: []string{"default/s2", "default/s1-dual", "default/s1", "default/s3-dual"}}
+	s := NewSigner(&types_helper.LoggerMock{T: t}, c, nil)
+	expected := "default/s1,default/s2,default/s3-dual"
+	if actual := strings.Join(s.SignedSecrets(), ","); actual != expected {
+		t.Errorf("signed secrets differ - expected: %s, actual: %s", expected, actual)
+	}
+}
+
+func TestValidateChain(t *testing.T) {
+	ca, caKey := newTestCert(&x509.Certificate{Subject: pkix.Name{CommonName: "Test CA"}, IsCA: true}, nil, nil)
+	otherCA, _ := newTestCert(&x509.Certificate{Subject: pkix.Name{CommonName: "Other CA"}, IsCA: true}, nil, nil)
+	leaf, leafKey := newTestCert(&x509.Certificate{Subject: pkix.Name{CommonName: "d1.local"}, DNSNames: []string{"d1.local", "*.d2.local"}}, ca, caKey)
+	expired, expiredKey := newTestCert(&x509.Certificate{
+		Subject:   pkix.Name{CommonName: "d1.local"},
+		DNSNames:  []string{"d1.local"},
+		NotBefore: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	}, ca, caKey)
+	testCases := []struct {
+		crt     []byte
+		key     []byte
+		domains []string
+		expErr  string
+	}{
+		// 0
+		{
+			crt:     encodeTestChain(leaf, ca),
+			key:     encodeTestKey(leafKey),
+			domains: []string{"d1.local", "www.d2.local"},
+		},
+		// 1
+		{
+			crt:     encodeTestChain(leaf),
+			key:     encodeTestKey(leafKey),
+			domains: []string{"d1.local"},
+		},
+		// 2
+		{
+			crt:     encodeTestChain(leaf, ca),
+			key:     encodeTestKey(caKey),
+			domains: []string{"d1.local"},
+			expErr:  "tls: private key does not match public key",
+		},
+		// 3
+		{
+			crt:     encodeTestChain(leaf, ca),
+			key:     encodeTestKey(leafKey),
+			domains: []string{"d1.local", "d3.local"},
+			expErr:  "certificate does not cover all the domains, found: d1.local,*.d2.local",
+		},
+		// 4
+		{
+			crt:     encodeTestChain(expired, ca),
+			key:     encodeTestKey(expiredKey),
+			domains: []string{"d1.local"},
+			expErr:  "certificate expired on 2020-01-01 00:00:00 +0000 UTC",
+		},
+		// 5
+		{
+			crt:     encodeTestChain(leaf, otherCA),
+			key:     encodeTestKey(leafKey),
+			domains: []string{"d1.local"},
+			expErr:  "certificate 'd1.local' is not signed by 'Other CA': x509: ECDSA verification failure",
+		},
+		// 6
+		{
+			crt:     []byte("<crt>"),
+			key:     encodeTestKey(leafKey),
+			domains: []string{"d1.local"},
+			expErr:  "tls: failed to find any PEM data in certificate input",
+		},
+	}
+	for i, test := range testCases {
+		var actual string
+		if err := validateChain(test.crt, test.key, test.domains); err != nil {
+			actual = err.Error()
+		}
+		if actual != test.expErr {
+			t.Errorf("error differs on %d - expected: %s, actual: %s", i, test.expErr, actual)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	testCases := []struct {
+		domains  []string
+		dnsnames []string
+		expected bool
+	}{
+		// 0
+		{
+			domains:  []string{"d1.local"},
+			dnsnames: []string{"d1.local", "d2.local"},
+			expected: true,
+		},
+		// 1
+		{
+			domains:  []string{"d1.local", "d3.local"},
+			dnsnames: []string{"d1.local", "d2.local"},
+			expected: false,
+		},
+		// 2
+		{
+			domains:  []string{"app.d1.local", "www.d1.local"},
+			dnsnames: []string{"*.d1.local"},
+			expected: true,
+		},
+		// 3
+		{
+			domains:  []string{"*.d1.local"},
+			dnsnames: []string{"*.d1.local"},
+			expected: true,
+		},
+		// 4
+		{
+			domains:  []string{"d1.local"},
+			dnsnames: []string{"*.d1.local"},
+			expected: false,
+		},
+		// 5
+		{
+			domains:  []string{"www.app.d1.local"},
+			dnsnames: []string{"*.d1.local"},
+			expected: false,
+		},
+		// 6
+		{
+			domains:  []string{"*.d1.local"},
+			dnsnames: []string{"*.local"},
+			expected: false,
+		},
+	}
+	for i, test := range testCases {
+		if actual := match(test.domains, test.dnsnames); actual != test.expected {
+			t.Errorf("match differs on %d - expected: %t, actual: %t", i, test.expected, actual)
+		}
+	}
+}
+
 func setup(t *testing.T) *config {
 	return &config{
 		t: t,
 		cache: &cache{
-			tlsSecret: map[string]*TLSSecret{},
+			tlsSecret:   map[string]*TLSSecret{},
+			dnsProvider: map[string]map[string][]byte{},
 		},
 		logger:  types_helper.NewLoggerMock(t),
 		metrics: types_helper.NewMetricsMock(),
@@ -108,14 +559,73 @@ func (c *config) newSigner() *signer {
 	return signer
 }
 
-type clientMock struct{}
+type clientMock struct {
+	window    *RenewalWindow
+	windowErr error
+	revokeErr error
+	signErr   error
+	revoked   []string
+}
 
-func (c *clientMock) Sign(domains []string) (crt, key []byte, err error) {
-	return nil, nil, nil
+func (c *clientMock) RenewalWindow(crt *x509.Certificate) (*RenewalWindow, error) {
+	return c.window, c.windowErr
+}
+
+func (c *clientMock) Revoke(crt *x509.Certificate) error {
+	if c.revokeErr != nil {
+		return c.revokeErr
+	}
+	c.revoked = append(c.revoked, crt.SerialNumber.String())
+	return nil
+}
+
+func (c *clientMock) Sign(domains []string, opts SignOptions) (crt, key []byte, err error) {
+	if c.signErr != nil {
+		return nil, nil, c.signErr
+	}
+	ca, caKey := newTestCert(&x509.Certificate{Subject: pkix.Name{CommonName: "Test CA"}, IsCA: true}, nil, nil)
+	leaf, leafKey := newTestCert(&x509.Certificate{Subject: pkix.Name{CommonName: domains[0]}, DNSNames: domains}, ca, caKey)
+	return encodeTestChain(leaf, ca), encodeTestKey(leafKey), nil
+}
+
+// newTestCert creates a certificate from template, valid for 90 days if not
+// configured, signed by parent or self signed if parent is nil.
+func newTestCert(template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template.SerialNumber = big.NewInt(1)
+	template.BasicConstraintsValid = true
+	if template.NotAfter.IsZero() {
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().Add(90 * 24 * time.Hour)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	crt, _ := x509.ParseCertificate(der)
+	return crt, key
+}
+
+func encodeTestChain(chain ...*x509.Certificate) []byte {
+	var pemCrt []byte
+	for _, crt := range chain {
+		pemCrt = append(pemCrt, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})...)
+	}
+	return pemCrt
+}
+
+func encodeTestKey(key *ecdsa.PrivateKey) []byte {
+	der, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
 }
 
 type cache struct {
-	tlsSecret map[string]*TLSSecret
+	signed      []string
+	tlsSecret   map[string]*TLSSecret
+	dnsProvider map[string]map[string][]byte
+	referenced  map[string]bool
+	changes     []string
+	events      []string
 }
 
 func (c *cache) GetKey() (crypto.Signer, error) {
@@ -130,6 +640,14 @@ func (c *cache) GetToken(domain, uri string) string {
 	return ""
 }
 
+func (c *cache) GetDNSProviderConfig(secretName string) (map[string][]byte, error) {
+	config, found := c.dnsProvider[secretName]
+	if !found {
+		return nil, fmt.Errorf("secret not found: '%s'", secretName)
+	}
+	return config, nil
+}
+
 func (c *cache) GetTLSSecretContent(secretName string) *TLSSecret {
 	tls, found := c.tlsSecret[secretName]
 	if found {
@@ -141,3 +659,39 @@ func (c *cache) GetTLSSecretContent(secretName string) *TLSSecret {
 func (c *cache) SetTLSSecretContent(secretName string, pemCrt, pemKey []byte) error {
 	return nil
 }
+
+func (c *cache) DeleteTLSSecret(secretName string) error {
+	c.changes = append(c.changes, "delete "+secretName)
+	return nil
+}
+
+func (c *cache) LabelTLSSecret(secretName string, labels map[string]string) error {
+	c.changes = append(c.changes, fmt.Sprintf("label %s %v", secretName, labels))
+	return nil
+}
+
+func (c *cache) GetSignedTLSSecrets() []string {
+	return c.signed
+}
+
+func (c *cache) IsTLSSecretReferenced(secretName string) bool {
+	return c.referenced[secretName]
+}
+
+func (c *cache) RecordSecretEvent(secretName string, warning bool, reason, message string) {
+	eventType := "Normal"
+	if warning {
+		eventType = "Warning"
+	}
+	c.events = append(c.events, fmt.Sprintf("%s %s %s %s", eventType, secretName, reason, message))
+}
+
+type schedulerMock struct {
+	items  []interface{}
+	delays []time.Duration
+}
+
+func (s *schedulerMock) AddAfter(item interface{}, duration time.Duration) {
+	s.items = append(s.items, item)
+	s.delays = append(s.delays, duration)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acme
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/asn1"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type tokenResolver map[string]string
+
+func (r tokenResolver) GetToken(domain, uri string) string {
+	return r[domain+uri]
+}
+
+func TestServeTLSALPN(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acme")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	c := setup(t)
+	defer c.teardown()
+	resolver := tokenResolver{"d1.local" + acmeTLSALPNProto: "token1.thumbprint"}
+	stopCh := make(chan struct{})
+	server := NewServer(c.logger, filepath.Join(dir, "acme.sock"), filepath.Join(dir, "acme-tls.sock"), resolver)
+	if err := server.Listen(stopCh); err != nil {
+		t.Fatalf("error listening: %v", err)
+	}
+	handshake := func(serverName string) *tls.ConnectionState {
+		conn, err := net.Dial("unix", filepath.Join(dir, "acme-tls.sock"))
+		if err != nil {
+			t.Fatalf("error connecting: %v", err)
+		}
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName:         serverName,
+			NextProtos:         []string{acmeTLSALPNProto},
+			InsecureSkipVerify: true,
+		})
+		defer tlsConn.Close()
+		if err := tlsConn.Handshake(); err != nil {
+			return nil
+		}
+		state := tlsConn.ConnectionState()
+		return &state
+	}
+
+	state := handshake("d1.local")
+	if state == nil {
+		t.Fatalf("tls-alpn-01 handshake of d1.local failed")
+	}
+	if state.NegotiatedProtocol != acmeTLSALPNProto {
+		t.Errorf("expected protocol %s, actual '%s'", acmeTLSALPNProto, state.NegotiatedProtocol)
+	}
+	crt := state.PeerCertificates[0]
+	if len(crt.DNSNames) != 1 || crt.DNSNames[0] != "d1.local" {
+		t.Errorf("expected d1.local as the only dnsname, actual %v", crt.DNSNames)
+	}
+	digest := sha256.Sum256([]byte("token1.thumbprint"))
+	var found bool
+	for _, ext := range crt.Extensions {
+		if ext.Id.Equal(idPeAcmeIdentifier) {
+			var value []byte
+			_, err := asn1.Unmarshal(ext.Value, &value)
+			found = ext.Critical && err == nil && bytes.Equal(value, digest[:])
+		}
+	}
+	if !found {
+		t.Errorf("acmeIdentifier extension not found or invalid")
+	}
+
+	if state := handshake("d2.local"); state != nil {
+		t.Errorf("tls-alpn-01 handshake of d2.local should fail")
+	}
+
+	c.logger.CompareLogging(`
+INFO acme: listening on unix socket: ` + filepath.Join(dir, "acme.sock") + `
+INFO acme: listening tls-alpn-01 challenges on unix socket: ` + filepath.Join(dir, "acme-tls.sock") + `
+INFO acme: request tls-alpn-01 token: domain=d1.local
+WARN acme: tls-alpn-01 token not found: domain=d2.local`)
+}
@@ -21,6 +21,7 @@ import (
 	"crypto"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
@@ -32,6 +33,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -119,13 +121,14 @@ func (c *Client) Discover(ctx context.Context) (Directory, error) {
 	}
 
 	var v struct {
-		NewNonce   string
-		NewAccount string
-		NewOrder   string
-		NewAuthz   string
-		RevokeCert string
-		KeyChange  string
-		Meta       struct {
+		NewNonce    string
+		NewAccount  string
+		NewOrder    string
+		NewAuthz    string
+		RevokeCert  string
+		KeyChange   string
+		RenewalInfo string
+		Meta        struct {
 			TermsOfService          string
 			Website                 string
 			CAAIdentities           []string
@@ -143,6 +146,7 @@ func (c *Client) Discover(ctx context.Context) (Directory, error) {
 		NewAuthzURL:             v.NewAuthz,
 		RevokeCertURL:           v.RevokeCert,
 		KeyChangeURL:            v.KeyChange,
+		RenewalInfoURL:          v.RenewalInfo,
 		Terms:                   v.Meta.TermsOfService,
 		Website:                 v.Meta.Website,
 		CAA:                     v.Meta.CAAIdentities,
@@ -213,6 +217,39 @@ func (c *Client) CreateOrder(ctx context.Context, order *Order) (*Order, error)
 // Callers are encouraged to parse the returned certificate chain to ensure it
 // is valid and has the expected attributes.
 func (c *Client) FinalizeOrder(ctx context.Context, finalizeURL string, csr []byte) (der [][]byte, err error) {
+	o, err := c.finalizeOrder(ctx, finalizeURL, csr)
+	if err != nil {
+		return nil, err
+	}
+	der, _, err = c.getCert(ctx, o.CertificateURL)
+	return der, err
+}
+
+// FinalizeOrderChains is like FinalizeOrder, but returns all the certificate
+// chains the CA offers. The default chain is the first one, followed by the
+// alternate chains advertised in the "alternate" Link header of the
+// certificate response, see RFC 8555, section 7.4.2.
+func (c *Client) FinalizeOrderChains(ctx context.Context, finalizeURL string, csr []byte) (chains [][][]byte, err error) {
+	o, err := c.finalizeOrder(ctx, finalizeURL, csr)
+	if err != nil {
+		return nil, err
+	}
+	der, alternates, err := c.getCert(ctx, o.CertificateURL)
+	if err != nil {
+		return nil, err
+	}
+	chains = append(chains, der)
+	for _, alternate := range alternates {
+		der, _, err := c.getCert(ctx, alternate)
+		if err != nil {
+			return nil, err
+		}
+		chains = append(chains, der)
+	}
+	return chains, nil
+}
+
+func (c *Client) finalizeOrder(ctx context.Context, finalizeURL string, csr []byte) (*Order, error) {
 	if _, err := c.Discover(ctx); err != nil {
 		return nil, err
 	}
@@ -249,8 +286,7 @@ func (c *Client) FinalizeOrder(ctx context.Context, finalizeURL string, csr []by
 	if o.Status != StatusValid {
 		return nil, fmt.Errorf("acme: unexpected order status %q", o.Status)
 	}
-
-	return c.getCert(ctx, o.CertificateURL)
+	return o, nil
 }
 
 // GetOrder retrieves an order identified by url.
@@ -313,6 +349,58 @@ func (c *Client) WaitOrder(ctx context.Context, url string) (*Order, error) {
 	}
 }
 
+// FetchRenewalInfo retrieves the ACME Renewal Information (ARI) of the
+// certificate leaf. It returns ErrNoRenewalInfo if the server does not
+// support ARI.
+func (c *Client) FetchRenewalInfo(ctx context.Context, leaf *x509.Certificate) (*RenewalInfo, error) {
+	dir, err := c.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if dir.RenewalInfoURL == "" {
+		return nil, ErrNoRenewalInfo
+	}
+	certID, err := certRenewalIdentifier(leaf)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.get(ctx, strings.TrimSuffix(dir.RenewalInfoURL, "/")+"/"+certID)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, responseError(res)
+	}
+	var info RenewalInfo
+	if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("acme: invalid response: %v", err)
+	}
+	if info.SuggestedWindow.End.Before(info.SuggestedWindow.Start) {
+		return nil, errors.New("acme: invalid renewal window, end is before start")
+	}
+	if ra := res.Header.Get("Retry-After"); ra != "" {
+		info.RetryAfter = retryAfter(ra)
+	}
+	return &info, nil
+}
+
+// certRenewalIdentifier builds the ARI unique identifier of a certificate:
+// the base64url encoded authority key identifier and serial number of the
+// certificate, joined with a dot.
+func certRenewalIdentifier(leaf *x509.Certificate) (string, error) {
+	if len(leaf.AuthorityKeyId) == 0 {
+		return "", errors.New("acme: certificate does not have an authority key identifier")
+	}
+	// DER encoding of the serial, a positive integer
+	serial := leaf.SerialNumber.Bytes()
+	if len(serial) == 0 || serial[0]&0x80 != 0 {
+		serial = append([]byte{0}, serial...)
+	}
+	return base64.RawURLEncoding.EncodeToString(leaf.AuthorityKeyId) + "." +
+		base64.RawURLEncoding.EncodeToString(serial), nil
+}
+
 // RevokeCert revokes a previously issued certificate cert, provided in DER
 // format.
 //
@@ -554,9 +642,10 @@ func (c *Client) HTTP01ChallengePath(token string) string {
 // the Account. Only the Contact field can be updated.
 func (c *Client) doAccount(ctx context.Context, url string, getExistingWithKey bool, acct *Account) (*Account, error) {
 	req := struct {
-		Contact     []string `json:"contact,omitempty"`
-		TermsAgreed bool     `json:"termsOfServiceAgreed,omitempty"`
-		GetExisting bool     `json:"onlyReturnExisting,omitempty"`
+		Contact                []string        `json:"contact,omitempty"`
+		TermsAgreed            bool            `json:"termsOfServiceAgreed,omitempty"`
+		GetExisting            bool            `json:"onlyReturnExisting,omitempty"`
+		ExternalAccountBinding json.RawMessage `json:"externalAccountBinding,omitempty"`
 	}{
 		GetExisting: getExistingWithKey,
 	}
@@ -567,6 +656,13 @@ func (c *Client) doAccount(ctx context.Context, url string, getExistingWithKey b
 	if acct != nil {
 		req.Contact = acct.Contact
 		req.TermsAgreed = acct.TermsAgreed
+		if acct.ExternalAccountBinding != nil && url == c.dir.NewAccountURL {
+			eab, err := jwsWithMAC(acct.ExternalAccountBinding.Key, acct.ExternalAccountBinding.KID, url, c.Key.Public())
+			if err != nil {
+				return nil, fmt.Errorf("acme: external account binding: %v", err)
+			}
+			req.ExternalAccountBinding = eab
+		}
 	}
 	res, err := c.retryPostJWS(ctx, c.Key, accountURL, url, req)
 	if err != nil {
@@ -821,18 +917,23 @@ func nonceFromHeader(h http.Header) string {
 	return h.Get("Replay-Nonce")
 }
 
-func (c *Client) getCert(ctx context.Context, url string) ([][]byte, error) {
+// getCert fetches the certificate chain of url, and also returns
+// the URLs of the alternate chains advertised by the server.
+func (c *Client) getCert(ctx context.Context, url string) ([][]byte, []string, error) {
 	res, err := c.postWithJWSAccount(ctx, url, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, responseError(res)
+	}
 	data, err := ioutil.ReadAll(io.LimitReader(res.Body, maxChainSize+1))
 	if err != nil {
-		return nil, fmt.Errorf("acme: error getting certificate: %v", err)
+		return nil, nil, fmt.Errorf("acme: error getting certificate: %v", err)
 	}
 	if len(data) > maxChainSize {
-		return nil, errors.New("acme: certificate chain is too big")
+		return nil, nil, errors.New("acme: certificate chain is too big")
 	}
 	var chain [][]byte
 	for {
@@ -840,19 +941,42 @@ func (c *Client) getCert(ctx context.Context, url string) ([][]byte, error) {
 		p, data = pem.Decode(data)
 		if p == nil {
 			if len(chain) == 0 {
-				return nil, errors.New("acme: invalid PEM certificate chain")
+				return nil, nil, errors.New("acme: invalid PEM certificate chain")
 			}
 			break
 		}
 		if len(chain) == maxChainLen {
-			return nil, errors.New("acme: certificate chain is too long")
+			return nil, nil, errors.New("acme: certificate chain is too long")
 		}
 		if p.Type != "CERTIFICATE" {
-			return nil, fmt.Errorf("acme: invalid PEM block type %q", p.Type)
+			return nil, nil, fmt.Errorf("acme: invalid PEM block type %q", p.Type)
 		}
 		chain = append(chain, p.Bytes)
 	}
-	return chain, nil
+	var alternates []string
+	for _, link := range linkHeader(res.Header, "alternate") {
+		alternate, err := resolveURL(url, link)
+		if err != nil {
+			return nil, nil, err
+		}
+		alternates = append(alternates, alternate)
+	}
+	return chain, alternates, nil
+}
+
+// linkHeader returns the URIs of the Link headers whose rel parameter is rel.
+func linkHeader(h http.Header, rel string) []string {
+	var links []string
+	for _, v := range h["Link"] {
+		parts := strings.Split(v, ";")
+		for _, p := range parts[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "rel=") && strings.Trim(p[4:], `"`) == rel {
+				links = append(links, strings.Trim(strings.TrimSpace(parts[0]), "<>"))
+			}
+		}
+	}
+	return links
 }
 
 // responseError creates an error of Error type from resp.
@@ -961,5 +1085,17 @@ func resolveLocation(base string, h http.Header) (string, error) {
 	return u.String(), nil
 }
 
+func resolveURL(base, ref string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	u, err = u.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("acme: error parsing Link: %s", err)
+	}
+	return u.String(), nil
+}
+
 // timeNow is useful for testing for fixed current time.
 var timeNow = time.Now
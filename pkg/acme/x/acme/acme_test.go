@@ -7,7 +7,9 @@ package acme
 import (
 	"context"
 	"crypto"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/base64"
@@ -172,6 +174,56 @@ func TestCreateAccount(t *testing.T) {
 	}
 }
 
+func TestCreateAccountWithEAB(t *testing.T) {
+	hmacKey := []byte("0123456789abcdef0123456789abcdef")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			w.Header().Set("Replay-Nonce", "test-nonce")
+			return
+		}
+		var j struct {
+			ExternalAccountBinding struct {
+				Protected string
+				Payload   string
+				Signature string
+			}
+		}
+		decodeJWSRequest(t, &j, r)
+		eab := j.ExternalAccountBinding
+		head, _ := base64.RawURLEncoding.DecodeString(eab.Protected)
+		if want := fmt.Sprintf(`{"alg":"HS256","kid":"kid-1","url":%q}`, "http://"+r.Host+"/account"); string(head) != want {
+			t.Errorf("eab protected = %s; want %s", head, want)
+		}
+		payload, _ := base64.RawURLEncoding.DecodeString(eab.Payload)
+		if jwk, _ := jwkEncode(testKeyEC.Public()); string(payload) != jwk {
+			t.Errorf("eab payload = %s; want %s", payload, jwk)
+		}
+		h := hmac.New(sha256.New, hmacKey)
+		h.Write([]byte(eab.Protected + "." + eab.Payload))
+		if sig := base64.RawURLEncoding.EncodeToString(h.Sum(nil)); eab.Signature != sig {
+			t.Errorf("eab signature = %s; want %s", eab.Signature, sig)
+		}
+		w.Header().Set("Location", "https://example.com/acme/account/1")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"status":"valid"}`)
+	}))
+	defer ts.Close()
+
+	c := Client{Key: testKeyEC, dir: &Directory{NewAccountURL: ts.URL + "/account", NewNonceURL: ts.URL}}
+	a := &Account{
+		TermsAgreed:            true,
+		ExternalAccountBinding: &ExternalAccountBinding{KID: "kid-1", Key: hmacKey},
+	}
+	if _, err := c.CreateAccount(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	a.ExternalAccountBinding.Key = nil
+	if _, err := c.CreateAccount(context.Background(), a); err == nil {
+		t.Error("CreateAccount with an empty MAC key succeeded; want error")
+	}
+}
+
 func TestUpdateAccount(t *testing.T) {
 	contacts := []string{"mailto:admin@example.com"}
 
@@ -798,6 +850,51 @@ func TestFinalizeOrder(t *testing.T) {
 	}
 }
 
+func TestFinalizeOrderChains(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			w.Header().Set("Replay-Nonce", "test-nonce")
+			return
+		}
+		switch r.URL.Path {
+		case "/finalize":
+			w.Header().Set("Location", "/order")
+			fmt.Fprintf(w, `{"status":"valid","certificate":%q}`, ts.URL+"/cert")
+		case "/cert":
+			w.Header().Add("Link", `<`+ts.URL+`/directory>;rel="index"`)
+			w.Header().Add("Link", `</cert/1>;rel="alternate"`)
+			w.Header().Add("Link", `<`+ts.URL+`/cert/2>; rel="alternate"`)
+			pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: []byte("leaf")})
+			pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: []byte("default")})
+		case "/cert/1", "/cert/2":
+			pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: []byte("leaf")})
+			pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: []byte("alt" + r.URL.Path[6:])})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c := Client{Key: testKeyEC, accountURL: "https://example.com/acme/account", dir: &Directory{NewNonceURL: ts.URL}}
+	chains, err := c.FinalizeOrderChains(context.Background(), ts.URL+"/finalize", []byte("csr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for _, chain := range chains {
+		var certs []string
+		for _, der := range chain {
+			certs = append(certs, string(der))
+		}
+		actual = append(actual, strings.Join(certs, "+"))
+	}
+	expected := []string{"leaf+default", "leaf+alt1", "leaf+alt2"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("chains = %v; want %v", actual, expected)
+	}
+}
+
 func TestWaitOrderInvalid(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "HEAD" {
@@ -890,6 +987,43 @@ func TestGetOrder(t *testing.T) {
 	}
 }
 
+func TestFetchRenewalInfo(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("r.Method = %q; want GET", r.Method)
+		}
+		if r.URL.Path != "/renewal-info/AQID.AIA" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Retry-After", "3600")
+		fmt.Fprint(w, `{"suggestedWindow":{"start":"2021-01-03T00:00:00Z","end":"2021-01-04T00:00:00Z"},"explanationURL":"https://example.com/revoked"}`)
+	}))
+	defer ts.Close()
+
+	leaf := &x509.Certificate{AuthorityKeyId: []byte{1, 2, 3}, SerialNumber: big.NewInt(0x80)}
+	c := &Client{dir: &Directory{RenewalInfoURL: ts.URL + "/renewal-info/"}}
+	info, err := c.FetchRenewalInfo(context.Background(), leaf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC)
+	if !info.SuggestedWindow.Start.Equal(start) || !info.SuggestedWindow.End.Equal(start.Add(24*time.Hour)) {
+		t.Errorf("info.SuggestedWindow = %+v; want 2021-01-03 to 2021-01-04", info.SuggestedWindow)
+	}
+	if info.ExplanationURL != "https://example.com/revoked" {
+		t.Errorf("info.ExplanationURL = %q; want https://example.com/revoked", info.ExplanationURL)
+	}
+	if d := time.Until(info.RetryAfter); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("info.RetryAfter = %v; want about one hour from now", info.RetryAfter)
+	}
+
+	c.dir.RenewalInfoURL = ""
+	if _, err := c.FetchRenewalInfo(context.Background(), leaf); err != ErrNoRenewalInfo {
+		t.Errorf("err = %v; want ErrNoRenewalInfo", err)
+	}
+}
+
 func TestRevokeCert(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "HEAD" {
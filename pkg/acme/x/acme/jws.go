@@ -9,12 +9,14 @@ package acme
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	_ "crypto/sha512" // need for EC keys
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 )
@@ -65,6 +67,36 @@ func jwsEncodeJSON(claimset interface{}, key crypto.Signer, accountURL, url, non
 	return json.Marshal(&enc)
 }
 
+// jwsWithMAC creates and signs a JWS using the given key and the HS256
+// algorithm. kid and url are included in the protected header. The payload
+// is the JWK of the account public key, as required by the external account
+// binding of a newAccount request.
+// See https://tools.ietf.org/html/rfc8555#section-7.3.4.
+func jwsWithMAC(key []byte, kid, url string, pub crypto.PublicKey) (json.RawMessage, error) {
+	if len(key) == 0 {
+		return nil, errors.New("cannot sign JWS with an empty MAC key")
+	}
+	jwk, err := jwkEncode(pub)
+	if err != nil {
+		return nil, err
+	}
+	phead := fmt.Sprintf(`{"alg":"HS256","kid":%q,"url":%q}`, kid, url)
+	phead = base64.RawURLEncoding.EncodeToString([]byte(phead))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(jwk))
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(phead + "." + payload))
+	enc := struct {
+		Protected string `json:"protected"`
+		Payload   string `json:"payload"`
+		Sig       string `json:"signature"`
+	}{
+		Protected: phead,
+		Payload:   payload,
+		Sig:       base64.RawURLEncoding.EncodeToString(h.Sum(nil)),
+	}
+	return json.Marshal(&enc)
+}
+
 // jwkEncode encodes public part of an RSA or ECDSA key into a JWK.
 // The result is also suitable for creating a JWK thumbprint.
 // https://tools.ietf.org/html/rfc7517
@@ -44,6 +44,10 @@ const (
 // ErrUnsupportedKey is returned when an unsupported key type is encountered.
 var ErrUnsupportedKey = errors.New("acme: unknown key type; only RSA and ECDSA are supported")
 
+// ErrNoRenewalInfo is returned by FetchRenewalInfo when the ACME server
+// does not provide the ACME Renewal Information (ARI) endpoint.
+var ErrNoRenewalInfo = errors.New("acme: server does not support renewal information")
+
 // Error is an ACME error as defined in RFC 7807, Problem Details for HTTP APIs.
 type Error struct {
 	// StatusCode is The HTTP status code generated by the origin server.
@@ -157,6 +161,29 @@ type Account struct {
 	// OrdersURL is the URL used to fetch a list of orders submitted by this
 	// account.
 	OrdersURL string
+
+	// ExternalAccountBinding represents an arbitrary binding to an account of
+	// the CA which the ACME server is tied to.
+	// See https://tools.ietf.org/html/rfc8555#section-7.3.4 for more details.
+	// It is only used when creating a new account.
+	ExternalAccountBinding *ExternalAccountBinding
+}
+
+// ExternalAccountBinding contains the data needed to form a request with
+// an external account binding.
+// See https://tools.ietf.org/html/rfc8555#section-7.3.4 for more details.
+type ExternalAccountBinding struct {
+	// KID is the Key ID of the symmetric MAC key that the CA provides to
+	// identify an external account from ACME.
+	KID string
+
+	// Key is the bytes of the symmetric key that the CA provides to identify
+	// the account. Key must correspond to the KID.
+	Key []byte
+}
+
+func (e *ExternalAccountBinding) String() string {
+	return fmt.Sprintf("&{KID: %q, Key: redacted}", e.KID)
 }
 
 // Directory is ACME server discovery data.
@@ -179,6 +206,11 @@ type Directory struct {
 	// KeyChangeURL is used to change the account key.
 	KeyChangeURL string
 
+	// RenewalInfoURL is used to fetch the suggested renewal window of
+	// a certificate, see https://datatracker.ietf.org/doc/draft-ietf-acme-ari/.
+	// Empty if the server does not support ARI.
+	RenewalInfoURL string
+
 	// Terms is a URL identifying the current terms of service.
 	Terms string
 
@@ -197,6 +229,27 @@ type Directory struct {
 	ExternalAccountRequired bool
 }
 
+// RenewalInfo is the ACME Renewal Information (ARI) of a certificate.
+type RenewalInfo struct {
+	// SuggestedWindow is the time window the server suggests the
+	// certificate to be renewed.
+	SuggestedWindow RenewalInfoWindow
+
+	// ExplanationURL optionally points to a page which explains why the
+	// suggested window is not the usual one, e.g. due to a revocation.
+	ExplanationURL string
+
+	// RetryAfter is the time the server suggests the renewal information
+	// to be fetched again. Zero if not provided.
+	RetryAfter time.Time
+}
+
+// RenewalInfoWindow is the suggested renewal window of a certificate.
+type RenewalInfoWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
 // NewOrder creates a new order with the domains provided, suitable for creating
 // a TLS certificate order with CreateOrder.
 func NewOrder(domains ...string) *Order {
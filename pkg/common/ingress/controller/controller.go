@@ -70,6 +70,7 @@ type Configuration struct {
 	AllowCrossNamespace     bool
 	DisableNodeList         bool
 	DisablePodList          bool
+	EnableNamespaceList     bool
 	AnnPrefix               string
 
 	AcmeServer              bool
@@ -83,13 +84,19 @@ type Configuration struct {
 
 	BucketsResponseTime []float64
 
-	TCPConfigMapName       string
-	DefaultSSLCertificate  string
-	VerifyHostname         bool
-	DefaultHealthzURL      string
-	StatsCollectProcPeriod time.Duration
-	PublishService         string
-	Backend                ingress.Controller
+	TCPConfigMapName         string
+	DefaultSSLCertificate    string
+	VerifyHostname           bool
+	DefaultHealthzURL        string
+	StatsCollectProcPeriod   time.Duration
+	StatsExporterPeriod      time.Duration
+	StatsExporterMaxObjects  int
+	DiagnosticEventsInterval time.Duration
+	Explain                  bool
+	Introspection            bool
+	ReloadHistory            bool
+	PublishService           string
+	Backend                  ingress.Controller
 
 	UpdateStatus           bool
 	UseNodeInternalIP      bool
@@ -99,6 +106,12 @@ type Configuration struct {
 	BackendShards             int
 	SortBackends              bool
 	IgnoreIngressWithoutClass bool
+
+	GRPCHealthAgent string
+
+	ValidatingWebhook     string
+	ValidatingWebhookCert string
+	ValidatingWebhookKey  string
 }
 
 // newIngressController creates an Ingress controller
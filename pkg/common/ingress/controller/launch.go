@@ -116,13 +116,39 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 
 		healthzPort = flags.Int("healthz-port", 10254, "port for healthz endpoint.")
 
+		diagnosticEventsInterval = flags.Duration("diagnostic-events-interval", 0,
+			`Publishes configuration warnings as events of the ingress or service that declared
+		the misconfiguration. The same warning of the same object is published again only after
+		this interval. Default value is 0 (zero), which disables the events.`)
+
 		statsCollectProcPeriod = flags.Duration("stats-collect-processing-period", 500*time.Millisecond,
 			`Defines the interval between two consecutive readings of haproxy's Idle_pct. haproxy
 		updates Idle_pct every 500ms, which makes that the best configuration value.
 		Change to 0 (zero) to disable this metric.`)
 
+		statsExporterPeriod = flags.Duration("stats-exporter-period", 0,
+			`Defines the interval between two consecutive readings of the haproxy stats, which are
+		exported as metrics labeled with namespace, service, pod, ingress, host and path.
+		Defaults to 0 (zero), which disables the exporter.`)
+
+		statsExporterMaxObjects = flags.Int("stats-exporter-max-objects", 10000,
+			`Maximum number of backends, servers and paths exported by the stats exporter.
+		Change to 0 (zero) to remove the limit.`)
+
 		profiling = flags.Bool("profiling", true, `Enable profiling via web interface host:port/debug/pprof/`)
 
+		explain = flags.Bool("explain", false,
+			`Enables the /explain endpoint of the healthz port, which shows the effective configuration
+		of hosts and backends, and where every configuration key came from`)
+
+		introspection = flags.Bool("introspection", false,
+			`Enables the read-only /introspect/ API of the healthz port, which exposes the in-memory
+		haproxy model as JSON, as well as the changed objects and the dynamic update commands of the last sync`)
+
+		reloadHistory = flags.Bool("reload-history", false,
+			`Enables the /debug/reloads endpoint of the healthz port, which shows the last haproxy
+		reloads, their reasons and the objects that lead to them`)
+
 		defSSLCertificate = flags.String("default-ssl-certificate", "", `Name of the secret
 		that contains a SSL certificate to be used as default for a HTTPS catch-all server`)
 
@@ -157,6 +183,11 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 			`Defines if HAProxy Ingress should disable pod watch and in memory list. Pod list is
 		mandatory for drain-support (should not be disabled) and optional for blue/green.`)
 
+		enableNamespaceList = flags.Bool("enable-namespace-list", false,
+			`Enables namespace watch and in memory list. Namespaces are used to read
+		default annotations and the labels of the annotation policy. Needs list and watch
+		permissions on namespaces, and is ignored if --watch-namespace is used.`)
+
 		updateStatusOnShutdown = flags.Bool("update-status-on-shutdown", true, `Indicates if the
 		ingress controller should update the Ingress status IP/hostname when the controller
 		is being stopped. Default is true`)
@@ -173,6 +204,21 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 		showVersion = flags.Bool("version", false,
 			`Shows release information about the Ingress controller`)
 
+		grpcHealthAgent = flags.String("grpc-health-agent", "",
+			`Address of the agent that answers the gRPC health checks of the backends, eg 127.0.0.1:10260.
+		If not configured, the agent listens on 127.0.0.1:10260 when the first backend with gRPC health
+		check is configured`)
+
+		validatingWebhook = flags.String("validating-webhook", "",
+			`Address of the validating admission webhook server of ingress objects, eg :8443.
+		The webhook is disabled if not configured`)
+
+		validatingWebhookCertificate = flags.String("validating-webhook-certificate", "",
+			`Certificate file, PEM encoded, of the validating admission webhook server`)
+
+		validatingWebhookKey = flags.String("validating-webhook-key", "",
+			`Private key file, PEM encoded, of the validating admission webhook server`)
+
 		ignoreIngressWithoutClass = flags.Bool("ignore-ingress-without-class", false,
 			`Defines if the ingress without the ingress.class annotation will be considered or not. If true then 
 			only the ingresses with the matching ingress.class annotation will be considered, ingresses with missing 
@@ -292,6 +338,10 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 		glog.Fatal("Cannot use --allow-cross-namespace if --force-namespace-isolation is true")
 	}
 
+	if *validatingWebhook != "" && (*validatingWebhookCertificate == "" || *validatingWebhookKey == "") {
+		glog.Fatal("--validating-webhook-certificate and --validating-webhook-key are mandatory if --validating-webhook is configured")
+	}
+
 	config := &Configuration{
 		UpdateStatus:              *updateStatus,
 		ElectionID:                *electionID,
@@ -318,6 +368,12 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 		VerifyHostname:            *verifyHostname,
 		DefaultHealthzURL:         *defHealthzURL,
 		StatsCollectProcPeriod:    *statsCollectProcPeriod,
+		StatsExporterPeriod:       *statsExporterPeriod,
+		StatsExporterMaxObjects:   *statsExporterMaxObjects,
+		DiagnosticEventsInterval:  *diagnosticEventsInterval,
+		Explain:                   *explain,
+		Introspection:             *introspection,
+		ReloadHistory:             *reloadHistory,
 		PublishService:            *publishSvc,
 		Backend:                   backend,
 		ForceNamespaceIsolation:   *forceIsolation,
@@ -325,11 +381,16 @@ func NewIngressController(backend ingress.Controller) *GenericController {
 		AllowCrossNamespace:       *allowCrossNamespace,
 		DisableNodeList:           *disableNodeList,
 		DisablePodList:            *disablePodList,
+		EnableNamespaceList:       *enableNamespaceList,
 		UpdateStatusOnShutdown:    *updateStatusOnShutdown,
 		BackendShards:             *backendShards,
 		SortBackends:              *sortBackends,
 		UseNodeInternalIP:         *useNodeInternalIP,
 		IgnoreIngressWithoutClass: *ignoreIngressWithoutClass,
+		GRPCHealthAgent:           *grpcHealthAgent,
+		ValidatingWebhook:         *validatingWebhook,
+		ValidatingWebhookCert:     *validatingWebhookCertificate,
+		ValidatingWebhookKey:      *validatingWebhookKey,
 	}
 
 	ic := newIngressController(config)
@@ -382,6 +443,16 @@ func registerHandlers(enableProfiling bool, port int, ic *GenericController) {
 		}
 	})
 
+	if ic.cfg.Explain {
+		mux.HandleFunc("/explain", ic.cfg.Backend.Explain)
+	}
+	if ic.cfg.Introspection {
+		mux.HandleFunc("/introspect/", ic.cfg.Backend.Introspect)
+	}
+	if ic.cfg.ReloadHistory {
+		mux.HandleFunc("/debug/reloads", ic.cfg.Backend.Reloads)
+	}
+
 	if enableProfiling {
 		mux.HandleFunc("/debug/pprof/", pprof.Index)
 		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
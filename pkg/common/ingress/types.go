@@ -18,6 +18,7 @@ package ingress
 
 import (
 	"fmt"
+	"net/http"
 
 	"github.com/spf13/pflag"
 	apiv1 "k8s.io/api/core/v1"
@@ -47,6 +48,12 @@ type Controller interface {
 	Info() *BackendInfo
 	// AcmeCheck starts a certificate missing/expiring/outdated check
 	AcmeCheck() (int, error)
+	// Explain answers the queries of the explain endpoint
+	Explain(w http.ResponseWriter, r *http.Request)
+	// Introspect answers the requests of the introspection API
+	Introspect(w http.ResponseWriter, r *http.Request)
+	// Reloads answers the last haproxy reloads and their reasons
+	Reloads(w http.ResponseWriter, r *http.Request)
 	// ConfigureFlags allow to configure more flags before the parsing of
 	// command line arguments
 	ConfigureFlags(*pflag.FlagSet)
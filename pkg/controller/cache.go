@@ -34,6 +34,7 @@ import (
 	networking "k8s.io/api/networking/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	k8s "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	typedv1 "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -45,6 +46,7 @@ import (
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress/controller"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/net/ssl"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/crl"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 )
@@ -63,6 +65,10 @@ type k8scache struct {
 	acmeSecretKeyName      string
 	acmeTokenConfigmapName string
 	//
+	crlUpdater crl.Updater
+	crlMutex   sync.Mutex
+	crlSecrets map[string]string
+	//
 	updateQueue      utils.Queue
 	stateMutex       sync.RWMutex
 	waitBeforeUpdate time.Duration
@@ -85,6 +91,7 @@ type k8scache struct {
 	secretsUpd   []*api.Secret
 	secretsAdd   []*api.Secret
 	podsNew      []*api.Pod
+	namespaces   []*api.Namespace
 	//
 }
 
@@ -97,6 +104,7 @@ func createCache(
 	watchNamespace string,
 	isolateNamespace bool,
 	disablePodList bool,
+	enableNamespaceList bool,
 	resync time.Duration,
 	waitBeforeUpdate time.Duration,
 ) *k8scache {
@@ -142,11 +150,12 @@ func createCache(
 		stateMutex:             sync.RWMutex{},
 		updateQueue:            updateQueue,
 		waitBeforeUpdate:       waitBeforeUpdate,
+		crlSecrets:             map[string]string{},
 		clear:                  true,
 		needFullSync:           false,
 	}
 	// TODO I'm a circular reference, can you fix me?
-	cache.listers = createListers(cache, logger, recorder, client, watchNamespace, isolateNamespace, !disablePodList, resync)
+	cache.listers = createListers(cache, logger, recorder, client, watchNamespace, isolateNamespace, !disablePodList, enableNamespaceList, resync)
 	return cache
 }
 
@@ -202,6 +211,10 @@ func (c *k8scache) GetService(serviceName string) (*api.Service, error) {
 	return c.listers.serviceLister.Services(namespace).Get(name)
 }
 
+func (c *k8scache) GetServiceList() ([]*api.Service, error) {
+	return c.listers.serviceLister.List(labels.Everything())
+}
+
 func (c *k8scache) GetSecret(secretName string) (*api.Secret, error) {
 	namespace, name, err := cache.SplitMetaNamespaceKey(secretName)
 	if err != nil {
@@ -284,6 +297,13 @@ func (c *k8scache) GetPod(podName string) (*api.Pod, error) {
 	return c.client.CoreV1().Pods(namespace).Get(c.ctx, name, metav1.GetOptions{})
 }
 
+func (c *k8scache) GetNamespace(name string) (*api.Namespace, error) {
+	if !c.listers.hasNamespaceLister {
+		return nil, fmt.Errorf("namespaces are not watched")
+	}
+	return c.listers.namespaceLister.Get(name)
+}
+
 func (c *k8scache) buildSecretName(defaultNamespace, secretName string) (string, string, error) {
 	ns, name, err := cache.SplitMetaNamespaceKey(secretName)
 	if err != nil {
@@ -357,6 +377,50 @@ func (c *k8scache) GetCASecretPath(defaultNamespace, secretName string, track co
 	return ca, crl, nil
 }
 
+func (c *k8scache) GetRefreshedCRLPath(defaultNamespace, secretName string, refresh time.Duration) (crlFile convtypes.File, err error) {
+	namespace, name, err := c.buildSecretName(defaultNamespace, secretName)
+	if err != nil {
+		return crlFile, err
+	}
+	sslCert, err := c.controller.GetCertificate(namespace, name)
+	if err != nil {
+		return crlFile, err
+	}
+	if sslCert.CAFileName == "" {
+		return crlFile, fmt.Errorf("secret '%s/%s' does not have key 'ca.crt'", namespace, name)
+	}
+	c.crlMutex.Lock()
+	c.crlSecrets[sslCert.CAFileName] = namespace + "/" + name
+	c.crlMutex.Unlock()
+	filename, hash, found := c.crlUpdater.CRLFile(sslCert.CAFileName, refresh)
+	if !found {
+		return crlFile, fmt.Errorf("CRL of secret '%s/%s' was not downloaded yet", namespace, name)
+	}
+	crlFile = convtypes.File{
+		Filename: filename,
+		SHA1Hash: hash,
+	}
+	return crlFile, nil
+}
+
+// NotifyCRLUpdate enqueues the secret of a CA file as changed, so the hosts that
+// use it are parsed again and read the new hash of its refreshed CRL file.
+func (c *k8scache) NotifyCRLUpdate(caFile string) {
+	c.crlMutex.Lock()
+	secretName, found := c.crlSecrets[caFile]
+	c.crlMutex.Unlock()
+	if !found {
+		return
+	}
+	namespace, name, err := cache.SplitMetaNamespaceKey(secretName)
+	if err != nil {
+		return
+	}
+	if secret, err := c.listers.secretLister.Secrets(namespace).Get(name); err == nil {
+		c.Notify(secret, secret)
+	}
+}
+
 func (c *k8scache) GetDHSecretPath(defaultNamespace, secretName string) (file convtypes.File, err error) {
 	namespace, name, err := c.buildSecretName(defaultNamespace, secretName)
 	if err != nil {
@@ -443,6 +507,15 @@ func (c *k8scache) GetKey() (crypto.Signer, error) {
 	return key, nil
 }
 
+// Implements acme.DNSResolver
+func (c *k8scache) GetDNSProviderConfig(secretName string) (map[string][]byte, error) {
+	secret, err := c.GetSecret(secretName)
+	if err != nil {
+		return nil, err
+	}
+	return secret.Data, nil
+}
+
 // Implements acme.SignerResolver
 func (c *k8scache) GetTLSSecretContent(secretName string) *acme.TLSSecret {
 	secret, err := c.GetSecret(secretName)
@@ -460,7 +533,7 @@ func (c *k8scache) GetTLSSecretContent(secretName string) *acme.TLSSecret {
 		return nil
 	}
 	crt, errCrt := x509.ParseCertificate(derCrt.Bytes)
-	key, errKey := x509.ParsePKCS1PrivateKey(derKey.Bytes)
+	key, errKey := parsePrivateKey(derKey.Bytes)
 	if errCrt != nil || errKey != nil {
 		return nil
 	}
@@ -470,6 +543,23 @@ func (c *k8scache) GetTLSSecretContent(secretName string) *acme.TLSSecret {
 	}
 }
 
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	if signer, ok := key.(crypto.Signer); ok {
+		return signer, nil
+	}
+	return nil, fmt.Errorf("unsupported private key type")
+}
+
 // Implements acme.SignerResolver
 func (c *k8scache) SetTLSSecretContent(secretName string, pemCrt, pemKey []byte) error {
 	namespace, name, err := cache.SplitMetaNamespaceKey(secretName)
@@ -480,6 +570,7 @@ func (c *k8scache) SetTLSSecretContent(secretName string, pemCrt, pemKey []byte)
 	secret.Namespace = namespace
 	secret.Name = name
 	secret.Type = api.SecretTypeTLS
+	secret.Labels = map[string]string{acme.SignedLabel: "true"}
 	secret.Data = map[string][]byte{
 		api.TLSCertKey:       pemCrt,
 		api.TLSPrivateKeyKey: pemKey,
@@ -487,6 +578,132 @@ func (c *k8scache) SetTLSSecretContent(secretName string, pemCrt, pemKey []byte)
 	return c.CreateOrUpdateSecret(secret)
 }
 
+// Implements acme.SignerResolver
+func (c *k8scache) DeleteTLSSecret(secretName string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(secretName)
+	if err != nil {
+		return err
+	}
+	return c.client.CoreV1().Secrets(namespace).Delete(c.ctx, name, metav1.DeleteOptions{})
+}
+
+// Implements acme.SignerResolver
+func (c *k8scache) LabelTLSSecret(secretName string, newLabels map[string]string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(secretName)
+	if err != nil {
+		return err
+	}
+	secret, err := c.listers.secretLister.Secrets(namespace).Get(name)
+	if err != nil {
+		return err
+	}
+	secret = secret.DeepCopy()
+	if secret.Labels == nil {
+		secret.Labels = map[string]string{}
+	}
+	for k, v := range newLabels {
+		secret.Labels[k] = v
+	}
+	_, err = c.client.CoreV1().Secrets(namespace).Update(c.ctx, secret, metav1.UpdateOptions{})
+	return err
+}
+
+// Implements acme.SignerResolver
+func (c *k8scache) GetSignedTLSSecrets() []string {
+	secretList, err := c.listers.secretLister.List(labels.SelectorFromSet(labels.Set{acme.SignedLabel: "true"}))
+	if err != nil {
+		c.listers.logger.Warn("error listing acme signed secrets: %v", err)
+		return nil
+	}
+	names := make([]string, 0, len(secretList))
+	for _, secret := range secretList {
+		if secret.Labels[acme.OrphanLabel] != "true" {
+			names = append(names, secret.Namespace+"/"+secret.Name)
+		}
+	}
+	return names
+}
+
+// Implements acme.SignerResolver
+func (c *k8scache) IsTLSSecretReferenced(secretName string) bool {
+	namespace, name, err := cache.SplitMetaNamespaceKey(secretName)
+	if err != nil {
+		return false
+	}
+	ingList, err := c.listers.ingressLister.Ingresses(namespace).List(labels.Everything())
+	if err != nil {
+		// cannot confirm, so the secret is preserved
+		return true
+	}
+	for _, ing := range ingList {
+		for _, tls := range ing.Spec.TLS {
+			if tls.SecretName == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Implements acme.SignerResolver
+func (c *k8scache) RecordSecretEvent(secretName string, warning bool, reason, message string) {
+	namespace, name, err := cache.SplitMetaNamespaceKey(secretName)
+	if err != nil {
+		return
+	}
+	ingList, err := c.listers.ingressLister.Ingresses(namespace).List(labels.Everything())
+	if err != nil {
+		return
+	}
+	eventType := api.EventTypeNormal
+	if warning {
+		eventType = api.EventTypeWarning
+	}
+	for _, ing := range ingList {
+		if !c.IsValidIngress(ing) {
+			continue
+		}
+		for _, tls := range ing.Spec.TLS {
+			if tls.SecretName == name {
+				c.listers.recorder.Event(ing, eventType, reason, message)
+				break
+			}
+		}
+	}
+}
+
+// implements diagnostics.Recorder
+func (c *k8scache) RecordObjectEvent(rtype convtypes.ResourceType, name string, warning bool, reason, message string) {
+	var obj runtime.Object
+	switch rtype {
+	case convtypes.IngressType:
+		ing, err := c.GetIngress(name)
+		if err != nil {
+			return
+		}
+		obj = ing
+	case convtypes.ServiceType:
+		svc, err := c.GetService(name)
+		if err != nil {
+			return
+		}
+		obj = svc
+	case convtypes.NamespaceType:
+		ns, err := c.GetNamespace(name)
+		if err != nil {
+			return
+		}
+		obj = ns
+	default:
+		return
+	}
+	eventType := api.EventTypeNormal
+	if warning {
+		eventType = api.EventTypeWarning
+	}
+	c.listers.recorder.Event(obj, eventType, reason, message)
+}
+
 // Implements acme.ServerResolver
 func (c *k8scache) GetToken(domain, uri string) string {
 	config, err := c.GetConfigMap(c.acmeTokenConfigmapName)
@@ -622,6 +839,8 @@ func (c *k8scache) Notify(old, cur interface{}) {
 			}
 		case *api.Pod:
 			c.podsNew = append(c.podsNew, cur.(*api.Pod))
+		case *api.Namespace:
+			c.namespaces = append(c.namespaces, cur.(*api.Namespace))
 		}
 	}
 	if old == nil && cur == nil {
@@ -680,6 +899,9 @@ func (c *k8scache) SwapChangedObjects() *convtypes.ChangedObjects {
 	for _, pod := range c.podsNew {
 		obj = append(obj, "update/pod:"+pod.Namespace+"/"+pod.Name)
 	}
+	for _, ns := range c.namespaces {
+		obj = append(obj, "update/namespace:"+ns.Name)
+	}
 	//
 	changed := &convtypes.ChangedObjects{
 		GlobalCur:       c.globalConfigMapData,
@@ -697,11 +919,13 @@ func (c *k8scache) SwapChangedObjects() *convtypes.ChangedObjects {
 		SecretsUpd:      c.secretsUpd,
 		SecretsAdd:      c.secretsAdd,
 		Pods:            c.podsNew,
+		Namespaces:      c.namespaces,
 		Objects:         obj,
 	}
 	//
 	c.podsNew = nil
 	c.endpointsNew = nil
+	c.namespaces = nil
 	//
 	// Secrets
 	//
@@ -743,3 +967,14 @@ func (c *k8scache) NeedFullSync() bool {
 	defer c.stateMutex.RUnlock()
 	return c.needFullSync
 }
+
+// GlobalConfig returns the most recent data of the global configmap,
+// including changes not applied yet.
+func (c *k8scache) GlobalConfig() map[string]string {
+	c.stateMutex.RLock()
+	defer c.stateMutex.RUnlock()
+	if c.globalConfigMapDataNew != nil {
+		return c.globalConfigMapDataNew
+	}
+	return c.globalConfigMapData
+}
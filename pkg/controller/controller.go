@@ -18,8 +18,11 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
@@ -37,12 +40,23 @@ import (
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/tracker"
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/crl"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/diagnostics"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/explain"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/grpchealth"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/introspect"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/ocsp"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/version"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/webhook"
 )
 
+// defaultGRPCHealthAgent is the address of the gRPC health agent if not
+// configured, the agent only listens if a backend uses gRPC health check
+const defaultGRPCHealthAgent = "127.0.0.1:10260"
+
 // HAProxyController has internal data of a HAProxyController instance
 type HAProxyController struct {
 	instance          haproxy.Instance
@@ -53,8 +67,17 @@ type HAProxyController struct {
 	stopCh            chan struct{}
 	ingressQueue      utils.Queue
 	acmeQueue         utils.Queue
+	ocspStapler       ocsp.Stapler
+	ocspChecker       ocsp.Checker
+	crlUpdater        crl.Updater
+	grpcHealthAgent   grpchealth.Agent
+	diagnostics       diagnostics.Collector
+	explainer         explain.Store
+	introspector      http.Handler
 	leaderelector     types.LeaderElector
+	updateMutex       sync.Mutex
 	updateCount       int
+	lastSync          *introspect.Sync
 	controller        *controller.GenericController
 	cfg               *controller.Configuration
 	configMap         *api.ConfigMap
@@ -62,6 +85,7 @@ type HAProxyController struct {
 	reloadStrategy    *string
 	maxOldConfigFiles *int
 	validateConfig    *bool
+	logFormat         *string
 }
 
 // NewHAProxyController constructor
@@ -98,7 +122,7 @@ func (hc *HAProxyController) configController() {
 	hc.cfg = hc.controller.GetConfig()
 	hc.stopCh = hc.controller.GetStopCh()
 	hc.controller.SetNewCtrl(hc)
-	hc.logger = &logger{depth: 1}
+	hc.logger = newLogger(*hc.logFormat)
 	hc.metrics = createMetrics(hc.cfg.BucketsResponseTime)
 	hc.ingressQueue = utils.NewRateLimitingQueue(hc.cfg.RateLimitUpdate, hc.syncIngress)
 	hc.tracker = tracker.NewTracker()
@@ -106,6 +130,7 @@ func (hc *HAProxyController) configController() {
 		hc.logger, hc.cfg.Client, hc.controller, hc.tracker, hc.ingressQueue,
 		hc.cfg.WatchNamespace, hc.cfg.ForceNamespaceIsolation,
 		hc.cfg.DisablePodList,
+		hc.cfg.EnableNamespaceList,
 		hc.cfg.ResyncPeriod,
 		hc.cfg.WaitBeforeUpdate,
 	)
@@ -119,19 +144,36 @@ func (hc *HAProxyController) configController() {
 			hc.cfg.AcmeFailMaxDuration,
 			acmeSigner.Notify,
 		)
+		acmeSigner.AcmeScheduler(hc.acmeQueue, hc.cfg.AcmeCheckPeriod)
+	}
+	hc.ocspStapler = ocsp.NewStapler(hc.logger, hc.metrics)
+	hc.ocspChecker = ocsp.NewChecker(hc.logger, "/var/run/haproxy/ocsp.sock")
+	hc.crlUpdater = crl.NewUpdater(hc.logger, hc.metrics, hc.cache.NotifyCRLUpdate)
+	hc.cache.crlUpdater = hc.crlUpdater
+	grpcHealthAgentAddr := hc.cfg.GRPCHealthAgent
+	if grpcHealthAgentAddr == "" {
+		grpcHealthAgentAddr = defaultGRPCHealthAgent
+	} else if _, port, err := net.SplitHostPort(grpcHealthAgentAddr); err != nil || port == "" {
+		glog.Fatalf("invalid grpc health agent address '%s', expected <ip>:<port>", grpcHealthAgentAddr)
 	}
+	hc.grpcHealthAgent = grpchealth.NewAgent(hc.logger, grpcHealthAgentAddr, hc.cfg.GRPCHealthAgent == "")
 	instanceOptions := haproxy.InstanceOptions{
 		HAProxyCmd:        "haproxy",
 		ReloadCmd:         "/haproxy-reload.sh",
 		HAProxyCfgDir:     "/etc/haproxy",
 		HAProxyMapsDir:    ingress.DefaultMapsDirectory,
 		BackendShards:     hc.cfg.BackendShards,
+		CRLUpdater:        hc.crlUpdater,
+		GRPCHealthAgent:   hc.grpcHealthAgent,
 		AcmeSigner:        acmeSigner,
 		AcmeQueue:         hc.acmeQueue,
 		LeaderElector:     hc.leaderelector,
 		Metrics:           hc.metrics,
+		OCSPChecker:       hc.ocspChecker,
+		OCSPStapler:       hc.ocspStapler,
 		ReloadStrategy:    *hc.reloadStrategy,
 		MaxOldConfigFiles: *hc.maxOldConfigFiles,
+		StatsMaxObjects:   hc.cfg.StatsExporterMaxObjects,
 		ValidateConfig:    *hc.validateConfig,
 	}
 	hc.instance = haproxy.CreateInstance(hc.logger, instanceOptions)
@@ -148,6 +190,18 @@ func (hc *HAProxyController) configController() {
 		FakeCrtFile:      hc.createFakeCrtFile(),
 		FakeCAFile:       hc.createFakeCAFile(),
 		AcmeTrackTLSAnn:  hc.cfg.AcmeTrackTLSAnn,
+		GRPCHealthAgent:  grpcHealthAgentAddr,
+	}
+	if hc.cfg.DiagnosticEventsInterval > 0 {
+		hc.diagnostics = diagnostics.NewCollector(hc.cache, hc.cfg.DiagnosticEventsInterval)
+		hc.converterOptions.Diagnostics = hc.diagnostics
+	}
+	if hc.cfg.Explain {
+		hc.explainer = explain.NewStore()
+		hc.converterOptions.Explainer = hc.explainer
+	}
+	if hc.cfg.Introspection {
+		hc.introspector = introspect.NewHandler(hc.readIntrospection)
 	}
 }
 
@@ -159,20 +213,50 @@ func (hc *HAProxyController) startServices() {
 			hc.instance.CalcIdleMetric()
 		}, hc.cfg.StatsCollectProcPeriod, hc.stopCh)
 	}
+	if hc.cfg.StatsExporterPeriod > 0 {
+		go wait.Until(hc.collectStats, hc.cfg.StatsExporterPeriod, hc.stopCh)
+	}
+	// the stapler only fetches the responses that need to be refreshed
+	go wait.Until(hc.ocspStapler.Refresh, time.Minute, hc.stopCh)
+	go wait.Until(hc.crlUpdater.Refresh, time.Minute, hc.stopCh)
+	if err := hc.ocspChecker.Listen(hc.stopCh); err != nil {
+		hc.logger.Error("error creating the ocsp checker listener: %v", err)
+	}
+	if err := hc.grpcHealthAgent.Listen(hc.stopCh); err != nil {
+		hc.logger.Error("error creating the grpc health agent listener: %v", err)
+	}
+	if hc.cfg.ValidatingWebhook != "" {
+		validator := ingressconverter.NewIngressValidator(hc.converterOptions, hc.cache.GlobalConfig)
+		server := webhook.NewServer(hc.logger, hc.cfg.ValidatingWebhook, hc.cfg.ValidatingWebhookCert, hc.cfg.ValidatingWebhookKey,
+			func(ing *networking.Ingress) []string {
+				if !hc.cache.IsValidIngress(ing) {
+					return nil
+				}
+				return validator.Validate(ing)
+			})
+		if err := server.Listen(hc.stopCh); err != nil {
+			hc.logger.Fatal("error creating the validating webhook listener: %v", err)
+		}
+	}
 	if hc.leaderelector != nil {
 		go hc.leaderelector.Run(hc.stopCh)
 	}
 	if hc.cfg.AcmeServer {
 		// TODO deduplicate acme socket
-		server := acme.NewServer(hc.logger, "/var/run/haproxy/acme.sock", hc.cache)
+		server := acme.NewServer(hc.logger, "/var/run/haproxy/acme.sock", "/var/run/haproxy/acme-tls.sock", hc.cache)
 		// TODO move goroutine from the server to the controller
 		if err := server.Listen(hc.stopCh); err != nil {
 			hc.logger.Fatal("error creating the acme server listener: %v", err)
 		}
 		go hc.acmeQueue.Run()
-		go wait.JitterUntil(func() {
-			_, _ = hc.instance.AcmeCheck("periodic check")
-		}, hc.cfg.AcmeCheckPeriod, 0, false, hc.stopCh)
+		// certificates schedule their own next check when due sooner than
+		// the check period, this periodic check of all of them is a safety
+		// net for the ones whose schedule was lost, e.g. on failures.
+		if hc.cfg.AcmeCheckPeriod > 0 {
+			go wait.JitterUntil(func() {
+				_, _ = hc.instance.AcmeCheck("periodic check")
+			}, hc.cfg.AcmeCheckPeriod, 0, false, hc.stopCh)
+		}
 	}
 	hc.controller.StartAsync()
 }
@@ -212,10 +296,58 @@ func (hc *HAProxyController) AcmeCheck() (int, error) {
 	return hc.instance.AcmeCheck("external call")
 }
 
+// Explain ...
+func (hc *HAProxyController) Explain(w http.ResponseWriter, r *http.Request) {
+	if hc.explainer == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	hc.explainer.ServeHTTP(w, r)
+}
+
+// Introspect ...
+func (hc *HAProxyController) Introspect(w http.ResponseWriter, r *http.Request) {
+	if hc.introspector == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	hc.introspector.ServeHTTP(w, r)
+}
+
+// Reloads ...
+func (hc *HAProxyController) Reloads(w http.ResponseWriter, r *http.Request) {
+	if hc.instance == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(hc.instance.Reloads())
+}
+
+// collectStats reads the haproxy stats, the update lock prevents a
+// concurrent sync from changing the model while the stats are labeled.
+func (hc *HAProxyController) collectStats() {
+	hc.instance.CollectStats(&hc.updateMutex)
+}
+
+// readIntrospection calls fn with the current haproxy model, preventing
+// a concurrent sync from changing it while fn runs.
+func (hc *HAProxyController) readIntrospection(fn func(config haproxy.Config, sync *introspect.Sync)) {
+	hc.updateMutex.Lock()
+	defer hc.updateMutex.Unlock()
+	fn(hc.instance.Config(), hc.lastSync)
+}
+
 // OnStartedLeading ...
 // implements LeaderSubscriber
 func (hc *HAProxyController) OnStartedLeading(ctx context.Context) {
-	_, _ = hc.instance.AcmeCheck("started leading")
+	// retry until it succeeds, e.g. if the acme server is not reachable yet
+	_ = wait.PollImmediateUntil(hc.cfg.AcmeFailInitialDuration, func() (bool, error) {
+		_, err := hc.instance.AcmeCheck("started leading")
+		return err == nil, nil
+	}, ctx.Done())
 }
 
 // OnStoppedLeading ...
@@ -283,6 +415,8 @@ func (hc *HAProxyController) ConfigureFlags(flags *pflag.FlagSet) {
 		`Maximum old haproxy timestamped config files to allow before being cleaned up. A value <= 0 indicates a single non-timestamped config file will be used`)
 	hc.validateConfig = flags.Bool("validate-config", false,
 		`Define if the resulting configuration files should be validated when a dynamic update was applied. Default value is false, which means the validation will only happen when HAProxy need to be reloaded.`)
+	hc.logFormat = flags.String("log-format", "text",
+		`Format of the controller logs. Options are: text (default), which uses glog, or json, which writes one JSON object per line with contextual fields like namespace, ingress, host, backend, server and sync id`)
 	ingressClass := flags.Lookup("ingress-class")
 	if ingressClass != nil {
 		ingressClass.Value.Set("haproxy")
@@ -295,6 +429,9 @@ func (hc *HAProxyController) OverrideFlags(flags *pflag.FlagSet) {
 	if !(*hc.reloadStrategy == "native" || *hc.reloadStrategy == "reusesocket" || *hc.reloadStrategy == "multibinder") {
 		glog.Fatalf("Unsupported reload strategy: %v", *hc.reloadStrategy)
 	}
+	if !(*hc.logFormat == "text" || *hc.logFormat == "json") {
+		glog.Fatalf("Unsupported log format: %v", *hc.logFormat)
+	}
 }
 
 // SetConfig receives the ConfigMap the user has configured
@@ -308,10 +445,15 @@ func (hc *HAProxyController) syncIngress(item interface{}) {
 		return
 	}
 
+	hc.updateMutex.Lock()
+	defer hc.updateMutex.Unlock()
+
 	//
 	// ingress converter
 	//
 	hc.updateCount++
+	hc.logger.setSyncID(hc.updateCount)
+	defer hc.logger.setSyncID(0)
 	hc.logger.Info("starting HAProxy update id=%d", hc.updateCount)
 	timer := utils.NewTimer(hc.metrics.ControllerProcTime)
 	ingConverter := ingressconverter.NewIngressConverter(
@@ -319,6 +461,14 @@ func (hc *HAProxyController) syncIngress(item interface{}) {
 		hc.instance.Config(),
 	)
 	ingConverter.Sync()
+	hc.lastSync = &introspect.Sync{
+		ID:             hc.updateCount,
+		FullSync:       ingConverter.NeedFullSync(),
+		ChangedObjects: ingConverter.ChangedObjects().Objects,
+	}
+	if hc.diagnostics != nil {
+		hc.diagnostics.Publish()
+	}
 	timer.Tick("parse_ingress")
 
 	//
@@ -344,5 +494,6 @@ func (hc *HAProxyController) syncIngress(item interface{}) {
 	// update proxy
 	//
 	hc.instance.Update(timer)
+	hc.lastSync.Commands = hc.instance.DynUpdateCommands()
 	hc.logger.Info("finish HAProxy update id=%d: %s", hc.updateCount, timer.AsString("total"))
 }
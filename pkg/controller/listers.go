@@ -50,8 +50,9 @@ type listers struct {
 	recorder record.EventRecorder
 	running  bool
 	//
-	hasPodLister  bool
-	hasNodeLister bool
+	hasPodLister       bool
+	hasNodeLister      bool
+	hasNamespaceLister bool
 	//
 	ingressLister   listersv1beta1.IngressLister
 	endpointLister  listersv1.EndpointsLister
@@ -60,6 +61,7 @@ type listers struct {
 	configMapLister listersv1.ConfigMapLister
 	podLister       listersv1.PodLister
 	nodeLister      listersv1.NodeLister
+	namespaceLister listersv1.NamespaceLister
 	//
 	ingressInformer   cache.SharedInformer
 	endpointInformer  cache.SharedInformer
@@ -68,6 +70,7 @@ type listers struct {
 	configMapInformer cache.SharedInformer
 	podInformer       cache.SharedInformer
 	nodeInformer      cache.SharedInformer
+	namespaceInformer cache.SharedInformer
 }
 
 func createListers(
@@ -78,6 +81,7 @@ func createListers(
 	watchNamespace string,
 	isolateNamespace bool,
 	podWatch bool,
+	namespaceWatch bool,
 	resync time.Duration,
 ) *listers {
 	clusterWatch := watchNamespace == api.NamespaceAll
@@ -94,7 +98,7 @@ func createListers(
 		ingressInformer = informers.NewSharedInformerFactoryWithOptions(client, resync, namespaceOption)
 		resourceInformer = informers.NewSharedInformerFactoryWithOptions(client, resync, clusterOption)
 	}
-	if !podWatch || !clusterWatch {
+	if !podWatch || !clusterWatch || !namespaceWatch {
 		localInformer = informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
 	}
 	l := &listers{
@@ -120,6 +124,16 @@ func createListers(
 	} else {
 		l.createNodeLister(localInformer.Core().V1().Nodes())
 	}
+	if clusterWatch && namespaceWatch {
+		// namespaces are cluster scoped, the annotations of the
+		// namespace are ignored if watching a single namespace.
+		// Opt-in, so clusters whose role doesn't allow to list
+		// namespaces don't wait forever on the cache sync
+		l.createNamespaceLister(resourceInformer.Core().V1().Namespaces())
+		l.hasNamespaceLister = true
+	} else {
+		l.createNamespaceLister(localInformer.Core().V1().Namespaces())
+	}
 	return l
 }
 
@@ -131,6 +145,7 @@ func (l *listers) RunAsync(stopCh <-chan struct{}) {
 	go l.configMapInformer.Run(stopCh)
 	go l.podInformer.Run(stopCh)
 	go l.nodeInformer.Run(stopCh)
+	go l.namespaceInformer.Run(stopCh)
 	l.logger.Info("loading object cache...")
 	synced := cache.WaitForCacheSync(stopCh,
 		l.ingressInformer.HasSynced,
@@ -140,6 +155,7 @@ func (l *listers) RunAsync(stopCh <-chan struct{}) {
 		l.configMapInformer.HasSynced,
 		l.podInformer.HasSynced,
 		l.nodeInformer.HasSynced,
+		l.namespaceInformer.HasSynced,
 	)
 	if synced {
 		l.logger.Info("cache successfully synced")
@@ -328,3 +344,20 @@ func (l *listers) createNodeLister(informer informersv1.NodeInformer) {
 	l.nodeLister = informer.Lister()
 	l.nodeInformer = informer.Informer()
 }
+
+func (l *listers) createNamespaceLister(informer informersv1.NamespaceInformer) {
+	l.namespaceLister = informer.Lister()
+	l.namespaceInformer = informer.Informer()
+	l.namespaceInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(old, cur interface{}) {
+			// annotations are used as default values of ingress and services,
+			// and labels select the rules of the annotation policy
+			oldNs := old.(*api.Namespace)
+			curNs := cur.(*api.Namespace)
+			if !reflect.DeepEqual(oldNs.Annotations, curNs.Annotations) ||
+				!reflect.DeepEqual(oldNs.Labels, curNs.Labels) {
+				l.events.Notify(oldNs, curNs)
+			}
+		},
+	})
+}
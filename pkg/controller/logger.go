@@ -17,13 +17,42 @@ limitations under the License.
 package controller
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/golang/glog"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 )
 
 type logger struct {
 	depth int
+	// json output, glog is used if nil
+	json   io.Writer
+	mutex  sync.Mutex
+	syncID int64
+	now    func() time.Time
+}
+
+func newLogger(format string) *logger {
+	l := &logger{depth: 1}
+	if format == "json" {
+		l.json = os.Stderr
+		l.now = time.Now
+	}
+	return l
+}
+
+// setSyncID configures the id of the running sync, which is added to the
+// messages of structured logs. Use zero if a sync is not running.
+func (l *logger) setSyncID(id int) {
+	atomic.StoreInt64(&l.syncID, int64(id))
 }
 
 func (l *logger) build(msg string, args []interface{}) string {
@@ -35,22 +64,88 @@ func (l *logger) build(msg string, args []interface{}) string {
 
 func (l *logger) InfoV(v int, msg string, args ...interface{}) {
 	if glog.V(glog.Level(v)) {
+		if l.json != nil {
+			l.writeJSON("info", msg, args)
+			return
+		}
 		glog.InfoDepth(l.depth, l.build(msg, args))
 	}
 }
 
 func (l *logger) Info(msg string, args ...interface{}) {
+	if l.json != nil {
+		l.writeJSON("info", msg, args)
+		return
+	}
 	glog.InfoDepth(l.depth, l.build(msg, args))
 }
 
 func (l *logger) Warn(msg string, args ...interface{}) {
+	if l.json != nil {
+		l.writeJSON("warning", msg, args)
+		return
+	}
 	glog.WarningDepth(l.depth, l.build(msg, args))
 }
 
 func (l *logger) Error(msg string, args ...interface{}) {
+	if l.json != nil {
+		l.writeJSON("error", msg, args)
+		return
+	}
 	glog.ErrorDepth(l.depth, l.build(msg, args))
 }
 
 func (l *logger) Fatal(msg string, args ...interface{}) {
+	if l.json != nil {
+		l.writeJSON("fatal", msg, args)
+		// same exit code of glog.Fatal()
+		os.Exit(255)
+	}
 	glog.FatalDepth(l.depth, l.build(msg, args))
 }
+
+// writeJSON writes one JSON object per line. Arguments implementing
+// types.LogFielder are added as fields, the first declaration of a key
+// wins. Fields are written in a stable order: ts, level, msg, sync, and
+// then the fields in the order of the arguments.
+func (l *logger) writeJSON(level, msg string, args []interface{}) {
+	out := &bytes.Buffer{}
+	// Encode() writes a trailing newline, which is removed
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	keys := map[string]bool{}
+	writeField := func(key string, value interface{}) {
+		if keys[key] {
+			return
+		}
+		keys[key] = true
+		if out.Len() == 0 {
+			out.WriteString("{")
+		} else {
+			out.WriteString(",")
+		}
+		_ = enc.Encode(key)
+		out.Truncate(out.Len() - 1)
+		out.WriteString(":")
+		_ = enc.Encode(value)
+		out.Truncate(out.Len() - 1)
+	}
+	writeField("ts", l.now().UTC().Format(time.RFC3339Nano))
+	writeField("level", level)
+	writeField("msg", l.build(msg, args))
+	if id := atomic.LoadInt64(&l.syncID); id > 0 {
+		writeField("sync", id)
+	}
+	for _, arg := range args {
+		if fielder, ok := arg.(types.LogFielder); ok {
+			for _, field := range fielder.LogFields() {
+				writeField(field.Key, field.Value)
+			}
+		}
+	}
+	out.WriteString("}\n")
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	_, _ = l.json.Write(out.Bytes())
+}
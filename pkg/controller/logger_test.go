@@ -0,0 +1,105 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+type sourceMock struct {
+	fullName string
+}
+
+func (s *sourceMock) String() string {
+	return "ingress '" + s.fullName + "'"
+}
+
+func (s *sourceMock) LogFields() []types.LogField {
+	if s == nil {
+		return nil
+	}
+	return types.LogIngress(s.fullName).LogFields()
+}
+
+func TestLoggerJSON(t *testing.T) {
+	testCases := []struct {
+		syncID int
+		log    func(l *logger)
+		exp    string
+	}{
+		// 0
+		{
+			log: func(l *logger) {
+				l.Info("HAProxy successfully reloaded")
+			},
+			exp: `{"ts":"2020-10-18T12:00:00Z","level":"info","msg":"HAProxy successfully reloaded"}`,
+		},
+		// 1
+		{
+			syncID: 5,
+			log: func(l *logger) {
+				l.InfoV(0, "added endpoint '%s' weight '%d' state '%s' on backend/server '%s/%s'",
+					"172.17.0.11:8080", 1, "ready", types.LogBackend("default_app_8080"), types.LogServer("srv001"))
+			},
+			exp: `{"ts":"2020-10-18T12:00:00Z","level":"info","msg":"added endpoint '172.17.0.11:8080' weight '1' state 'ready' on backend/server 'default_app_8080/srv001'","sync":5,"backend":"default_app_8080","server":"srv001"}`,
+		},
+		// 2
+		{
+			syncID: 6,
+			log: func(l *logger) {
+				l.Warn("skipping hostname '%s' of %v: hostname is owned by namespace '%s'",
+					types.LogHost("domain.local"), &sourceMock{fullName: "default/app"}, "team1")
+			},
+			exp: `{"ts":"2020-10-18T12:00:00Z","level":"warning","msg":"skipping hostname 'domain.local' of ingress 'default/app': hostname is owned by namespace 'team1'","sync":6,"host":"domain.local","namespace":"default","ingress":"app"}`,
+		},
+		// 3
+		{
+			log: func(l *logger) {
+				var source *sourceMock
+				l.Warn("ignoring invalid naming type '%s' on %v", "x", source)
+			},
+			exp: `{"ts":"2020-10-18T12:00:00Z","level":"warning","msg":"ignoring invalid naming type 'x' on <nil>"}`,
+		},
+		// 4
+		{
+			log: func(l *logger) {
+				l.Error("error adding endpoints of service '%s': %v \"%s\"", types.LogService("default/echo"), "not found", types.LogNamespace("other"))
+			},
+			exp: `{"ts":"2020-10-18T12:00:00Z","level":"error","msg":"error adding endpoints of service 'default/echo': not found \"other\"","namespace":"default","service":"echo"}`,
+		},
+	}
+	for i, test := range testCases {
+		out := &bytes.Buffer{}
+		l := &logger{
+			json: out,
+			now: func() time.Time {
+				return time.Date(2020, 10, 18, 12, 0, 0, 0, time.UTC)
+			},
+		}
+		l.setSyncID(test.syncID)
+		test.log(l)
+		actual := strings.TrimSuffix(out.String(), "\n")
+		if actual != test.exp {
+			t.Errorf("output differs on %d - expected:\n%s\nactual:\n%s", i, test.exp, actual)
+		}
+	}
+}
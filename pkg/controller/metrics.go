@@ -18,9 +18,12 @@ package controller
 
 import (
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 )
 
 type metrics struct {
@@ -30,8 +33,13 @@ type metrics struct {
 	procSecondsCounter *prometheus.CounterVec
 	updatesCounter     *prometheus.CounterVec
 	updateSuccessGauge *prometheus.GaugeVec
+	reloadsCounter     *prometheus.CounterVec
+	reloadNsCounter    *prometheus.CounterVec
 	certExpireGauge    *prometheus.GaugeVec
 	certSigningCounter *prometheus.CounterVec
+	ocspNextUpdate     *prometheus.GaugeVec
+	crlNextUpdate      *prometheus.GaugeVec
+	stats              *statsCollector
 	lastTrack          time.Time
 }
 
@@ -87,6 +95,22 @@ func createMetrics(bucketsResponseTime []float64) *metrics {
 			},
 			[]string{},
 		),
+		reloadsCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "reloads_total",
+				Help:      "Cumulative number of haproxy reloads per reason. A reload can have more than one reason.",
+			},
+			[]string{"reason"},
+		),
+		reloadNsCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "reload_namespaces_total",
+				Help:      "Cumulative number of haproxy reloads per reason and namespace of the responsible objects.",
+			},
+			[]string{"reason", "namespace"},
+		),
 		certExpireGauge: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
@@ -103,6 +127,23 @@ func createMetrics(bucketsResponseTime []float64) *metrics {
 			},
 			[]string{"domains", "reason", "success"},
 		),
+		ocspNextUpdate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "ocsp_next_update_epoch",
+				Help:      "The next update of the stapled OCSP response of a certificate in unix epoch time.",
+			},
+			[]string{"crtfile"},
+		),
+		crlNextUpdate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "crl_next_update_epoch",
+				Help:      "The next update of the refreshed CRL of a CA file in unix epoch time.",
+			},
+			[]string{"cafile"},
+		),
+		stats: newStatsCollector(namespace),
 	}
 	prometheus.MustRegister(metrics.responseTime)
 	prometheus.MustRegister(metrics.ctlProcTimeSum)
@@ -110,8 +151,13 @@ func createMetrics(bucketsResponseTime []float64) *metrics {
 	prometheus.MustRegister(metrics.procSecondsCounter)
 	prometheus.MustRegister(metrics.updatesCounter)
 	prometheus.MustRegister(metrics.updateSuccessGauge)
+	prometheus.MustRegister(metrics.reloadsCounter)
+	prometheus.MustRegister(metrics.reloadNsCounter)
 	prometheus.MustRegister(metrics.certExpireGauge)
 	prometheus.MustRegister(metrics.certSigningCounter)
+	prometheus.MustRegister(metrics.ocspNextUpdate)
+	prometheus.MustRegister(metrics.crlNextUpdate)
+	prometheus.MustRegister(metrics.stats)
 	return metrics
 }
 
@@ -119,10 +165,22 @@ func (m *metrics) HAProxyShowInfoResponseTime(duration time.Duration) {
 	m.responseTime.WithLabelValues("show_info").Observe(duration.Seconds())
 }
 
+func (m *metrics) HAProxyShowStatResponseTime(duration time.Duration) {
+	m.responseTime.WithLabelValues("show_stat").Observe(duration.Seconds())
+}
+
 func (m *metrics) HAProxySetServerResponseTime(duration time.Duration) {
 	m.responseTime.WithLabelValues("set_server").Observe(duration.Seconds())
 }
 
+func (m *metrics) HAProxySetSSLOCSPResponseTime(duration time.Duration) {
+	m.responseTime.WithLabelValues("set_ssl_ocsp_response").Observe(duration.Seconds())
+}
+
+func (m *metrics) HAProxySetSSLCRLFileTime(duration time.Duration) {
+	m.responseTime.WithLabelValues("set_ssl_crl_file").Observe(duration.Seconds())
+}
+
 func (m *metrics) ControllerProcTime(task string, duration time.Duration) {
 	m.ctlProcTimeSum.WithLabelValues(task).Add(duration.Seconds())
 	m.ctlProcCount.WithLabelValues(task).Inc()
@@ -156,6 +214,14 @@ func (m *metrics) UpdateSuccessful(success bool) {
 	m.updateSuccessGauge.WithLabelValues().Set(value[success])
 }
 
+func (m *metrics) IncReloadReason(reason string) {
+	m.reloadsCounter.WithLabelValues(reason).Inc()
+}
+
+func (m *metrics) IncReloadNamespace(reason, namespace string) {
+	m.reloadNsCounter.WithLabelValues(reason, namespace).Inc()
+}
+
 func (m *metrics) SetCertExpireDate(domain, cn string, notAfter *time.Time) {
 	if notAfter == nil {
 		m.certExpireGauge.DeleteLabelValues(domain, cn)
@@ -175,3 +241,162 @@ func (m *metrics) IncCertSigningExpiring(domains string, success bool) {
 func (m *metrics) IncCertSigningOutdated(domains string, success bool) {
 	m.certSigningCounter.WithLabelValues(domains, "outdated", strconv.FormatBool(success)).Inc()
 }
+
+func (m *metrics) SetOCSPNextUpdate(crtFile string, nextUpdate *time.Time) {
+	if nextUpdate == nil {
+		m.ocspNextUpdate.DeleteLabelValues(crtFile)
+		return
+	}
+	m.ocspNextUpdate.WithLabelValues(crtFile).Set(float64(nextUpdate.Unix()))
+}
+
+func (m *metrics) SetCRLNextUpdate(caFile string, nextUpdate *time.Time) {
+	if nextUpdate == nil {
+		m.crlNextUpdate.DeleteLabelValues(caFile)
+		return
+	}
+	m.crlNextUpdate.WithLabelValues(caFile).Set(float64(nextUpdate.Unix()))
+}
+
+func (m *metrics) SetHAProxyStats(stats *types.HAProxyStats) {
+	m.stats.set(stats)
+}
+
+// statsCollector exports the last haproxy stats read from the admin
+// socket. The values are collected as constant metrics, so removed
+// backends, servers and paths don't leave stale series.
+type statsCollector struct {
+	mutex             sync.Mutex
+	stats             *types.HAProxyStats
+	backend           *statsCountersDesc
+	server            *statsCountersDesc
+	serverUp          *prometheus.Desc
+	pathInfo          *prometheus.Desc
+	processConns      *prometheus.Desc
+	processConnsTotal *prometheus.Desc
+	processReqsTotal  *prometheus.Desc
+	droppedObjects    *prometheus.Desc
+}
+
+type statsCountersDesc struct {
+	curSessions *prometheus.Desc
+	sessions    *prometheus.Desc
+	bytesIn     *prometheus.Desc
+	bytesOut    *prometheus.Desc
+	responses   *prometheus.Desc
+	rtime       *prometheus.Desc
+}
+
+func newStatsCollector(namespace string) *statsCollector {
+	backendLabels := []string{"backend", "namespace", "service", "port"}
+	serverLabels := []string{"backend", "namespace", "service", "port", "server", "pod"}
+	pathLabels := []string{"backend", "namespace", "service", "port", "ingress", "host", "path"}
+	name := func(name string) string {
+		return prometheus.BuildFQName(namespace, "stats", name)
+	}
+	return &statsCollector{
+		backend: newStatsCountersDesc(namespace, "backend", backendLabels),
+		server:  newStatsCountersDesc(namespace, "server", serverLabels),
+		serverUp: prometheus.NewDesc(name("server_up"),
+			"Whether the server is up or its health check is disabled.", serverLabels, nil),
+		pathInfo: prometheus.NewDesc(name("path_info"),
+			"Hosts and paths of a backend, and the ingress that declared them. Always 1.", pathLabels, nil),
+		processConns: prometheus.NewDesc(name("process_current_connections"),
+			"Number of active connections of the haproxy process.", nil, nil),
+		processConnsTotal: prometheus.NewDesc(name("process_connections_total"),
+			"Cumulative number of connections of the haproxy process.", nil, nil),
+		processReqsTotal: prometheus.NewDesc(name("process_requests_total"),
+			"Cumulative number of requests of the haproxy process.", nil, nil),
+		droppedObjects: prometheus.NewDesc(name("dropped_objects"),
+			"Number of backends, servers and paths not exported due to the configured limit.", nil, nil),
+	}
+}
+
+func newStatsCountersDesc(namespace, level string, labels []string) *statsCountersDesc {
+	name := func(name string) string {
+		return prometheus.BuildFQName(namespace, "stats", level+"_"+name)
+	}
+	return &statsCountersDesc{
+		curSessions: prometheus.NewDesc(name("current_sessions"),
+			"Number of active sessions of the "+level+".", labels, nil),
+		sessions: prometheus.NewDesc(name("sessions_total"),
+			"Cumulative number of sessions of the "+level+".", labels, nil),
+		bytesIn: prometheus.NewDesc(name("bytes_in_total"),
+			"Cumulative number of request bytes of the "+level+".", labels, nil),
+		bytesOut: prometheus.NewDesc(name("bytes_out_total"),
+			"Cumulative number of response bytes of the "+level+".", labels, nil),
+		responses: prometheus.NewDesc(name("http_responses_total"),
+			"Cumulative number of http responses of the "+level+" by status code class.",
+			append(append([]string{}, labels...), "code"), nil),
+		rtime: prometheus.NewDesc(name("response_time_average_seconds"),
+			"Average response time of the last 1024 requests of the "+level+".", labels, nil),
+	}
+}
+
+func (d *statsCountersDesc) describe(ch chan<- *prometheus.Desc) {
+	ch <- d.curSessions
+	ch <- d.sessions
+	ch <- d.bytesIn
+	ch <- d.bytesOut
+	ch <- d.responses
+	ch <- d.rtime
+}
+
+func (d *statsCountersDesc) collect(ch chan<- prometheus.Metric, counters *types.StatsCounters, labels ...string) {
+	ch <- prometheus.MustNewConstMetric(d.curSessions, prometheus.GaugeValue, float64(counters.CurrentSessions), labels...)
+	ch <- prometheus.MustNewConstMetric(d.sessions, prometheus.CounterValue, float64(counters.SessionsTotal), labels...)
+	ch <- prometheus.MustNewConstMetric(d.bytesIn, prometheus.CounterValue, float64(counters.BytesIn), labels...)
+	ch <- prometheus.MustNewConstMetric(d.bytesOut, prometheus.CounterValue, float64(counters.BytesOut), labels...)
+	for code, value := range counters.Responses {
+		codeLabels := append(append([]string{}, labels...), code)
+		ch <- prometheus.MustNewConstMetric(d.responses, prometheus.CounterValue, float64(value), codeLabels...)
+	}
+	ch <- prometheus.MustNewConstMetric(d.rtime, prometheus.GaugeValue, counters.ResponseTime.Seconds(), labels...)
+}
+
+func (c *statsCollector) set(stats *types.HAProxyStats) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats = stats
+}
+
+// Describe ...
+// implements prometheus.Collector
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.backend.describe(ch)
+	c.server.describe(ch)
+	ch <- c.serverUp
+	ch <- c.pathInfo
+	ch <- c.processConns
+	ch <- c.processConnsTotal
+	ch <- c.processReqsTotal
+	ch <- c.droppedObjects
+}
+
+// Collect ...
+// implements prometheus.Collector
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	stats := c.stats
+	c.mutex.Unlock()
+	if stats == nil {
+		return
+	}
+	for _, b := range stats.Backends {
+		c.backend.collect(ch, &b.Counters, b.Backend, b.Namespace, b.Service, b.Port)
+	}
+	for _, s := range stats.Servers {
+		labels := []string{s.Backend, s.Namespace, s.Service, s.Port, s.Server, s.Pod}
+		c.server.collect(ch, &s.Counters, labels...)
+		up := map[bool]float64{false: 0, true: 1}
+		ch <- prometheus.MustNewConstMetric(c.serverUp, prometheus.GaugeValue, up[s.Up], labels...)
+	}
+	for _, p := range stats.Paths {
+		ch <- prometheus.MustNewConstMetric(c.pathInfo, prometheus.GaugeValue, 1,
+			p.Backend, p.Namespace, p.Service, p.Port, p.Ingress, p.Host, p.Path)
+	}
+	ch <- prometheus.MustNewConstMetric(c.processConns, prometheus.GaugeValue, float64(stats.Process.CurrentConnections))
+	ch <- prometheus.MustNewConstMetric(c.processConnsTotal, prometheus.CounterValue, float64(stats.Process.ConnectionsTotal))
+	ch <- prometheus.MustNewConstMetric(c.processReqsTotal, prometheus.CounterValue, float64(stats.Process.RequestsTotal))
+	ch <- prometheus.MustNewConstMetric(c.droppedObjects, prometheus.GaugeValue, float64(stats.Dropped))
+}
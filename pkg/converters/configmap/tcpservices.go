@@ -18,7 +18,7 @@ package configmap
 
 import (
 	"fmt"
-	"regexp"
+	"net"
 	"strconv"
 	"strings"
 
@@ -48,14 +48,21 @@ type tcpSvcConverter struct {
 	haproxy haproxy.Config
 }
 
-var regexValidTime = regexp.MustCompile(`^[0-9]+(us|ms|s|m|h|d)$`)
-
 func (c *tcpSvcConverter) Sync(tcpservices map[string]string) {
-	c.haproxy.TCPBackends().RemoveAll()
+	// TCP services created from service annotations are
+	// managed by the ingress converter, remove only ours
+	tcpbackends := c.haproxy.TCPBackends()
+	var ports []int
+	for port, backend := range tcpbackends.Items() {
+		if !backend.Annotated {
+			ports = append(ports, port)
+		}
+	}
+	tcpbackends.Remove(ports)
 
 	// map[key]value is:
 	// - key   => port to expose
-	// - value => <service-name>:<port>:[<PROXY>]:[<PROXY[-<V1|V2>]]:<secret-name-cert>:check-interval:<secret-name-ca>
+	// - value => <service-name>:<port>:[<PROXY>]:[<PROXY[-<V1|V2>]]:<secret-name-cert>:check-interval:<secret-name-ca>:balance:timeout-client:timeout-server:whitelist:maxconn
 	//   - 0: namespace/name of the target service
 	//   - 1: target port number
 	//   - 2: "PROXY" means accept proxy protocol
@@ -63,12 +70,23 @@ func (c *tcpSvcConverter) Sync(tcpservices map[string]string) {
 	//   - 4: namespace/name of crt/key secret if should ssl-offload
 	//   - 5: check interval
 	//   - 6: namespace/name of ca/crl secret if should verify client ssl
+	//   - 7: load balancing algorithm
+	//   - 8: client side inactivity timeout
+	//   - 9: server side inactivity timeout
+	//   - 10: comma separated list of IPv4 or CIDR allowed to connect
+	//   - 11: max number of concurrent connections per endpoint
+	// colon is the field separator, so IPv6 cannot be used on the whitelist
 	for k, v := range tcpservices {
 		publicport, err := strconv.Atoi(k)
 		if err != nil {
 			c.logger.Warn("skipping invalid public listening port of TCP service: %s", k)
 			continue
 		}
+		if strings.Count(v, ":") >= 12 {
+			// a colon in the whitelist would silently drop the remaining cidrs and allow everyone
+			c.logger.Warn("skipping TCP service on public port %d: too many fields, IPv6 is not supported on the whitelist: %s", publicport, v)
+			continue
+		}
 		svc := c.parseService(v)
 		if svc.name == "" {
 			c.logger.Warn("skipping empty TCP service name on public port %d", publicport)
@@ -109,7 +127,7 @@ func (c *tcpSvcConverter) Sync(tcpservices map[string]string) {
 		if svc.checkInt != "" {
 			if svc.checkInt == "-" {
 				checkInterval = ""
-			} else if regexValidTime.MatchString(svc.checkInt) {
+			} else if convutils.IsValidTime(svc.checkInt) {
 				checkInterval = svc.checkInt
 			} else {
 				c.logger.Warn(
@@ -117,8 +135,41 @@ func (c *tcpSvcConverter) Sync(tcpservices map[string]string) {
 					checkInterval, publicport, svc.checkInt)
 			}
 		}
+		timeoutClient := c.readTime(publicport, "client timeout", svc.timeoutCli)
+		timeoutServer := c.readTime(publicport, "server timeout", svc.timeoutSrv)
+		balance := svc.balance
+		if balance != "" && !convutils.IsValidTCPBalance(balance) {
+			c.logger.Warn("ignoring invalid balance algorithm on TCP service %d: %s", publicport, balance)
+			balance = ""
+		}
+		var maxconn int
+		if svc.maxconn != "" {
+			maxconn, err = strconv.Atoi(svc.maxconn)
+			if err != nil || maxconn < 0 {
+				c.logger.Warn("ignoring invalid maxconn on TCP service %d: %s", publicport, svc.maxconn)
+				maxconn = 0
+			}
+		}
+		var whitelist []string
+		for _, cidr := range strings.Split(svc.whitelist, ",") {
+			cidr = strings.TrimSpace(cidr)
+			if cidr == "" {
+				continue
+			}
+			if net.ParseIP(cidr) == nil {
+				if _, _, err := net.ParseCIDR(cidr); err != nil {
+					c.logger.Warn("skipping invalid IP or cidr on TCP service %d: %s", publicport, cidr)
+					continue
+				}
+			}
+			whitelist = append(whitelist, cidr)
+		}
 		servicename := fmt.Sprintf("%s_%s", service.Namespace, service.Name)
-		backend := c.haproxy.TCPBackends().Acquire(servicename, publicport)
+		if backend, found := tcpbackends.Items()[publicport]; found && backend.Annotated {
+			c.logger.Warn("skipping TCP service on public port %d: port already in use by '%s'", publicport, backend.Name)
+			continue
+		}
+		backend := tcpbackends.Acquire(servicename, publicport)
 		for _, addr := range addrs {
 			backend.AddEndpoint(addr.IP, addr.Port)
 		}
@@ -133,33 +184,57 @@ func (c *tcpSvcConverter) Sync(tcpservices map[string]string) {
 		backend.SSL.Filename = crtfile.Filename
 		backend.SSL.CAFilename = cafile.Filename
 		backend.SSL.CRLFilename = crlfile.Filename
+		backend.Dynamic = c.haproxy.Global().DynamicTCP
+		backend.BalanceAlgorithm = balance
+		backend.Timeout.Client = timeoutClient
+		backend.Timeout.Server = timeoutServer
+		backend.Whitelist = whitelist
+		backend.MaxConn = maxconn
+	}
+}
+
+func (c *tcpSvcConverter) readTime(publicport int, name, value string) string {
+	if value == "" || convutils.IsValidTime(value) {
+		return value
 	}
+	c.logger.Warn("ignoring invalid %s config on TCP service %d: %s", name, publicport, value)
+	return ""
 }
 
 type tcpSvc struct {
-	name      string
-	port      string
-	inProxy   string
-	outProxy  string
-	secretTLS string
-	secretCA  string
-	checkInt  string
+	name       string
+	port       string
+	inProxy    string
+	outProxy   string
+	secretTLS  string
+	secretCA   string
+	checkInt   string
+	balance    string
+	timeoutCli string
+	timeoutSrv string
+	whitelist  string
+	maxconn    string
 }
 
 func (c *tcpSvcConverter) parseService(service string) *tcpSvc {
-	svc := make([]string, 7)
+	svc := make([]string, 12)
 	for i, v := range strings.Split(service, ":") {
-		if i < 7 {
+		if i < 12 {
 			svc[i] = v
 		}
 	}
 	return &tcpSvc{
-		name:      svc[0],
-		port:      svc[1],
-		inProxy:   svc[2],
-		outProxy:  svc[3],
-		secretTLS: svc[4],
-		checkInt:  svc[5],
-		secretCA:  svc[6],
+		name:       svc[0],
+		port:       svc[1],
+		inProxy:    svc[2],
+		outProxy:   svc[3],
+		secretTLS:  svc[4],
+		checkInt:   svc[5],
+		secretCA:   svc[6],
+		balance:    svc[7],
+		timeoutCli: svc[8],
+		timeoutSrv: svc[9],
+		whitelist:  svc[10],
+		maxconn:    svc[11],
 	}
 }
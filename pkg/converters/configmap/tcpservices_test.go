@@ -35,6 +35,7 @@ func TestTCPSvcSync(t *testing.T) {
 		secretCAMock   map[string]string
 		secretCRLMock  map[string]string
 		services       map[string]string
+		annotated      []int
 		expected       []*hatypes.TCPBackend
 		logging        string
 	}{
@@ -62,7 +63,7 @@ func TestTCPSvcSync(t *testing.T) {
 					Name: "default_pg",
 					Port: 15432,
 					Endpoints: []*hatypes.TCPEndpoint{
-						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+						{Enabled: true, Name: "srv001", IP: "172.17.0.101", Port: 5432},
 					},
 					CheckInterval: "2s",
 				},
@@ -70,8 +71,8 @@ func TestTCPSvcSync(t *testing.T) {
 					Name: "default_sendmail",
 					Port: 10025,
 					Endpoints: []*hatypes.TCPEndpoint{
-						{Name: "srv001", IP: "172.17.0.201", Port: 25},
-						{Name: "srv002", IP: "172.17.0.202", Port: 25},
+						{Enabled: true, Name: "srv001", IP: "172.17.0.201", Port: 25},
+						{Enabled: true, Name: "srv002", IP: "172.17.0.202", Port: 25},
 					},
 					CheckInterval: "2s",
 				},
@@ -122,7 +123,7 @@ func TestTCPSvcSync(t *testing.T) {
 					Port:      5432,
 					ProxyProt: hatypes.TCPProxyProt{Decode: true},
 					Endpoints: []*hatypes.TCPEndpoint{
-						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+						{Enabled: true, Name: "srv001", IP: "172.17.0.101", Port: 5432},
 					},
 					CheckInterval: "2s",
 				},
@@ -138,7 +139,7 @@ func TestTCPSvcSync(t *testing.T) {
 					Port:      5432,
 					ProxyProt: hatypes.TCPProxyProt{EncodeVersion: "v1"},
 					Endpoints: []*hatypes.TCPEndpoint{
-						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+						{Enabled: true, Name: "srv001", IP: "172.17.0.101", Port: 5432},
 					},
 					CheckInterval: "2s",
 				},
@@ -154,7 +155,7 @@ func TestTCPSvcSync(t *testing.T) {
 					Port:      5432,
 					ProxyProt: hatypes.TCPProxyProt{EncodeVersion: "v2"},
 					Endpoints: []*hatypes.TCPEndpoint{
-						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+						{Enabled: true, Name: "srv001", IP: "172.17.0.101", Port: 5432},
 					},
 				},
 			},
@@ -177,7 +178,7 @@ func TestTCPSvcSync(t *testing.T) {
 					Port: 5432,
 					SSL:  hatypes.TCPSSL{Filename: "/var/haproxy/ssl/crt.pem"},
 					Endpoints: []*hatypes.TCPEndpoint{
-						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+						{Enabled: true, Name: "srv001", IP: "172.17.0.101", Port: 5432},
 					},
 					CheckInterval: "2s",
 				},
@@ -192,7 +193,7 @@ func TestTCPSvcSync(t *testing.T) {
 					Name: "default_pg",
 					Port: 5432,
 					Endpoints: []*hatypes.TCPEndpoint{
-						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+						{Enabled: true, Name: "srv001", IP: "172.17.0.101", Port: 5432},
 					},
 					CheckInterval: "2s",
 				},
@@ -208,7 +209,7 @@ func TestTCPSvcSync(t *testing.T) {
 					Name: "default_pg",
 					Port: 5432,
 					Endpoints: []*hatypes.TCPEndpoint{
-						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+						{Enabled: true, Name: "srv001", IP: "172.17.0.101", Port: 5432},
 					},
 					CheckInterval: "2s",
 				},
@@ -230,7 +231,7 @@ func TestTCPSvcSync(t *testing.T) {
 					Name: "default_pg",
 					Port: 5432,
 					Endpoints: []*hatypes.TCPEndpoint{
-						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+						{Enabled: true, Name: "srv001", IP: "172.17.0.101", Port: 5432},
 					},
 					CheckInterval: "2s",
 					SSL: hatypes.TCPSSL{
@@ -251,7 +252,7 @@ func TestTCPSvcSync(t *testing.T) {
 					Name: "default_pg",
 					Port: 5432,
 					Endpoints: []*hatypes.TCPEndpoint{
-						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+						{Enabled: true, Name: "srv001", IP: "172.17.0.101", Port: 5432},
 					},
 					CheckInterval: "2s",
 					SSL: hatypes.TCPSSL{
@@ -273,7 +274,7 @@ func TestTCPSvcSync(t *testing.T) {
 					Name: "default_pg",
 					Port: 5432,
 					Endpoints: []*hatypes.TCPEndpoint{
-						{Name: "srv001", IP: "172.17.0.101", Port: 5432},
+						{Enabled: true, Name: "srv001", IP: "172.17.0.101", Port: 5432},
 					},
 					CheckInterval: "2s",
 					SSL: hatypes.TCPSSL{
@@ -284,6 +285,77 @@ func TestTCPSvcSync(t *testing.T) {
 				},
 			},
 		},
+		// 19
+		{
+			svcmock:  map[string]string{"default/pg:5432": "172.17.0.101"},
+			services: map[string]string{"5432": "default/pg:5432::::::leastconn:1h:2h:10.0.0.0/8,192.168.0.1:100"},
+			expected: []*hatypes.TCPBackend{
+				{
+					Name: "default_pg",
+					Port: 5432,
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Enabled: true, Name: "srv001", IP: "172.17.0.101", Port: 5432},
+					},
+					BalanceAlgorithm: "leastconn",
+					CheckInterval:    "2s",
+					MaxConn:          100,
+					Timeout:          hatypes.TCPTimeoutConfig{Client: "1h", Server: "2h"},
+					Whitelist:        []string{"10.0.0.0/8", "192.168.0.1"},
+				},
+			},
+		},
+		// 20
+		{
+			svcmock:  map[string]string{"default/pg:5432": "172.17.0.101"},
+			services: map[string]string{"5432": "default/pg:5432::::::rr:1x:10:10.0.0.0/8,fail:-1"},
+			expected: []*hatypes.TCPBackend{
+				{
+					Name: "default_pg",
+					Port: 5432,
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Enabled: true, Name: "srv001", IP: "172.17.0.101", Port: 5432},
+					},
+					CheckInterval: "2s",
+					Whitelist:     []string{"10.0.0.0/8"},
+				},
+			},
+			logging: `
+WARN ignoring invalid client timeout config on TCP service 5432: 1x
+WARN ignoring invalid server timeout config on TCP service 5432: 10
+WARN ignoring invalid balance algorithm on TCP service 5432: rr
+WARN ignoring invalid maxconn on TCP service 5432: -1
+WARN skipping invalid IP or cidr on TCP service 5432: fail`,
+		},
+		// 21
+		{
+			svcmock:  map[string]string{"default/pg:5432": "172.17.0.101"},
+			services: map[string]string{"5432": "default/pg:5432::::::leastconn:::10.0.0.0/8,fd00::/8:100"},
+			logging: `
+WARN skipping TCP service on public port 5432: too many fields, IPv6 is not supported on the whitelist: default/pg:5432::::::leastconn:::10.0.0.0/8,fd00::/8:100`,
+		},
+		// 22
+		{
+			svcmock:   map[string]string{"default/pg:5432": "172.17.0.101"},
+			services:  map[string]string{"5432": "default/pg:5432", "5433": "default/pg:5432"},
+			annotated: []int{5433},
+			expected: []*hatypes.TCPBackend{
+				{
+					Name: "default_pg",
+					Port: 5432,
+					Endpoints: []*hatypes.TCPEndpoint{
+						{Enabled: true, Name: "srv001", IP: "172.17.0.101", Port: 5432},
+					},
+					CheckInterval: "2s",
+				},
+				{
+					Name:      "default_redis",
+					Port:      5433,
+					Annotated: true,
+				},
+			},
+			logging: `
+WARN skipping TCP service on public port 5433: port already in use by 'default_redis'`,
+		},
 	}
 	for i, test := range testCases {
 		c := setup(t)
@@ -296,6 +368,9 @@ func TestTCPSvcSync(t *testing.T) {
 		c.cache.SecretTLSPath = test.secretCertMock
 		c.cache.SecretCAPath = test.secretCAMock
 		c.cache.SecretCRLPath = test.secretCRLMock
+		for _, port := range test.annotated {
+			c.haproxy.TCPBackends().Acquire("default_redis", port).Annotated = true
+		}
 		NewTCPServicesConverter(c.logger, c.haproxy, c.cache).Sync(test.services)
 		backends := c.haproxy.TCPBackends().BuildSortedItems()
 		for _, b := range backends {
@@ -40,11 +40,13 @@ type CacheMock struct {
 	EpList        map[string]*api.Endpoints
 	TermPodList   map[string][]*api.Pod
 	PodList       map[string]*api.Pod
+	NsList        map[string]*api.Namespace
 	SecretTLSPath map[string]string
 	SecretCAPath  map[string]string
 	SecretCRLPath map[string]string
 	SecretDHPath  map[string]string
 	SecretContent SecretContent
+	RefreshedCRL  map[string]string
 }
 
 // NewCacheMock ...
@@ -96,6 +98,11 @@ func (c *CacheMock) GetService(serviceName string) (*api.Service, error) {
 	return nil, fmt.Errorf("service not found: '%s'", serviceName)
 }
 
+// GetServiceList ...
+func (c *CacheMock) GetServiceList() ([]*api.Service, error) {
+	return c.SvcList, nil
+}
+
 // GetEndpoints ...
 func (c *CacheMock) GetEndpoints(service *api.Service) (*api.Endpoints, error) {
 	serviceName := service.Namespace + "/" + service.Name
@@ -122,6 +129,14 @@ func (c *CacheMock) GetPod(podName string) (*api.Pod, error) {
 	return nil, fmt.Errorf("pod not found: '%s'", podName)
 }
 
+// GetNamespace ...
+func (c *CacheMock) GetNamespace(name string) (*api.Namespace, error) {
+	if ns, found := c.NsList[name]; found {
+		return ns, nil
+	}
+	return nil, fmt.Errorf("namespace not found: '%s'", name)
+}
+
 // GetTLSSecretPath ...
 func (c *CacheMock) GetTLSSecretPath(defaultNamespace, secretName string, track convtypes.TrackingTarget) (convtypes.CrtFile, error) {
 	fullname := c.buildSecretName(defaultNamespace, secretName)
@@ -160,6 +175,18 @@ func (c *CacheMock) GetCASecretPath(defaultNamespace, secretName string, track c
 	return ca, crl, nil
 }
 
+// GetRefreshedCRLPath ...
+func (c *CacheMock) GetRefreshedCRLPath(defaultNamespace, secretName string, refresh time.Duration) (convtypes.File, error) {
+	fullname := c.buildSecretName(defaultNamespace, secretName)
+	if path, found := c.RefreshedCRL[fullname]; found {
+		return convtypes.File{
+			Filename: path,
+			SHA1Hash: fmt.Sprintf("%x", sha1.Sum([]byte(path))),
+		}, nil
+	}
+	return convtypes.File{}, fmt.Errorf("CRL of secret '%s' was not downloaded yet", fullname)
+}
+
 // GetDHSecretPath ...
 func (c *CacheMock) GetDHSecretPath(defaultNamespace, secretName string) (convtypes.File, error) {
 	fullname := c.buildSecretName(defaultNamespace, secretName)
@@ -191,8 +218,14 @@ func (c *CacheMock) GetSecretContent(defaultNamespace, secretName, keyName strin
 func (c *CacheMock) SwapChangedObjects() *convtypes.ChangedObjects {
 	changed := c.Changed
 	c.Changed = &convtypes.ChangedObjects{
-		GlobalCur:       changed.GlobalNew,
-		TCPConfigMapCur: changed.TCPConfigMapNew,
+		GlobalCur:       changed.GlobalCur,
+		TCPConfigMapCur: changed.TCPConfigMapCur,
+	}
+	if changed.GlobalNew != nil {
+		c.Changed.GlobalCur = changed.GlobalNew
+	}
+	if changed.TCPConfigMapNew != nil {
+		c.Changed.TCPConfigMapCur = changed.TCPConfigMapNew
 	}
 	// update c.IngList based on notifications
 	for i, ing := range c.IngList {
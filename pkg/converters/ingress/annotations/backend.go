@@ -49,7 +49,7 @@ func (c *updater) buildBackendAffinity(d *backData) {
 		strategyName = strategy.Value
 	default:
 		if strategy.Source != nil {
-			c.logger.Warn("invalid affinity cookie strategy '%s' on %v, using 'insert' instead", strategy.Value, strategy.Source)
+			c.warn(strategy.Source, "invalid affinity cookie strategy '%s' on %v, using 'insert' instead", strategy.Value, strategy.Source)
 		}
 		strategyName = "insert"
 	}
@@ -108,11 +108,11 @@ func (c *updater) buildBackendAuthHTTP(d *backData) {
 				userstr := string(userb)
 				users, errs := extractUserlist(authSecret.Source.Name, secretName, userstr)
 				for _, err := range errs {
-					c.logger.Warn("ignoring malformed usr/passwd on secret '%s', declared on %v: %v", secretName, authSecret.Source, err)
+					c.warn(authSecret.Source, "ignoring malformed usr/passwd on secret '%s', declared on %v: %v", secretName, authSecret.Source, err)
 				}
 				userlist = c.haproxy.Userlists().Replace(listName, users)
 				if len(users) == 0 {
-					c.logger.Warn("userlist on %v for basic authentication is empty", authSecret.Source)
+					c.warn(authSecret.Source, "userlist on %v for basic authentication is empty", authSecret.Source)
 				}
 			}
 			realm := "localhost" // HAProxy's backend name would be used if missing
@@ -120,7 +120,7 @@ func (c *updater) buildBackendAuthHTTP(d *backData) {
 			if authRealm == nil || authRealm.Source == nil {
 				// leave default
 			} else if strings.Index(authRealm.Value, `"`) >= 0 {
-				c.logger.Warn("ignoring auth-realm with quotes on %v", authRealm.Source)
+				c.warn(authRealm.Source, "ignoring auth-realm with quotes on %v", authRealm.Source)
 			} else if authRealm.Value != "" {
 				realm = authRealm.Value
 			}
@@ -209,11 +209,11 @@ func (c *updater) buildBackendBlueGreenBalance(d *backData) {
 			return
 		}
 		if w < 0 {
-			c.logger.Warn("invalid weight '%d' on %v, using '0' instead", w, balance.Source)
+			c.warn(balance.Source, "invalid weight '%d' on %v, using '0' instead", w, balance.Source)
 			w = 0
 		}
 		if w > 256 {
-			c.logger.Warn("invalid weight '%d' on %v, using '256' instead", w, balance.Source)
+			c.warn(balance.Source, "invalid weight '%d' on %v, using '256' instead", w, balance.Source)
 			w = 256
 		}
 		dw := &deployWeight{
@@ -245,7 +245,7 @@ func (c *updater) buildBackendBlueGreenBalance(d *backData) {
 			if ep.TargetRef == "" {
 				err = fmt.Errorf("endpoint does not reference a pod")
 			}
-			c.logger.Warn("endpoint '%s:%d' on %v was removed from balance: %v", ep.IP, ep.Port, balance.Source, err)
+			c.warn(balance.Source, "endpoint '%s:%d' on %v was removed from balance: %v", ep.IP, ep.Port, balance.Source, err)
 		}
 		if !hasLabel {
 			// no label match, set weight as zero to remove new traffic
@@ -263,7 +263,7 @@ func (c *updater) buildBackendBlueGreenBalance(d *backData) {
 		// no need to rebalance
 		return
 	} else if mode.Source != nil && mode.Value != "deploy" {
-		c.logger.Warn("unsupported blue/green mode '%s' on %s, falling back to 'deploy'", mode.Value, mode.Source)
+		c.warn(mode.Source, "unsupported blue/green mode '%s' on %s, falling back to 'deploy'", mode.Value, mode.Source)
 	}
 	// mode == deploy, need to recalc based on the number of replicas
 	lcmCount := 0
@@ -397,7 +397,7 @@ func (c *updater) buildBackendBodySize(d *backData) {
 			}
 			value, err := utils.SizeSuffixToInt64(bodysize.Value)
 			if err != nil {
-				c.logger.Warn("ignoring invalid body size on %v: %s", bodysize.Source, bodysize.Value)
+				c.warn(bodysize.Source, "ignoring invalid body size on %v: %s", bodysize.Source, bodysize.Value)
 				return nil
 			}
 			bodysize.Value = strconv.FormatInt(value, 10)
@@ -500,8 +500,33 @@ func (c *updater) buildBackendHealthCheck(d *backData) {
 	d.backend.HealthCheck.Port = d.mapper.Get(ingtypes.BackHealthCheckPort).Int()
 	d.backend.HealthCheck.RiseCount = d.mapper.Get(ingtypes.BackHealthCheckRiseCount).Int()
 	d.backend.HealthCheck.URI = d.mapper.Get(ingtypes.BackHealthCheckURI).Value
+	grpc := d.mapper.Get(ingtypes.BackHealthCheckGRPC)
+	if !grpc.Bool() {
+		return
+	}
+	if d.backend.Server.Protocol != "h2" {
+		c.warn(grpc.Source, "ignoring gRPC health check on %v: backend protocol should be h2 or grpc", grpc.Source)
+		return
+	}
+	if d.backend.Resolver != "" {
+		c.warn(grpc.Source, "ignoring gRPC health check on %v: not supported with DNS resolvers", grpc.Source)
+		return
+	}
+	service := d.mapper.Get(ingtypes.BackHealthCheckGRPCService)
+	if !grpcServiceRegex.MatchString(service.Value) {
+		c.warn(service.Source, "ignoring invalid gRPC health check service on %v: %s", service.Source, service.Value)
+		return
+	}
+	if d.backend.AgentCheck.Port > 0 {
+		c.warn(grpc.Source, "ignoring agent check on %v due to gRPC health check", grpc.Source)
+		d.backend.AgentCheck = hatypes.AgentCheck{}
+	}
+	d.backend.HealthCheck.GRPC = true
+	d.backend.HealthCheck.GRPCService = service.Value
 }
 
+var grpcServiceRegex = regexp.MustCompile(`^[A-Za-z0-9_.]*$`)
+
 func (c *updater) buildBackendHeaders(d *backData) {
 	headers := d.mapper.Get(ingtypes.BackHeaders)
 	if headers.Value == "" {
@@ -514,7 +539,7 @@ func (c *updater) buildBackendHeaders(d *backData) {
 		}
 		idx := strings.IndexAny(header, ": ")
 		if idx <= 0 {
-			c.logger.Warn("ignored missing header name or value on %v: %s", headers.Source, header)
+			c.warn(headers.Source, "ignored missing header name or value on %v: %s", headers.Source, header)
 			continue
 		}
 		name := strings.TrimRight(header[:idx], ":")
@@ -564,7 +589,7 @@ func (c *updater) buildBackendOAuth(d *backData) {
 		return
 	}
 	if oauth.Value != "oauth2_proxy" {
-		c.logger.Warn("ignoring invalid oauth implementation '%s' on %v", oauth, oauth.Source)
+		c.warn(oauth.Source, "ignoring invalid oauth implementation '%s' on %v", oauth, oauth.Source)
 		return
 	}
 	uriPrefix := "/oauth2"
@@ -589,7 +614,7 @@ func (c *updater) buildBackendOAuth(d *backData) {
 			continue
 		}
 		if !oauthHeaderRegex.MatchString(header) {
-			c.logger.Warn("invalid header format '%s' on %v", header, h.Source)
+			c.warn(h.Source, "invalid header format '%s' on %v", header, h.Source)
 			continue
 		}
 		h := strings.Split(header, ":")
@@ -630,11 +655,11 @@ func (c *updater) buildBackendProtocol(d *backData) {
 		protocol = "h2"
 		secure = true
 	default:
-		c.logger.Warn("ignoring invalid backend protocol on %v: %s", proto.Source, proto.Value)
+		c.warn(proto.Source, "ignoring invalid backend protocol on %v: %s", proto.Source, proto.Value)
 		return
 	}
 	if protocol == "h2" && !c.haproxy.Global().UseHTX {
-		c.logger.Warn("ignoring h2 protocol on %v due to HTX disabled, changing to h1", proto.Source)
+		c.warn(proto.Source, "ignoring h2 protocol on %v due to HTX disabled, changing to h1", proto.Source)
 		protocol = "h1"
 	}
 	if !secure {
@@ -642,6 +667,9 @@ func (c *updater) buildBackendProtocol(d *backData) {
 	}
 	d.backend.Server.Protocol = protocol
 	d.backend.Server.Secure = secure
+	if protocol == "h2" && strings.HasPrefix(strings.ToLower(proto.Value), "grpc") {
+		d.backend.Server.GRPC = true
+	}
 	if !secure {
 		return
 	}
@@ -654,7 +682,7 @@ func (c *updater) buildBackendProtocol(d *backData) {
 			d.backend.Server.CrtFilename = crtFile.Filename
 			d.backend.Server.CrtHash = crtFile.SHA1Hash
 		} else {
-			c.logger.Warn("skipping client certificate on %v: %v", crt.Source, err)
+			c.warn(crt.Source, "skipping client certificate on %v: %v", crt.Source, err)
 		}
 	}
 	if ca := d.mapper.Get(ingtypes.BackSecureVerifyCASecret); ca.Value != "" {
@@ -668,9 +696,57 @@ func (c *updater) buildBackendProtocol(d *backData) {
 			d.backend.Server.CRLFilename = crlFile.Filename
 			d.backend.Server.CRLHash = crlFile.SHA1Hash
 		} else {
-			c.logger.Warn("skipping CA on %v: %v", ca.Source, err)
+			c.warn(ca.Source, "skipping CA on %v: %v", ca.Source, err)
+		}
+	}
+	if sni := d.mapper.Get(ingtypes.BackSecureSNI); sni.Value != "" {
+		switch sni.Value {
+		case "host":
+			// req.host is declared by the http and https frontends
+			d.backend.Server.SNI = "var(req.host)"
+		default:
+			if hostname := c.secureHostname(d, sni); hostname != "" {
+				d.backend.Server.SNI = "str(" + hostname + ")"
+				d.backend.Server.CheckSNI = hostname
+			}
+		}
+	}
+	if verifyHost := d.mapper.Get(ingtypes.BackSecureVerifyHostname); verifyHost.Value != "" {
+		if d.backend.Server.CAFilename == "" {
+			c.warn(verifyHost.Source, "ignoring verify hostname on %v due to missing CA secret", verifyHost.Source)
+		} else {
+			d.backend.Server.VerifyHost = c.secureHostname(d, verifyHost)
 		}
 	}
+	if alpn := d.mapper.Get(ingtypes.BackSecureALPN); alpn.Value != "" {
+		if alpnRegex.MatchString(alpn.Value) {
+			d.backend.Server.ALPN = alpn.Value
+		} else {
+			c.warn(alpn.Source, "ignoring invalid ALPN on %v: %s", alpn.Source, alpn.Value)
+		}
+	}
+}
+
+var (
+	hostnameRegex = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9.-]*[A-Za-z0-9])?$`)
+	alpnRegex     = regexp.MustCompile(`^[A-Za-z0-9./-]+(,[A-Za-z0-9./-]+)*$`)
+)
+
+// secureHostname returns the hostname configured in a secure backend key, the
+// `service` value is changed to the DNS name of the service.
+func (c *updater) secureHostname(d *backData, config *ConfigValue) string {
+	if config.Value == "service" {
+		clusterDomain := c.haproxy.Global().DNS.ClusterDomain
+		if clusterDomain == "" {
+			clusterDomain = "cluster.local"
+		}
+		return fmt.Sprintf("%s.%s.svc.%s", d.backend.Name, d.backend.Namespace, clusterDomain)
+	}
+	if !hostnameRegex.MatchString(config.Value) {
+		c.warn(config.Source, "ignoring invalid hostname on %v: %s", config.Source, config.Value)
+		return ""
+	}
+	return config.Value
 }
 
 func (c *updater) buildBackendProxyProtocol(d *backData) {
@@ -688,7 +764,7 @@ func (c *updater) buildBackendProxyProtocol(d *backData) {
 	case "v2-ssl-cn":
 		d.backend.Server.SendProxy = "send-proxy-v2-ssl-cn"
 	default:
-		c.logger.Warn("ignoring invalid proxy protocol version on %v: %s", cfg.Source, cfg.Value)
+		c.warn(cfg.Source, "ignoring invalid proxy protocol version on %v: %s", cfg.Source, cfg.Value)
 	}
 }
 
@@ -706,7 +782,7 @@ func (c *updater) buildBackendRewriteURL(d *backData) {
 				return nil
 			}
 			if !rewriteURLRegex.MatchString(rewrite.Value) {
-				c.logger.Warn(
+				c.warn(rewrite.Source,
 					"rewrite-target does not allow white spaces or single/double quotes on %v: '%s'",
 					rewrite.Source, rewrite.Value)
 				return nil
@@ -728,7 +804,7 @@ func (c *updater) buildBackendServerNaming(d *backData) {
 	// Only warning here. d.backend.EpNaming should be updated before backend.AcquireEndpoint()
 	naming := d.mapper.Get(ingtypes.BackBackendServerNaming)
 	if !epNamingRegex.MatchString(naming.Value) {
-		c.logger.Warn("ignoring invalid naming type '%s' on %s, using 'seq' instead", naming.Value, naming.Source)
+		c.warn(naming.Source, "ignoring invalid naming type '%s' on %s, using 'seq' instead", naming.Value, naming.Source)
 	}
 }
 
@@ -800,7 +876,7 @@ func (c *updater) buildBackendWAF(d *backData) {
 				return nil
 			}
 			if waf.Value != "modsecurity" {
-				c.logger.Warn("ignoring invalid WAF module on %s: %s", waf.Source, waf.Value)
+				c.warn(waf.Source, "ignoring invalid WAF module on %s: %s", waf.Source, waf.Value)
 				return nil
 			}
 			wafMode, foundWAFMode := values[ingtypes.BackWAFMode]
@@ -808,7 +884,7 @@ func (c *updater) buildBackendWAF(d *backData) {
 				return values
 			}
 			if wafMode.Value != "deny" && wafMode.Value != "detect" {
-				c.logger.Warn("ignoring invalid WAF mode '%s' on %s, using 'deny' instead", wafMode.Value, wafMode.Source)
+				c.warn(wafMode.Source, "ignoring invalid WAF mode '%s' on %s, using 'deny' instead", wafMode.Value, wafMode.Source)
 				wafMode.Value = "deny"
 			}
 			return values
@@ -1001,6 +1001,97 @@ host: %[service].%[namespace].svc.cluster.local
 	}
 }
 
+func TestHealthCheck(t *testing.T) {
+	testCases := []struct {
+		ann        map[string]string
+		protocol   string
+		resolver   string
+		agentCheck hatypes.AgentCheck
+		expected   hatypes.HealthCheck
+		expAgent   hatypes.AgentCheck
+		logging    string
+	}{
+		// 0
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckURI:      "/check",
+				ingtypes.BackHealthCheckInterval: "2s",
+			},
+			expected: hatypes.HealthCheck{
+				Interval: "2s",
+				URI:      "/check",
+			},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckGRPC: "true",
+			},
+			protocol: "h1",
+			logging:  `WARN ignoring gRPC health check on ingress 'default/ing1': backend protocol should be h2 or grpc`,
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckGRPC: "true",
+			},
+			protocol: "h2",
+			expected: hatypes.HealthCheck{
+				GRPC: true,
+			},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckGRPC:        "true",
+				ingtypes.BackHealthCheckGRPCService: "app.v1.Service",
+			},
+			protocol:   "h2",
+			agentCheck: hatypes.AgentCheck{Port: 8000},
+			expected: hatypes.HealthCheck{
+				GRPC:        true,
+				GRPCService: "app.v1.Service",
+			},
+			logging: `WARN ignoring agent check on ingress 'default/ing1' due to gRPC health check`,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckGRPC:        "true",
+				ingtypes.BackHealthCheckGRPCService: "app/Service",
+			},
+			protocol: "h2",
+			logging:  `WARN ignoring invalid gRPC health check service on ingress 'default/ing1': app/Service`,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackHealthCheckGRPC: "true",
+			},
+			protocol: "h2",
+			resolver: "k8s",
+			logging:  `WARN ignoring gRPC health check on ingress 'default/ing1': not supported with DNS resolvers`,
+		},
+	}
+	source := &Source{
+		Namespace: "default",
+		Name:      "ing1",
+		Type:      "ingress",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{})
+		d.backend.Server.Protocol = test.protocol
+		d.backend.Resolver = test.resolver
+		d.backend.AgentCheck = test.agentCheck
+		c.createUpdater().buildBackendHealthCheck(d)
+		c.compareObjects("health check", i, d.backend.HealthCheck, test.expected)
+		c.compareObjects("agent check", i, d.backend.AgentCheck, test.expAgent)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestHSTS(t *testing.T) {
 	testCases := []struct {
 		paths      []string
@@ -1343,15 +1434,16 @@ func TestBackendServerNaming(t *testing.T) {
 
 func TestBackendProtocol(t *testing.T) {
 	testCase := []struct {
-		source     Source
-		useHTX     bool
-		annDefault map[string]string
-		ann        map[string]map[string]string
-		paths      []string
-		tlsSecrets map[string]string
-		caSecrets  map[string]string
-		expected   hatypes.ServerConfig
-		logging    string
+		source        Source
+		useHTX        bool
+		clusterDomain string
+		annDefault    map[string]string
+		ann           map[string]map[string]string
+		paths         []string
+		tlsSecrets    map[string]string
+		caSecrets     map[string]string
+		expected      hatypes.ServerConfig
+		logging       string
 	}{
 		// 0
 		{
@@ -1485,6 +1577,7 @@ WARN skipping CA on service 'default/app1': secret not found: 'default/ca'`,
 				},
 			},
 			expected: hatypes.ServerConfig{
+				GRPC:     true,
 				Protocol: "h2",
 				Secure:   false,
 			},
@@ -1526,11 +1619,111 @@ WARN skipping CA on service 'default/app1': secret not found: 'default/ca'`,
 			},
 			logging: `WARN ignoring h2 protocol on service 'default/app1' due to HTX disabled, changing to h1`,
 		},
+		// 12
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackSecureSNI:            "host",
+					ingtypes.BackSecureVerifyHostname: "app.local",
+					ingtypes.BackSecureALPN:           "h2,http/1.1",
+				},
+			},
+			expected: hatypes.ServerConfig{
+				Protocol: "h1",
+			},
+		},
+		// 13
+		{
+			useHTX: true,
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackBackendProtocol: "h2-ssl",
+					ingtypes.BackSecureSNI:       "host",
+					ingtypes.BackSecureALPN:      "h2,http/1.1",
+				},
+			},
+			expected: hatypes.ServerConfig{
+				Protocol: "h2",
+				Secure:   true,
+				SNI:      "var(req.host)",
+				ALPN:     "h2,http/1.1",
+			},
+		},
+		// 14
+		{
+			source: Source{Namespace: "default", Name: "app1", Type: "service"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackSecureBackends:       "true",
+					ingtypes.BackSecureVerifyCASecret: "ca",
+					ingtypes.BackSecureSNI:            "service",
+					ingtypes.BackSecureVerifyHostname: "service",
+				},
+			},
+			caSecrets: map[string]string{
+				"default/ca": "/var/haproxy/ssl/ca.pem",
+			},
+			expected: hatypes.ServerConfig{
+				Protocol:   "h1",
+				Secure:     true,
+				CAFilename: "/var/haproxy/ssl/ca.pem",
+				CAHash:     "3be93154b1cddfd0e1279f4d76022221676d08c7",
+				SNI:        "str(app.defualt.svc.cluster.local)",
+				CheckSNI:   "app.defualt.svc.cluster.local",
+				VerifyHost: "app.defualt.svc.cluster.local",
+			},
+		},
+		// 15
+		{
+			source:        Source{Namespace: "default", Name: "app1", Type: "service"},
+			clusterDomain: "k8s.local",
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackSecureBackends:       "true",
+					ingtypes.BackSecureVerifyCASecret: "ca",
+					ingtypes.BackSecureSNI:            "app.local",
+					ingtypes.BackSecureVerifyHostname: "service",
+				},
+			},
+			caSecrets: map[string]string{
+				"default/ca": "/var/haproxy/ssl/ca.pem",
+			},
+			expected: hatypes.ServerConfig{
+				Protocol:   "h1",
+				Secure:     true,
+				CAFilename: "/var/haproxy/ssl/ca.pem",
+				CAHash:     "3be93154b1cddfd0e1279f4d76022221676d08c7",
+				SNI:        "str(app.local)",
+				CheckSNI:   "app.local",
+				VerifyHost: "app.defualt.svc.k8s.local",
+			},
+		},
+		// 16
+		{
+			source: Source{Namespace: "default", Name: "app1", Type: "service"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackSecureBackends:       "true",
+					ingtypes.BackSecureSNI:            "app local",
+					ingtypes.BackSecureVerifyHostname: "app.local",
+					ingtypes.BackSecureALPN:           "h2 http/1.1",
+				},
+			},
+			expected: hatypes.ServerConfig{
+				Protocol: "h1",
+				Secure:   true,
+			},
+			logging: `
+WARN ignoring invalid hostname on service 'default/app1': app local
+WARN ignoring verify hostname on service 'default/app1' due to missing CA secret
+WARN ignoring invalid ALPN on service 'default/app1': h2 http/1.1`,
+		},
 	}
 	for i, test := range testCase {
 		c := setup(t)
 		d := c.createBackendMappingData("defualt/app", &test.source, test.annDefault, test.ann, test.paths)
 		c.haproxy.Global().UseHTX = test.useHTX
+		c.haproxy.Global().DNS.ClusterDomain = test.clusterDomain
 		c.cache.SecretTLSPath = test.tlsSecrets
 		c.cache.SecretCAPath = test.caSecrets
 		c.createUpdater().buildBackendProtocol(d)
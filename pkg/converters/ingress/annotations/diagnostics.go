@@ -0,0 +1,47 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotations
+
+import (
+	"fmt"
+
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// Warn logs a warning and, if a diagnostics collector is configured, also
+// attaches it to the ingress, service or namespace of every source.
+func Warn(logger types.Logger, diagnostics convtypes.Diagnostics, sources []*Source, msg string, args ...interface{}) {
+	logger.Warn(msg, args...)
+	if diagnostics == nil {
+		return
+	}
+	message := fmt.Sprintf(msg, args...)
+	for _, source := range sources {
+		if source == nil {
+			continue
+		}
+		switch source.Type {
+		case "ingress":
+			diagnostics.Warn(convtypes.IngressType, source.FullName(), message)
+		case "service":
+			diagnostics.Warn(convtypes.ServiceType, source.FullName(), message)
+		case "namespace":
+			diagnostics.Warn(convtypes.NamespaceType, source.Name, message)
+		}
+	}
+}
@@ -17,11 +17,14 @@ limitations under the License.
 package annotations
 
 import (
+	"encoding/base64"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/acme"
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
@@ -43,16 +46,103 @@ func (c *updater) buildGlobalAcme(d *globalData) {
 		c.logger.Warn("acme terms was not agreed, configure '%s' with \"true\" value", ingtypes.GlobalAcmeTermsAgreed)
 		return
 	}
+	var eabKeyID string
+	var eabHMACKey, caBundle []byte
+	if eabSecret := d.mapper.Get(ingtypes.GlobalAcmeEABSecret).Value; eabSecret != "" {
+		var err error
+		eabKeyID, eabHMACKey, err = c.readAcmeEAB(eabSecret)
+		if err != nil {
+			c.logger.Warn("skipping acme config, error reading external account binding: %v", err)
+			return
+		}
+	}
+	if caSecret := d.mapper.Get(ingtypes.GlobalAcmeCASecret).Value; caSecret != "" {
+		var err error
+		caBundle, err = c.cache.GetSecretContent("", caSecret, "ca.crt", convtypes.TrackingTarget{})
+		if err != nil {
+			c.logger.Warn("skipping acme config, error reading CA bundle: %v", err)
+			return
+		}
+	}
+	d.acmeData.CABundle = caBundle
+	d.acmeData.EABHMACKey = eabHMACKey
+	d.acmeData.EABKeyID = eabKeyID
 	d.acmeData.Emails = emails
 	d.acmeData.Endpoint = endpoint
 	d.acmeData.Expiring = time.Duration(d.mapper.Get(ingtypes.GlobalAcmeExpiring).Int()) * 24 * time.Hour
+	expiringRatio := d.mapper.Get(ingtypes.GlobalAcmeExpiringRatio)
+	if ratio, err := strconv.ParseFloat(expiringRatio.Value, 64); err == nil && ratio >= 0 && ratio < 1 {
+		d.acmeData.ExpiringRatio = ratio
+	} else if expiringRatio.Value != "" {
+		c.logger.Warn("ignoring invalid acme expiring ratio, should be a number between 0 and 1: %s", expiringRatio.Value)
+	}
+	d.acmeData.PreferredChain = d.mapper.Get(ingtypes.GlobalAcmePreferredChain).Value
 	d.acmeData.TermsAgreed = termsAgreed
+	d.acmeData.OrphanActions = c.readAcmeOrphanActions(d.mapper.Get(ingtypes.GlobalAcmeOrphanActions).Value)
+	if len(d.acmeData.OrphanActions) > 0 {
+		gracePeriod := d.mapper.Get(ingtypes.GlobalAcmeOrphanGracePeriod).Value
+		if duration, err := time.ParseDuration(gracePeriod); err == nil && duration >= 0 {
+			d.acmeData.OrphanGracePeriod = duration
+		} else {
+			c.logger.Warn("ignoring acme orphan actions due to invalid grace period: %s", gracePeriod)
+			d.acmeData.OrphanActions = nil
+		}
+	}
 	d.global.Acme.Prefix = "/.well-known/acme-challenge/"
 	d.global.Acme.Socket = "/var/run/haproxy/acme.sock"
+	d.global.Acme.TLSSocket = "/var/run/haproxy/acme-tls.sock"
 	d.global.Acme.Enabled = true
 	d.global.Acme.Shared = d.mapper.Get(ingtypes.GlobalAcmeShared).Bool()
 }
 
+// readAcmeOrphanActions parses a comma-separated list of orphan actions.
+// delete and label are mutually exclusive, delete is used if both are declared.
+func (c *updater) readAcmeOrphanActions(config string) []string {
+	var actions []string
+	var hasDelete, hasLabel bool
+	for _, action := range utils.Split(config, ",") {
+		switch action {
+		case acme.OrphanActionRevoke:
+			actions = append(actions, action)
+		case acme.OrphanActionDelete:
+			hasDelete = true
+		case acme.OrphanActionLabel:
+			hasLabel = true
+		default:
+			c.logger.Warn("ignoring invalid acme orphan action: %s", action)
+		}
+	}
+	if hasDelete {
+		if hasLabel {
+			c.logger.Warn("ignoring acme orphan action '%s', secret is already configured to be deleted", acme.OrphanActionLabel)
+		}
+		actions = append(actions, acme.OrphanActionDelete)
+	} else if hasLabel {
+		actions = append(actions, acme.OrphanActionLabel)
+	}
+	return actions
+}
+
+// readAcmeEAB reads the key ID and the base64url encoded HMAC key of
+// an external account binding from the `kid` and `hmac-key` keys of
+// secretName.
+func (c *updater) readAcmeEAB(secretName string) (kid string, hmacKey []byte, err error) {
+	kidContent, err := c.cache.GetSecretContent("", secretName, "kid", convtypes.TrackingTarget{})
+	if err != nil {
+		return "", nil, err
+	}
+	hmacContent, err := c.cache.GetSecretContent("", secretName, "hmac-key", convtypes.TrackingTarget{})
+	if err != nil {
+		return "", nil, err
+	}
+	kid = strings.TrimSpace(string(kidContent))
+	hmacKey, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.TrimSpace(string(hmacContent)), "="))
+	if kid == "" || err != nil || len(hmacKey) == 0 {
+		return "", nil, fmt.Errorf("secret '%s' should have a non empty kid and a base64url encoded hmac-key", secretName)
+	}
+	return kid, hmacKey, nil
+}
+
 func (c *updater) buildGlobalBind(d *globalData) {
 	d.global.Bind.AcceptProxy = d.mapper.Get(ingtypes.GlobalUseProxyProtocol).Bool()
 	d.global.Bind.TCPBindIP = d.mapper.Get(ingtypes.GlobalBindIPAddrTCP).Value
@@ -197,6 +287,7 @@ func (c *updater) buildGlobalSSL(d *globalData) {
 	ssl.Engine = d.mapper.Get(ingtypes.GlobalSSLEngine).Value
 	ssl.HeadersPrefix = d.mapper.Get(ingtypes.GlobalSSLHeadersPrefix).Value
 	ssl.ModeAsync = d.mapper.Get(ingtypes.GlobalSSLModeAsync).Bool()
+	ssl.OCSPCheckSocket = "/var/run/haproxy/ocsp.sock"
 	ssl.Options = d.mapper.Get(ingtypes.GlobalSSLOptions).Value
 	ssl.RedirectCode = d.mapper.Get(ingtypes.GlobalSSLRedirectCode).Int()
 }
@@ -280,6 +371,14 @@ var (
 	forwardRegex = regexp.MustCompile(`^(add|update|ignore|ifmissing)$`)
 )
 
+func (c *updater) buildGlobalDynamicTCP(d *globalData) {
+	d.global.DynamicTCP = hatypes.DynBackendConfig{
+		DynUpdate:    d.mapper.Get(ingtypes.BackDynamicScaling).Bool(),
+		BlockSize:    d.mapper.Get(ingtypes.BackBackendServerSlotsInc).Int(),
+		MinFreeSlots: d.mapper.Get(ingtypes.BackSlotsMinFree).Int(),
+	}
+}
+
 func (c *updater) buildGlobalForwardFor(d *globalData) {
 	if forwardFor := d.mapper.Get(ingtypes.GlobalForwardfor).Value; forwardRegex.MatchString(forwardFor) {
 		d.global.ForwardFor = forwardFor
@@ -18,11 +18,171 @@ package annotations
 
 import (
 	"testing"
+	"time"
 
+	conv_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/helper_test"
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 )
 
+func TestAcme(t *testing.T) {
+	testCases := []struct {
+		ann        map[string]string
+		secrets    conv_helper.SecretContent
+		expected   hatypes.AcmeData
+		expLogging string
+	}{
+		// 0
+		{
+			ann: map[string]string{},
+			expected: hatypes.AcmeData{
+				Emails:      "admin@d1.local",
+				Endpoint:    "https://acme.local",
+				TermsAgreed: true,
+			},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.GlobalAcmeEABSecret: "default/eab",
+				ingtypes.GlobalAcmeCASecret:  "default/ca",
+			},
+			secrets: conv_helper.SecretContent{
+				"default/eab": {"kid": []byte("kid-1\n"), "hmac-key": []byte("MDEyMzQ1Njc4OWFiY2RlZg\n")},
+				"default/ca":  {"ca.crt": []byte("<ca>")},
+			},
+			expected: hatypes.AcmeData{
+				CABundle:    []byte("<ca>"),
+				EABHMACKey:  []byte("0123456789abcdef"),
+				EABKeyID:    "kid-1",
+				Emails:      "admin@d1.local",
+				Endpoint:    "https://acme.local",
+				TermsAgreed: true,
+			},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.GlobalAcmeEABSecret: "default/eab",
+			},
+			secrets: conv_helper.SecretContent{
+				"default/eab": {"kid": []byte("kid-1"), "hmac-key": []byte("0123+/")},
+			},
+			expLogging: "WARN skipping acme config, error reading external account binding: secret 'default/eab' should have a non empty kid and a base64url encoded hmac-key",
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.GlobalAcmeEABSecret: "default/eab",
+			},
+			secrets: conv_helper.SecretContent{
+				"default/eab": {"kid": []byte("kid-1")},
+			},
+			expLogging: "WARN skipping acme config, error reading external account binding: secret 'default/eab' does not have file/key 'hmac-key'",
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.GlobalAcmeCASecret: "default/ca",
+			},
+			expLogging: "WARN skipping acme config, error reading CA bundle: secret not found: 'default/ca'",
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.GlobalAcmeExpiringRatio: "0.5",
+			},
+			expected: hatypes.AcmeData{
+				Emails:        "admin@d1.local",
+				Endpoint:      "https://acme.local",
+				ExpiringRatio: 0.5,
+				TermsAgreed:   true,
+			},
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.GlobalAcmeExpiringRatio: "1.5",
+			},
+			expected: hatypes.AcmeData{
+				Emails:      "admin@d1.local",
+				Endpoint:    "https://acme.local",
+				TermsAgreed: true,
+			},
+			expLogging: "WARN ignoring invalid acme expiring ratio, should be a number between 0 and 1: 1.5",
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.GlobalAcmeOrphanActions:     "delete,revoke",
+				ingtypes.GlobalAcmeOrphanGracePeriod: "1h",
+			},
+			expected: hatypes.AcmeData{
+				Emails:            "admin@d1.local",
+				Endpoint:          "https://acme.local",
+				OrphanActions:     []string{"revoke", "delete"},
+				OrphanGracePeriod: time.Hour,
+				TermsAgreed:       true,
+			},
+		},
+		// 8
+		{
+			ann: map[string]string{
+				ingtypes.GlobalAcmeOrphanActions:     "label,remove,delete",
+				ingtypes.GlobalAcmeOrphanGracePeriod: "0s",
+			},
+			expected: hatypes.AcmeData{
+				Emails:        "admin@d1.local",
+				Endpoint:      "https://acme.local",
+				OrphanActions: []string{"delete"},
+				TermsAgreed:   true,
+			},
+			expLogging: `
+WARN ignoring invalid acme orphan action: remove
+WARN ignoring acme orphan action 'label', secret is already configured to be deleted`,
+		},
+		// 9
+		{
+			ann: map[string]string{
+				ingtypes.GlobalAcmeOrphanActions:     "label",
+				ingtypes.GlobalAcmeOrphanGracePeriod: "1d",
+			},
+			expected: hatypes.AcmeData{
+				Emails:      "admin@d1.local",
+				Endpoint:    "https://acme.local",
+				TermsAgreed: true,
+			},
+			expLogging: "WARN ignoring acme orphan actions due to invalid grace period: 1d",
+		},
+		// 10
+		{
+			ann: map[string]string{
+				ingtypes.GlobalAcmePreferredChain: "ISRG Root X1",
+			},
+			expected: hatypes.AcmeData{
+				Emails:         "admin@d1.local",
+				Endpoint:       "https://acme.local",
+				PreferredChain: "ISRG Root X1",
+				TermsAgreed:    true,
+			},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.SecretContent = test.secrets
+		d := c.createGlobalData(map[string]string{
+			ingtypes.GlobalAcmeEmails:      "admin@d1.local",
+			ingtypes.GlobalAcmeEndpoint:    "https://acme.local",
+			ingtypes.GlobalAcmeTermsAgreed: "true",
+		})
+		d.mapper.AddAnnotations(nil, hatypes.CreatePathLink("-", "-"), test.ann)
+		c.createUpdater().buildGlobalAcme(d)
+		c.compareObjects("acme", i, *d.acmeData, test.expected)
+		c.logger.CompareLogging(test.expLogging)
+		c.teardown()
+	}
+}
+
 func TestBind(t *testing.T) {
 	testCases := []struct {
 		ann      map[string]string
@@ -383,3 +543,45 @@ func TestDisableCpuMap(t *testing.T) {
 		c.teardown()
 	}
 }
+
+func TestGRPCHealthAgent(t *testing.T) {
+	testCases := []struct {
+		agent    string
+		expected hatypes.GRPCHealthAgentConfig
+	}{
+		// 0
+		{
+			agent:    "",
+			expected: hatypes.GRPCHealthAgentConfig{Addr: "127.0.0.1", Port: 10260},
+		},
+		// 1
+		{
+			agent:    "127.0.0.1:10300",
+			expected: hatypes.GRPCHealthAgentConfig{Addr: "127.0.0.1", Port: 10300},
+		},
+		// 2
+		{
+			agent:    ":10300",
+			expected: hatypes.GRPCHealthAgentConfig{Addr: "127.0.0.1", Port: 10300},
+		},
+		// 3
+		{
+			agent:    "0.0.0.0:10300",
+			expected: hatypes.GRPCHealthAgentConfig{Addr: "127.0.0.1", Port: 10300},
+		},
+		// 4
+		{
+			agent:    "10.0.0.10:10300",
+			expected: hatypes.GRPCHealthAgentConfig{Addr: "10.0.0.10", Port: 10300},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createGlobalData(map[string]string{})
+		u := c.createUpdater()
+		u.grpcHealthAgent = test.agent
+		u.buildGlobalGRPCHealthAgent(d)
+		c.compareObjects("grpc health agent", i, d.global.GRPCHealthAgent, test.expected)
+		c.teardown()
+	}
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package annotations
 
 import (
+	"time"
+
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 )
@@ -40,6 +42,9 @@ func (c *updater) buildHostAuthTLS(d *hostData) {
 		tls.CAHash = cafile.SHA1Hash
 		tls.CRLFilename = crlfile.Filename
 		tls.CRLHash = crlfile.SHA1Hash
+		c.buildHostAuthTLSCRLRefresh(d, tlsSecret)
+		tls.OCSPCheck = d.mapper.Get(ingtypes.HostAuthTLSOCSP).Bool()
+		tls.OCSPFailOpen = tls.OCSPCheck && d.mapper.Get(ingtypes.HostAuthTLSOCSPFailOpen).Bool()
 	} else {
 		c.logger.Error("error building TLS auth config on %s: %v", tlsSecret.Source, err)
 	}
@@ -54,18 +59,39 @@ func (c *updater) buildHostAuthTLS(d *hostData) {
 	tls.CAErrorPage = d.mapper.Get(ingtypes.HostAuthTLSErrorPage).Value
 }
 
+func (c *updater) buildHostAuthTLSCRLRefresh(d *hostData, tlsSecret *ConfigValue) {
+	crlRefresh := d.mapper.Get(ingtypes.HostAuthTLSCRLRefresh)
+	if crlRefresh.Value == "" {
+		return
+	}
+	refresh, err := time.ParseDuration(crlRefresh.Value)
+	if err != nil || refresh <= 0 {
+		c.warn(crlRefresh.Source, "ignoring invalid CRL refresh interval on %v: %s", crlRefresh.Source, crlRefresh.Value)
+		return
+	}
+	tls := &d.host.TLS
+	tls.CRLRefresh = refresh
+	if crlfile, err := c.cache.GetRefreshedCRLPath(tlsSecret.Source.Namespace, tlsSecret.Value, refresh); err == nil {
+		tls.CRLFilename = crlfile.Filename
+		tls.CRLHash = crlfile.SHA1Hash
+	} else {
+		// the refreshed CRL is used as soon as the first download finishes
+		c.logger.InfoV(2, "using the CRL from the secret on %v: %v", crlRefresh.Source, err)
+	}
+}
+
 func (c *updater) buildHostCertSigner(d *hostData) {
 	signer := d.mapper.Get(ingtypes.HostCertSigner)
 	if signer.Value == "" {
 		return
 	}
 	if signer.Value != "acme" {
-		c.logger.Warn("ignoring invalid cert-signer on %v: %s", signer.Source, signer.Value)
+		c.warn(signer.Source, "ignoring invalid cert-signer on %v: %s", signer.Source, signer.Value)
 		return
 	}
 	acmeData := c.haproxy.AcmeData()
 	if acmeData.Endpoint == "" || acmeData.Emails == "" {
-		c.logger.Warn("ignoring acme signer on %v due to missing endpoint or email config", signer.Source)
+		c.warn(signer.Source, "ignoring acme signer on %v due to missing endpoint or email config", signer.Source)
 		return
 	}
 	// just the warnings, ingress.syncIngress() has already added the domains
@@ -78,12 +104,12 @@ func (c *updater) buildHostSSLPassthrough(d *hostData) {
 	}
 	rootPath := d.host.FindPath("/")
 	if rootPath == nil {
-		c.logger.Warn("skipping SSL of %s: root path was not configured", sslpassthrough.Source)
+		c.warn(sslpassthrough.Source, "skipping SSL of %s: root path was not configured", sslpassthrough.Source)
 		return
 	}
 	for _, path := range d.host.Paths {
 		if path.Path != "/" {
-			c.logger.Warn("ignoring path '%s' from %s: ssl-passthrough only support root path", path.Path, sslpassthrough.Source)
+			c.warn(sslpassthrough.Source, "ignoring path '%s' from %s: ssl-passthrough only support root path", path.Path, sslpassthrough.Source)
 		}
 	}
 	sslpassHTTPPort := d.mapper.Get(ingtypes.HostSSLPassthroughHTTPPort)
@@ -109,4 +135,5 @@ func (c *updater) buildHostTLSConfig(d *hostData) {
 		d.host.TLS.ALPN = cfg.Value
 	}
 	d.host.TLS.Options = d.mapper.Get(ingtypes.HostSSLOptionsHost).Value
+	d.host.TLS.OCSPStapling = d.mapper.Get(ingtypes.HostSSLOCSPStapling).Bool()
 }
@@ -18,6 +18,7 @@ package annotations
 
 import (
 	"testing"
+	"time"
 
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
@@ -198,12 +199,120 @@ func TestTLSConfig(t *testing.T) {
 				Options: "ssl-min-ver TLSv1.0 ssl-max-ver TLSv1.2",
 			},
 		},
+		// 18
+		{
+			annDefault: map[string]string{
+				ingtypes.HostSSLOCSPStapling: "true",
+			},
+			expected: hatypes.HostTLSConfig{
+				OCSPStapling: true,
+			},
+		},
+		// 19
+		{
+			annDefault: map[string]string{
+				ingtypes.HostSSLOCSPStapling: "true",
+			},
+			ann: map[string]string{
+				ingtypes.HostSSLOCSPStapling: "false",
+			},
+			expected: hatypes.HostTLSConfig{},
+		},
+		// 20
+		{
+			ann: map[string]string{
+				ingtypes.HostAuthTLSSecret: "cafile",
+				ingtypes.HostAuthTLSOCSP:   "true",
+			},
+			expected: hatypes.HostTLSConfig{
+				CAFilename: "/path/ca.crt",
+				CAHash:     "c0e1bf73caf75d7353cf3ecdd20ceb2f6fa1cab1",
+				OCSPCheck:  true,
+			},
+		},
+		// 21
+		{
+			ann: map[string]string{
+				ingtypes.HostAuthTLSSecret: "caerr",
+				ingtypes.HostAuthTLSOCSP:   "true",
+			},
+			expected: hatypes.HostTLSConfig{},
+			logging:  "ERROR error building TLS auth config on ingress 'system/ing1': secret not found: 'system/caerr'",
+		},
+		// 22
+		{
+			ann: map[string]string{
+				ingtypes.HostAuthTLSSecret:     "cafile",
+				ingtypes.HostAuthTLSCRLRefresh: "1h",
+			},
+			expected: hatypes.HostTLSConfig{
+				CAFilename: "/path/ca.crt",
+				CAHash:     "c0e1bf73caf75d7353cf3ecdd20ceb2f6fa1cab1",
+				CRLRefresh: time.Hour,
+			},
+			logging: "INFO-V(2) using the CRL from the secret on ingress 'system/ing1': CRL of secret 'system/cafile' was not downloaded yet",
+		},
+		// 23
+		{
+			ann: map[string]string{
+				ingtypes.HostAuthTLSSecret:     "cafile2",
+				ingtypes.HostAuthTLSCRLRefresh: "1h",
+			},
+			expected: hatypes.HostTLSConfig{
+				CAFilename:  "/path/ca2.crt",
+				CAHash:      "628edf730f80910c6a8dce8f94e033a202239fab",
+				CRLFilename: "/path/ca2.crt.crl",
+				CRLHash:     "3e72b288543ba44055682472c65f268f61234bc0",
+				CRLRefresh:  time.Hour,
+			},
+		},
+		// 24
+		{
+			ann: map[string]string{
+				ingtypes.HostAuthTLSSecret:     "cafile2",
+				ingtypes.HostAuthTLSCRLRefresh: "1x",
+			},
+			expected: hatypes.HostTLSConfig{
+				CAFilename: "/path/ca2.crt",
+				CAHash:     "628edf730f80910c6a8dce8f94e033a202239fab",
+			},
+			logging: "WARN ignoring invalid CRL refresh interval on ingress 'system/ing1': 1x",
+		},
+		// 25
+		{
+			ann: map[string]string{
+				ingtypes.HostAuthTLSSecret:       "cafile",
+				ingtypes.HostAuthTLSOCSP:         "true",
+				ingtypes.HostAuthTLSOCSPFailOpen: "true",
+			},
+			expected: hatypes.HostTLSConfig{
+				CAFilename:   "/path/ca.crt",
+				CAHash:       "c0e1bf73caf75d7353cf3ecdd20ceb2f6fa1cab1",
+				OCSPCheck:    true,
+				OCSPFailOpen: true,
+			},
+		},
+		// 26
+		{
+			ann: map[string]string{
+				ingtypes.HostAuthTLSSecret:       "cafile",
+				ingtypes.HostAuthTLSOCSPFailOpen: "true",
+			},
+			expected: hatypes.HostTLSConfig{
+				CAFilename: "/path/ca.crt",
+				CAHash:     "c0e1bf73caf75d7353cf3ecdd20ceb2f6fa1cab1",
+			},
+		},
 	}
 	source := &Source{Namespace: "system", Name: "ing1", Type: "ingress"}
 	for i, test := range testCases {
 		c := setup(t)
 		c.cache.SecretCAPath = map[string]string{
-			"system/cafile": "/path/ca.crt",
+			"system/cafile":  "/path/ca.crt",
+			"system/cafile2": "/path/ca2.crt",
+		}
+		c.cache.RefreshedCRL = map[string]string{
+			"system/cafile2": "/path/ca2.crt.crl",
 		}
 		d := c.createHostData(source, test.ann, test.annDefault)
 		updater := c.createUpdater()
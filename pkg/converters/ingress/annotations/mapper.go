@@ -21,6 +21,7 @@ import (
 	"sort"
 	"strconv"
 
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 )
@@ -28,14 +29,19 @@ import (
 // MapBuilder ...
 type MapBuilder struct {
 	logger      types.Logger
+	diagnostics convtypes.Diagnostics
 	annPrefix   string
 	annDefaults map[string]string
+	policy      Policy
 }
 
 // Mapper ...
 type Mapper struct {
 	MapBuilder
-	maps map[string][]*Map
+	maps       map[string][]*Map
+	conflicts  map[string][]*Map
+	nsSource   *Source
+	nsDefaults map[string]string
 }
 
 // Map ...
@@ -80,11 +86,24 @@ func NewMapBuilder(logger types.Logger, annPrefix string, annDefaults map[string
 	}
 }
 
+// SetPolicy configures the annotation policy of the mappers created
+// by this builder. Values not allowed by the policy are ignored.
+func (b *MapBuilder) SetPolicy(policy Policy) {
+	b.policy = policy
+}
+
+// SetDiagnostics configures the collector that receives the warnings
+// of the mappers created by this builder.
+func (b *MapBuilder) SetDiagnostics(diagnostics convtypes.Diagnostics) {
+	b.diagnostics = diagnostics
+}
+
 // NewMapper ...
 func (b *MapBuilder) NewMapper() *Mapper {
 	return &Mapper{
 		MapBuilder: *b,
 		maps:       map[string][]*Map{},
+		conflicts:  map[string][]*Map{},
 	}
 }
 
@@ -98,15 +117,29 @@ func (c *Mapper) addAnnotation(source *Source, link hatypes.PathLink, key, value
 	if found {
 		for _, annMap := range annMaps {
 			if annMap.Link == link {
-				return annMap.Value != value
+				if annMap.Value != value {
+					c.conflicts[key] = append(c.conflicts[key], &Map{
+						Source: source,
+						Link:   link,
+						Value:  value,
+					})
+					return true
+				}
+				return false
 			}
 		}
 	}
+	if c.policy != nil && !c.policy.AllowValue(source.Namespace, key, value) {
+		Warn(c.logger, c.diagnostics, []*Source{source},
+			"ignoring annotation '%s' on %v: value not allowed by the annotation policy: %s",
+			c.annPrefix+key, source, value)
+		return
+	}
 	var realValue string
 	var ok bool
 	validator, found := validators[key]
 	if found {
-		if realValue, ok = validator(validate{logger: c.logger, source: source, key: key, value: value}); !ok {
+		if realValue, ok = validator(validate{logger: c.logger, diagnostics: c.diagnostics, source: source, key: key, value: value}); !ok {
 			return
 		}
 	} else {
@@ -121,6 +154,28 @@ func (c *Mapper) addAnnotation(source *Source, link hatypes.PathLink, key, value
 	return
 }
 
+// AddNamespaceAnnotations adds the annotations of a namespace, used as the
+// default value of keys not declared on ingress and service objects. Global
+// defaults are used if the key is also missing on the namespace. Only the
+// first namespace added is used, so a host declared on ingress objects of
+// distinct namespaces uses the namespace of the first parsed ingress.
+func (c *Mapper) AddNamespaceAnnotations(source *Source, ann map[string]string) {
+	if c.nsSource != nil {
+		return
+	}
+	c.nsSource = source
+	c.nsDefaults = make(map[string]string, len(ann))
+	for key, value := range ann {
+		if validator, found := validators[key]; found {
+			var ok bool
+			if value, ok = validator(validate{logger: c.logger, diagnostics: c.diagnostics, source: source, key: key, value: value}); !ok {
+				continue
+			}
+		}
+		c.nsDefaults[key] = value
+	}
+}
+
 // AddAnnotations ...
 func (c *Mapper) AddAnnotations(source *Source, link hatypes.PathLink, ann map[string]string) (conflicts []string) {
 	conflicts = make([]string, 0, len(ann))
@@ -132,15 +187,32 @@ func (c *Mapper) AddAnnotations(source *Source, link hatypes.PathLink, ann map[s
 	return conflicts
 }
 
+// GetConflicts returns the annotations that were not added due to a
+// distinct value declared before on the same hostname and path.
+func (c *Mapper) GetConflicts() map[string][]*Map {
+	return c.conflicts
+}
+
 // GetStrMap ...
 func (c *Mapper) GetStrMap(key string) ([]*Map, bool) {
 	annMaps, found := c.maps[key]
 	if found && len(annMaps) > 0 {
 		return annMaps, true
 	}
-	value, found := c.annDefaults[key]
-	if found {
-		return []*Map{{Value: value}}, true
+	if value, found := c.getDefault(key); found {
+		return []*Map{{Source: value.Source, Value: value.Value}}, true
+	}
+	return nil, false
+}
+
+// getDefault returns the value of a key from the namespace, or
+// from the global defaults if not declared in the namespace.
+func (c *Mapper) getDefault(key string) (*ConfigValue, bool) {
+	if value, found := c.nsDefaults[key]; found {
+		return &ConfigValue{Source: c.nsSource, Value: value}, true
+	}
+	if value, found := c.annDefaults[key]; found {
+		return &ConfigValue{Value: value}, true
 	}
 	return nil, false
 }
@@ -163,7 +235,7 @@ func (c *Mapper) Get(key string) *ConfigValue {
 			}
 		}
 		if len(sources) > 0 {
-			c.logger.Warn(
+			Warn(c.logger, c.diagnostics, append([]*Source{value.Source}, sources...),
 				"annotation '%s' from %s overrides the same annotation with distinct value from %s",
 				c.annPrefix+key, value.Source, sources)
 		}
@@ -196,10 +268,8 @@ func (c *Mapper) GetBackendConfig(backend *hatypes.Backend, keys []string, overw
 	for _, path := range backend.Paths {
 		kv := make(map[string]*ConfigValue, len(keys))
 		for _, key := range keys {
-			if value, found := c.annDefaults[key]; found {
-				kv[key] = &ConfigValue{
-					Value: value,
-				}
+			if value, found := c.getDefault(key); found {
+				kv[key] = value
 			}
 		}
 		rawConfig[path.Link] = kv
@@ -323,7 +393,22 @@ func (m *Map) String() string {
 	return fmt.Sprintf("%+v", *m)
 }
 
+// LogFields ...
+func (s *Source) LogFields() []types.LogField {
+	if s == nil {
+		return nil
+	}
+	if s.Type == "namespace" {
+		return []types.LogField{types.LogNamespace(s.Name)}
+	}
+	return types.LogObject{Kind: s.Type, FullName: s.FullName()}.LogFields()
+}
+
 // String ...
 func (s *Source) String() string {
+	if s.Type == "namespace" {
+		// namespaces are cluster scoped
+		return s.Type + " '" + s.Name + "'"
+	}
 	return s.Type + " '" + s.FullName() + "'"
 }
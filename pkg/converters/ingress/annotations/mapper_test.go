@@ -223,6 +223,7 @@ func TestGetAnnotation(t *testing.T) {
 func TestGetDefault(t *testing.T) {
 	testCases := []struct {
 		annDefaults map[string]string
+		nsAnn       map[string]string
 		ann         map[string]string
 		expAnn      map[string]string
 	}{
@@ -286,11 +287,46 @@ func TestGetDefault(t *testing.T) {
 				"balance":        "leastconn",
 			},
 		},
+		// 5
+		{
+			annDefaults: map[string]string{
+				"timeout-client": "10s",
+				"balance":        "roundrobin",
+			},
+			nsAnn: map[string]string{
+				"balance": "leastconn",
+			},
+			expAnn: map[string]string{
+				"timeout-client": "10s",
+				"balance":        "leastconn",
+			},
+		},
+		// 6
+		{
+			annDefaults: map[string]string{
+				"timeout-client": "10s",
+				"balance":        "roundrobin",
+			},
+			nsAnn: map[string]string{
+				"timeout-client": "20s",
+				"balance":        "leastconn",
+			},
+			ann: map[string]string{
+				"balance": "first",
+			},
+			expAnn: map[string]string{
+				"timeout-client": "20s",
+				"balance":        "first",
+			},
+		},
 	}
 	pathRoot := hatypes.CreatePathLink("domain.local", "/")
 	for i, test := range testCases {
 		c := setup(t)
 		mapper := NewMapBuilder(c.logger, "ing.k8s.io", test.annDefaults).NewMapper()
+		if test.nsAnn != nil {
+			mapper.AddNamespaceAnnotations(&Source{Namespace: "default", Name: "default", Type: "namespace"}, test.nsAnn)
+		}
 		mapper.AddAnnotations(&Source{}, pathRoot, test.ann)
 		for key, exp := range test.expAnn {
 			value := mapper.Get(key).Value
@@ -0,0 +1,150 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotations
+
+import (
+	"fmt"
+	"regexp"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Policy restricts the annotation keys, and the values of these keys,
+// that ingress and service objects of a namespace can use.
+type Policy interface {
+	AllowKey(namespace, key string) bool
+	AllowValue(namespace, key, value string) bool
+}
+
+// NamespaceLabels ...
+type NamespaceLabels func(namespace string) map[string]string
+
+// NewPolicy parses the annotation policy, a YAML list of rules. Rules are
+// applied to the namespaces matched by their namespace selector, all the
+// namespaces are matched if the selector is empty. A namespace matched by
+// more than one rule must satisfy all of them. An empty config means that
+// all the annotations are allowed.
+func NewPolicy(config string, nsLabels NamespaceLabels) (Policy, error) {
+	var rawRules []struct {
+		NamespaceSelector string            `yaml:"namespaceSelector"`
+		Allow             []string          `yaml:"allow"`
+		Deny              []string          `yaml:"deny"`
+		Values            map[string]string `yaml:"values"`
+	}
+	if err := yaml.UnmarshalStrict([]byte(config), &rawRules); err != nil {
+		return nil, err
+	}
+	rules := make([]*policyRule, len(rawRules))
+	for i, raw := range rawRules {
+		selector, err := labels.Parse(raw.NamespaceSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace selector on rule %d: %v", i, err)
+		}
+		rule := &policyRule{
+			selector: selector,
+			allow:    make(map[string]bool, len(raw.Allow)),
+			deny:     make(map[string]bool, len(raw.Deny)),
+			values:   make(map[string]*regexp.Regexp, len(raw.Values)),
+		}
+		for _, key := range raw.Allow {
+			rule.allow[key] = true
+		}
+		for _, key := range raw.Deny {
+			rule.deny[key] = true
+		}
+		for key, value := range raw.Values {
+			// values should match entirely
+			rx, err := regexp.Compile("^(?:" + value + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of key '%s' on rule %d: %v", key, i, err)
+			}
+			rule.values[key] = rx
+		}
+		rules[i] = rule
+	}
+	return &policy{
+		rules:    rules,
+		nsLabels: nsLabels,
+		nsRules:  map[string][]*policyRule{},
+	}, nil
+}
+
+// NewDenyAllPolicy returns a policy that denies all the annotations. It should
+// be used if the configured policy cannot be parsed, so an invalid policy does
+// not leave the namespaces unrestricted.
+func NewDenyAllPolicy() Policy {
+	return &denyAllPolicy{}
+}
+
+type policy struct {
+	rules    []*policyRule
+	nsLabels NamespaceLabels
+	nsRules  map[string][]*policyRule
+}
+
+type policyRule struct {
+	selector labels.Selector
+	allow    map[string]bool
+	deny     map[string]bool
+	values   map[string]*regexp.Regexp
+}
+
+func (p *policy) AllowKey(namespace, key string) bool {
+	for _, rule := range p.matchRules(namespace) {
+		if rule.deny[key] || (len(rule.allow) > 0 && !rule.allow[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *policy) AllowValue(namespace, key, value string) bool {
+	for _, rule := range p.matchRules(namespace) {
+		if rx, found := rule.values[key]; found && !rx.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *policy) matchRules(namespace string) []*policyRule {
+	rules, found := p.nsRules[namespace]
+	if !found {
+		var nsLabels labels.Set
+		if p.nsLabels != nil {
+			nsLabels = p.nsLabels(namespace)
+		}
+		for _, rule := range p.rules {
+			if rule.selector.Matches(nsLabels) {
+				rules = append(rules, rule)
+			}
+		}
+		p.nsRules[namespace] = rules
+	}
+	return rules
+}
+
+type denyAllPolicy struct{}
+
+func (p *denyAllPolicy) AllowKey(namespace, key string) bool {
+	return false
+}
+
+func (p *denyAllPolicy) AllowValue(namespace, key, value string) bool {
+	return false
+}
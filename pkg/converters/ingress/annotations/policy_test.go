@@ -0,0 +1,155 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotations
+
+import (
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	nsLabels := map[string]map[string]string{
+		"team1":  {"tenant": "true", "team": "team1"},
+		"team2":  {"tenant": "true", "team": "team2"},
+		"system": {},
+	}
+	type check struct {
+		namespace string
+		key       string
+		value     string
+		allowed   bool
+	}
+	testCases := []struct {
+		config   string
+		checks   []check
+		expError string
+	}{
+		// 0
+		{
+			config: ``,
+			checks: []check{
+				{"team1", "config-backend", "", true},
+				{"team1", "ssl-redirect", "false", true},
+			},
+		},
+		// 1
+		{
+			config: `
+- deny: [config-backend]
+`,
+			checks: []check{
+				{"team1", "config-backend", "", false},
+				{"system", "config-backend", "", false},
+				{"team1", "ssl-redirect", "", true},
+			},
+		},
+		// 2
+		{
+			config: `
+- namespaceSelector: tenant=true
+  deny: [config-backend]
+`,
+			checks: []check{
+				{"team1", "config-backend", "", false},
+				{"system", "config-backend", "", true},
+				{"other", "config-backend", "", true},
+			},
+		},
+		// 3
+		{
+			config: `
+- namespaceSelector: team notin (team2)
+  allow: [timeout-server, ssl-redirect]
+`,
+			checks: []check{
+				{"team1", "timeout-server", "", true},
+				{"team1", "config-backend", "", false},
+				{"system", "config-backend", "", false},
+				{"team2", "config-backend", "", true},
+			},
+		},
+		// 4
+		{
+			config: `
+- namespaceSelector: tenant=true
+  values:
+    ssl-redirect: "true"
+    auth-tls-secret: "[^/]+"
+`,
+			checks: []check{
+				{"team1", "ssl-redirect", "true", true},
+				{"team1", "ssl-redirect", "false", false},
+				{"team1", "ssl-redirect", "true1", false},
+				{"team1", "auth-tls-secret", "ca", true},
+				{"team1", "auth-tls-secret", "team2/ca", false},
+				{"team1", "timeout-server", "10s", true},
+				{"system", "ssl-redirect", "false", true},
+			},
+		},
+		// 5
+		{
+			config: `
+- allow: [timeout-server, timeout-client]
+- namespaceSelector: team=team1
+  deny: [timeout-client]
+`,
+			checks: []check{
+				{"team1", "timeout-server", "", true},
+				{"team1", "timeout-client", "", false},
+				{"team2", "timeout-client", "", true},
+			},
+		},
+		// 6
+		{
+			config:   `- namespaceSelector: "team in ("`,
+			expError: "invalid namespace selector on rule 0: unable to parse requirement: found '', expected: ',', ')' or identifier",
+		},
+		// 7
+		{
+			config: `
+- values:
+    ssl-redirect: "(true"
+`,
+			expError: "invalid value of key 'ssl-redirect' on rule 0: error parsing regexp: missing closing ): `^(?:(true)$`",
+		},
+	}
+	for i, test := range testCases {
+		policy, err := NewPolicy(test.config, func(namespace string) map[string]string {
+			return nsLabels[namespace]
+		})
+		var actualError string
+		if err != nil {
+			actualError = err.Error()
+		}
+		if actualError != test.expError {
+			t.Errorf("error differs on %d - expected: %s, actual: %s", i, test.expError, actualError)
+		}
+		if err != nil {
+			continue
+		}
+		for j, check := range test.checks {
+			var allowed bool
+			if check.value == "" {
+				allowed = policy.AllowKey(check.namespace, check.key)
+			} else {
+				allowed = policy.AllowValue(check.namespace, check.key, check.value)
+			}
+			if allowed != check.allowed {
+				t.Errorf("allowed differs on %d/%d - expected: %t, actual: %t", i, j, check.allowed, allowed)
+			}
+		}
+	}
+}
@@ -18,10 +18,11 @@ package annotations
 
 import (
 	"net"
-	"regexp"
+	"strconv"
 
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	convutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/utils"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
@@ -38,20 +39,28 @@ type Updater interface {
 // NewUpdater ...
 func NewUpdater(haproxy haproxy.Config, options *ingtypes.ConverterOptions) Updater {
 	return &updater{
-		haproxy: haproxy,
-		logger:  options.Logger,
-		cache:   options.Cache,
-		tracker: options.Tracker,
-		fakeCA:  options.FakeCAFile,
+		haproxy:         haproxy,
+		logger:          options.Logger,
+		diagnostics:     options.Diagnostics,
+		cache:           options.Cache,
+		tracker:         options.Tracker,
+		fakeCA:          options.FakeCAFile,
+		grpcHealthAgent: options.GRPCHealthAgent,
 	}
 }
 
 type updater struct {
-	haproxy haproxy.Config
-	logger  types.Logger
-	cache   convtypes.Cache
-	tracker convtypes.Tracker
-	fakeCA  convtypes.CrtFile
+	haproxy         haproxy.Config
+	logger          types.Logger
+	diagnostics     convtypes.Diagnostics
+	cache           convtypes.Cache
+	tracker         convtypes.Tracker
+	fakeCA          convtypes.CrtFile
+	grpcHealthAgent string
+}
+
+func (c *updater) warn(source *Source, msg string, args ...interface{}) {
+	Warn(c.logger, c.diagnostics, []*Source{source}, msg, args...)
 }
 
 type globalData struct {
@@ -70,12 +79,10 @@ type backData struct {
 	mapper  *Mapper
 }
 
-var regexValidTime = regexp.MustCompile(`^[0-9]+(us|ms|s|m|h|d)$`)
-
 func (c *updater) validateTime(cfg *ConfigValue) string {
-	if !regexValidTime.MatchString(cfg.Value) {
+	if !convutils.IsValidTime(cfg.Value) {
 		if cfg.Source != nil {
-			c.logger.Warn("ignoring invalid time format on %v: %s", cfg.Source, cfg.Value)
+			c.warn(cfg.Source, "ignoring invalid time format on %v: %s", cfg.Source, cfg.Value)
 		} else if cfg.Value != "" {
 			c.logger.Warn("ignoring invalid time format on global/default config: %s", cfg.Value)
 		}
@@ -92,7 +99,7 @@ func (c *updater) splitCIDR(cidrlist *ConfigValue) []string {
 			_, _, err = net.ParseCIDR(cidr)
 		}
 		if err != nil {
-			c.logger.Warn("skipping invalid IP or cidr on %v: %s", cidrlist.Source, cidr)
+			c.warn(cidrlist.Source, "skipping invalid IP or cidr on %v: %s", cidrlist.Source, cidr)
 		} else {
 			cidrslice = append(cidrslice, cidr)
 		}
@@ -100,6 +107,24 @@ func (c *updater) splitCIDR(cidrlist *ConfigValue) []string {
 	return cidrslice
 }
 
+// buildGlobalGRPCHealthAgent configures the address haproxy uses to reach
+// the gRPC health agent of the controller, 127.0.0.1:10260 if not declared.
+// The address is validated by the controller on startup.
+func (c *updater) buildGlobalGRPCHealthAgent(d *globalData) {
+	addr, port := "127.0.0.1", 10260
+	if host, portStr, err := net.SplitHostPort(c.grpcHealthAgent); err == nil {
+		if portNum, err := strconv.Atoi(portStr); err == nil {
+			port = portNum
+		}
+		// haproxy connects to the loopback if the agent listens on all the addresses
+		if host != "" && !net.ParseIP(host).IsUnspecified() {
+			addr = host
+		}
+	}
+	d.global.GRPCHealthAgent.Addr = addr
+	d.global.GRPCHealthAgent.Port = port
+}
+
 func (c *updater) UpdateGlobalConfig(haproxyConfig haproxy.Config, mapper *Mapper) {
 	d := &globalData{
 		acmeData: haproxyConfig.AcmeData(),
@@ -107,6 +132,7 @@ func (c *updater) UpdateGlobalConfig(haproxyConfig haproxy.Config, mapper *Mappe
 		mapper:   mapper,
 	}
 	d.global.AdminSocket = "/var/run/haproxy/admin.sock"
+	c.buildGlobalGRPCHealthAgent(d)
 	d.global.MaxConn = mapper.Get(ingtypes.GlobalMaxConnections).Int()
 	d.global.DrainSupport.Drain = mapper.Get(ingtypes.GlobalDrainSupport).Bool()
 	d.global.DrainSupport.Redispatch = mapper.Get(ingtypes.GlobalDrainSupportRedispatch).Bool()
@@ -120,6 +146,7 @@ func (c *updater) UpdateGlobalConfig(haproxyConfig haproxy.Config, mapper *Mappe
 	c.buildGlobalBind(d)
 	c.buildGlobalCustomConfig(d)
 	c.buildGlobalDNS(d)
+	c.buildGlobalDynamicTCP(d)
 	c.buildGlobalForwardFor(d)
 	c.buildGlobalHTTPStoHTTP(d)
 	c.buildGlobalModSecurity(d)
@@ -165,11 +192,12 @@ func (c *updater) UpdateBackendConfig(backend *hatypes.Backend, mapper *Mapper)
 	c.buildBackendDynamic(data)
 	c.buildBackendAgentCheck(data)
 	c.buildBackendHeaders(data)
-	c.buildBackendHealthCheck(data)
 	c.buildBackendHSTS(data)
 	c.buildBackendLimit(data)
 	c.buildBackendOAuth(data)
 	c.buildBackendProtocol(data)
+	// gRPC health check depends on the backend protocol
+	c.buildBackendHealthCheck(data)
 	c.buildBackendProxyProtocol(data)
 	c.buildBackendRewriteURL(data)
 	c.buildBackendServerNaming(data)
@@ -140,7 +140,8 @@ func (c *testConfig) compareObjects(name string, index int, actual, expected int
 
 func (c *testConfig) createGlobalData(config map[string]string) *globalData {
 	return &globalData{
-		global: &hatypes.Global{},
-		mapper: NewMapBuilder(c.logger, "", config).NewMapper(),
+		acmeData: &hatypes.AcmeData{},
+		global:   &hatypes.Global{},
+		mapper:   NewMapBuilder(c.logger, "", config).NewMapper(),
 	}
 }
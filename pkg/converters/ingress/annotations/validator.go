@@ -21,14 +21,20 @@ import (
 	"strconv"
 
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 )
 
 type validate struct {
-	logger types.Logger
-	source *Source
-	key    string
-	value  string
+	logger      types.Logger
+	diagnostics convtypes.Diagnostics
+	source      *Source
+	key         string
+	value       string
+}
+
+func (v validate) warn(msg string, args ...interface{}) {
+	Warn(v.logger, v.diagnostics, []*Source{v.source}, msg, args...)
 }
 
 var (
@@ -43,28 +49,28 @@ var validators = map[string]func(v validate) (string, bool){
 		if corsHeadersRegex.MatchString(v.value) {
 			return v.value, true
 		}
-		v.logger.Warn("ignoring invalid cors headers on %s: %s", v.source, v.value)
+		v.warn("ignoring invalid cors headers on %s: %s", v.source, v.value)
 		return "", false
 	},
 	ingtypes.BackCorsAllowMethods: func(v validate) (string, bool) {
 		if corsMethodsRegex.MatchString(v.value) {
 			return v.value, true
 		}
-		v.logger.Warn("ignoring invalid cors methods on %s: %s", v.source, v.value)
+		v.warn("ignoring invalid cors methods on %s: %s", v.source, v.value)
 		return "", false
 	},
 	ingtypes.BackCorsAllowOrigin: func(v validate) (string, bool) {
 		if corsOriginRegex.MatchString(v.value) {
 			return v.value, true
 		}
-		v.logger.Warn("ignoring invalid cors origin on %s: %s", v.source, v.value)
+		v.warn("ignoring invalid cors origin on %s: %s", v.source, v.value)
 		return "", false
 	},
 	ingtypes.BackCorsExposeHeaders: func(v validate) (string, bool) {
 		if corsHeadersRegex.MatchString(v.value) {
 			return v.value, true
 		}
-		v.logger.Warn("ignoring invalid cors expose headers on %s: %s", v.source, v.value)
+		v.warn("ignoring invalid cors expose headers on %s: %s", v.source, v.value)
 		return "", false
 	},
 	ingtypes.BackCorsMaxAge: func(v validate) (string, bool) {
@@ -72,7 +78,7 @@ var validators = map[string]func(v validate) (string, bool){
 		if err == nil || maxAge > 0 {
 			return v.value, true
 		}
-		v.logger.Warn("ignoring invalid cors max age on %s: %s", v.source, v.value)
+		v.warn("ignoring invalid cors max age on %s: %s", v.source, v.value)
 		return "", false
 	},
 	ingtypes.BackHSTS:                  validateBool,
@@ -86,7 +92,7 @@ func validateBool(v validate) (string, bool) {
 	if res, err := strconv.ParseBool(v.value); err == nil {
 		return strconv.FormatBool(res), true
 	}
-	v.logger.Warn("ignoring invalid bool expression on %s key '%s': %s", v.source, v.key, v.value)
+	v.warn("ignoring invalid bool expression on %s key '%s': %s", v.source, v.key, v.value)
 	return "", false
 }
 
@@ -94,6 +100,6 @@ func validateInt(v validate) (string, bool) {
 	if res, err := strconv.Atoi(v.value); err == nil {
 		return strconv.Itoa(res), true
 	}
-	v.logger.Warn("ignoring invalid int expression on %s key '%s': %s", v.source, v.key, v.value)
+	v.warn("ignoring invalid int expression on %s key '%s': %s", v.source, v.key, v.value)
 	return "", false
 }
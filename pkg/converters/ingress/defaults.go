@@ -30,11 +30,16 @@ const (
 
 func createDefaults() map[string]string {
 	return map[string]string{
-		types.HostAuthTLSStrict:   "false",
-		types.HostSSLCiphers:      defaultSSLCiphers,
-		types.HostSSLCipherSuites: defaultSSLCipherSuites,
-		types.HostSSLOptionsHost:  "",
-		types.HostTLSALPN:         "h2,http/1.1",
+		types.HostAcmeChallenge:       "http-01",
+		types.HostAcmeKeyType:         "rsa2048",
+		types.HostAuthTLSOCSP:         "false",
+		types.HostAuthTLSOCSPFailOpen: "false",
+		types.HostAuthTLSStrict:       "false",
+		types.HostSSLCiphers:          defaultSSLCiphers,
+		types.HostSSLCipherSuites:     defaultSSLCipherSuites,
+		types.HostSSLOCSPStapling:     "false",
+		types.HostSSLOptionsHost:      "",
+		types.HostTLSALPN:             "h2,http/1.1",
 		//
 		types.BackBackendServerNaming:    "sequence",
 		types.BackBackendServerSlotsInc:  "1",
@@ -45,6 +50,7 @@ func createDefaults() map[string]string {
 		types.BackCorsAllowOrigin:        "*",
 		types.BackCorsMaxAge:             "86400",
 		types.BackDynamicScaling:         "true",
+		types.BackHealthCheckGRPC:        "false",
 		types.BackHealthCheckInterval:    "2s",
 		types.BackHSTS:                   "true",
 		types.BackHSTSIncludeSubdomains:  "false",
@@ -66,6 +72,8 @@ func createDefaults() map[string]string {
 		types.BackWAFMode:                "deny",
 		//
 		types.GlobalAcmeExpiring:                 "30",
+		types.GlobalAcmeExpiringRatio:            "0.33",
+		types.GlobalAcmeOrphanGracePeriod:        "24h",
 		types.GlobalCookieKey:                    "Ingress",
 		types.GlobalDNSAcceptedPayloadSize:       "8192",
 		types.GlobalDNSClusterDomain:             "cluster.local",
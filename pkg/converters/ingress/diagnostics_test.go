@@ -0,0 +1,122 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"reflect"
+	"testing"
+
+	networking "k8s.io/api/networking/v1beta1"
+
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+)
+
+func TestDiagnostics(t *testing.T) {
+	testCases := []struct {
+		svcAnn   map[string]string
+		ingAnn   []map[string]string
+		expected []string
+		logging  string
+	}{
+		// 0
+		{
+			ingAnn: []map[string]string{{
+				"ingress.kubernetes.io/balance-algorithm": "leastconn",
+			}},
+		},
+		// 1
+		{
+			ingAnn: []map[string]string{{
+				"ingress.kubernetes.io/proxy-body-size": "1x",
+			}},
+			expected: []string{
+				"ingress default/app1: ignoring invalid body size on ingress 'default/app1': 1x",
+			},
+			logging: `WARN ignoring invalid body size on ingress 'default/app1': 1x`,
+		},
+		// 2
+		{
+			svcAnn: map[string]string{
+				"ingress.kubernetes.io/proxy-body-size": "1x",
+			},
+			ingAnn: []map[string]string{{}},
+			expected: []string{
+				"service default/echo: ignoring invalid body size on service 'default/echo': 1x",
+			},
+			logging: `WARN ignoring invalid body size on service 'default/echo': 1x`,
+		},
+		// 3
+		{
+			ingAnn: []map[string]string{
+				{"ingress.kubernetes.io/balance-algorithm": "roundrobin"},
+				{"ingress.kubernetes.io/balance-algorithm": "leastconn"},
+			},
+			expected: []string{
+				"ingress default/app1: annotation 'ingress.kubernetes.io/balance-algorithm' from ingress 'default/app1' overrides the same annotation with distinct value from [ingress 'default/app2']",
+				"ingress default/app2: annotation 'ingress.kubernetes.io/balance-algorithm' from ingress 'default/app1' overrides the same annotation with distinct value from [ingress 'default/app2']",
+			},
+			logging: `WARN annotation 'ingress.kubernetes.io/balance-algorithm' from ingress 'default/app1' overrides the same annotation with distinct value from [ingress 'default/app2']`,
+		},
+		// 4
+		{
+			ingAnn: []map[string]string{{
+				"ingress.kubernetes.io/ssl-redirect": "yes",
+			}},
+			expected: []string{
+				"ingress default/app1: ignoring invalid bool expression on ingress 'default/app1' key 'ssl-redirect': yes",
+			},
+			logging: `WARN ignoring invalid bool expression on ingress 'default/app1' key 'ssl-redirect': yes`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.createSvc1AutoAnn(test.svcAnn)
+		var ingList []*networking.Ingress
+		for j, ann := range test.ingAnn {
+			id := string(rune('1' + j))
+			ingList = append(ingList, c.createIng1Ann("default/app"+id, "domain.local", "/app"+id, "echo:8080", ann))
+		}
+		c.cache.IngList = ingList
+		c.cache.Changed.GlobalNew = map[string]string{}
+		c.cache.SecretTLSPath["system/default"] = "/tls/tls-default.pem"
+		diag := &diagnosticsMock{}
+		NewIngressConverter(&ingtypes.ConverterOptions{
+			Cache:            c.cache,
+			Logger:           c.logger,
+			Tracker:          c.tracker,
+			Diagnostics:      diag,
+			DefaultBackend:   "system/default",
+			DefaultCrtSecret: "system/default",
+			AnnotationPrefix: "ingress.kubernetes.io",
+		}, c.hconfig).Sync()
+		if !reflect.DeepEqual(diag.warnings, test.expected) {
+			t.Errorf("warnings differ on %d - expected: %q, actual: %q", i, test.expected, diag.warnings)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+type diagnosticsMock struct {
+	warnings []string
+}
+
+func (d *diagnosticsMock) Warn(rtype convtypes.ResourceType, name, message string) {
+	kind := map[convtypes.ResourceType]string{convtypes.IngressType: "ingress", convtypes.ServiceType: "service"}[rtype]
+	d.warnings = append(d.warnings, kind+" "+name+": "+message)
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"sort"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/annotations"
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+var (
+	explainHostKeys = sortedAnnKeys(ingtypes.AnnHost)
+	explainBackKeys = sortedAnnKeys(ingtypes.AnnBack)
+	// explainPathKeys are the backend keys that can be configured per path,
+	// see the GetBackendConfig() calls of the backend updater
+	explainPathKeys = map[string]bool{
+		ingtypes.BackAuthRealm:             true,
+		ingtypes.BackAuthSecret:            true,
+		ingtypes.BackAuthType:              true,
+		ingtypes.BackCorsAllowCredentials:  true,
+		ingtypes.BackCorsAllowHeaders:      true,
+		ingtypes.BackCorsAllowMethods:      true,
+		ingtypes.BackCorsAllowOrigin:       true,
+		ingtypes.BackCorsEnable:            true,
+		ingtypes.BackCorsExposeHeaders:     true,
+		ingtypes.BackCorsMaxAge:            true,
+		ingtypes.BackHSTS:                  true,
+		ingtypes.BackHSTSIncludeSubdomains: true,
+		ingtypes.BackHSTSMaxAge:            true,
+		ingtypes.BackHSTSPreload:           true,
+		ingtypes.BackProxyBodySize:         true,
+		ingtypes.BackRewriteTarget:         true,
+		ingtypes.BackSSLRedirect:           true,
+		ingtypes.BackWAF:                   true,
+		ingtypes.BackWAFMode:               true,
+		ingtypes.BackWhitelistSourceRange:  true,
+	}
+)
+
+func (c *converter) fullSyncExplain() {
+	if c.options.Explainer == nil {
+		return
+	}
+	c.options.Explainer.Clear()
+	c.addExplanations(c.haproxy.Hosts().Items(), c.haproxy.Backends().Items())
+}
+
+func (c *converter) partialSyncExplain(dirtyHosts []string, dirtyBacks []hatypes.BackendID) {
+	if c.options.Explainer == nil {
+		return
+	}
+	c.options.Explainer.RemoveHosts(dirtyHosts)
+	c.options.Explainer.RemoveBackends(dirtyBacks)
+	c.addExplanations(c.haproxy.Hosts().ItemsAdd(), c.haproxy.Backends().ItemsAdd())
+}
+
+func (c *converter) addExplanations(hosts map[string]*hatypes.Host, backends map[string]*hatypes.Backend) {
+	for _, host := range hosts {
+		if mapper, found := c.hostAnnotations[host]; found {
+			c.options.Explainer.AddHost(c.explainHost(host, mapper))
+		}
+	}
+	for _, backend := range backends {
+		if mapper, found := c.backendAnnotations[backend]; found {
+			c.options.Explainer.AddBackend(c.explainBackend(backend, mapper))
+		}
+	}
+}
+
+func (c *converter) explainHost(host *hatypes.Host, mapper *annotations.Mapper) *convtypes.HostExplanation {
+	paths := make([]*convtypes.PathExplanation, len(host.Paths))
+	for i, path := range host.Paths {
+		paths[i] = &convtypes.PathExplanation{
+			Path:    path.Path,
+			Backend: path.Backend.ID,
+		}
+	}
+	var config []*convtypes.ConfigExplanation
+	for _, key := range explainHostKeys {
+		// the first declaration is used, see Mapper.Get()
+		if maps, found := mapper.GetStrMap(key); found {
+			if cfg := c.explainConfig(key, maps[0].Source, maps[0].Value); cfg != nil {
+				config = append(config, cfg)
+			}
+		}
+	}
+	return &convtypes.HostExplanation{
+		Hostname:  host.Hostname,
+		Paths:     paths,
+		Config:    config,
+		Conflicts: c.explainConflicts(mapper),
+	}
+}
+
+func (c *converter) explainBackend(backend *hatypes.Backend, mapper *annotations.Mapper) *convtypes.BackendExplanation {
+	var config, conflicts []*convtypes.ConfigExplanation
+	var pathKeys []string
+	for _, key := range explainBackKeys {
+		if explainPathKeys[key] {
+			pathKeys = append(pathKeys, key)
+			continue
+		}
+		// the first declaration is used, see Mapper.Get()
+		maps, found := mapper.GetStrMap(key)
+		if !found {
+			continue
+		}
+		if cfg := c.explainConfig(key, maps[0].Source, maps[0].Value); cfg != nil {
+			config = append(config, cfg)
+		}
+		for _, m := range maps[1:] {
+			if m.Value != maps[0].Value {
+				conflicts = append(conflicts, &convtypes.ConfigExplanation{
+					Key:    key,
+					Value:  m.Value,
+					Source: c.explainSource(key, m.Source),
+					Link:   m.Link.String(),
+				})
+			}
+		}
+	}
+	backendConfig := mapper.GetBackendConfig(backend, pathKeys, nil)
+	paths := make([]*convtypes.BackendPathsExplanation, len(backendConfig))
+	for i, cfg := range backendConfig {
+		links := make([]string, len(cfg.Paths.Items))
+		for j, path := range cfg.Paths.Items {
+			links[j] = path.Link.String()
+		}
+		var pathConfig []*convtypes.ConfigExplanation
+		for _, key := range pathKeys {
+			if value, found := cfg.Config[key]; found {
+				if cfg := c.explainConfig(key, value.Source, value.Value); cfg != nil {
+					pathConfig = append(pathConfig, cfg)
+				}
+			}
+		}
+		paths[i] = &convtypes.BackendPathsExplanation{
+			Links:  links,
+			Config: pathConfig,
+		}
+	}
+	return &convtypes.BackendExplanation{
+		Backend:   backend.ID,
+		Config:    config,
+		Paths:     paths,
+		Conflicts: append(conflicts, c.explainConflicts(mapper)...),
+	}
+}
+
+func (c *converter) explainConflicts(mapper *annotations.Mapper) []*convtypes.ConfigExplanation {
+	conflicts := mapper.GetConflicts()
+	keys := make([]string, 0, len(conflicts))
+	for key := range conflicts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var explanation []*convtypes.ConfigExplanation
+	for _, key := range keys {
+		for _, conflict := range conflicts[key] {
+			explanation = append(explanation, &convtypes.ConfigExplanation{
+				Key:    key,
+				Value:  conflict.Value,
+				Source: c.explainSource(key, conflict.Source),
+				Link:   conflict.Link.String(),
+			})
+		}
+	}
+	return explanation
+}
+
+// explainConfig returns the explanation of a configuration key, or nil if
+// the key has an empty default value.
+func (c *converter) explainConfig(key string, source *annotations.Source, value string) *convtypes.ConfigExplanation {
+	src := c.explainSource(key, source)
+	if src == "default" && value == "" {
+		return nil
+	}
+	return &convtypes.ConfigExplanation{
+		Key:    key,
+		Value:  value,
+		Source: src,
+	}
+}
+
+func (c *converter) explainSource(key string, source *annotations.Source) string {
+	if source != nil {
+		return source.String()
+	}
+	if _, found := c.configMapData[key]; found {
+		return "configmap"
+	}
+	return "default"
+}
+
+func sortedAnnKeys(ann map[string]struct{}) []string {
+	keys := make([]string, 0, len(ann))
+	for key := range ann {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"testing"
+
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+func TestExplain(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1AutoAnn(map[string]string{
+		"ingress.kubernetes.io/balance-algorithm": "leastconn",
+	})
+	c.cache.IngList = append(c.cache.IngList,
+		c.createIng1Ann("default/app1", "domain.local", "/app1", "echo:8080", map[string]string{
+			"ingress.kubernetes.io/app-root":       "/app1",
+			"ingress.kubernetes.io/timeout-server": "10s",
+		}),
+		c.createIng1Ann("default/app2", "domain.local", "/app2", "echo:8080", map[string]string{
+			"ingress.kubernetes.io/app-root":       "/app2",
+			"ingress.kubernetes.io/ssl-redirect":   "false",
+			"ingress.kubernetes.io/timeout-server": "20s",
+		}),
+	)
+	c.cache.Changed.GlobalNew = map[string]string{
+		ingtypes.BackTimeoutServer: "30s",
+	}
+	c.cache.SecretTLSPath["system/default"] = "/tls/tls-default.pem"
+	explainer := &explainerMock{
+		hosts:    map[string]*convtypes.HostExplanation{},
+		backends: map[string]*convtypes.BackendExplanation{},
+	}
+	NewIngressConverter(&ingtypes.ConverterOptions{
+		Cache:   c.cache,
+		Logger:  c.logger,
+		Tracker: c.tracker,
+		DefaultConfig: func() map[string]string {
+			return map[string]string{
+				ingtypes.BackBackendServerNaming: "sequence",
+				ingtypes.BackBalanceAlgorithm:    "roundrobin",
+				ingtypes.BackSSLRedirect:         "true",
+				ingtypes.BackTimeoutServer:       "50s",
+				ingtypes.BackTimeoutQueue:        "",
+				ingtypes.GlobalNbprocBalance:     "1",
+				ingtypes.GlobalNbthread:          "1",
+			}
+		},
+		Explainer:        explainer,
+		DefaultBackend:   "system/default",
+		DefaultCrtSecret: "system/default",
+		AnnotationPrefix: "ingress.kubernetes.io",
+	}, c.hconfig).Sync()
+
+	c.compareText(_yamlMarshal(explainer.hosts["domain.local"]), `
+hostname: domain.local
+paths:
+- path: /app2
+  backend: default_echo_8080
+- path: /app1
+  backend: default_echo_8080
+config:
+- key: app-root
+  value: /app1
+  source: ingress 'default/app1'
+conflicts:
+- key: app-root
+  value: /app2
+  source: ingress 'default/app2'
+  link: domain.local/`)
+
+	c.compareText(_yamlMarshal(explainer.backends["default_echo_8080"]), `
+backend: default_echo_8080
+config:
+- key: backend-server-naming
+  value: sequence
+  source: default
+- key: balance-algorithm
+  value: leastconn
+  source: service 'default/echo'
+- key: timeout-server
+  value: 10s
+  source: ingress 'default/app1'
+paths:
+- links:
+  - domain.local/app1
+  config:
+  - key: ssl-redirect
+    value: "true"
+    source: default
+- links:
+  - domain.local/app2
+  config:
+  - key: ssl-redirect
+    value: "false"
+    source: ingress 'default/app2'
+conflicts:
+- key: timeout-server
+  value: 20s
+  source: ingress 'default/app2'
+  link: domain.local/app2`)
+
+	c.logger.CompareLogging(`
+WARN skipping host annotation(s) from ingress 'default/app2' due to conflict: [app-root]
+WARN annotation 'ingress.kubernetes.io/timeout-server' from ingress 'default/app1' overrides the same annotation with distinct value from [ingress 'default/app2']`)
+}
+
+type explainerMock struct {
+	hosts    map[string]*convtypes.HostExplanation
+	backends map[string]*convtypes.BackendExplanation
+}
+
+func (e *explainerMock) Clear() {}
+
+func (e *explainerMock) RemoveHosts(hostnames []string) {}
+
+func (e *explainerMock) RemoveBackends(backends []hatypes.BackendID) {}
+
+func (e *explainerMock) AddHost(host *convtypes.HostExplanation) {
+	e.hosts[host.Hostname] = host
+}
+
+func (e *explainerMock) AddBackend(backend *convtypes.BackendExplanation) {
+	e.backends[backend.Backend] = backend
+}
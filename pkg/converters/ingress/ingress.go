@@ -19,6 +19,7 @@ package ingress
 import (
 	"fmt"
 	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -26,6 +27,7 @@ import (
 	api "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1beta1"
 
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/acme"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/annotations"
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
@@ -33,11 +35,14 @@ import (
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 )
 
 // Config ...
 type Config interface {
 	Sync()
+	ChangedObjects() *convtypes.ChangedObjects
+	NeedFullSync() bool
 }
 
 // NewIngressConverter ...
@@ -58,18 +63,26 @@ func NewIngressConverter(options *ingtypes.ConverterOptions, haproxy haproxy.Con
 	for key, value := range globalConfig {
 		defaultConfig[key] = value
 	}
+	annPolicy := newAnnotationPolicy(options.Logger, options.Cache, defaultConfig[ingtypes.GlobalAnnotationPolicy])
+	mapBuilder := annotations.NewMapBuilder(options.Logger, options.AnnotationPrefix+"/", defaultConfig)
+	mapBuilder.SetPolicy(annPolicy)
+	mapBuilder.SetDiagnostics(options.Diagnostics)
 	return &converter{
 		haproxy:            haproxy,
 		options:            options,
 		changed:            changed,
 		logger:             options.Logger,
+		diagnostics:        options.Diagnostics,
 		cache:              options.Cache,
 		tracker:            options.Tracker,
-		mapBuilder:         annotations.NewMapBuilder(options.Logger, options.AnnotationPrefix+"/", defaultConfig),
+		annPolicy:          annPolicy,
+		mapBuilder:         mapBuilder,
 		updater:            annotations.NewUpdater(haproxy, options),
 		globalConfig:       annotations.NewMapBuilder(options.Logger, "", defaultConfig).NewMapper(),
+		configMapData:      globalConfig,
 		hostAnnotations:    map[*hatypes.Host]*annotations.Mapper{},
 		backendAnnotations: map[*hatypes.Backend]*annotations.Mapper{},
+		hostnameOwners:     map[string]string{},
 		needFullSync:       needFullSync,
 	}
 }
@@ -79,17 +92,26 @@ type converter struct {
 	options            *ingtypes.ConverterOptions
 	changed            *convtypes.ChangedObjects
 	logger             types.Logger
+	diagnostics        convtypes.Diagnostics
 	cache              convtypes.Cache
 	tracker            convtypes.Tracker
 	defaultCrt         convtypes.CrtFile
+	annPolicy          annotations.Policy
 	mapBuilder         *annotations.MapBuilder
 	updater            annotations.Updater
 	globalConfig       *annotations.Mapper
+	configMapData      map[string]string
 	hostAnnotations    map[*hatypes.Host]*annotations.Mapper
 	backendAnnotations map[*hatypes.Backend]*annotations.Mapper
+	hostnameOwners     map[string]string
+	hostnameAllowlist  map[string][]string
 	needFullSync       bool
 }
 
+func (c *converter) warn(source *annotations.Source, msg string, args ...interface{}) {
+	annotations.Warn(c.logger, c.diagnostics, []*annotations.Source{source}, msg, args...)
+}
+
 func (c *converter) Sync() {
 	if c.needFullSync {
 		c.haproxy.Clear()
@@ -101,6 +123,35 @@ func (c *converter) Sync() {
 	} else {
 		c.syncPartial()
 	}
+	c.syncTCPServices()
+}
+
+func (c *converter) ChangedObjects() *convtypes.ChangedObjects {
+	return c.changed
+}
+
+func (c *converter) NeedFullSync() bool {
+	return c.needFullSync
+}
+
+func newAnnotationPolicy(logger types.Logger, cache convtypes.Cache, config string) annotations.Policy {
+	if config == "" {
+		return nil
+	}
+	policy, err := annotations.NewPolicy(config, func(namespace string) map[string]string {
+		// namespaces are only watched on cluster wide watch, otherwise
+		// all the namespaces are handled as namespaces without labels
+		ns, err := cache.GetNamespace(namespace)
+		if err != nil || ns == nil {
+			return nil
+		}
+		return ns.Labels
+	})
+	if err != nil {
+		logger.Error("denying all annotations due to an error parsing the annotation policy: %v", err)
+		return annotations.NewDenyAllPolicy()
+	}
+	return policy
 }
 
 func globalConfigNeedFullSync(changed *convtypes.ChangedObjects) bool {
@@ -114,6 +165,7 @@ func globalConfigNeedFullSync(changed *convtypes.ChangedObjects) bool {
 	//        * GlobalDNSResolvers
 	//        * GlobalDrainSupport
 	//        * GlobalNoTLSRedirectLocations
+	//        * GlobalAnnotationPolicy
 	//
 	// This might be improved after implement a way to guarantee that a global
 	// is just a haproxy global, default or frontend config.
@@ -169,6 +221,7 @@ func (c *converter) syncFull() {
 		c.syncIngress(ing)
 	}
 	c.fullSyncAnnotations()
+	c.fullSyncExplain()
 }
 
 func (c *converter) syncPartial() {
@@ -215,6 +268,13 @@ func (c *converter) syncPartial() {
 		}
 		return podList
 	}
+	ns2names := func(namespaces []*api.Namespace) []string {
+		nsList := make([]string, len(namespaces))
+		for i, ns := range namespaces {
+			nsList[i] = ns.Name
+		}
+		return nsList
+	}
 
 	if len(c.changed.Objects) > 0 {
 		c.logger.InfoV(2, "applying %d change notification(s): %v", len(c.changed.Objects), c.changed.Objects)
@@ -236,9 +296,10 @@ func (c *converter) syncPartial() {
 	addSecretNames := secret2names(c.changed.SecretsAdd)
 	oldSecretNames := append(delSecretNames, updSecretNames...)
 	addPodNames := pod2names(c.changed.Pods)
+	oldNsNames := ns2names(c.changed.Namespaces)
 	c.trackAddedIngress()
 	dirtyIngs, dirtyHosts, dirtyBacks, dirtyUsers, dirtyStorages :=
-		c.tracker.GetDirtyLinks(oldIngNames, addIngNames, oldSvcNames, addSvcNames, oldSecretNames, addSecretNames, addPodNames)
+		c.tracker.GetDirtyLinks(oldIngNames, addIngNames, oldSvcNames, addSvcNames, oldSecretNames, addSecretNames, addPodNames, oldNsNames)
 	c.tracker.DeleteHostnames(dirtyHosts)
 	c.tracker.DeleteBackends(dirtyBacks)
 	c.tracker.DeleteUserlists(dirtyUsers)
@@ -266,7 +327,7 @@ func (c *converter) syncPartial() {
 			var err error
 			ing, err = c.cache.GetIngress(name)
 			if err != nil {
-				c.logger.Warn("ignoring ingress '%s': %v", name, err)
+				c.logger.Warn("ignoring ingress '%s': %v", types.LogIngress(name), err)
 				ing = nil
 			}
 		}
@@ -281,6 +342,7 @@ func (c *converter) syncPartial() {
 		c.syncIngress(ing)
 	}
 	c.partialSyncAnnotations()
+	c.partialSyncExplain(dirtyHosts, dirtyBacks)
 }
 
 // trackAddedIngress add tracking hostnames and backends to new ingress objects
@@ -295,7 +357,29 @@ func (c *converter) syncPartial() {
 // existent host or back is tracked only by an added ingress, it is tracked
 // here and removed before parse the added ingress which will readd such hosts
 // and backs
+//
+// Hostnames of server-alias and acme TLS entries of added and updated ingress
+// objects are also tracked if hostname ownership is enabled, so the ingress
+// objects that own such hostnames are parsed again and their namespace is
+// known when the new hostnames are checked.
 func (c *converter) trackAddedIngress() {
+	if c.hostnameOwnership() {
+		prefix := c.options.AnnotationPrefix + "/"
+		ingList := make([]*networking.Ingress, 0, len(c.changed.IngressesAdd)+len(c.changed.IngressesUpd))
+		ingList = append(ingList, c.changed.IngressesAdd...)
+		ingList = append(ingList, c.changed.IngressesUpd...)
+		for _, ing := range ingList {
+			name := ing.Namespace + "/" + ing.Name
+			if alias := ing.Annotations[prefix+ingtypes.HostServerAlias]; alias != "" {
+				c.tracker.TrackHostname(convtypes.IngressType, name, alias)
+			}
+			for _, tls := range ing.Spec.TLS {
+				for _, tlshost := range tls.Hosts {
+					c.tracker.TrackHostname(convtypes.IngressType, name, tlshost)
+				}
+			}
+		}
+	}
 	for _, ing := range c.changed.IngressesAdd {
 		name := ing.Namespace + "/" + ing.Name
 		if ing.Spec.Backend != nil {
@@ -319,6 +403,16 @@ func (c *converter) trackAddedIngress() {
 }
 
 func (c *converter) findBackend(namespace string, backend *networking.IngressBackend) *hatypes.Backend {
+	backendID := c.readBackendID(namespace, backend)
+	if backendID == nil {
+		return nil
+	}
+	return c.haproxy.Backends().FindBackend(backendID.Namespace, backendID.Name, backendID.Port)
+}
+
+// readBackendID returns the ID of the backend of an ingress backend,
+// regardless if the backend was already created.
+func (c *converter) readBackendID(namespace string, backend *networking.IngressBackend) *hatypes.BackendID {
 	svcName, svcPort := readServiceNamePort(backend)
 	fullSvcName := namespace + "/" + svcName
 	svc, err := c.cache.GetService(fullSvcName)
@@ -329,7 +423,11 @@ func (c *converter) findBackend(namespace string, backend *networking.IngressBac
 	if port == nil {
 		return nil
 	}
-	return c.haproxy.Backends().FindBackend(namespace, svcName, port.TargetPort.String())
+	return &hatypes.BackendID{
+		Namespace: namespace,
+		Name:      svcName,
+		Port:      port.TargetPort.String(),
+	}
 }
 
 func sortIngress(ingress []*networking.Ingress) {
@@ -350,12 +448,14 @@ func (c *converter) syncIngress(ing *networking.Ingress) {
 		Name:      ing.Name,
 		Type:      "ingress",
 	}
-	annHost, annBack := c.readAnnotations(ing.Annotations)
+	annHost, annBack := c.readAnnotations(source, ing.Annotations)
+	// host keys read straight from the annotations, instead of the host Mapper
+	annHostNs := c.withNamespaceDefaults(ing.Namespace, annHost)
 	if ing.Spec.Backend != nil {
 		svcName, svcPort := readServiceNamePort(ing.Spec.Backend)
 		err := c.addDefaultHostBackend(source, ing.Namespace+"/"+svcName, svcPort, annHost, annBack)
 		if err != nil {
-			c.logger.Warn("skipping default backend of ingress '%s': %v", fullIngName, err)
+			c.warn(source, "skipping default backend of %v: %v", source, err)
 		}
 	}
 	for _, rule := range ing.Spec.Rules {
@@ -366,74 +466,231 @@ func (c *converter) syncIngress(ing *networking.Ingress) {
 		if hostname == "" {
 			hostname = hatypes.DefaultHost
 		}
+		if !c.checkHostnameOwner(source, hostname) {
+			// rejected rules are parsed again if the owner of the hostname changes
+			c.tracker.TrackHostname(convtypes.IngressType, fullIngName, hostname)
+			for _, path := range rule.HTTP.Paths {
+				if backendID := c.readBackendID(ing.Namespace, &path.Backend); backendID != nil {
+					c.tracker.TrackBackend(convtypes.IngressType, fullIngName, *backendID)
+				}
+			}
+			continue
+		}
 		host := c.addHost(hostname, source, annHost)
+		if alias := annHost[ingtypes.HostServerAlias]; alias != "" && c.hostnameOwnership() {
+			// the ingress is parsed again if the owner of the alias changes
+			c.tracker.TrackHostname(convtypes.IngressType, fullIngName, alias)
+		}
 		for _, path := range rule.HTTP.Paths {
 			uri := path.Path
 			if uri == "" {
 				uri = "/"
 			}
 			if host.FindPath(uri) != nil {
-				c.logger.Warn("skipping redeclared path '%s' of ingress '%s'", uri, fullIngName)
+				c.warn(source, "skipping redeclared path '%s' of %v", uri, source)
 				continue
 			}
 			svcName, svcPort := readServiceNamePort(&path.Backend)
 			fullSvcName := ing.Namespace + "/" + svcName
 			backend, err := c.addBackend(source, hostname, uri, fullSvcName, svcPort, annBack)
 			if err != nil {
-				c.logger.Warn("skipping backend config of ingress '%s': %v", fullIngName, err)
+				c.warn(source, "skipping backend config of %v: %v", source, err)
 				continue
 			}
-			match := c.readPathType(path, annHost[ingtypes.HostPathType])
+			match := c.readPathType(path, annHostNs[ingtypes.HostPathType])
 			host.AddPath(backend, uri, match)
-			sslpassthrough, _ := strconv.ParseBool(annHost[ingtypes.HostSSLPassthrough])
-			sslpasshttpport := annHost[ingtypes.HostSSLPassthroughHTTPPort]
+			host.FindPath(uri).Ingress = fullIngName
+			sslpassthrough, _ := strconv.ParseBool(annHostNs[ingtypes.HostSSLPassthrough])
+			sslpasshttpport := annHostNs[ingtypes.HostSSLPassthroughHTTPPort]
 			if sslpassthrough && sslpasshttpport != "" {
 				if _, err := c.addBackend(source, hostname, uri, fullSvcName, sslpasshttpport, annBack); err != nil {
-					c.logger.Warn("skipping http port config of ssl-passthrough on %v: %v", source, err)
+					c.warn(source, "skipping http port config of ssl-passthrough on %v: %v", source, err)
 				}
 			}
 		}
-		for _, tls := range ing.Spec.TLS {
-			for _, tlshost := range tls.Hosts {
-				if tlshost == hostname {
-					tlsPath := c.addTLS(source, tlshost, tls.SecretName)
-					if host.TLS.TLSHash == "" {
-						host.TLS.TLSFilename = tlsPath.Filename
-						host.TLS.TLSHash = tlsPath.SHA1Hash
-						host.TLS.TLSCommonName = tlsPath.CommonName
-						host.TLS.TLSNotAfter = tlsPath.NotAfter
-					} else if host.TLS.TLSHash != tlsPath.SHA1Hash {
-						msg := fmt.Sprintf("TLS of host '%s' was already assigned", host.Hostname)
-						if tls.SecretName != "" {
-							c.logger.Warn("skipping TLS secret '%s' of ingress '%s': %s", tls.SecretName, fullIngName, msg)
-						} else {
-							c.logger.Warn("skipping default TLS secret of ingress '%s': %s", fullIngName, msg)
-						}
-					}
+		for _, tls := range findTLS(ing.Spec.TLS, hostname) {
+			tlsPath := c.addTLS(source, hostname, tls.SecretName)
+			if host.TLS.TLSHash == "" {
+				host.TLS.TLSFilename = tlsPath.Filename
+				host.TLS.TLSHash = tlsPath.SHA1Hash
+				host.TLS.TLSCommonName = tlsPath.CommonName
+				host.TLS.TLSNotAfter = tlsPath.NotAfter
+				if tls.SecretName != "" && c.isTLSAcme(ing, annHostNs) {
+					host.TLS.TLSDualFilename = c.readAcmeDualTLS(source, hostname, tls.SecretName, annHostNs)
+				}
+			} else if host.TLS.TLSHash != tlsPath.SHA1Hash {
+				msg := fmt.Sprintf("TLS of host '%s' was already assigned", host.Hostname)
+				if tls.SecretName != "" {
+					c.warn(source, "skipping TLS secret '%s' of %v: %s", tls.SecretName, source, msg)
+				} else {
+					c.warn(source, "skipping default TLS secret of %v: %s", source, msg)
 				}
 			}
 		}
 	}
 	for _, tls := range ing.Spec.TLS {
-		// distinct prefix, read from the Annotations map
-		var tlsAcme bool
-		if c.options.AcmeTrackTLSAnn {
-			tlsAcmeStr, _ := ing.Annotations[ingtypes.ExtraTLSAcme]
-			tlsAcme, _ = strconv.ParseBool(tlsAcmeStr)
-		}
-		if !tlsAcme {
-			tlsAcme = strings.ToLower(annHost[ingtypes.HostCertSigner]) == "acme"
-		}
-		if tlsAcme {
+		if c.isTLSAcme(ing, annHostNs) {
 			if tls.SecretName != "" {
+				challenge, dnsProvider, err := c.readAcmeChallenge(ing.Namespace, annHostNs)
+				if err != nil {
+					c.warn(source, "skipping cert signer of %v: %v", source, err)
+					continue
+				}
+				keyType, dualKeyType, err := c.readAcmeKeyType(annHostNs)
+				if err != nil {
+					c.warn(source, "skipping cert signer of %v: %v", source, err)
+					continue
+				}
 				secretName := ing.Namespace + "/" + tls.SecretName
-				c.haproxy.AcmeData().Storages().Acquire(secretName).AddDomains(tls.Hosts)
+				storage := c.haproxy.AcmeData().Storages().Acquire(secretName)
+				if !storage.SetChallenge(challenge, dnsProvider) {
+					c.warn(source, "ignoring acme challenge config of %v: secret '%s' was already assigned to another challenge or provider",
+						source, secretName)
+				}
+				if !storage.SetKeyType(keyType, dualKeyType) {
+					c.warn(source, "ignoring acme key type config of %v: secret '%s' was already assigned to another key type",
+						source, secretName)
+				}
+				hosts := make([]string, 0, len(tls.Hosts))
+				for _, tlshost := range tls.Hosts {
+					if challenge != "dns-01" && strings.HasPrefix(tlshost, "*.") {
+						c.warn(source, "skipping cert signer of wildcard host '%s' on %v: wildcard certificates need the dns-01 challenge",
+							types.LogHost(tlshost), source)
+						continue
+					}
+					if !c.checkHostnameOwner(source, tlshost) {
+						// rejected domains are parsed again if the owner of the hostname changes
+						c.tracker.TrackHostname(convtypes.IngressType, fullIngName, tlshost)
+						continue
+					}
+					if c.hostnameOwnership() {
+						c.tracker.TrackHostname(convtypes.IngressType, fullIngName, tlshost)
+					}
+					hosts = append(hosts, tlshost)
+				}
+				storage.AddDomains(hosts)
 				c.tracker.TrackStorage(convtypes.IngressType, fullIngName, secretName)
 			} else {
-				c.logger.Warn("skipping cert signer of ingress '%s': missing secret name", fullIngName)
+				c.warn(source, "skipping cert signer of %v: missing secret name", source)
+			}
+		}
+	}
+}
+
+// findTLS lists the TLS entries whose hosts declare hostname. A
+// wildcard host is used only if hostname isn't literally declared, so
+// the certificate of a wildcard TLS entry is reused by its subdomains.
+func findTLS(ingTLS []networking.IngressTLS, hostname string) []networking.IngressTLS {
+	var tlsList, wildcardList []networking.IngressTLS
+	for _, tls := range ingTLS {
+		for _, tlshost := range tls.Hosts {
+			if tlshost == hostname {
+				tlsList = append(tlsList, tls)
+			} else if dot := strings.Index(hostname, "."); dot > 0 && tlshost == "*"+hostname[dot:] {
+				wildcardList = append(wildcardList, tls)
 			}
 		}
 	}
+	if len(tlsList) > 0 {
+		return tlsList
+	}
+	return wildcardList
+}
+
+func (c *converter) isTLSAcme(ing *networking.Ingress, annHost map[string]string) bool {
+	// distinct prefix, read from the Annotations map
+	if c.options.AcmeTrackTLSAnn {
+		tlsAcmeStr, _ := ing.Annotations[ingtypes.ExtraTLSAcme]
+		if tlsAcme, _ := strconv.ParseBool(tlsAcmeStr); tlsAcme {
+			return true
+		}
+	}
+	return strings.ToLower(annHost[ingtypes.HostCertSigner]) == "acme"
+}
+
+func (c *converter) readAcmeValue(annHost map[string]string, key string) string {
+	if value, found := annHost[key]; found {
+		return value
+	}
+	return c.globalConfig.Get(key).Value
+}
+
+// readAcmeKeyType reads the key type of an acme certificate and the
+// optional key type of its dual certificate.
+func (c *converter) readAcmeKeyType(annHost map[string]string) (keyType, dualKeyType string, err error) {
+	isValid := func(keyType string) bool {
+		switch keyType {
+		case acme.KeyTypeRSA2048, acme.KeyTypeRSA4096, acme.KeyTypeEC256, acme.KeyTypeEC384:
+			return true
+		}
+		return false
+	}
+	keyType = c.readAcmeValue(annHost, ingtypes.HostAcmeKeyType)
+	if keyType == "" {
+		keyType = acme.KeyTypeRSA2048
+	}
+	if !isValid(keyType) {
+		return "", "", fmt.Errorf("unsupported acme key type: %s", keyType)
+	}
+	dualKeyType = c.readAcmeValue(annHost, ingtypes.HostAcmeDualKeyType)
+	if dualKeyType != "" && !isValid(dualKeyType) {
+		return "", "", fmt.Errorf("unsupported acme dual key type: %s", dualKeyType)
+	}
+	if dualKeyType == keyType {
+		dualKeyType = ""
+	}
+	return keyType, dualKeyType, nil
+}
+
+// readAcmeDualTLS returns the crt filename of the dual key type certificate
+// of an acme managed secret, if configured and already issued.
+func (c *converter) readAcmeDualTLS(source *annotations.Source, hostname, secretName string, annHost map[string]string) string {
+	if _, dualKeyType, err := c.readAcmeKeyType(annHost); err != nil || dualKeyType == "" {
+		return ""
+	}
+	tlsFile, err := c.cache.GetTLSSecretPath(
+		source.Namespace,
+		secretName+acme.DualSecretSuffix,
+		convtypes.TrackingTarget{Hostname: hostname},
+	)
+	if err != nil {
+		// not issued yet, the tracker will update the host
+		// as soon as the secret is created
+		return ""
+	}
+	return tlsFile.Filename
+}
+
+// readAcmeChallenge reads the acme challenge type and, on dns-01, the
+// `<type>:<secret>` dns provider. The provider secret name defaults
+// to the ingress namespace and cannot reference another namespace if
+// declared as an ingress annotation.
+func (c *converter) readAcmeChallenge(namespace string, annHost map[string]string) (challenge, dnsProvider string, err error) {
+	challenge = c.readAcmeValue(annHost, ingtypes.HostAcmeChallenge)
+	switch challenge {
+	case "", "http-01":
+		return "http-01", "", nil
+	case "tls-alpn-01":
+		return challenge, "", nil
+	case "dns-01":
+	default:
+		return "", "", fmt.Errorf("unsupported acme challenge: %s", challenge)
+	}
+	dnsProvider, fromAnn := annHost[ingtypes.HostAcmeDNSProvider]
+	if !fromAnn {
+		dnsProvider = c.globalConfig.Get(ingtypes.HostAcmeDNSProvider).Value
+	}
+	typeSecret := strings.SplitN(dnsProvider, ":", 2)
+	if len(typeSecret) != 2 || typeSecret[0] == "" || typeSecret[1] == "" {
+		return "", "", fmt.Errorf("invalid or missing acme dns provider, expected '<type>:<secret-name>': '%s'", dnsProvider)
+	}
+	secretName := typeSecret[1]
+	if slash := strings.Index(secretName, "/"); slash < 0 {
+		secretName = namespace + "/" + secretName
+	} else if fromAnn && secretName[:slash] != namespace {
+		return "", "", fmt.Errorf("acme dns provider secret '%s' must be in the ingress namespace", secretName)
+	}
+	return challenge, typeSecret[0] + ":" + secretName, nil
 }
 
 func (c *converter) fullSyncAnnotations() {
@@ -443,6 +700,7 @@ func (c *converter) fullSyncAnnotations() {
 			c.updater.UpdateHostConfig(host, ann)
 		}
 	}
+	c.checkHostAliases(c.haproxy.Hosts().Items())
 	for _, backend := range c.haproxy.Backends().Items() {
 		if ann, found := c.backendAnnotations[backend]; found {
 			c.updater.UpdateBackendConfig(backend, ann)
@@ -456,6 +714,7 @@ func (c *converter) partialSyncAnnotations() {
 			c.updater.UpdateHostConfig(host, ann)
 		}
 	}
+	c.checkHostAliases(c.haproxy.Hosts().ItemsAdd())
 	for _, backend := range c.haproxy.Backends().ItemsAdd() {
 		if ann, found := c.backendAnnotations[backend]; found {
 			c.updater.UpdateBackendConfig(backend, ann)
@@ -511,21 +770,179 @@ func (c *converter) addDefaultHostBackend(source *annotations.Source, fullSvcNam
 	}
 	host := c.addHost(hostname, source, annHost)
 	host.AddPath(backend, uri, hatypes.MatchBegin)
+	host.FindPath(uri).Ingress = source.FullName()
 	return nil
 }
 
+// checkHostnameOwner checks if the namespace of source can declare paths on
+// hostname. The namespace of the first ingress parsed with a hostname owns it,
+// other namespaces need to be delegated by the owner, using the
+// hostname-delegation key, or by the hostname-allowlist global config.
+// Ingress objects are parsed in the order they were created, so the first
+// namespace that claimed a hostname keeps owning it.
+func (c *converter) checkHostnameOwner(source *annotations.Source, hostname string) bool {
+	owner, allowed := c.readHostnameOwner(source, hostname)
+	if !allowed {
+		c.warn(source, "skipping hostname '%s' of %v: hostname is owned by namespace '%s'", types.LogHost(hostname), source, owner)
+	}
+	return allowed
+}
+
+// readHostnameOwner returns the namespace that owns hostname, and if the
+// namespace of source can use it. hostname is claimed by the namespace of
+// source if it doesn't have an owner yet.
+func (c *converter) readHostnameOwner(source *annotations.Source, hostname string) (owner string, allowed bool) {
+	if hostname == hatypes.DefaultHost || !c.hostnameOwnership() {
+		return "", true
+	}
+	owner, found := c.hostnameOwners[hostname]
+	if !found {
+		c.hostnameOwners[hostname] = source.Namespace
+		return source.Namespace, true
+	}
+	if owner == source.Namespace {
+		return owner, true
+	}
+	for _, ns := range c.readHostnameAllowlist()[hostname] {
+		if ns == source.Namespace {
+			return owner, true
+		}
+	}
+	if mapper := c.hostAnnotations[c.haproxy.Hosts().FindHost(hostname)]; mapper != nil {
+		// only the owner can delegate, the key can also be declared as
+		// an annotation of the owner's namespace or in the global config
+		delegation := mapper.Get(ingtypes.HostHostnameDelegation)
+		if delegation.Source == nil || delegation.Source.Namespace == owner {
+			for _, ns := range utils.Split(delegation.Value, ",") {
+				if ns == source.Namespace {
+					return owner, true
+				}
+			}
+		}
+	}
+	return owner, false
+}
+
+// checkHostAliases removes the server-alias and server-alias-regex of the
+// hosts whose namespace can not use the hostnames they match. Aliases from
+// the global config are always allowed.
+func (c *converter) checkHostAliases(hosts map[string]*hatypes.Host) {
+	if !c.hostnameOwnership() {
+		return
+	}
+	hostnames := make([]string, 0, len(hosts))
+	for hostname := range hosts {
+		hostnames = append(hostnames, hostname)
+	}
+	// aliases claim hostnames that don't have an owner yet, the sort
+	// makes the owner predictable
+	sort.Strings(hostnames)
+	for _, hostname := range hostnames {
+		host := hosts[hostname]
+		mapper, found := c.hostAnnotations[host]
+		if !found {
+			continue
+		}
+		if alias := mapper.Get(ingtypes.HostServerAlias); host.Alias.AliasName != "" && alias.Source != nil {
+			if owner, allowed := c.readHostnameOwner(alias.Source, host.Alias.AliasName); !allowed {
+				c.warn(alias.Source, "skipping server-alias '%s' of %v: hostname is owned by namespace '%s'",
+					types.LogHost(host.Alias.AliasName), alias.Source, owner)
+				host.Alias.AliasName = ""
+			}
+		}
+		if aliasRegex := mapper.Get(ingtypes.HostServerAliasRegex); host.Alias.AliasRegex != "" && aliasRegex.Source != nil {
+			if matched, owner, allowed := c.checkAliasRegexOwner(aliasRegex.Source, host.Alias.AliasRegex); !allowed {
+				c.warn(aliasRegex.Source, "skipping server-alias-regex '%s' of %v: it matches hostname '%s' owned by namespace '%s'",
+					host.Alias.AliasRegex, aliasRegex.Source, types.LogHost(matched), owner)
+				host.Alias.AliasRegex = ""
+			}
+		}
+	}
+}
+
+// checkAliasRegexOwner checks if the namespace of source can use all the
+// hostnames of the current config matched by aliasRegex. Hosts parsed on
+// a former sync and not parsed again don't have a known owner, they are
+// allowed only if the namespace of source declares one of their paths, and
+// the namespace of their first path is reported as the owner.
+func (c *converter) checkAliasRegexOwner(source *annotations.Source, aliasRegex string) (matched, owner string, allowed bool) {
+	regex, err := regexp.Compile(aliasRegex)
+	if err != nil {
+		// haproxy reports the invalid regex
+		return "", "", true
+	}
+	for _, host := range c.haproxy.Hosts().BuildSortedItems() {
+		hostname := host.Hostname
+		if hostname == hatypes.DefaultHost || !regex.MatchString(hostname) {
+			continue
+		}
+		if _, found := c.hostnameOwners[hostname]; found {
+			if owner, allowed := c.readHostnameOwner(source, hostname); !allowed {
+				return hostname, owner, false
+			}
+			continue
+		}
+		var declared bool
+		var pathOwner string
+		for _, path := range host.Paths {
+			ns := strings.Split(path.Ingress, "/")[0]
+			if ns == source.Namespace {
+				declared = true
+				break
+			}
+			if pathOwner == "" {
+				pathOwner = ns
+			}
+		}
+		if !declared {
+			return hostname, pathOwner, false
+		}
+	}
+	return "", "", true
+}
+
+func (c *converter) hostnameOwnership() bool {
+	return c.globalConfig.Get(ingtypes.GlobalHostnameOwnership).Bool()
+}
+
+func (c *converter) readHostnameAllowlist() map[string][]string {
+	if c.hostnameAllowlist != nil {
+		return c.hostnameAllowlist
+	}
+	c.hostnameAllowlist = map[string][]string{}
+	for _, line := range utils.LineToSlice(c.globalConfig.Get(ingtypes.GlobalHostnameAllowlist).Value) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		allowlist := strings.Split(line, "=")
+		if len(allowlist) != 2 {
+			c.logger.Warn("ignoring misconfigured hostname allowlist: %s", line)
+			continue
+		}
+		hostname := strings.TrimSpace(allowlist[0])
+		c.hostnameAllowlist[hostname] = append(c.hostnameAllowlist[hostname], utils.Split(allowlist[1], ",")...)
+	}
+	return c.hostnameAllowlist
+}
+
 func (c *converter) addHost(hostname string, source *annotations.Source, ann map[string]string) *hatypes.Host {
 	// TODO build a stronger tracking
 	host := c.haproxy.Hosts().AcquireHost(hostname)
 	c.tracker.TrackHostname(convtypes.IngressType, source.FullName(), hostname)
+	c.tracker.TrackHostname(convtypes.NamespaceType, source.Namespace, hostname)
 	mapper, found := c.hostAnnotations[host]
 	if !found {
 		mapper = c.mapBuilder.NewMapper()
+		nsSource, annHost, _ := c.readNamespaceAnnotations(source.Namespace)
+		if nsSource != nil {
+			mapper.AddNamespaceAnnotations(nsSource, annHost)
+		}
 		c.hostAnnotations[host] = mapper
 	}
 	conflict := mapper.AddAnnotations(source, hatypes.CreatePathLink(hostname, "/"), ann)
 	if len(conflict) > 0 {
-		c.logger.Warn("skipping host annotation(s) from %v due to conflict: %v", source, conflict)
+		c.warn(source, "skipping host annotation(s) from %v due to conflict: %v", source, conflict)
 	}
 	return host
 }
@@ -557,19 +974,25 @@ func (c *converter) addBackend(source *annotations.Source, hostname, uri, fullSv
 	if !found {
 		// New backend, initialize with service annotations, giving precedence
 		mapper = c.mapBuilder.NewMapper()
-		_, ann := c.readAnnotations(svc.Annotations)
-		mapper.AddAnnotations(&annotations.Source{
+		c.tracker.TrackBackend(convtypes.NamespaceType, namespace, backend.BackendID())
+		nsSource, _, annBack := c.readNamespaceAnnotations(namespace)
+		if nsSource != nil {
+			mapper.AddNamespaceAnnotations(nsSource, annBack)
+		}
+		svcSource := &annotations.Source{
 			Namespace: namespace,
 			Name:      svcName,
 			Type:      "service",
-		}, pathlink, ann)
+		}
+		_, ann := c.readAnnotations(svcSource, svc.Annotations)
+		mapper.AddAnnotations(svcSource, pathlink, ann)
 		c.backendAnnotations[backend] = mapper
 		backend.Server.InitialWeight = mapper.Get(ingtypes.BackInitialWeight).Int()
 	}
 	// Merging Ingress annotations
 	conflict := mapper.AddAnnotations(source, pathlink, ann)
 	if len(conflict) > 0 {
-		c.logger.Warn("skipping backend '%s:%s' annotation(s) from %v due to conflict: %v",
+		c.warn(source, "skipping backend '%s:%s' annotation(s) from %v due to conflict: %v",
 			svcName, svcPort, source, conflict)
 	}
 	// Configure endpoints
@@ -586,11 +1009,11 @@ func (c *converter) addBackend(source *annotations.Source, hostname, uri, fullSv
 			if addr, err := convutils.CreateSvcEndpoint(svc, port); err == nil {
 				backend.AcquireEndpoint(addr.IP, addr.Port, addr.TargetRef)
 			} else {
-				c.logger.Error("error adding IP of service '%s': %v", fullSvcName, err)
+				c.logger.Error("error adding IP of service '%s': %v", types.LogService(fullSvcName), err)
 			}
 		} else {
 			if err := c.addEndpoints(svc, port, backend); err != nil {
-				c.logger.Error("error adding endpoints of service '%s': %v", fullSvcName, err)
+				c.logger.Error("error adding endpoints of service '%s': %v", types.LogService(fullSvcName), err)
 			}
 		}
 	}
@@ -607,7 +1030,7 @@ func (c *converter) addTLS(source *annotations.Source, hostname, secretName stri
 		if err == nil {
 			return tlsFile
 		}
-		c.logger.Warn("using default certificate due to an error reading secret '%s' on %s: %v", secretName, source, err)
+		c.warn(source, "using default certificate due to an error reading secret '%s' on %s: %v", secretName, source, err)
 	}
 	return c.defaultCrt
 }
@@ -635,28 +1058,93 @@ func (c *converter) addEndpoints(svc *api.Service, svcPort *api.ServicePort, bac
 				ep := backend.AcquireEndpoint(pod.Status.PodIP, targetPort, pod.Namespace+"/"+pod.Name)
 				ep.Weight = 0
 			} else {
-				c.logger.Warn("skipping endpoint %s of service %s/%s: port '%s' was not found",
-					pod.Status.PodIP, svc.Namespace, svc.Name, svcPort.TargetPort.String())
+				c.logger.Warn("skipping endpoint %s of service %s: port '%s' was not found",
+					pod.Status.PodIP, types.LogService(svc.Namespace+"/"+svc.Name), svcPort.TargetPort.String())
 			}
 		}
 	}
 	return nil
 }
 
-func (c *converter) readAnnotations(annotations map[string]string) (annHost, annBack map[string]string) {
-	annHost = make(map[string]string, len(annotations))
-	annBack = make(map[string]string, len(annotations))
+// readNamespaceAnnotations reads the annotations of a namespace, used as the
+// default values of the ingress and service objects of the namespace.
+func (c *converter) readNamespaceAnnotations(namespace string) (source *annotations.Source, annHost, annBack map[string]string) {
+	ns, err := c.cache.GetNamespace(namespace)
+	if err != nil || ns == nil {
+		// namespace annotations are optional, eg the namespace lister
+		// is not started if watching a single namespace
+		return nil, nil, nil
+	}
+	// namespaces are cluster wide objects, usually managed by the same team
+	// that configures the annotation policy, so the policy is not applied
+	annHost, annBack = c.readAnnotations(nil, ns.Annotations)
+	if len(annHost) == 0 && len(annBack) == 0 {
+		return nil, nil, nil
+	}
+	source = &annotations.Source{
+		Namespace: ns.Name,
+		Name:      ns.Name,
+		Type:      "namespace",
+	}
+	return source, annHost, annBack
+}
+
+// withNamespaceDefaults merges the host annotations of namespace, as default
+// values, with annHost, which is not changed. Used by the host annotations that
+// are read straight from the annotations, the host Mapper has its own defaults.
+func (c *converter) withNamespaceDefaults(namespace string, annHost map[string]string) map[string]string {
+	_, nsAnnHost, _ := c.readNamespaceAnnotations(namespace)
+	if len(nsAnnHost) == 0 {
+		return annHost
+	}
+	ann := make(map[string]string, len(annHost)+len(nsAnnHost))
+	for key, value := range nsAnnHost {
+		ann[key] = value
+	}
+	for key, value := range annHost {
+		ann[key] = value
+	}
+	return ann
+}
+
+// readAnnotations splits the annotations of an object in host and backend
+// annotations. Annotations not allowed by the annotation policy in the
+// namespace of source are skipped, the policy is not applied if source is nil.
+// Some host annotations are read straight from annHost instead of the host
+// Mapper, so the values of the host annotations are also checked here. The
+// values of the backend annotations are checked by the Mapper.
+func (c *converter) readAnnotations(source *annotations.Source, ann map[string]string) (annHost, annBack map[string]string) {
+	annHost = make(map[string]string, len(ann))
+	annBack = make(map[string]string, len(ann))
+	var denied, deniedValues []string
 	prefix := c.options.AnnotationPrefix + "/"
-	for annName, annValue := range annotations {
+	for annName, annValue := range ann {
 		if strings.HasPrefix(annName, prefix) {
 			name := strings.TrimPrefix(annName, prefix)
+			if source != nil && c.annPolicy != nil && !c.annPolicy.AllowKey(source.Namespace, name) {
+				denied = append(denied, annName)
+				continue
+			}
 			if _, isHostAnn := ingtypes.AnnHost[name]; isHostAnn {
+				if source != nil && c.annPolicy != nil && !c.annPolicy.AllowValue(source.Namespace, name, annValue) {
+					deniedValues = append(deniedValues, annName)
+					continue
+				}
 				annHost[name] = annValue
 			} else {
 				annBack[name] = annValue
 			}
 		}
 	}
+	if len(denied) > 0 {
+		sort.Strings(denied)
+		c.warn(source, "skipping annotation(s) from %v not allowed by the annotation policy: %v", source, denied)
+	}
+	sort.Strings(deniedValues)
+	for _, annName := range deniedValues {
+		c.warn(source, "ignoring annotation '%s' on %v: value not allowed by the annotation policy: %s",
+			annName, source, ann[annName])
+	}
 	return annHost, annBack
 }
 
@@ -17,6 +17,7 @@ limitations under the License.
 package ingress
 
 import (
+	"sort"
 	"strings"
 	"testing"
 
@@ -24,6 +25,7 @@ import (
 	yaml "gopkg.in/yaml.v2"
 	api "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -471,6 +473,72 @@ func TestSyncTLSCustom(t *testing.T) {
     tlsfilename: /tls/default/tls-echo.pem`)
 }
 
+func TestSyncTLSWildcard(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1Auto()
+	c.createSecretTLS1("default/tls-wildcard")
+	c.createSecretTLS1("default/tls-echo")
+	c.Sync(
+		c.createIngTLS1("default/echo1", "echo1.example.com", "/", "echo:8080", "tls-wildcard:*.example.com"),
+		c.createIngTLS1("default/echo2", "echo2.example.com", "/", "echo:8080", "tls-wildcard:*.example.com;tls-echo:echo2.example.com"),
+		c.createIngTLS1("default/echo3", "www.echo3.example.com", "/", "echo:8080", "tls-wildcard:*.example.com"),
+	)
+
+	c.compareConfigFront(`
+- hostname: echo1.example.com
+  paths:
+  - path: /
+    backend: default_echo_8080
+  tls:
+    tlsfilename: /tls/default/tls-wildcard.pem
+- hostname: echo2.example.com
+  paths:
+  - path: /
+    backend: default_echo_8080
+  tls:
+    tlsfilename: /tls/default/tls-echo.pem
+- hostname: www.echo3.example.com
+  paths:
+  - path: /
+    backend: default_echo_8080`)
+}
+
+func TestSyncTLSAcmeDual(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1Auto()
+	c.createSecretTLS1("default/tls1")
+	c.createSecretTLS1("default/tls1-dual")
+	c.createSecretTLS1("default/tls2")
+	ann := map[string]string{
+		"ingress.kubernetes.io/cert-signer":        "acme",
+		"ingress.kubernetes.io/acme-dual-key-type": "ec256",
+	}
+	ing1 := c.createIngTLS1("default/echo1", "echo1.example.com", "/", "echo:8080", "tls1")
+	ing1.SetAnnotations(ann)
+	ing2 := c.createIngTLS1("default/echo2", "echo2.example.com", "/", "echo:8080", "tls2")
+	ing2.SetAnnotations(ann)
+	c.Sync(ing1, ing2)
+
+	c.compareConfigFront(`
+- hostname: echo1.example.com
+  paths:
+  - path: /
+    backend: default_echo_8080
+  tls:
+    tlsfilename: /tls/default/tls1.pem
+    tlsdualfilename: /tls/default/tls1-dual.pem
+- hostname: echo2.example.com
+  paths:
+  - path: /
+    backend: default_echo_8080
+  tls:
+    tlsfilename: /tls/default/tls2.pem`)
+}
+
 func TestSyncRedeclareTLS(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -1527,6 +1595,455 @@ func TestSyncAnnBacksSvcIng(t *testing.T) {
   maxconnserver: 10` + defaultBackendConfig)
 }
 
+func TestSyncAnnBackNamespace(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.cache.NsList = map[string]*api.Namespace{
+		"default": {ObjectMeta: metav1.ObjectMeta{Name: "default", Annotations: map[string]string{
+			"ingress.kubernetes.io/balance-algorithm": "leastconn",
+			"ingress.kubernetes.io/maxconn-server":    "10",
+			"ingress.kubernetes.io/app-root":          "/app",
+		}}},
+	}
+	c.createSvc1("default/echo1", "8080", "172.17.0.11")
+	c.createSvc1Ann("default/echo2", "8080", "172.17.0.12", map[string]string{
+		"ingress.kubernetes.io/maxconn-server": "20",
+	})
+	c.Sync(
+		c.createIng1("default/echo1", "echo.example.com", "/app1", "echo1:8080"),
+		c.createIng1Ann("default/echo2", "echo.example.com", "/app2", "echo2:8080", map[string]string{
+			"ingress.kubernetes.io/balance-algorithm": "first",
+		}),
+	)
+
+	c.compareConfigBack(`
+- id: default_echo1_8080
+  endpoints:
+  - ip: 172.17.0.11
+    port: 8080
+  balancealgorithm: leastconn
+  maxconnserver: 10
+- id: default_echo2_8080
+  endpoints:
+  - ip: 172.17.0.12
+    port: 8080
+  balancealgorithm: first
+  maxconnserver: 20` + defaultBackendConfig)
+
+	if root := c.hconfig.Hosts().FindHost("echo.example.com").RootRedirect; root != "/app" {
+		t.Errorf("expected app-root from namespace '/app', but was '%s'", root)
+	}
+
+	// partial sync, namespace annotation changed
+	ns := c.cache.NsList["default"].DeepCopy()
+	ns.Annotations["ingress.kubernetes.io/balance-algorithm"] = "roundrobin"
+	c.cache.NsList["default"] = ns
+	c.cache.Changed.Namespaces = []*api.Namespace{ns}
+	c.Sync()
+
+	c.compareConfigBack(`
+- id: default_echo1_8080
+  endpoints:
+  - ip: 172.17.0.11
+    port: 8080
+  balancealgorithm: roundrobin
+  maxconnserver: 10
+- id: default_echo2_8080
+  endpoints:
+  - ip: 172.17.0.12
+    port: 8080
+  balancealgorithm: first
+  maxconnserver: 20` + defaultBackendConfig)
+
+	c.logger.CompareLogging(`INFO-V(2) syncing 1 host(s) and 2 backend(s)`)
+}
+
+func TestSyncAnnHostNamespace(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.cache.NsList = map[string]*api.Namespace{
+		"team1": {ObjectMeta: metav1.ObjectMeta{Name: "team1", Annotations: map[string]string{
+			"ingress.kubernetes.io/cert-signer":   "acme",
+			"ingress.kubernetes.io/acme-key-type": "ec256",
+		}}},
+	}
+	c.createSvc1("team1/echo1", "8080", "172.17.0.11")
+	c.createSvc1("team2/echo2", "8080", "172.17.0.12")
+	c.createSecretTLS1("team1/tls1")
+	c.createSecretTLS1("team1/tls2")
+	c.createSecretTLS1("team2/tls3")
+	ing2 := c.createIngTLS1("team1/echo2", "echo2.example.com", "/", "echo1:8080", "tls2:echo2.example.com")
+	ing2.SetAnnotations(map[string]string{
+		"ingress.kubernetes.io/acme-key-type": "rsa4096",
+	})
+	c.Sync(
+		c.createIngTLS1("team1/echo1", "echo1.example.com", "/", "echo1:8080", "tls1:echo1.example.com"),
+		ing2,
+		c.createIngTLS1("team2/echo3", "echo3.example.com", "/", "echo2:8080", "tls3:echo3.example.com"),
+	)
+
+	storages := strings.Join(c.hconfig.AcmeData().Storages().BuildAcmeStorages(), ";")
+	sorted := strings.Split(storages, ";")
+	sort.Strings(sorted)
+	expected := "team1/tls1,challenge=http-01,keytype=ec256,echo1.example.com;team1/tls2,challenge=http-01,keytype=rsa4096,echo2.example.com"
+	if actual := strings.Join(sorted, ";"); actual != expected {
+		t.Errorf("acme storages differ - expected: '%s', actual: '%s'", expected, actual)
+	}
+}
+
+func TestSyncAnnotationPolicy(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.cache.NsList = map[string]*api.Namespace{
+		"team1": {ObjectMeta: metav1.ObjectMeta{Name: "team1", Labels: map[string]string{"tenant": "true"}}},
+		"team2": {ObjectMeta: metav1.ObjectMeta{Name: "team2"}},
+	}
+	c.cache.Changed.GlobalNew = map[string]string{
+		ingtypes.GlobalAnnotationPolicy: `
+- namespaceSelector: tenant=true
+  deny: [maxconn-server]
+  values:
+    balance-algorithm: roundrobin|leastconn
+`,
+	}
+	c.createSvc1Ann("team1/echo1", "8080", "172.17.0.11", map[string]string{
+		"ingress.kubernetes.io/maxconn-server": "20",
+	})
+	c.createSvc1("team1/echo2", "8080", "172.17.0.12")
+	c.createSvc1("team2/echo3", "8080", "172.17.0.13")
+	c.Sync(
+		c.createIng1("team1/echo1", "echo1.example.com", "/", "echo1:8080"),
+		c.createIng1Ann("team1/echo2", "echo2.example.com", "/", "echo2:8080", map[string]string{
+			"ingress.kubernetes.io/balance-algorithm": "first",
+			"ingress.kubernetes.io/maxconn-server":    "10",
+		}),
+		c.createIng1Ann("team2/echo3", "echo3.example.com", "/", "echo3:8080", map[string]string{
+			"ingress.kubernetes.io/balance-algorithm": "first",
+			"ingress.kubernetes.io/maxconn-server":    "10",
+		}),
+	)
+
+	c.compareConfigBack(`
+- id: team1_echo1_8080
+  endpoints:
+  - ip: 172.17.0.11
+    port: 8080
+- id: team1_echo2_8080
+  endpoints:
+  - ip: 172.17.0.12
+    port: 8080
+- id: team2_echo3_8080
+  endpoints:
+  - ip: 172.17.0.13
+    port: 8080
+  balancealgorithm: first
+  maxconnserver: 10` + defaultBackendConfig)
+
+	c.logger.CompareLogging(`
+WARN skipping annotation(s) from service 'team1/echo1' not allowed by the annotation policy: [ingress.kubernetes.io/maxconn-server]
+WARN skipping annotation(s) from ingress 'team1/echo2' not allowed by the annotation policy: [ingress.kubernetes.io/maxconn-server]
+WARN ignoring annotation 'ingress.kubernetes.io/balance-algorithm' on ingress 'team1/echo2': value not allowed by the annotation policy: first`)
+
+	// invalid policy denies all the annotations
+	c.cache.Changed.GlobalCur = c.cache.Changed.GlobalNew
+	c.cache.Changed.GlobalNew = map[string]string{
+		ingtypes.GlobalAnnotationPolicy: `- namespaceSelector: "tenant in ("`,
+	}
+	c.Sync()
+
+	c.compareConfigBack(`
+- id: team1_echo1_8080
+  endpoints:
+  - ip: 172.17.0.11
+    port: 8080
+- id: team1_echo2_8080
+  endpoints:
+  - ip: 172.17.0.12
+    port: 8080
+- id: team2_echo3_8080
+  endpoints:
+  - ip: 172.17.0.13
+    port: 8080` + defaultBackendConfig)
+
+	c.logger.CompareLogging(`
+ERROR denying all annotations due to an error parsing the annotation policy: invalid namespace selector on rule 0: unable to parse requirement: found '', expected: ',', ')' or identifier
+WARN skipping annotation(s) from service 'team1/echo1' not allowed by the annotation policy: [ingress.kubernetes.io/maxconn-server]
+WARN skipping annotation(s) from ingress 'team1/echo2' not allowed by the annotation policy: [ingress.kubernetes.io/balance-algorithm ingress.kubernetes.io/maxconn-server]
+WARN skipping annotation(s) from ingress 'team2/echo3' not allowed by the annotation policy: [ingress.kubernetes.io/balance-algorithm ingress.kubernetes.io/maxconn-server]`)
+}
+
+func TestSyncAnnotationPolicyHost(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.cache.NsList = map[string]*api.Namespace{
+		"team1": {ObjectMeta: metav1.ObjectMeta{Name: "team1", Labels: map[string]string{"tenant": "true"}}},
+	}
+	c.cache.Changed.GlobalNew = map[string]string{
+		ingtypes.GlobalAnnotationPolicy: `
+- namespaceSelector: tenant=true
+  values:
+    ssl-passthrough: "false"
+    acme-dns-provider: "rfc2136:dns1"
+`,
+	}
+	c.createSvc1("team1/echo1", "8080", "172.17.0.11")
+	c.createSecretTLS1("team1/tls1")
+	c.createSecretTLS1("team1/tls2")
+	ing2 := c.createIngTLS1("team1/echo2", "echo2.example.com", "/", "echo1:8080", "tls1:echo2.example.com")
+	ing2.SetAnnotations(map[string]string{
+		"ingress.kubernetes.io/cert-signer":       "acme",
+		"ingress.kubernetes.io/acme-challenge":    "dns-01",
+		"ingress.kubernetes.io/acme-dns-provider": "webhook:hook1",
+	})
+	ing3 := c.createIngTLS1("team1/echo3", "echo3.example.com", "/", "echo1:8080", "tls2:echo3.example.com")
+	ing3.SetAnnotations(map[string]string{
+		"ingress.kubernetes.io/cert-signer":       "acme",
+		"ingress.kubernetes.io/acme-challenge":    "dns-01",
+		"ingress.kubernetes.io/acme-dns-provider": "rfc2136:dns1",
+	})
+	c.Sync(
+		c.createIng1Ann("team1/echo1", "echo1.example.com", "/", "echo1:8080", map[string]string{
+			"ingress.kubernetes.io/ssl-passthrough":           "true",
+			"ingress.kubernetes.io/ssl-passthrough-http-port": "9000",
+		}),
+		ing2,
+		ing3,
+	)
+
+	storages := strings.Join(c.hconfig.AcmeData().Storages().BuildAcmeStorages(), ";")
+	expected := "team1/tls2,challenge=dns-01,provider=rfc2136:team1/dns1,keytype=rsa2048,echo3.example.com"
+	if storages != expected {
+		t.Errorf("acme storages differ - expected: '%s', actual: '%s'", expected, storages)
+	}
+
+	c.logger.CompareLogging(`
+WARN ignoring annotation 'ingress.kubernetes.io/ssl-passthrough' on ingress 'team1/echo1': value not allowed by the annotation policy: true
+WARN ignoring annotation 'ingress.kubernetes.io/acme-dns-provider' on ingress 'team1/echo2': value not allowed by the annotation policy: webhook:hook1
+WARN skipping cert signer of ingress 'team1/echo2': invalid or missing acme dns provider, expected '<type>:<secret-name>': ''`)
+}
+
+func TestSyncHostnameOwnership(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.cache.Changed.GlobalNew = map[string]string{
+		ingtypes.GlobalHostnameOwnership: "true",
+		ingtypes.GlobalHostnameAllowlist: "echo.example.com=team3",
+	}
+	c.createSvc1("team1/echo1", "8080", "172.17.0.11")
+	c.createSvc1("team2/echo2", "8080", "172.17.0.12")
+	c.createSvc1("team3/echo3", "8080", "172.17.0.13")
+	c.createSvc1("team4/echo4", "8080", "172.17.0.14")
+	ing1 := c.createIng1Ann("team1/echo1", "echo.example.com", "/app1", "echo1:8080", map[string]string{
+		"ingress.kubernetes.io/hostname-delegation": "team4",
+	})
+	c.Sync(
+		ing1,
+		c.createIng1("team2/echo2", "echo.example.com", "/app2", "echo2:8080"),
+		c.createIng1("team3/echo3", "echo.example.com", "/app3", "echo3:8080"),
+		c.createIng1("team4/echo4", "echo.example.com", "/app4", "echo4:8080"),
+		c.createIng1("team2/echo5", "echo2.example.com", "/", "echo2:8080"),
+	)
+
+	c.compareConfigFront(`
+- hostname: echo.example.com
+  paths:
+  - path: /app4
+    backend: team4_echo4_8080
+  - path: /app3
+    backend: team3_echo3_8080
+  - path: /app1
+    backend: team1_echo1_8080
+- hostname: echo2.example.com
+  paths:
+  - path: /
+    backend: team2_echo2_8080`)
+
+	c.logger.CompareLogging(`
+WARN skipping hostname 'echo.example.com' of ingress 'team2/echo2': hostname is owned by namespace 'team1'`)
+
+	// partial sync, the owner of the hostname was removed
+	c.cache.Changed.IngressesDel = []*networking.Ingress{ing1}
+	c.Sync()
+
+	c.compareConfigFront(`
+- hostname: echo.example.com
+  paths:
+  - path: /app3
+    backend: team3_echo3_8080
+  - path: /app2
+    backend: team2_echo2_8080
+- hostname: echo2.example.com
+  paths:
+  - path: /
+    backend: team2_echo2_8080`)
+
+	c.logger.CompareLogging(`
+INFO-V(2) syncing 2 host(s) and 4 backend(s)
+WARN skipping hostname 'echo.example.com' of ingress 'team4/echo4': hostname is owned by namespace 'team2'`)
+}
+
+func TestSyncHostnameOwnershipAlias(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.cache.Changed.GlobalNew = map[string]string{
+		ingtypes.GlobalHostnameOwnership: "true",
+	}
+	c.createSvc1("team1/echo1", "8080", "172.17.0.11")
+	c.createSvc1("team2/echo2", "8080", "172.17.0.12")
+	c.Sync(
+		c.createIng1("team1/echo1", "echo1.example.com", "/", "echo1:8080"),
+		c.createIng1Ann("team2/echo2", "echo2.example.com", "/", "echo2:8080", map[string]string{
+			"ingress.kubernetes.io/server-alias":       "echo1.example.com",
+			"ingress.kubernetes.io/server-alias-regex": `^echo[0-9]\.example\.com$`,
+		}),
+		c.createIng1Ann("team2/echo3", "echo3.example.com", "/", "echo2:8080", map[string]string{
+			"ingress.kubernetes.io/server-alias":       "www.echo3.example.com",
+			"ingress.kubernetes.io/server-alias-regex": `^echo[2-3]\.example\.com$`,
+		}),
+	)
+
+	c.compareConfigFront(`
+- hostname: echo1.example.com
+  paths:
+  - path: /
+    backend: team1_echo1_8080
+- hostname: echo2.example.com
+  paths:
+  - path: /
+    backend: team2_echo2_8080
+- hostname: echo3.example.com
+  paths:
+  - path: /
+    backend: team2_echo2_8080
+  alias:
+    aliasname: www.echo3.example.com
+    aliasregex: ^echo[2-3]\.example\.com$`)
+
+	c.logger.CompareLogging(`
+WARN skipping server-alias 'echo1.example.com' of ingress 'team2/echo2': hostname is owned by namespace 'team1'
+WARN skipping server-alias-regex '^echo[0-9]\.example\.com$' of ingress 'team2/echo2': it matches hostname 'echo1.example.com' owned by namespace 'team1'`)
+}
+
+func TestSyncHostnameOwnershipAcme(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.cache.Changed.GlobalNew = map[string]string{
+		ingtypes.GlobalHostnameOwnership: "true",
+		ingtypes.GlobalHostnameAllowlist: "echo3.example.com=team2",
+	}
+	c.createSvc1("team1/echo1", "8080", "172.17.0.11")
+	c.createSvc1("team2/echo2", "8080", "172.17.0.12")
+	c.createSecretTLS1("team2/tls2")
+	ing2 := c.createIngTLS1("team2/echo2", "echo2.example.com", "/", "echo2:8080",
+		"tls2:echo1.example.com,echo2.example.com,echo3.example.com")
+	ing2.SetAnnotations(map[string]string{"ingress.kubernetes.io/cert-signer": "acme"})
+	c.Sync(
+		c.createIng1("team1/echo1", "echo1.example.com", "/", "echo1:8080"),
+		c.createIng1("team1/echo3", "echo3.example.com", "/", "echo1:8080"),
+		ing2,
+	)
+
+	storages := strings.Join(c.hconfig.AcmeData().Storages().BuildAcmeStorages(), ";")
+	expected := "team2/tls2,challenge=http-01,keytype=rsa2048,echo2.example.com,echo3.example.com"
+	if storages != expected {
+		t.Errorf("acme storages differ - expected: '%s', actual: '%s'", expected, storages)
+	}
+
+	c.logger.CompareLogging(`
+WARN skipping hostname 'echo1.example.com' of ingress 'team2/echo2': hostname is owned by namespace 'team1'`)
+}
+
+func TestSyncHostnameOwnershipPartial(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.cache.Changed.GlobalNew = map[string]string{
+		ingtypes.GlobalHostnameOwnership: "true",
+	}
+	c.createSvc1("team1/echo1", "8080", "172.17.0.11")
+	c.createSvc1("team2/echo2", "8080", "172.17.0.12")
+	c.createSecretTLS1("team2/tls2")
+	ing1 := c.createIng1("team1/echo1", "echo1.example.com", "/", "echo1:8080")
+	ing3 := c.createIng1("team2/echo3", "echo3.example.com", "/", "echo2:8080")
+	c.Sync(ing1, ing3)
+	c.logger.CompareLogging("")
+
+	// partial sync, added ingress declares the hostname of another namespace as alias and acme domain
+	ing2 := c.createIngTLS1("team2/echo2", "echo2.example.com", "/", "echo2:8080", "tls2:echo1.example.com,echo2.example.com")
+	ing2.SetAnnotations(map[string]string{
+		"ingress.kubernetes.io/cert-signer":  "acme",
+		"ingress.kubernetes.io/server-alias": "echo1.example.com",
+	})
+	c.cache.IngList = append(c.cache.IngList, ing2)
+	c.cache.Changed.IngressesAdd = []*networking.Ingress{ing2}
+	c.Sync()
+
+	c.compareConfigFront(`
+- hostname: echo1.example.com
+  paths:
+  - path: /
+    backend: team1_echo1_8080
+- hostname: echo2.example.com
+  paths:
+  - path: /
+    backend: team2_echo2_8080
+  tls:
+    tlsfilename: /tls/team2/tls2.pem
+- hostname: echo3.example.com
+  paths:
+  - path: /
+    backend: team2_echo2_8080`)
+
+	storages := strings.Join(c.hconfig.AcmeData().Storages().BuildAcmeStorages(), ";")
+	expected := "team2/tls2,challenge=http-01,keytype=rsa2048,echo2.example.com"
+	if storages != expected {
+		t.Errorf("acme storages differ - expected: '%s', actual: '%s'", expected, storages)
+	}
+
+	c.logger.CompareLogging(`
+INFO-V(2) syncing 3 host(s) and 2 backend(s)
+WARN skipping hostname 'echo1.example.com' of ingress 'team2/echo2': hostname is owned by namespace 'team1'
+WARN skipping server-alias 'echo1.example.com' of ingress 'team2/echo2': hostname is owned by namespace 'team1'`)
+
+	// partial sync, updated ingress declares the hostname of another namespace as alias
+	ing3upd := c.createIng1Ann("team2/echo3", "echo3.example.com", "/", "echo2:8080", map[string]string{
+		"ingress.kubernetes.io/server-alias": "echo1.example.com",
+	})
+	c.cache.IngList = []*networking.Ingress{ing1, ing2, ing3upd}
+	c.cache.Changed.IngressesAdd = nil
+	c.cache.Changed.IngressesUpd = []*networking.Ingress{ing3upd}
+	c.Sync()
+
+	c.compareConfigFront(`
+- hostname: echo1.example.com
+  paths:
+  - path: /
+    backend: team1_echo1_8080
+- hostname: echo2.example.com
+  paths:
+  - path: /
+    backend: team2_echo2_8080
+  tls:
+    tlsfilename: /tls/team2/tls2.pem
+- hostname: echo3.example.com
+  paths:
+  - path: /
+    backend: team2_echo2_8080`)
+
+	c.logger.CompareLogging(`
+INFO-V(2) syncing 3 host(s) and 2 backend(s)
+WARN skipping hostname 'echo1.example.com' of ingress 'team2/echo2': hostname is owned by namespace 'team1'
+WARN skipping server-alias 'echo1.example.com' of ingress 'team2/echo2': hostname is owned by namespace 'team1'
+WARN skipping server-alias 'echo1.example.com' of ingress 'team2/echo3': hostname is owned by namespace 'team1'`)
+}
+
 func TestSyncAnnBackDefault(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -1675,6 +2192,568 @@ WARN skipping http port config of ssl-passthrough on ingress 'default/echo2': po
 `)
 }
 
+func TestSyncAcmeChallenge(t *testing.T) {
+	testCases := []struct {
+		global   map[string]string
+		ann1     map[string]string
+		ann2     map[string]string
+		tlsHosts string
+		expected []string
+		logging  string
+	}{
+		// 0
+		{
+			expected: []string{"default/tls1,challenge=http-01,keytype=rsa2048,echo1.example.com"},
+		},
+		// 1
+		{
+			ann1: map[string]string{
+				"ingress.kubernetes.io/acme-challenge":    "dns-01",
+				"ingress.kubernetes.io/acme-dns-provider": "rfc2136:dns",
+			},
+			expected: []string{"default/tls1,challenge=dns-01,provider=rfc2136:default/dns,keytype=rsa2048,echo1.example.com"},
+		},
+		// 2
+		{
+			global: map[string]string{
+				"acme-challenge":    "dns-01",
+				"acme-dns-provider": "webhook:ingress/dns",
+			},
+			expected: []string{"default/tls1,challenge=dns-01,provider=webhook:ingress/dns,keytype=rsa2048,echo1.example.com"},
+		},
+		// 3
+		{
+			global: map[string]string{
+				"acme-dns-provider": "webhook:ingress/dns",
+			},
+			ann1: map[string]string{
+				"ingress.kubernetes.io/acme-challenge": "dns-01",
+			},
+			expected: []string{"default/tls1,challenge=dns-01,provider=webhook:ingress/dns,keytype=rsa2048,echo1.example.com"},
+		},
+		// 4
+		{
+			ann1: map[string]string{
+				"ingress.kubernetes.io/acme-challenge":    "dns-01",
+				"ingress.kubernetes.io/acme-dns-provider": "rfc2136:ingress/dns",
+			},
+			expected: []string{},
+			logging:  `WARN skipping cert signer of ingress 'default/echo1': acme dns provider secret 'ingress/dns' must be in the ingress namespace`,
+		},
+		// 5
+		{
+			ann1: map[string]string{
+				"ingress.kubernetes.io/acme-challenge": "dns-01",
+			},
+			expected: []string{},
+			logging:  `WARN skipping cert signer of ingress 'default/echo1': invalid or missing acme dns provider, expected '<type>:<secret-name>': ''`,
+		},
+		// 6
+		{
+			ann1: map[string]string{
+				"ingress.kubernetes.io/acme-challenge": "tls-sni-01",
+			},
+			expected: []string{},
+			logging:  `WARN skipping cert signer of ingress 'default/echo1': unsupported acme challenge: tls-sni-01`,
+		},
+		// 7
+		{
+			ann1: map[string]string{
+				"ingress.kubernetes.io/acme-challenge":    "dns-01",
+				"ingress.kubernetes.io/acme-dns-provider": "rfc2136:dns",
+			},
+			ann2:     map[string]string{},
+			expected: []string{"default/tls1,challenge=dns-01,provider=rfc2136:default/dns,keytype=rsa2048,echo1.example.com,echo2.example.com"},
+			logging:  `WARN ignoring acme challenge config of ingress 'default/echo2': secret 'default/tls1' was already assigned to another challenge or provider`,
+		},
+		// 8
+		{
+			ann1: map[string]string{
+				"ingress.kubernetes.io/acme-challenge":    "dns-01",
+				"ingress.kubernetes.io/acme-dns-provider": "rfc2136:dns",
+			},
+			tlsHosts: "*.example.com,echo1.example.com",
+			expected: []string{"default/tls1,challenge=dns-01,provider=rfc2136:default/dns,keytype=rsa2048,*.example.com"},
+		},
+		// 9
+		{
+			tlsHosts: "*.example.com,echo1.example.com",
+			expected: []string{"default/tls1,challenge=http-01,keytype=rsa2048,echo1.example.com"},
+			logging:  `WARN skipping cert signer of wildcard host '*.example.com' on ingress 'default/echo1': wildcard certificates need the dns-01 challenge`,
+		},
+		// 10
+		{
+			global: map[string]string{
+				"acme-challenge": "tls-alpn-01",
+			},
+			expected: []string{"default/tls1,challenge=tls-alpn-01,keytype=rsa2048,echo1.example.com"},
+		},
+		// 11
+		{
+			global: map[string]string{
+				"acme-key-type":      "ec256",
+				"acme-dual-key-type": "rsa2048",
+			},
+			expected: []string{"default/tls1,challenge=http-01,keytype=ec256,dualkeytype=rsa2048,echo1.example.com"},
+		},
+		// 12
+		{
+			ann1: map[string]string{
+				"ingress.kubernetes.io/acme-key-type":      "ec384",
+				"ingress.kubernetes.io/acme-dual-key-type": "ec384",
+			},
+			expected: []string{"default/tls1,challenge=http-01,keytype=ec384,echo1.example.com"},
+		},
+		// 13
+		{
+			ann1: map[string]string{
+				"ingress.kubernetes.io/acme-key-type": "dsa1024",
+			},
+			expected: []string{},
+			logging:  `WARN skipping cert signer of ingress 'default/echo1': unsupported acme key type: dsa1024`,
+		},
+		// 14
+		{
+			ann1: map[string]string{
+				"ingress.kubernetes.io/acme-key-type": "rsa4096",
+			},
+			ann2:     map[string]string{},
+			expected: []string{"default/tls1,challenge=http-01,keytype=rsa4096,echo1.example.com,echo2.example.com"},
+			logging:  `WARN ignoring acme key type config of ingress 'default/echo2': secret 'default/tls1' was already assigned to another key type`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.createSvc1Auto()
+		c.createSecretTLS1("default/tls1")
+		c.cache.Changed.GlobalNew = test.global
+		ann1 := map[string]string{"ingress.kubernetes.io/cert-signer": "acme"}
+		for k, v := range test.ann1 {
+			ann1[k] = v
+		}
+		tls1 := "tls1"
+		if test.tlsHosts != "" {
+			tls1 += ":" + test.tlsHosts
+		}
+		ing1 := c.createIngTLS1("default/echo1", "echo1.example.com", "/", "echo:8080", tls1)
+		ing1.SetAnnotations(ann1)
+		ings := []*networking.Ingress{ing1}
+		if test.ann2 != nil {
+			ann2 := map[string]string{"ingress.kubernetes.io/cert-signer": "acme"}
+			for k, v := range test.ann2 {
+				ann2[k] = v
+			}
+			ing2 := c.createIngTLS1("default/echo2", "echo2.example.com", "/", "echo:8080", "tls1")
+			ing2.SetAnnotations(ann2)
+			ings = append(ings, ing2)
+		}
+		c.Sync(ings...)
+		storages := strings.Join(c.hconfig.AcmeData().Storages().BuildAcmeStorages(), ";")
+		if expected := strings.Join(test.expected, ";"); storages != expected {
+			t.Errorf("acme storages differ on %d - expected: '%s', actual: '%s'", i, expected, storages)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * *
+ *
+ *  TCP SERVICES
+ *
+ * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+func TestSyncTCPServices(t *testing.T) {
+	testCases := []struct {
+		svc     [][]string
+		ann     []map[string]string
+		allowed string
+		tcpcm   map[string]string
+		secrets []string
+		cas     []string
+		expTCP  string
+		logging string
+	}{
+		// 0
+		{
+			svc:    [][]string{{"default/redis", "6379", "172.17.0.11"}},
+			ann:    []map[string]string{{"ingress.kubernetes.io/tcp-service-port": "7000"}},
+			expTCP: `[]`,
+			logging: `
+WARN skipping TCP service on public port 7000 of service 'default/redis': port not allowed on namespace 'default'`,
+		},
+		// 1
+		{
+			svc:     [][]string{{"default/redis", "6379", "172.17.0.11"}},
+			ann:     []map[string]string{{"ingress.kubernetes.io/tcp-service-port": "7000"}},
+			allowed: "team1=7000",
+			expTCP:  `[]`,
+			logging: `
+WARN skipping TCP service on public port 7000 of service 'default/redis': port not allowed on namespace 'default'`,
+		},
+		// 2
+		{
+			svc:     [][]string{{"default/redis", "6379", "172.17.0.11"}},
+			ann:     []map[string]string{{"ingress.kubernetes.io/tcp-service-port": "7000"}},
+			allowed: "default=6000,7000-7099",
+			expTCP: `
+- name: default_redis
+  port: 7000
+  endpoints:
+  - ip: 172.17.0.11
+    port: 6379
+  checkinterval: 2s`,
+		},
+		// 3
+		{
+			svc:     [][]string{{"default/redis", "6379", "172.17.0.11"}},
+			ann:     []map[string]string{{"ingress.kubernetes.io/tcp-service-port": "7a"}},
+			allowed: "*=7000",
+			expTCP:  `[]`,
+			logging: `
+WARN skipping TCP service of service 'default/redis': invalid public port: 7a`,
+		},
+		// 4
+		{
+			svc:     [][]string{{"default/redis", "6379", "172.17.0.11"}},
+			ann:     []map[string]string{{"ingress.kubernetes.io/tcp-service-port": "7000"}},
+			allowed: "default=7000\n*=10-5,7x,8000\ninvalid",
+			expTCP: `
+- name: default_redis
+  port: 7000
+  endpoints:
+  - ip: 172.17.0.11
+    port: 6379
+  checkinterval: 2s`,
+			logging: `
+WARN ignoring invalid port or port range of TCP service allowed ports on namespace '*': 10-5
+WARN ignoring invalid port or port range of TCP service allowed ports on namespace '*': 7x
+WARN ignoring misconfigured TCP service allowed ports: invalid`,
+		},
+		// 5
+		{
+			svc: [][]string{
+				{"team2/redis", "6379", "172.17.0.12"},
+				{"team1/redis", "6379", "172.17.0.11"},
+			},
+			ann: []map[string]string{
+				{"ingress.kubernetes.io/tcp-service-port": "7000"},
+				{"ingress.kubernetes.io/tcp-service-port": "7000"},
+			},
+			allowed: "*=7000",
+			expTCP: `
+- name: team1_redis
+  port: 7000
+  endpoints:
+  - ip: 172.17.0.11
+    port: 6379
+  checkinterval: 2s`,
+			logging: `
+WARN skipping TCP service on public port 7000 of service 'team2/redis': port already in use by 'team1_redis'`,
+		},
+		// 6
+		{
+			svc:     [][]string{{"default/redis", "6379", "172.17.0.11"}},
+			ann:     []map[string]string{{"ingress.kubernetes.io/tcp-service-port": "7000"}},
+			allowed: "*=7000",
+			tcpcm:   map[string]string{"7000": "default/pg:5432"},
+			expTCP:  `[]`,
+			logging: `
+WARN skipping TCP service on public port 7000 of service 'default/redis': port already declared on the TCP services configmap`,
+		},
+		// 7
+		{
+			svc: [][]string{{"default/redis", "6379", "172.17.0.11"}},
+			ann: []map[string]string{{
+				"ingress.kubernetes.io/tcp-service-port":        "7000",
+				"ingress.kubernetes.io/tcp-service-target-port": "6380",
+			}},
+			allowed: "*=7000",
+			expTCP:  `[]`,
+			logging: `
+WARN skipping TCP service on public port 7000 of service 'default/redis': port not found: '6380'`,
+		},
+		// 8
+		{
+			svc: [][]string{{"default/redis", "6379", "172.17.0.11"}},
+			ann: []map[string]string{{
+				"ingress.kubernetes.io/tcp-service-port":       "7000",
+				"ingress.kubernetes.io/tcp-service-tls-secret": "tls1",
+			}},
+			allowed: "*=7000",
+			expTCP:  `[]`,
+			logging: `
+WARN skipping TCP service on public port 7000 of service 'default/redis': secret not found: 'default/tls1'`,
+		},
+		// 9
+		{
+			svc: [][]string{{"default/redis", "6379", "172.17.0.11"}},
+			ann: []map[string]string{{
+				"ingress.kubernetes.io/tcp-service-port":           "7000",
+				"ingress.kubernetes.io/tcp-service-tls-secret":     "tls1",
+				"ingress.kubernetes.io/tcp-service-accept-proxy":   "true",
+				"ingress.kubernetes.io/tcp-service-send-proxy":     "v1",
+				"ingress.kubernetes.io/tcp-service-check-interval": "-",
+			}},
+			allowed: "*=7000",
+			secrets: []string{"default/tls1"},
+			expTCP: `
+- name: default_redis
+  port: 7000
+  endpoints:
+  - ip: 172.17.0.11
+    port: 6379
+  sslfilename: /tls/default/tls1.pem
+  acceptproxy: true
+  sendproxy: v1`,
+		},
+		// 10
+		{
+			svc: [][]string{{"default/redis", "6379", "172.17.0.11"}},
+			ann: []map[string]string{{
+				"ingress.kubernetes.io/tcp-service-port":           "7000",
+				"ingress.kubernetes.io/tcp-service-accept-proxy":   "yes",
+				"ingress.kubernetes.io/tcp-service-send-proxy":     "v3",
+				"ingress.kubernetes.io/tcp-service-check-interval": "1x",
+			}},
+			allowed: "*=7000",
+			expTCP: `
+- name: default_redis
+  port: 7000
+  endpoints:
+  - ip: 172.17.0.11
+    port: 6379
+  checkinterval: 2s`,
+			logging: `
+WARN using default check interval '2s' due to an invalid time config on service 'default/redis': 1x
+WARN ignoring invalid accept proxy config on service 'default/redis': yes
+WARN ignoring invalid send proxy version on service 'default/redis': v3`,
+		},
+		// 11
+		{
+			svc: [][]string{{"default/redis", "6379", "172.17.0.11"}},
+			ann: []map[string]string{{
+				"ingress.kubernetes.io/tcp-service-port":                   "7000",
+				"ingress.kubernetes.io/tcp-service-tls-secret":             "tls1",
+				"ingress.kubernetes.io/tcp-service-ca-secret":              "ca1",
+				"ingress.kubernetes.io/tcp-service-balance-algorithm":      "leastconn",
+				"ingress.kubernetes.io/tcp-service-timeout-client":         "1m",
+				"ingress.kubernetes.io/tcp-service-timeout-server":         "2m",
+				"ingress.kubernetes.io/tcp-service-whitelist-source-range": "10.0.0.0/8, 192.168.0.1,fd00::/8",
+				"ingress.kubernetes.io/tcp-service-maxconn":                "100",
+			}},
+			allowed: "*=7000",
+			secrets: []string{"default/tls1"},
+			cas:     []string{"default/ca1"},
+			expTCP: `
+- name: default_redis
+  port: 7000
+  endpoints:
+  - ip: 172.17.0.11
+    port: 6379
+  checkinterval: 2s
+  sslfilename: /tls/default/tls1.pem
+  cafilename: /ca/default/ca1.pem
+  balance: leastconn
+  timeoutclient: 1m
+  timeoutserver: 2m
+  whitelist:
+  - 10.0.0.0/8
+  - 192.168.0.1
+  - fd00::/8
+  maxconn: 100`,
+		},
+		// 12
+		{
+			svc: [][]string{{"default/redis", "6379", "172.17.0.11"}},
+			ann: []map[string]string{{
+				"ingress.kubernetes.io/tcp-service-port":      "7000",
+				"ingress.kubernetes.io/tcp-service-ca-secret": "ca1",
+			}},
+			allowed: "*=7000",
+			expTCP:  `[]`,
+			logging: `
+WARN skipping TCP service on public port 7000 of service 'default/redis': secret not found: 'default/ca1'`,
+		},
+		// 13
+		{
+			svc: [][]string{{"default/redis", "6379", "172.17.0.11"}},
+			ann: []map[string]string{{
+				"ingress.kubernetes.io/tcp-service-port":                   "7000",
+				"ingress.kubernetes.io/tcp-service-balance-algorithm":      "uri",
+				"ingress.kubernetes.io/tcp-service-timeout-client":         "1x",
+				"ingress.kubernetes.io/tcp-service-timeout-server":         "10",
+				"ingress.kubernetes.io/tcp-service-whitelist-source-range": "10.0.0.0/8,10.0.0.300",
+				"ingress.kubernetes.io/tcp-service-maxconn":                "-1",
+			}},
+			allowed: "*=7000",
+			expTCP: `
+- name: default_redis
+  port: 7000
+  endpoints:
+  - ip: 172.17.0.11
+    port: 6379
+  checkinterval: 2s
+  whitelist:
+  - 10.0.0.0/8`,
+			logging: `
+WARN ignoring invalid balance algorithm on service 'default/redis': uri
+WARN ignoring invalid client timeout config on service 'default/redis': 1x
+WARN ignoring invalid server timeout config on service 'default/redis': 10
+WARN ignoring invalid maxconn on service 'default/redis': -1
+WARN skipping invalid IP or cidr on service 'default/redis': 10.0.0.300`,
+		},
+	}
+	for _, test := range testCases {
+		c := setup(t)
+		for j, svc := range test.svc {
+			c.createSvc1Ann(svc[0], svc[1], svc[2], test.ann[j])
+		}
+		for _, secret := range test.secrets {
+			c.createSecretTLS1(secret)
+		}
+		c.cache.SecretCAPath = map[string]string{}
+		for _, ca := range test.cas {
+			c.cache.SecretCAPath[ca] = "/ca/" + ca + ".pem"
+		}
+		c.cache.Changed.GlobalNew = map[string]string{ingtypes.GlobalTCPServiceAllowedPorts: test.allowed}
+		c.cache.Changed.TCPConfigMapNew = test.tcpcm
+		c.Sync()
+		c.compareConfigTCP(test.expTCP)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestSyncTCPServicesAnnotationPolicy(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.cache.NsList = map[string]*api.Namespace{
+		"team1": {ObjectMeta: metav1.ObjectMeta{Name: "team1", Labels: map[string]string{"tenant": "true"}}},
+		"team2": {ObjectMeta: metav1.ObjectMeta{Name: "team2", Labels: map[string]string{"tenant": "true"}}},
+	}
+	c.cache.Changed.GlobalNew = map[string]string{
+		ingtypes.GlobalTCPServiceAllowedPorts: "*=7000-7002",
+		ingtypes.GlobalAnnotationPolicy: `
+- namespaceSelector: tenant=true
+  deny: [tcp-service-send-proxy]
+  values:
+    tcp-service-port: "700[01]"
+    tcp-service-check-interval: "[0-9]+s"
+`,
+	}
+	c.createSvc1Ann("team1/redis", "6379", "172.17.0.11", map[string]string{
+		"ingress.kubernetes.io/tcp-service-port":           "7000",
+		"ingress.kubernetes.io/tcp-service-check-interval": "500ms",
+		"ingress.kubernetes.io/tcp-service-send-proxy":     "v2",
+	})
+	c.createSvc1Ann("team2/redis", "6379", "172.17.0.12", map[string]string{
+		"ingress.kubernetes.io/tcp-service-port": "7002",
+	})
+	c.Sync()
+
+	c.compareConfigTCP(`
+- name: team1_redis
+  port: 7000
+  endpoints:
+  - ip: 172.17.0.11
+    port: 6379
+  checkinterval: 2s`)
+	c.logger.CompareLogging(`
+WARN skipping annotation(s) from service 'team1/redis' not allowed by the annotation policy: [ingress.kubernetes.io/tcp-service-send-proxy]
+WARN ignoring annotation 'ingress.kubernetes.io/tcp-service-check-interval' on service 'team1/redis': value not allowed by the annotation policy: 500ms
+WARN ignoring annotation 'ingress.kubernetes.io/tcp-service-port' on service 'team2/redis': value not allowed by the annotation policy: 7002`)
+}
+
+func TestSyncTCPServicesPartial(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	annPort := func(port string) map[string]string {
+		return map[string]string{"ingress.kubernetes.io/tcp-service-port": port}
+	}
+	c.cache.Changed.GlobalNew = map[string]string{ingtypes.GlobalTCPServiceAllowedPorts: "*=7000-7001"}
+	svc1, _ := c.createSvc1Ann("team1/redis", "6379", "172.17.0.11", annPort("7000"))
+	c.createSvc1Ann("team2/redis", "6379", "172.17.0.12", annPort("7000"))
+	c.Sync()
+
+	c.compareConfigTCP(`
+- name: team1_redis
+  port: 7000
+  endpoints:
+  - ip: 172.17.0.11
+    port: 6379
+  checkinterval: 2s`)
+	c.logger.CompareLogging(`
+WARN skipping TCP service on public port 7000 of service 'team2/redis': port already in use by 'team1_redis'`)
+
+	// move team1 to another port, team2 takes over
+	svc1upd, _ := conv_helper.CreateService("team1/redis", "6379", "172.17.0.11")
+	svc1upd.SetAnnotations(annPort("7001"))
+	svc1upd.CreationTimestamp = svc1.CreationTimestamp
+	c.cache.Changed.ServicesUpd = []*api.Service{svc1upd}
+	c.Sync()
+
+	c.compareConfigTCP(`
+- name: team1_redis
+  port: 7001
+  endpoints:
+  - ip: 172.17.0.11
+    port: 6379
+  checkinterval: 2s
+- name: team2_redis
+  port: 7000
+  endpoints:
+  - ip: 172.17.0.12
+    port: 6379
+  checkinterval: 2s`)
+	c.logger.CompareLogging(`
+INFO-V(2) syncing 0 host(s) and 0 backend(s)
+INFO-V(2) syncing 2 TCP service port(s)`)
+
+	// endpoints of team2 changed
+	_, ep2 := conv_helper.CreateService("team2/redis", "6379", "172.17.0.12,172.17.0.13")
+	c.cache.Changed.Endpoints = []*api.Endpoints{ep2}
+	c.Sync()
+
+	c.compareConfigTCP(`
+- name: team1_redis
+  port: 7001
+  endpoints:
+  - ip: 172.17.0.11
+    port: 6379
+  checkinterval: 2s
+- name: team2_redis
+  port: 7000
+  endpoints:
+  - ip: 172.17.0.12
+    port: 6379
+  - ip: 172.17.0.13
+    port: 6379
+  checkinterval: 2s`)
+	c.logger.CompareLogging(`
+INFO-V(2) syncing 0 host(s) and 0 backend(s)
+INFO-V(2) syncing 1 TCP service port(s)`)
+
+	// team1 removed
+	c.cache.Changed.ServicesDel = []*api.Service{svc1upd}
+	c.Sync()
+
+	c.compareConfigTCP(`
+- name: team2_redis
+  port: 7000
+  endpoints:
+  - ip: 172.17.0.12
+    port: 6379
+  - ip: 172.17.0.13
+    port: 6379
+  checkinterval: 2s`)
+	c.logger.CompareLogging(`
+INFO-V(2) syncing 0 host(s) and 0 backend(s)
+INFO-V(2) syncing 1 TCP service port(s)`)
+}
+
 /* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * *
  *
  *  BUILDERS
@@ -1927,6 +3006,8 @@ func (u *updaterMock) UpdateGlobalConfig(haproxyConfig haproxy.Config, config *a
 
 func (u *updaterMock) UpdateHostConfig(host *hatypes.Host, mapper *annotations.Mapper) {
 	host.RootRedirect = mapper.Get(ingtypes.HostAppRoot).Value
+	host.Alias.AliasName = mapper.Get(ingtypes.HostServerAlias).Value
+	host.Alias.AliasRegex = mapper.Get(ingtypes.HostServerAliasRegex).Value
 }
 
 func (u *updaterMock) UpdateBackendConfig(backend *hatypes.Backend, mapper *annotations.Mapper) {
@@ -1943,13 +3024,15 @@ type (
 		Client string `yaml:",omitempty"`
 	}
 	tlsMock struct {
-		TLSFilename string `yaml:",omitempty"`
+		TLSFilename     string `yaml:",omitempty"`
+		TLSDualFilename string `yaml:",omitempty"`
 	}
 	hostMock struct {
 		Hostname     string
 		Paths        []pathMock
-		RootRedirect string  `yaml:",omitempty"`
-		TLS          tlsMock `yaml:",omitempty"`
+		RootRedirect string                  `yaml:",omitempty"`
+		TLS          tlsMock                 `yaml:",omitempty"`
+		Alias        hatypes.HostAliasConfig `yaml:",omitempty"`
 	}
 )
 
@@ -1964,7 +3047,8 @@ func convertHost(hafronts ...*hatypes.Host) []hostMock {
 			Hostname:     f.Hostname,
 			Paths:        paths,
 			RootRedirect: f.RootRedirect,
-			TLS:          tlsMock{TLSFilename: f.TLS.TLSFilename},
+			TLS:          tlsMock{TLSFilename: f.TLS.TLSFilename, TLSDualFilename: f.TLS.TLSDualFilename},
+			Alias:        f.Alias,
 		})
 	}
 	return hosts
@@ -2017,3 +3101,49 @@ func convertBackend(habackends ...*hatypes.Backend) []backendMock {
 func (c *testConfig) compareConfigBack(expected string) {
 	c.compareText(_yamlMarshal(convertBackend(c.hconfig.Backends().BuildSortedItems()...)), expected)
 }
+
+type tcpServiceMock struct {
+	Name          string
+	Port          int
+	Endpoints     []endpointMock
+	CheckInterval string   `yaml:",omitempty"`
+	SSLFilename   string   `yaml:",omitempty"`
+	AcceptProxy   bool     `yaml:",omitempty"`
+	SendProxy     string   `yaml:",omitempty"`
+	CAFilename    string   `yaml:",omitempty"`
+	Balance       string   `yaml:",omitempty"`
+	TimeoutClient string   `yaml:",omitempty"`
+	TimeoutServer string   `yaml:",omitempty"`
+	Whitelist     []string `yaml:",omitempty"`
+	MaxConn       int      `yaml:",omitempty"`
+}
+
+func convertTCPService(hatcpbackends ...*hatypes.TCPBackend) []tcpServiceMock {
+	tcpServices := []tcpServiceMock{}
+	for _, b := range hatcpbackends {
+		endpoints := []endpointMock{}
+		for _, e := range b.Endpoints {
+			endpoints = append(endpoints, endpointMock{IP: e.IP, Port: e.Port})
+		}
+		tcpServices = append(tcpServices, tcpServiceMock{
+			Name:          b.Name,
+			Port:          b.Port,
+			Endpoints:     endpoints,
+			CheckInterval: b.CheckInterval,
+			SSLFilename:   b.SSL.Filename,
+			AcceptProxy:   b.ProxyProt.Decode,
+			SendProxy:     b.ProxyProt.EncodeVersion,
+			CAFilename:    b.SSL.CAFilename,
+			Balance:       b.BalanceAlgorithm,
+			TimeoutClient: b.Timeout.Client,
+			TimeoutServer: b.Timeout.Server,
+			Whitelist:     b.Whitelist,
+			MaxConn:       b.MaxConn,
+		})
+	}
+	return tcpServices
+}
+
+func (c *testConfig) compareConfigTCP(expected string) {
+	c.compareText(_yamlMarshal(convertTCPService(c.hconfig.TCPBackends().BuildSortedItems()...)), expected)
+}
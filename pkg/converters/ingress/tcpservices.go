@@ -0,0 +1,415 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	api "k8s.io/api/core/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/annotations"
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	convutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/utils"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
+)
+
+type portRange struct {
+	first, last int
+}
+
+// tcpAllowedPorts maps a namespace to the list of public
+// ports services of that namespace can expose. The `*`
+// namespace applies to all namespaces.
+type tcpAllowedPorts map[string][]portRange
+
+func (a tcpAllowedPorts) allowed(namespace string, port int) bool {
+	for _, ns := range []string{namespace, "*"} {
+		for _, r := range a[ns] {
+			if port >= r.first && port <= r.last {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// syncTCPServices exposes services annotated with tcp-service-port as TCP
+// services. TCP services declared in the tcp-services configmap have
+// precedence, so all the annotated services are parsed again if the
+// configmap changes.
+func (c *converter) syncTCPServices() {
+	cur, new := c.changed.TCPConfigMapCur, c.changed.TCPConfigMapNew
+	if c.needFullSync || (new != nil && !reflect.DeepEqual(cur, new)) {
+		c.syncTCPServicesFull()
+	} else {
+		c.syncTCPServicesPartial()
+	}
+}
+
+func (c *converter) syncTCPServicesFull() {
+	tcpbackends := c.haproxy.TCPBackends()
+	var ports []int
+	for port, backend := range tcpbackends.Items() {
+		if backend.Annotated {
+			ports = append(ports, port)
+		}
+	}
+	c.tracker.DeleteTCPServices(ports)
+	tcpbackends.Remove(ports)
+	svcList, err := c.cache.GetServiceList()
+	if err != nil {
+		c.logger.Error("error reading service list: %v", err)
+		return
+	}
+	c.syncTCPServiceList(svcList)
+}
+
+func (c *converter) syncTCPServicesPartial() {
+	var svcNames, secretNames []string
+	var claimedPorts []int
+	for _, svc := range c.changed.ServicesDel {
+		svcNames = append(svcNames, svc.Namespace+"/"+svc.Name)
+	}
+	svcNew := make([]*api.Service, 0, len(c.changed.ServicesUpd)+len(c.changed.ServicesAdd))
+	svcNew = append(svcNew, c.changed.ServicesUpd...)
+	svcNew = append(svcNew, c.changed.ServicesAdd...)
+	for _, svc := range svcNew {
+		svcNames = append(svcNames, svc.Namespace+"/"+svc.Name)
+		if port, _ := c.readTCPServicePort(svc); port > 0 {
+			claimedPorts = append(claimedPorts, port)
+		}
+	}
+	for _, ep := range c.changed.Endpoints {
+		svcNames = append(svcNames, ep.Namespace+"/"+ep.Name)
+	}
+	for _, secrets := range [][]*api.Secret{c.changed.SecretsDel, c.changed.SecretsUpd, c.changed.SecretsAdd} {
+		for _, secret := range secrets {
+			secretNames = append(secretNames, secret.Namespace+"/"+secret.Name)
+		}
+	}
+	dirtyPorts, dirtySvcNames := c.tracker.GetDirtyTCPServices(claimedPorts, svcNames, secretNames)
+	if len(dirtyPorts) == 0 {
+		return
+	}
+	c.tracker.DeleteTCPServices(dirtyPorts)
+	tcpbackends := c.haproxy.TCPBackends()
+	var ports []int
+	for _, port := range dirtyPorts {
+		// ports owned by the tcp-services configmap are left untouched
+		if backend, found := tcpbackends.Items()[port]; found && backend.Annotated {
+			ports = append(ports, port)
+		}
+	}
+	tcpbackends.Remove(ports)
+	c.logger.InfoV(2, "syncing %d TCP service port(s)", len(dirtyPorts))
+
+	svcMap := make(map[string]*api.Service, len(dirtySvcNames))
+	for _, name := range dirtySvcNames {
+		svcMap[name] = nil
+	}
+	for _, svc := range svcNew {
+		if port, _ := c.readTCPServicePort(svc); port > 0 {
+			svcMap[svc.Namespace+"/"+svc.Name] = svc
+		}
+	}
+	svcList := make([]*api.Service, 0, len(svcMap))
+	for name, svc := range svcMap {
+		if svc == nil {
+			var err error
+			svc, err = c.cache.GetService(name)
+			if err != nil {
+				// deleted services are also tracked
+				continue
+			}
+		}
+		svcList = append(svcList, svc)
+	}
+	c.syncTCPServiceList(svcList)
+}
+
+func (c *converter) syncTCPServiceList(svcList []*api.Service) {
+	// the older service wins a port conflict, this
+	// makes the outcome independent of the sync order
+	sort.Slice(svcList, func(i, j int) bool {
+		svc1 := svcList[i]
+		svc2 := svcList[j]
+		if !svc1.CreationTimestamp.Equal(&svc2.CreationTimestamp) {
+			return svc1.CreationTimestamp.Before(&svc2.CreationTimestamp)
+		}
+		return svc1.Namespace+"/"+svc1.Name < svc2.Namespace+"/"+svc2.Name
+	})
+	allowedPorts := c.readTCPAllowedPorts()
+	configmapPorts := c.readTCPConfigMapPorts()
+	for _, svc := range svcList {
+		c.syncTCPService(svc, allowedPorts, configmapPorts)
+	}
+}
+
+func (c *converter) syncTCPService(svc *api.Service, allowedPorts tcpAllowedPorts, configmapPorts map[int]bool) {
+	publicport, portStr := c.readTCPServicePort(svc)
+	if portStr == "" {
+		return
+	}
+	svcName := svc.Namespace + "/" + svc.Name
+	if publicport == 0 {
+		c.logger.Warn("skipping TCP service of service '%s': invalid public port: %s", svcName, portStr)
+		return
+	}
+	ann := c.readServiceAnnotations(svc, publicport)
+	if ann[ingtypes.SvcTCPServicePort] == "" {
+		// denied by the annotation policy
+		return
+	}
+	// tracked before any validation, so a service that
+	// lost a port conflict can claim it later
+	c.tracker.TrackTCPService(convtypes.ServiceType, svcName, publicport)
+	if !allowedPorts.allowed(svc.Namespace, publicport) {
+		c.logger.Warn("skipping TCP service on public port %d of service '%s': port not allowed on namespace '%s'",
+			publicport, svcName, svc.Namespace)
+		return
+	}
+	if configmapPorts[publicport] {
+		c.logger.Warn("skipping TCP service on public port %d of service '%s': port already declared on the TCP services configmap",
+			publicport, svcName)
+		return
+	}
+	tcpbackends := c.haproxy.TCPBackends()
+	if backend, found := tcpbackends.Items()[publicport]; found {
+		c.logger.Warn("skipping TCP service on public port %d of service '%s': port already in use by '%s'",
+			publicport, svcName, backend.Name)
+		return
+	}
+	var svcport *api.ServicePort
+	if targetPort := ann[ingtypes.SvcTCPServiceTargetPort]; targetPort != "" {
+		svcport = convutils.FindServicePort(svc, targetPort)
+	} else if len(svc.Spec.Ports) > 0 {
+		svcport = &svc.Spec.Ports[0]
+	}
+	if svcport == nil {
+		c.logger.Warn("skipping TCP service on public port %d of service '%s': port not found: '%s'",
+			publicport, svcName, ann[ingtypes.SvcTCPServiceTargetPort])
+		return
+	}
+	addrs, _, err := convutils.CreateEndpoints(c.cache, svc, svcport)
+	if err != nil {
+		c.logger.Warn("skipping TCP service on public port %d of service '%s': %v", publicport, svcName, err)
+		return
+	}
+	var crtfile convtypes.CrtFile
+	if secretName := ann[ingtypes.SvcTCPServiceTLSSecret]; secretName != "" {
+		crtfile, err = c.cache.GetTLSSecretPath(svc.Namespace, secretName, convtypes.TrackingTarget{TCPPort: publicport})
+		if err != nil {
+			c.logger.Warn("skipping TCP service on public port %d of service '%s': %v", publicport, svcName, err)
+			return
+		}
+	}
+	var cafile, crlfile convtypes.File
+	if secretName := ann[ingtypes.SvcTCPServiceCASecret]; secretName != "" {
+		cafile, crlfile, err = c.cache.GetCASecretPath(svc.Namespace, secretName, convtypes.TrackingTarget{TCPPort: publicport})
+		if err != nil {
+			c.logger.Warn("skipping TCP service on public port %d of service '%s': %v", publicport, svcName, err)
+			return
+		}
+	}
+	checkInterval := "2s"
+	if checkInt := ann[ingtypes.SvcTCPServiceCheckInterval]; checkInt != "" {
+		if checkInt == "-" {
+			checkInterval = ""
+		} else if convutils.IsValidTime(checkInt) {
+			checkInterval = checkInt
+		} else {
+			c.logger.Warn("using default check interval '%s' due to an invalid time config on service '%s': %s",
+				checkInterval, svcName, checkInt)
+		}
+	}
+	var acceptProxy bool
+	if value := ann[ingtypes.SvcTCPServiceAcceptProxy]; value != "" {
+		acceptProxy, err = strconv.ParseBool(value)
+		if err != nil {
+			c.logger.Warn("ignoring invalid accept proxy config on service '%s': %s", svcName, value)
+		}
+	}
+	var sendProxy string
+	switch value := strings.ToLower(ann[ingtypes.SvcTCPServiceSendProxy]); value {
+	case "":
+	case "v1", "v2":
+		sendProxy = value
+	default:
+		c.logger.Warn("ignoring invalid send proxy version on service '%s': %s", svcName, value)
+	}
+	balance := ann[ingtypes.SvcTCPServiceBalanceAlgorithm]
+	if balance != "" && !convutils.IsValidTCPBalance(balance) {
+		c.logger.Warn("ignoring invalid balance algorithm on service '%s': %s", svcName, balance)
+		balance = ""
+	}
+	timeoutClient := c.readTCPServiceTime(svcName, "client timeout", ann[ingtypes.SvcTCPServiceTimeoutClient])
+	timeoutServer := c.readTCPServiceTime(svcName, "server timeout", ann[ingtypes.SvcTCPServiceTimeoutServer])
+	var maxconn int
+	if value := ann[ingtypes.SvcTCPServiceMaxconn]; value != "" {
+		maxconn, err = strconv.Atoi(value)
+		if err != nil || maxconn < 0 {
+			c.logger.Warn("ignoring invalid maxconn on service '%s': %s", svcName, value)
+			maxconn = 0
+		}
+	}
+	var whitelist []string
+	for _, cidr := range utils.Split(ann[ingtypes.SvcTCPServiceWhitelistSourceRange], ",") {
+		if cidr == "" {
+			continue
+		}
+		if net.ParseIP(cidr) == nil {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				c.logger.Warn("skipping invalid IP or cidr on service '%s': %s", svcName, cidr)
+				continue
+			}
+		}
+		whitelist = append(whitelist, cidr)
+	}
+	backend := tcpbackends.Acquire(fmt.Sprintf("%s_%s", svc.Namespace, svc.Name), publicport)
+	backend.Annotated = true
+	for _, addr := range addrs {
+		backend.AddEndpoint(addr.IP, addr.Port)
+	}
+	backend.CheckInterval = checkInterval
+	backend.ProxyProt.Decode = acceptProxy
+	backend.ProxyProt.EncodeVersion = sendProxy
+	backend.SSL.Filename = crtfile.Filename
+	backend.SSL.CAFilename = cafile.Filename
+	backend.SSL.CRLFilename = crlfile.Filename
+	backend.Dynamic = c.haproxy.Global().DynamicTCP
+	backend.BalanceAlgorithm = balance
+	backend.Timeout.Client = timeoutClient
+	backend.Timeout.Server = timeoutServer
+	backend.Whitelist = whitelist
+	backend.MaxConn = maxconn
+}
+
+func (c *converter) readTCPServiceTime(svcName, name, value string) string {
+	if value == "" || convutils.IsValidTime(value) {
+		return value
+	}
+	c.logger.Warn("ignoring invalid %s config on service '%s': %s", name, svcName, value)
+	return ""
+}
+
+// readTCPServicePort reads the public port of an annotated service.
+// port is zero if the annotation is missing or invalid, portStr is
+// empty if the annotation is missing.
+func (c *converter) readTCPServicePort(svc *api.Service) (port int, portStr string) {
+	portStr = svc.Annotations[c.options.AnnotationPrefix+"/"+ingtypes.SvcTCPServicePort]
+	if portStr == "" {
+		return 0, ""
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, portStr
+	}
+	return port, portStr
+}
+
+// readServiceAnnotations reads the annotations of a TCP service into a
+// Mapper, which applies the annotation policy and the validators of the
+// keys. A TCP service doesn't have hostnames, so its public port is used
+// in the link of the annotations.
+func (c *converter) readServiceAnnotations(svc *api.Service, publicport int) map[string]string {
+	source := &annotations.Source{
+		Namespace: svc.Namespace,
+		Name:      svc.Name,
+		Type:      "service",
+	}
+	_, annBack := c.readAnnotations(source, svc.Annotations)
+	mapper := c.mapBuilder.NewMapper()
+	mapper.AddAnnotations(source, hatypes.CreateTCPServiceLink(publicport), annBack)
+	ann := map[string]string{}
+	for _, key := range []string{
+		ingtypes.SvcTCPServiceAcceptProxy,
+		ingtypes.SvcTCPServiceBalanceAlgorithm,
+		ingtypes.SvcTCPServiceCASecret,
+		ingtypes.SvcTCPServiceCheckInterval,
+		ingtypes.SvcTCPServiceMaxconn,
+		ingtypes.SvcTCPServicePort,
+		ingtypes.SvcTCPServiceSendProxy,
+		ingtypes.SvcTCPServiceTargetPort,
+		ingtypes.SvcTCPServiceTimeoutClient,
+		ingtypes.SvcTCPServiceTimeoutServer,
+		ingtypes.SvcTCPServiceTLSSecret,
+		ingtypes.SvcTCPServiceWhitelistSourceRange,
+	} {
+		ann[key] = mapper.Get(key).Value
+	}
+	return ann
+}
+
+func (c *converter) readTCPAllowedPorts() tcpAllowedPorts {
+	allowedPorts := tcpAllowedPorts{}
+	config := c.globalConfig.Get(ingtypes.GlobalTCPServiceAllowedPorts).Value
+	for _, line := range utils.LineToSlice(config) {
+		if line == "" {
+			continue
+		}
+		nsPorts := strings.Split(line, "=")
+		if len(nsPorts) != 2 || nsPorts[0] == "" {
+			c.logger.Warn("ignoring misconfigured TCP service allowed ports: %s", line)
+			continue
+		}
+		ns := strings.TrimSpace(nsPorts[0])
+		for _, ports := range strings.Split(nsPorts[1], ",") {
+			ports = strings.TrimSpace(ports)
+			if ports == "" {
+				continue
+			}
+			var first, last int
+			var err error
+			if i := strings.Index(ports, "-"); i >= 0 {
+				first, err = strconv.Atoi(ports[:i])
+				if err == nil {
+					last, err = strconv.Atoi(ports[i+1:])
+				}
+			} else {
+				first, err = strconv.Atoi(ports)
+				last = first
+			}
+			if err != nil || first > last {
+				c.logger.Warn("ignoring invalid port or port range of TCP service allowed ports on namespace '%s': %s", ns, ports)
+				continue
+			}
+			allowedPorts[ns] = append(allowedPorts[ns], portRange{first: first, last: last})
+		}
+	}
+	return allowedPorts
+}
+
+func (c *converter) readTCPConfigMapPorts() map[int]bool {
+	tcpConfigMap := c.changed.TCPConfigMapCur
+	if c.changed.TCPConfigMapNew != nil {
+		tcpConfigMap = c.changed.TCPConfigMapNew
+	}
+	ports := make(map[int]bool, len(tcpConfigMap))
+	for key := range tcpConfigMap {
+		if port, err := strconv.Atoi(key); err == nil {
+			ports[port] = true
+		}
+	}
+	return ports
+}
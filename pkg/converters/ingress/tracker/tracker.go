@@ -34,6 +34,8 @@ type (
 	stringStringMap  map[string]map[string]empty
 	stringBackendMap map[string]map[hatypes.BackendID]empty
 	backendStringMap map[hatypes.BackendID]map[string]empty
+	stringIntMap     map[string]map[int]empty
+	intStringMap     map[int]map[string]empty
 	//
 	empty struct{}
 )
@@ -59,6 +61,16 @@ type tracker struct {
 	// pod
 	podBackend stringBackendMap
 	backendPod backendStringMap
+	// namespace
+	namespaceHostname stringStringMap
+	hostnameNamespace stringStringMap
+	namespaceBackend  stringBackendMap
+	backendNamespace  backendStringMap
+	// tcp services
+	serviceTCPPort stringIntMap
+	tcpPortService intStringMap
+	secretTCPPort  stringIntMap
+	tcpPortSecret  intStringMap
 	// service (missing)
 	serviceHostnameMissing stringStringMap
 	hostnameServiceMissing stringStringMap
@@ -89,10 +101,15 @@ func (t *tracker) Track(isMissing bool, track convtypes.TrackingTarget, rtype co
 			t.TrackUserlist(rtype, name, track.Userlist)
 		}
 	}
+	if track.TCPPort > 0 {
+		// missing and found resources share the same tracking,
+		// any change on them should rebuild the TCP service
+		t.TrackTCPService(rtype, name, track.TCPPort)
+	}
 }
 
 func (t *tracker) TrackHostname(rtype convtypes.ResourceType, name, hostname string) {
-	validName(name)
+	validResourceName(rtype, name)
 	switch rtype {
 	case convtypes.IngressType:
 		addStringTracking(&t.ingressHostname, name, hostname)
@@ -103,13 +120,16 @@ func (t *tracker) TrackHostname(rtype convtypes.ResourceType, name, hostname str
 	case convtypes.SecretType:
 		addStringTracking(&t.secretHostname, name, hostname)
 		addStringTracking(&t.hostnameSecret, hostname, name)
+	case convtypes.NamespaceType:
+		addStringTracking(&t.namespaceHostname, name, hostname)
+		addStringTracking(&t.hostnameNamespace, hostname, name)
 	default:
 		panic(fmt.Errorf("unsupported resource type %d", rtype))
 	}
 }
 
 func (t *tracker) TrackBackend(rtype convtypes.ResourceType, name string, backendID hatypes.BackendID) {
-	validName(name)
+	validResourceName(rtype, name)
 	switch rtype {
 	case convtypes.IngressType:
 		addStringBackendTracking(&t.ingressBackend, name, backendID)
@@ -120,6 +140,9 @@ func (t *tracker) TrackBackend(rtype convtypes.ResourceType, name string, backen
 	case convtypes.PodType:
 		addStringBackendTracking(&t.podBackend, name, backendID)
 		addBackendStringTracking(&t.backendPod, backendID, name)
+	case convtypes.NamespaceType:
+		addStringBackendTracking(&t.namespaceBackend, name, backendID)
+		addBackendStringTracking(&t.backendNamespace, backendID, name)
 	default:
 		panic(fmt.Errorf("unsupported resource type %d", rtype))
 	}
@@ -147,6 +170,20 @@ func (t *tracker) TrackStorage(rtype convtypes.ResourceType, name, storage strin
 	}
 }
 
+func (t *tracker) TrackTCPService(rtype convtypes.ResourceType, name string, port int) {
+	validName(name)
+	switch rtype {
+	case convtypes.ServiceType:
+		addStringIntTracking(&t.serviceTCPPort, name, port)
+		addIntStringTracking(&t.tcpPortService, port, name)
+	case convtypes.SecretType:
+		addStringIntTracking(&t.secretTCPPort, name, port)
+		addIntStringTracking(&t.tcpPortSecret, port, name)
+	default:
+		panic(fmt.Errorf("unsupported resource type %d", rtype))
+	}
+}
+
 func (t *tracker) TrackMissingOnHostname(rtype convtypes.ResourceType, name, hostname string) {
 	validName(name)
 	switch rtype {
@@ -178,6 +215,18 @@ func validName(name string) {
 	}
 }
 
+// validResourceName validates the name of namespaced resources,
+// and also accepts namespace names, which are cluster scoped.
+func validResourceName(rtype convtypes.ResourceType, name string) {
+	if rtype == convtypes.NamespaceType {
+		if name == "" || strings.Contains(name, "/") {
+			panic(fmt.Errorf("invalid namespace name: %s", name))
+		}
+		return
+	}
+	validName(name)
+}
+
 // GetDirtyLinks lists all hostnames and backendIDs that a
 // list of ingress touches directly or indirectly:
 //
@@ -191,6 +240,7 @@ func (t *tracker) GetDirtyLinks(
 	oldServiceList, addServiceList []string,
 	oldSecretList, addSecretList []string,
 	addPodList []string,
+	oldNamespaceList []string,
 ) (dirtyIngs, dirtyHosts []string, dirtyBacks []hatypes.BackendID, dirtyUsers, dirtyStorages []string) {
 	ingsMap := make(map[string]empty)
 	hostsMap := make(map[string]empty)
@@ -286,6 +336,21 @@ func (t *tracker) GetDirtyLinks(
 			}
 		}
 	}
+	//
+	for _, nsName := range oldNamespaceList {
+		for _, hostname := range t.getHostnamesByNamespace(nsName) {
+			if _, found := hostsMap[hostname]; !found {
+				hostsMap[hostname] = empty{}
+				build(t.getIngressByHostname(hostname))
+			}
+		}
+		for _, backend := range t.getBackendsByNamespace(nsName) {
+			if _, found := backsMap[backend]; !found {
+				backsMap[backend] = empty{}
+				build(t.getIngressByBackend(backend))
+			}
+		}
+	}
 
 	// convert hostsMap and backsMap to slices
 	if len(ingsMap) > 0 {
@@ -328,6 +393,48 @@ func (t *tracker) GetDirtyLinks(
 	return dirtyIngs, dirtyHosts, dirtyBacks, dirtyUsers, dirtyStorages
 }
 
+// GetDirtyTCPServices lists all public ports of TCP services that should
+// be rebuilt due to a change on the listed ports, services or secrets, as
+// well as all the services that claims such ports, including the ones
+// that lost a port conflict.
+func (t *tracker) GetDirtyTCPServices(ports []int, serviceList, secretList []string) (dirtyPorts []int, dirtyServices []string) {
+	portsMap := make(map[int]empty)
+	for _, port := range ports {
+		portsMap[port] = empty{}
+	}
+	for _, svcName := range serviceList {
+		for _, port := range t.getTCPPortsByService(svcName) {
+			portsMap[port] = empty{}
+		}
+	}
+	for _, secretName := range secretList {
+		for _, port := range t.getTCPPortsBySecret(secretName) {
+			portsMap[port] = empty{}
+		}
+	}
+	servicesMap := make(map[string]empty)
+	for port := range portsMap {
+		for _, svcName := range t.getServicesByTCPPort(port) {
+			servicesMap[svcName] = empty{}
+		}
+	}
+	if len(portsMap) > 0 {
+		dirtyPorts = make([]int, 0, len(portsMap))
+		for port := range portsMap {
+			dirtyPorts = append(dirtyPorts, port)
+		}
+		sort.Ints(dirtyPorts)
+	}
+	if len(servicesMap) > 0 {
+		dirtyServices = make([]string, 0, len(servicesMap))
+		for svcName := range servicesMap {
+			dirtyServices = append(dirtyServices, svcName)
+		}
+		sort.Strings(dirtyServices)
+	}
+	return dirtyPorts, dirtyServices
+}
+
 func (t *tracker) DeleteHostnames(hostnames []string) {
 	for _, hostname := range hostnames {
 		for ing := range t.hostnameIngress[hostname] {
@@ -350,6 +457,10 @@ func (t *tracker) DeleteHostnames(hostnames []string) {
 			deleteStringTracking(&t.secretHostnameMissing, secret, hostname)
 		}
 		deleteStringMapKey(&t.hostnameSecretMissing, hostname)
+		for namespace := range t.hostnameNamespace[hostname] {
+			deleteStringTracking(&t.namespaceHostname, namespace, hostname)
+		}
+		deleteStringMapKey(&t.hostnameNamespace, hostname)
 	}
 }
 
@@ -371,6 +482,10 @@ func (t *tracker) DeleteBackends(backends []hatypes.BackendID) {
 			deleteStringBackendTracking(&t.podBackend, pod, backend)
 		}
 		deleteBackendStringMapKey(&t.backendPod, backend)
+		for namespace := range t.backendNamespace[backend] {
+			deleteStringBackendTracking(&t.namespaceBackend, namespace, backend)
+		}
+		deleteBackendStringMapKey(&t.backendNamespace, backend)
 	}
 }
 
@@ -392,6 +507,19 @@ func (t *tracker) DeleteStorages(storages []string) {
 	}
 }
 
+func (t *tracker) DeleteTCPServices(ports []int) {
+	for _, port := range ports {
+		for service := range t.tcpPortService[port] {
+			deleteStringIntTracking(&t.serviceTCPPort, service, port)
+		}
+		deleteIntStringMapKey(&t.tcpPortService, port)
+		for secret := range t.tcpPortSecret[port] {
+			deleteStringIntTracking(&t.secretTCPPort, secret, port)
+		}
+		deleteIntStringMapKey(&t.tcpPortSecret, port)
+	}
+}
+
 func (t *tracker) getIngressByHostname(hostname string) []string {
 	if t.hostnameIngress == nil {
 		return nil
@@ -462,6 +590,20 @@ func (t *tracker) getHostnamesBySecretMissing(secretName string) []string {
 	return getStringTracking(t.secretHostnameMissing[secretName])
 }
 
+func (t *tracker) getHostnamesByNamespace(nsName string) []string {
+	if t.namespaceHostname == nil {
+		return nil
+	}
+	return getStringTracking(t.namespaceHostname[nsName])
+}
+
+func (t *tracker) getBackendsByNamespace(nsName string) []hatypes.BackendID {
+	if t.namespaceBackend == nil {
+		return nil
+	}
+	return getBackendTracking(t.namespaceBackend[nsName])
+}
+
 func (t *tracker) getBackendsBySecret(secretName string) []hatypes.BackendID {
 	if t.secretBackend == nil {
 		return nil
@@ -490,6 +632,27 @@ func (t *tracker) getBackendsByPod(podName string) []hatypes.BackendID {
 	return getBackendTracking(t.podBackend[podName])
 }
 
+func (t *tracker) getTCPPortsByService(serviceName string) []int {
+	if t.serviceTCPPort == nil {
+		return nil
+	}
+	return getIntTracking(t.serviceTCPPort[serviceName])
+}
+
+func (t *tracker) getTCPPortsBySecret(secretName string) []int {
+	if t.secretTCPPort == nil {
+		return nil
+	}
+	return getIntTracking(t.secretTCPPort[secretName])
+}
+
+func (t *tracker) getServicesByTCPPort(port int) []string {
+	if t.tcpPortService == nil {
+		return nil
+	}
+	return getStringTracking(t.tcpPortService[port])
+}
+
 func addStringTracking(trackingRef *stringStringMap, key, value string) {
 	if *trackingRef == nil {
 		*trackingRef = stringStringMap{}
@@ -529,6 +692,32 @@ func addStringBackendTracking(trackingRef *stringBackendMap, key string, value h
 	trackingMap[value] = empty{}
 }
 
+func addStringIntTracking(trackingRef *stringIntMap, key string, value int) {
+	if *trackingRef == nil {
+		*trackingRef = stringIntMap{}
+	}
+	tracking := *trackingRef
+	trackingMap, found := tracking[key]
+	if !found {
+		trackingMap = map[int]empty{}
+		tracking[key] = trackingMap
+	}
+	trackingMap[value] = empty{}
+}
+
+func addIntStringTracking(trackingRef *intStringMap, key int, value string) {
+	if *trackingRef == nil {
+		*trackingRef = intStringMap{}
+	}
+	tracking := *trackingRef
+	trackingMap, found := tracking[key]
+	if !found {
+		trackingMap = map[string]empty{}
+		tracking[key] = trackingMap
+	}
+	trackingMap[value] = empty{}
+}
+
 func getStringTracking(tracking map[string]empty) []string {
 	stringList := make([]string, 0, len(tracking))
 	for value := range tracking {
@@ -545,6 +734,14 @@ func getBackendTracking(tracking map[hatypes.BackendID]empty) []hatypes.BackendI
 	return backendList
 }
 
+func getIntTracking(tracking map[int]empty) []int {
+	intList := make([]int, 0, len(tracking))
+	for value := range tracking {
+		intList = append(intList, value)
+	}
+	return intList
+}
+
 func deleteStringTracking(trackingRef *stringStringMap, key, value string) {
 	if *trackingRef == nil {
 		return
@@ -575,6 +772,21 @@ func deleteStringBackendTracking(trackingRef *stringBackendMap, key string, valu
 	}
 }
 
+func deleteStringIntTracking(trackingRef *stringIntMap, key string, value int) {
+	if *trackingRef == nil {
+		return
+	}
+	tracking := *trackingRef
+	trackingMap := tracking[key]
+	delete(trackingMap, value)
+	if len(trackingMap) == 0 {
+		delete(tracking, key)
+	}
+	if len(tracking) == 0 {
+		*trackingRef = nil
+	}
+}
+
 func deleteStringMapKey(stringMap *stringStringMap, key string) {
 	delete(*stringMap, key)
 	if len(*stringMap) == 0 {
@@ -588,3 +800,10 @@ func deleteBackendStringMapKey(backendMap *backendStringMap, key hatypes.Backend
 		*backendMap = nil
 	}
 }
+
+func deleteIntStringMapKey(intMap *intStringMap, key int) {
+	delete(*intMap, key)
+	if len(*intMap) == 0 {
+		*intMap = nil
+	}
+}
@@ -49,6 +49,12 @@ type storageTracking struct {
 	storage string
 }
 
+type tcpTracking struct {
+	rtype convtypes.ResourceType
+	name  string
+	port  int
+}
+
 var (
 	back1a = hatypes.BackendID{
 		Namespace: "default",
@@ -89,6 +95,7 @@ func TestGetDirtyLinks(t *testing.T) {
 		oldSecretList  []string
 		addSecretList  []string
 		addPodList     []string
+		oldNsList      []string
 		//
 		expDirtyIngs     []string
 		expDirtyHosts    []string
@@ -280,6 +287,25 @@ func TestGetDirtyLinks(t *testing.T) {
 			expDirtyIngs:     []string{"default/ing2"},
 			expDirtyStorages: []string{"crt2", "crt3"},
 		},
+		// 19
+		{
+			trackedHosts: []hostTracking{
+				{convtypes.IngressType, "ns1/ing1", "domain1.local"},
+				{convtypes.NamespaceType, "ns1", "domain1.local"},
+				{convtypes.IngressType, "ns2/ing2", "domain2.local"},
+				{convtypes.NamespaceType, "ns2", "domain2.local"},
+			},
+			trackedBacks: []backTracking{
+				{convtypes.IngressType, "ns1/ing1", back1a},
+				{convtypes.NamespaceType, "ns1", back1a},
+				{convtypes.IngressType, "ns2/ing2", back2a},
+				{convtypes.NamespaceType, "ns2", back2a},
+			},
+			oldNsList:     []string{"ns1"},
+			expDirtyIngs:  []string{"ns1/ing1"},
+			expDirtyHosts: []string{"domain1.local"},
+			expDirtyBacks: []hatypes.BackendID{back1b},
+		},
 	}
 	for i, test := range testCases {
 		c := setup(t)
@@ -310,6 +336,7 @@ func TestGetDirtyLinks(t *testing.T) {
 				test.oldSecretList,
 				test.addSecretList,
 				test.addPodList,
+				test.oldNsList,
 			)
 		sort.Strings(dirtyIngs)
 		sort.Strings(dirtyHosts)
@@ -685,6 +712,149 @@ func TestDeleteStorages(t *testing.T) {
 	}
 }
 
+func TestGetDirtyTCPServices(t *testing.T) {
+	testCases := []struct {
+		trackedTCP []tcpTracking
+		//
+		ports       []int
+		serviceList []string
+		secretList  []string
+		//
+		expDirtyPorts    []int
+		expDirtyServices []string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			ports:         []int{6379},
+			expDirtyPorts: []int{6379},
+		},
+		// 2
+		{
+			trackedTCP: []tcpTracking{
+				{convtypes.ServiceType, "default/svc1", 6379},
+			},
+			serviceList: []string{"default/svc2"},
+		},
+		// 3
+		{
+			trackedTCP: []tcpTracking{
+				{convtypes.ServiceType, "default/svc1", 6379},
+				{convtypes.ServiceType, "default/svc2", 6380},
+			},
+			serviceList:      []string{"default/svc1"},
+			expDirtyPorts:    []int{6379},
+			expDirtyServices: []string{"default/svc1"},
+		},
+		// 4
+		{
+			trackedTCP: []tcpTracking{
+				{convtypes.ServiceType, "default/svc1", 6379},
+				{convtypes.ServiceType, "other/svc1", 6379},
+			},
+			serviceList:      []string{"default/svc1"},
+			expDirtyPorts:    []int{6379},
+			expDirtyServices: []string{"default/svc1", "other/svc1"},
+		},
+		// 5
+		{
+			trackedTCP: []tcpTracking{
+				{convtypes.ServiceType, "default/svc1", 6379},
+				{convtypes.SecretType, "default/tls1", 6379},
+				{convtypes.ServiceType, "default/svc2", 6380},
+			},
+			secretList:       []string{"default/tls1"},
+			expDirtyPorts:    []int{6379},
+			expDirtyServices: []string{"default/svc1"},
+		},
+		// 6
+		{
+			trackedTCP: []tcpTracking{
+				{convtypes.ServiceType, "default/svc1", 6379},
+				{convtypes.ServiceType, "default/svc2", 6380},
+			},
+			ports:            []int{6380},
+			serviceList:      []string{"default/svc1"},
+			expDirtyPorts:    []int{6379, 6380},
+			expDirtyServices: []string{"default/svc1", "default/svc2"},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		for _, trackedTCP := range test.trackedTCP {
+			c.tracker.TrackTCPService(trackedTCP.rtype, trackedTCP.name, trackedTCP.port)
+		}
+		dirtyPorts, dirtyServices := c.tracker.GetDirtyTCPServices(test.ports, test.serviceList, test.secretList)
+		c.compareObjects("dirty ports", i, dirtyPorts, test.expDirtyPorts)
+		c.compareObjects("dirty services", i, dirtyServices, test.expDirtyServices)
+		c.teardown()
+	}
+}
+
+func TestDeleteTCPServices(t *testing.T) {
+	testCases := []struct {
+		trackedTCP []tcpTracking
+		//
+		deletePorts []int
+		//
+		expServiceTCPPort stringIntMap
+		expTCPPortService intStringMap
+		expSecretTCPPort  stringIntMap
+		expTCPPortSecret  intStringMap
+	}{
+		// 0
+		{},
+		// 1
+		{
+			deletePorts: []int{6379},
+		},
+		// 2
+		{
+			trackedTCP: []tcpTracking{
+				{convtypes.ServiceType, "default/svc1", 6379},
+				{convtypes.SecretType, "default/tls1", 6379},
+			},
+			deletePorts:       []int{6380},
+			expServiceTCPPort: stringIntMap{"default/svc1": {6379: empty{}}},
+			expTCPPortService: intStringMap{6379: {"default/svc1": empty{}}},
+			expSecretTCPPort:  stringIntMap{"default/tls1": {6379: empty{}}},
+			expTCPPortSecret:  intStringMap{6379: {"default/tls1": empty{}}},
+		},
+		// 3
+		{
+			trackedTCP: []tcpTracking{
+				{convtypes.ServiceType, "default/svc1", 6379},
+				{convtypes.SecretType, "default/tls1", 6379},
+			},
+			deletePorts: []int{6379},
+		},
+		// 4
+		{
+			trackedTCP: []tcpTracking{
+				{convtypes.ServiceType, "default/svc1", 6379},
+				{convtypes.ServiceType, "default/svc1", 6380},
+				{convtypes.SecretType, "default/tls1", 6380},
+			},
+			deletePorts:       []int{6380},
+			expServiceTCPPort: stringIntMap{"default/svc1": {6379: empty{}}},
+			expTCPPortService: intStringMap{6379: {"default/svc1": empty{}}},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		for _, trackedTCP := range test.trackedTCP {
+			c.tracker.TrackTCPService(trackedTCP.rtype, trackedTCP.name, trackedTCP.port)
+		}
+		c.tracker.DeleteTCPServices(test.deletePorts)
+		c.compareObjects("serviceTCPPort", i, c.tracker.serviceTCPPort, test.expServiceTCPPort)
+		c.compareObjects("tcpPortService", i, c.tracker.tcpPortService, test.expTCPPortService)
+		c.compareObjects("secretTCPPort", i, c.tracker.secretTCPPort, test.expSecretTCPPort)
+		c.compareObjects("tcpPortSecret", i, c.tracker.tcpPortSecret, test.expTCPPortSecret)
+		c.teardown()
+	}
+}
+
 type testConfig struct {
 	t       *testing.T
 	tracker *tracker
@@ -18,17 +18,26 @@ package types
 
 // Host Annotations
 const (
+	HostAcmeChallenge          = "acme-challenge"
+	HostAcmeDNSProvider        = "acme-dns-provider"
+	HostAcmeDualKeyType        = "acme-dual-key-type"
+	HostAcmeKeyType            = "acme-key-type"
 	HostAppRoot                = "app-root"
+	HostAuthTLSCRLRefresh      = "auth-tls-crl-refresh"
 	HostAuthTLSErrorPage       = "auth-tls-error-page"
+	HostAuthTLSOCSP            = "auth-tls-ocsp"
+	HostAuthTLSOCSPFailOpen    = "auth-tls-ocsp-fail-open"
 	HostAuthTLSSecret          = "auth-tls-secret"
 	HostAuthTLSStrict          = "auth-tls-strict"
 	HostAuthTLSVerifyClient    = "auth-tls-verify-client"
 	HostCertSigner             = "cert-signer"
+	HostHostnameDelegation     = "hostname-delegation"
 	HostPathType               = "path-type"
 	HostServerAlias            = "server-alias"
 	HostServerAliasRegex       = "server-alias-regex"
 	HostSSLCiphers             = "ssl-ciphers"
 	HostSSLCipherSuites        = "ssl-cipher-suites"
+	HostSSLOCSPStapling        = "ssl-ocsp-stapling"
 	HostSSLOptionsHost         = "ssl-options-host"
 	HostSSLPassthrough         = "ssl-passthrough"
 	HostSSLPassthroughHTTPPort = "ssl-passthrough-http-port"
@@ -39,17 +48,26 @@ const (
 var (
 	// AnnHost ...
 	AnnHost = map[string]struct{}{
+		HostAcmeChallenge:          {},
+		HostAcmeDNSProvider:        {},
+		HostAcmeDualKeyType:        {},
+		HostAcmeKeyType:            {},
 		HostAppRoot:                {},
+		HostAuthTLSCRLRefresh:      {},
 		HostAuthTLSErrorPage:       {},
+		HostAuthTLSOCSP:            {},
+		HostAuthTLSOCSPFailOpen:    {},
 		HostAuthTLSSecret:          {},
 		HostAuthTLSStrict:          {},
 		HostAuthTLSVerifyClient:    {},
 		HostCertSigner:             {},
+		HostHostnameDelegation:     {},
 		HostServerAlias:            {},
 		HostPathType:               {},
 		HostServerAliasRegex:       {},
 		HostSSLCiphers:             {},
 		HostSSLCipherSuites:        {},
+		HostSSLOCSPStapling:        {},
 		HostSSLOptionsHost:         {},
 		HostSSLPassthrough:         {},
 		HostSSLPassthroughHTTPPort: {},
@@ -91,6 +109,8 @@ const (
 	BackHeaders                = "headers"
 	BackHealthCheckAddr        = "health-check-addr"
 	BackHealthCheckFallCount   = "health-check-fall-count"
+	BackHealthCheckGRPC        = "health-check-grpc"
+	BackHealthCheckGRPCService = "health-check-grpc-service"
 	BackHealthCheckInterval    = "health-check-interval"
 	BackHealthCheckPort        = "health-check-port"
 	BackHealthCheckRiseCount   = "health-check-rise-count"
@@ -112,9 +132,12 @@ const (
 	BackProxyProtocol          = "proxy-protocol"
 	BackRewriteTarget          = "rewrite-target"
 	BackSlotsMinFree           = "slots-min-free"
+	BackSecureALPN             = "secure-alpn"
 	BackSecureBackends         = "secure-backends"
 	BackSecureCrtSecret        = "secure-crt-secret"
+	BackSecureSNI              = "secure-sni"
 	BackSecureVerifyCASecret   = "secure-verify-ca-secret"
+	BackSecureVerifyHostname   = "secure-verify-hostname"
 	BackServiceUpstream        = "service-upstream"
 	BackSessionCookieDynamic   = "session-cookie-dynamic"
 	BackSessionCookieKeywords  = "session-cookie-keywords"
@@ -139,6 +162,110 @@ const (
 	BackWhitelistSourceRange   = "whitelist-source-range"
 )
 
+var (
+	// AnnBack ...
+	AnnBack = map[string]struct{}{
+		BackAffinity:               {},
+		BackAgentCheckAddr:         {},
+		BackAgentCheckInterval:     {},
+		BackAgentCheckPort:         {},
+		BackAgentCheckSend:         {},
+		BackAuthRealm:              {},
+		BackAuthSecret:             {},
+		BackAuthTLSCertHeader:      {},
+		BackAuthType:               {},
+		BackBackendCheckInterval:   {},
+		BackBackendProtocol:        {},
+		BackBackendServerNaming:    {},
+		BackBackendServerSlotsInc:  {},
+		BackBalanceAlgorithm:       {},
+		BackBlueGreenBalance:       {},
+		BackBlueGreenCookie:        {},
+		BackBlueGreenDeploy:        {},
+		BackBlueGreenHeader:        {},
+		BackBlueGreenMode:          {},
+		BackConfigBackend:          {},
+		BackCorsAllowCredentials:   {},
+		BackCorsAllowHeaders:       {},
+		BackCorsAllowMethods:       {},
+		BackCorsAllowOrigin:        {},
+		BackCorsEnable:             {},
+		BackCorsExposeHeaders:      {},
+		BackCorsMaxAge:             {},
+		BackDynamicScaling:         {},
+		BackHeaders:                {},
+		BackHealthCheckAddr:        {},
+		BackHealthCheckFallCount:   {},
+		BackHealthCheckGRPC:        {},
+		BackHealthCheckGRPCService: {},
+		BackHealthCheckInterval:    {},
+		BackHealthCheckPort:        {},
+		BackHealthCheckRiseCount:   {},
+		BackHealthCheckURI:         {},
+		BackHSTS:                   {},
+		BackHSTSIncludeSubdomains:  {},
+		BackHSTSMaxAge:             {},
+		BackHSTSPreload:            {},
+		BackInitialWeight:          {},
+		BackLimitConnections:       {},
+		BackLimitRPS:               {},
+		BackLimitWhitelist:         {},
+		BackMaxconnServer:          {},
+		BackMaxQueueServer:         {},
+		BackOAuth:                  {},
+		BackOAuthHeaders:           {},
+		BackOAuthURIPrefix:         {},
+		BackProxyBodySize:          {},
+		BackProxyProtocol:          {},
+		BackRewriteTarget:          {},
+		BackSlotsMinFree:           {},
+		BackSecureALPN:             {},
+		BackSecureBackends:         {},
+		BackSecureCrtSecret:        {},
+		BackSecureSNI:              {},
+		BackSecureVerifyCASecret:   {},
+		BackSecureVerifyHostname:   {},
+		BackServiceUpstream:        {},
+		BackSessionCookieDynamic:   {},
+		BackSessionCookieKeywords:  {},
+		BackSessionCookieName:      {},
+		BackSessionCookieShared:    {},
+		BackSessionCookieStrategy:  {},
+		BackSSLCipherSuitesBackend: {},
+		BackSSLCiphersBackend:      {},
+		BackSSLFingerprintLower:    {},
+		BackSSLOptionsBackend:      {},
+		BackSSLRedirect:            {},
+		BackTimeoutConnect:         {},
+		BackTimeoutHTTPRequest:     {},
+		BackTimeoutKeepAlive:       {},
+		BackTimeoutQueue:           {},
+		BackTimeoutServer:          {},
+		BackTimeoutServerFin:       {},
+		BackTimeoutTunnel:          {},
+		BackUseResolver:            {},
+		BackWAF:                    {},
+		BackWAFMode:                {},
+		BackWhitelistSourceRange:   {},
+	}
+)
+
+// Service Annotations
+const (
+	SvcTCPServiceAcceptProxy          = "tcp-service-accept-proxy"
+	SvcTCPServiceBalanceAlgorithm     = "tcp-service-balance-algorithm"
+	SvcTCPServiceCASecret             = "tcp-service-ca-secret"
+	SvcTCPServiceCheckInterval        = "tcp-service-check-interval"
+	SvcTCPServiceMaxconn              = "tcp-service-maxconn"
+	SvcTCPServicePort                 = "tcp-service-port"
+	SvcTCPServiceSendProxy            = "tcp-service-send-proxy"
+	SvcTCPServiceTargetPort           = "tcp-service-target-port"
+	SvcTCPServiceTimeoutClient        = "tcp-service-timeout-client"
+	SvcTCPServiceTimeoutServer        = "tcp-service-timeout-server"
+	SvcTCPServiceTLSSecret            = "tcp-service-tls-secret"
+	SvcTCPServiceWhitelistSourceRange = "tcp-service-whitelist-source-range"
+)
+
 // Extra Annotations
 const (
 	ExtraTLSAcme = "kubernetes.io/tls-acme"
@@ -18,11 +18,18 @@ package types
 
 // Global config
 const (
+	GlobalAcmeCASecret                 = "acme-ca-secret"
+	GlobalAcmeEABSecret                = "acme-eab-secret"
 	GlobalAcmeEmails                   = "acme-emails"
 	GlobalAcmeEndpoint                 = "acme-endpoint"
 	GlobalAcmeExpiring                 = "acme-expiring"
+	GlobalAcmeExpiringRatio            = "acme-expiring-ratio"
+	GlobalAcmeOrphanActions            = "acme-orphan-actions"
+	GlobalAcmeOrphanGracePeriod        = "acme-orphan-grace-period"
+	GlobalAcmePreferredChain           = "acme-preferred-chain"
 	GlobalAcmeShared                   = "acme-shared"
 	GlobalAcmeTermsAgreed              = "acme-terms-agreed"
+	GlobalAnnotationPolicy             = "annotation-policy"
 	GlobalBindFrontingProxy            = "bind-fronting-proxy"
 	GlobalBindHTTP                     = "bind-http"
 	GlobalBindHTTPS                    = "bind-https"
@@ -47,6 +54,8 @@ const (
 	GlobalForwardfor                   = "forwardfor"
 	GlobalFrontingProxyPort            = "fronting-proxy-port"
 	GlobalHealthzPort                  = "healthz-port"
+	GlobalHostnameAllowlist            = "hostname-allowlist"
+	GlobalHostnameOwnership            = "hostname-ownership"
 	GlobalHTTPLogFormat                = "http-log-format"
 	GlobalHTTPPort                     = "http-port"
 	GlobalHTTPSLogFormat               = "https-log-format"
@@ -82,6 +91,7 @@ const (
 	GlobalSyslogLength                 = "syslog-length"
 	GlobalSyslogTag                    = "syslog-tag"
 	GlobalTCPLogFormat                 = "tcp-log-format"
+	GlobalTCPServiceAllowedPorts       = "tcp-service-allowed-ports"
 	GlobalTimeoutClient                = "timeout-client"
 	GlobalTimeoutClientFin             = "timeout-client-fin"
 	GlobalTimeoutStop                  = "timeout-stop"
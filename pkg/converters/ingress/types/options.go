@@ -26,6 +26,8 @@ type ConverterOptions struct {
 	Logger           types.Logger
 	Cache            convtypes.Cache
 	Tracker          convtypes.Tracker
+	Diagnostics      convtypes.Diagnostics
+	Explainer        convtypes.Explainer
 	DefaultConfig    func() map[string]string
 	DefaultBackend   string
 	DefaultCrtSecret string
@@ -33,4 +35,5 @@ type ConverterOptions struct {
 	FakeCAFile       convtypes.CrtFile
 	AnnotationPrefix string
 	AcmeTrackTLSAnn  bool
+	GRPCHealthAgent  string
 }
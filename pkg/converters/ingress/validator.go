@@ -0,0 +1,195 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/tracker"
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+)
+
+// NewIngressValidator ...
+func NewIngressValidator(options *ingtypes.ConverterOptions, globalConfig func() map[string]string) Validator {
+	return &validator{
+		options:      options,
+		globalConfig: globalConfig,
+	}
+}
+
+// Validator parses ingress objects the same way the converter does, along
+// with all the other ingress objects, and reports the configurations that
+// the converter would ignore.
+type Validator interface {
+	// Validate returns the problems found on ing, or an empty list if
+	// ing is valid. Problems found on other ingress objects are ignored.
+	Validate(ing *networking.Ingress) []string
+}
+
+type validator struct {
+	options      *ingtypes.ConverterOptions
+	globalConfig func() map[string]string
+	// mutex runs one validation at a time, each one parses all the
+	// ingress objects of the cluster
+	mutex sync.Mutex
+}
+
+func (v *validator) Validate(ing *networking.Ingress) []string {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	ing = ing.DeepCopy()
+	if ing.CreationTimestamp.IsZero() {
+		// new objects are parsed after the existing ones
+		ing.CreationTimestamp = metav1.Now()
+	}
+	logger := &validationLogger{}
+	options := *v.options
+	options.Logger = logger
+	options.Tracker = tracker.NewTracker()
+	options.Diagnostics = nil
+	options.Explainer = nil
+	options.Cache = &validationCache{
+		Cache:        v.options.Cache,
+		ing:          ing,
+		globalConfig: v.globalConfig(),
+	}
+	hconfig := haproxy.CreateInstance(logger, haproxy.InstanceOptions{}).Config()
+	NewIngressConverter(&options, hconfig).Sync()
+
+	var problems []string
+	// messages have the ingress name, either as an annotation source or as a
+	// `<namespace>/<name>` full name, and both are quoted
+	ingName := fmt.Sprintf("'%s/%s'", ing.Namespace, ing.Name)
+	for _, msg := range logger.messages {
+		if strings.Contains(msg, ingName) {
+			problems = append(problems, msg)
+		}
+	}
+	prefix := v.options.AnnotationPrefix + "/"
+	for annName := range ing.Annotations {
+		if !strings.HasPrefix(annName, prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(annName, prefix)
+		_, isHostAnn := ingtypes.AnnHost[name]
+		_, isBackAnn := ingtypes.AnnBack[name]
+		if !isHostAnn && !isBackAnn {
+			problems = append(problems, fmt.Sprintf("unknown annotation '%s'", annName))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// validationLogger collects the warnings and errors of a validation.
+type validationLogger struct {
+	mutex    sync.Mutex
+	messages []string
+}
+
+func (l *validationLogger) InfoV(v int, msg string, args ...interface{}) {}
+
+func (l *validationLogger) Info(msg string, args ...interface{}) {}
+
+func (l *validationLogger) Warn(msg string, args ...interface{}) {
+	l.add(msg, args...)
+}
+
+func (l *validationLogger) Error(msg string, args ...interface{}) {
+	l.add(msg, args...)
+}
+
+func (l *validationLogger) Fatal(msg string, args ...interface{}) {
+	l.add(msg, args...)
+}
+
+func (l *validationLogger) add(msg string, args ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.messages = append(l.messages, fmt.Sprintf(msg, args...))
+}
+
+// validationCache reads the objects from the controller's cache, adding or
+// replacing the ingress being validated. Nothing is tracked and the change
+// notifications of the controller's cache are preserved.
+type validationCache struct {
+	convtypes.Cache
+	ing          *networking.Ingress
+	globalConfig map[string]string
+}
+
+func (c *validationCache) GetIngress(ingressName string) (*networking.Ingress, error) {
+	if ingressName == c.ing.Namespace+"/"+c.ing.Name {
+		return c.ing, nil
+	}
+	return c.Cache.GetIngress(ingressName)
+}
+
+func (c *validationCache) GetIngressList() ([]*networking.Ingress, error) {
+	ingList, err := c.Cache.GetIngressList()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*networking.Ingress, 0, len(ingList)+1)
+	for _, ing := range ingList {
+		if ing.Namespace != c.ing.Namespace || ing.Name != c.ing.Name {
+			list = append(list, ing)
+		}
+	}
+	return append(list, c.ing), nil
+}
+
+func (c *validationCache) GetTerminatingPods(service *api.Service, track convtypes.TrackingTarget) ([]*api.Pod, error) {
+	return c.Cache.GetTerminatingPods(service, convtypes.TrackingTarget{})
+}
+
+func (c *validationCache) GetTLSSecretPath(defaultNamespace, secretName string, track convtypes.TrackingTarget) (convtypes.CrtFile, error) {
+	return c.Cache.GetTLSSecretPath(defaultNamespace, secretName, convtypes.TrackingTarget{})
+}
+
+func (c *validationCache) GetCASecretPath(defaultNamespace, secretName string, track convtypes.TrackingTarget) (ca, crl convtypes.File, err error) {
+	return c.Cache.GetCASecretPath(defaultNamespace, secretName, convtypes.TrackingTarget{})
+}
+
+func (c *validationCache) GetRefreshedCRLPath(defaultNamespace, secretName string, refresh time.Duration) (convtypes.File, error) {
+	// does not start to manage CRLs of objects not applied yet
+	return convtypes.File{}, fmt.Errorf("CRL is not read on validations")
+}
+
+func (c *validationCache) GetSecretContent(defaultNamespace, secretName, keyName string, track convtypes.TrackingTarget) ([]byte, error) {
+	return c.Cache.GetSecretContent(defaultNamespace, secretName, keyName, convtypes.TrackingTarget{})
+}
+
+func (c *validationCache) SwapChangedObjects() *convtypes.ChangedObjects {
+	return &convtypes.ChangedObjects{
+		GlobalNew: c.globalConfig,
+	}
+}
+
+func (c *validationCache) NeedFullSync() bool {
+	return true
+}
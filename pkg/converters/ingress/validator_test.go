@@ -0,0 +1,113 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"reflect"
+	"testing"
+
+	networking "k8s.io/api/networking/v1beta1"
+
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		existing []map[string]string
+		ann      map[string]string
+		global   map[string]string
+		expected []string
+	}{
+		// 0
+		{
+			ann: map[string]string{
+				"ingress.kubernetes.io/balance-algorithm": "leastconn",
+				"kubernetes.io/ingress.class":             "haproxy",
+			},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				"ingress.kubernetes.io/balance-alg": "leastconn",
+			},
+			expected: []string{
+				"unknown annotation 'ingress.kubernetes.io/balance-alg'",
+			},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				"ingress.kubernetes.io/ssl-redirect": "invalid",
+			},
+			expected: []string{
+				"ignoring invalid bool expression on ingress 'default/app2' key 'ssl-redirect': invalid",
+			},
+		},
+		// 3
+		{
+			existing: []map[string]string{{
+				"ingress.kubernetes.io/balance-algorithm": "roundrobin",
+			}},
+			ann: map[string]string{
+				"ingress.kubernetes.io/balance-algorithm": "leastconn",
+			},
+			expected: []string{
+				"annotation 'ingress.kubernetes.io/balance-algorithm' from ingress 'default/app1' overrides the same annotation with distinct value from [ingress 'default/app2']",
+			},
+		},
+		// 4
+		{
+			existing: []map[string]string{{
+				"ingress.kubernetes.io/ssl-redirect": "invalid",
+			}},
+		},
+		// 5
+		{
+			global: map[string]string{
+				"ssl-redirect": "false",
+			},
+			ann: map[string]string{
+				"ingress.kubernetes.io/ssl-redirect": "true",
+			},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.createSvc1Auto()
+		var ingList []*networking.Ingress
+		for j, ann := range test.existing {
+			ingList = append(ingList, c.createIng1Ann("default/app1", "domain.local", "/app"+string(rune('1'+j)), "echo:8080", ann))
+		}
+		c.cache.IngList = ingList
+		validator := NewIngressValidator(&ingtypes.ConverterOptions{
+			Cache:            c.cache,
+			Logger:           c.logger,
+			Tracker:          c.tracker,
+			DefaultBackend:   "system/default",
+			DefaultCrtSecret: "system/default",
+			AnnotationPrefix: "ingress.kubernetes.io",
+		}, func() map[string]string { return test.global })
+		actual := validator.Validate(c.createIng1Ann("default/app2", "domain.local", "/", "echo:8080", test.ann))
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("problems differ on %d - expected: %q, actual: %q", i, test.expected, actual)
+		}
+		if len(c.cache.Changed.Objects) > 0 || !reflect.DeepEqual(c.cache.IngList, ingList) {
+			t.Errorf("validation changed the cache state on %d", i)
+		}
+		c.teardown()
+	}
+}
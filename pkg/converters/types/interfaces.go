@@ -30,11 +30,14 @@ type Cache interface {
 	GetIngress(ingressName string) (*networking.Ingress, error)
 	GetIngressList() ([]*networking.Ingress, error)
 	GetService(serviceName string) (*api.Service, error)
+	GetServiceList() ([]*api.Service, error)
 	GetEndpoints(service *api.Service) (*api.Endpoints, error)
 	GetTerminatingPods(service *api.Service, track TrackingTarget) ([]*api.Pod, error)
 	GetPod(podName string) (*api.Pod, error)
+	GetNamespace(name string) (*api.Namespace, error)
 	GetTLSSecretPath(defaultNamespace, secretName string, track TrackingTarget) (CrtFile, error)
 	GetCASecretPath(defaultNamespace, secretName string, track TrackingTarget) (ca, crl File, err error)
+	GetRefreshedCRLPath(defaultNamespace, secretName string, refresh time.Duration) (File, error)
 	GetDHSecretPath(defaultNamespace, secretName string) (File, error)
 	GetSecretContent(defaultNamespace, secretName, keyName string, track TrackingTarget) ([]byte, error)
 	SwapChangedObjects() *ChangedObjects
@@ -58,6 +61,8 @@ type ChangedObjects struct {
 	//
 	Pods []*api.Pod
 	//
+	Namespaces []*api.Namespace
+	//
 	Objects []string
 }
 
@@ -68,11 +73,73 @@ type Tracker interface {
 	TrackBackend(rtype ResourceType, name string, backendID hatypes.BackendID)
 	TrackMissingOnHostname(rtype ResourceType, name, hostname string)
 	TrackStorage(rtype ResourceType, name, storage string)
-	GetDirtyLinks(oldIngressList, addIngressList, oldServiceList, addServiceList, oldSecretList, addSecretList, addPodList []string) (dirtyIngs, dirtyHosts []string, dirtyBacks []hatypes.BackendID, dirtyUsers, dirtyStorages []string)
+	TrackTCPService(rtype ResourceType, name string, port int)
+	GetDirtyLinks(oldIngressList, addIngressList, oldServiceList, addServiceList, oldSecretList, addSecretList, addPodList, oldNamespaceList []string) (dirtyIngs, dirtyHosts []string, dirtyBacks []hatypes.BackendID, dirtyUsers, dirtyStorages []string)
 	DeleteHostnames(hostnames []string)
 	DeleteBackends(backends []hatypes.BackendID)
 	DeleteUserlists(userlists []string)
+	GetDirtyTCPServices(ports []int, serviceList, secretList []string) (dirtyPorts []int, dirtyServices []string)
 	DeleteStorages(storages []string)
+	DeleteTCPServices(ports []int)
+}
+
+// Diagnostics collects the warnings found while converting the objects,
+// attached to the ingress or service object that declared the misconfiguration.
+type Diagnostics interface {
+	Warn(rtype ResourceType, name, message string)
+}
+
+// Explainer stores the effective configuration of hosts and backends along
+// with the object that declared every configuration key.
+type Explainer interface {
+	Clear()
+	RemoveHosts(hostnames []string)
+	RemoveBackends(backends []hatypes.BackendID)
+	AddHost(host *HostExplanation)
+	AddBackend(backend *BackendExplanation)
+}
+
+// HostExplanation ...
+type HostExplanation struct {
+	Hostname  string               `json:"hostname"`
+	Paths     []*PathExplanation   `json:"paths"`
+	Config    []*ConfigExplanation `json:"config"`
+	Conflicts []*ConfigExplanation `json:"conflicts,omitempty" yaml:",omitempty"`
+}
+
+// PathExplanation ...
+type PathExplanation struct {
+	Path    string `json:"path"`
+	Backend string `json:"backend"`
+}
+
+// BackendExplanation has the keys that apply to the whole backend in
+// Config, and the keys that can be configured per path in Paths. Conflicts
+// are the declarations that were ignored due to a distinct value declared
+// before on the same path, or on another path of the same backend if the
+// key cannot be configured per path.
+type BackendExplanation struct {
+	Backend   string                     `json:"backend"`
+	Config    []*ConfigExplanation       `json:"config"`
+	Paths     []*BackendPathsExplanation `json:"paths"`
+	Conflicts []*ConfigExplanation       `json:"conflicts,omitempty" yaml:",omitempty"`
+}
+
+// BackendPathsExplanation is the configuration of a group of paths of a
+// backend. Backends have one group for each distinct configuration.
+type BackendPathsExplanation struct {
+	Links  []string             `json:"links"`
+	Config []*ConfigExplanation `json:"config"`
+}
+
+// ConfigExplanation is the value of a configuration key and its source: a
+// default value, the global ConfigMap, or an ingress, service or namespace.
+// Link is the hostname and path of a conflicting declaration.
+type ConfigExplanation struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	Link   string `json:"link,omitempty" yaml:",omitempty"`
 }
 
 // TrackingTarget ...
@@ -80,6 +147,7 @@ type TrackingTarget struct {
 	Hostname string
 	Backend  hatypes.BackendID
 	Userlist string
+	TCPPort  int
 }
 
 // File ...
@@ -111,4 +179,7 @@ const (
 
 	// PodType ...
 	PodType
+
+	// NamespaceType ...
+	NamespaceType
 )
@@ -0,0 +1,50 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"strings"
+)
+
+// tcpBalanceAlgorithms are the balance algorithms of haproxy
+// that work on TCP proxies, the http based ones need mode http.
+var tcpBalanceAlgorithms = map[string]bool{
+	"first":      true,
+	"leastconn":  true,
+	"random":     true,
+	"rdp-cookie": true,
+	"roundrobin": true,
+	"source":     true,
+	"static-rr":  true,
+}
+
+// IsValidTCPBalance returns true if value is a balance algorithm of
+// haproxy, with its optional arguments, that can be used on TCP proxies.
+func IsValidTCPBalance(value string) bool {
+	name := strings.Fields(value)
+	if len(name) == 0 {
+		return false
+	}
+	algorithm := name[0]
+	if i := strings.Index(algorithm, "("); i >= 0 {
+		if !strings.HasSuffix(algorithm, ")") {
+			return false
+		}
+		algorithm = algorithm[:i]
+	}
+	return tcpBalanceAlgorithms[algorithm]
+}
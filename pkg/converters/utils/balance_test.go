@@ -0,0 +1,44 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+)
+
+func TestIsValidTCPBalance(t *testing.T) {
+	testCases := map[string]bool{
+		"":                 false,
+		"round-robin":      false,
+		"uri":              false,
+		"hdr(host)":        false,
+		"random(2":         false,
+		"roundrobin":       true,
+		"leastconn":        true,
+		"source":           true,
+		"random":           true,
+		"random(2)":        true,
+		"rdp-cookie(msts)": true,
+		"first":            true,
+		"static-rr":        true,
+	}
+	for value, expected := range testCases {
+		if actual := IsValidTCPBalance(value); actual != expected {
+			t.Errorf("valid balance differs on '%s' - expected: %t, actual: %t", value, expected, actual)
+		}
+	}
+}
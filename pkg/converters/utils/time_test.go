@@ -0,0 +1,42 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+)
+
+func TestIsValidTime(t *testing.T) {
+	testCases := map[string]bool{
+		"":      false,
+		"10":    false,
+		"10x":   false,
+		"1.5s":  false,
+		"-1s":   false,
+		"500us": true,
+		"500ms": true,
+		"2s":    true,
+		"1m":    true,
+		"1h":    true,
+		"1d":    true,
+	}
+	for value, expected := range testCases {
+		if actual := IsValidTime(value); actual != expected {
+			t.Errorf("valid time differs on '%s' - expected: %t, actual: %t", value, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crl
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	hautils "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/utils"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+const (
+	// maxCRLSize is the maximum size of a CRL read from a distribution point
+	maxCRLSize = 16 * 1024 * 1024
+	// failRetry is the time to wait before trying to fetch the CRLs again after a failure
+	failRetry = 5 * time.Minute
+	// minRefresh is the shortest time between two downloads of the same CRL
+	minRefresh = time.Minute
+)
+
+// NewUpdater ...
+func NewUpdater(logger types.Logger, metrics types.Metrics, notify func(caFile string)) Updater {
+	return &updater{
+		logger:  logger,
+		metrics: metrics,
+		notify:  notify,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		cas:     map[string]*caState{},
+	}
+}
+
+// Updater manages CRL files built from the distribution points of CA
+// certificates. The CRLs are stored next to the CA file with the `.crl`
+// extension and sent to the running haproxy via its admin socket. notify
+// is called whenever haproxy needs to be reloaded to read a CRL file: on
+// its first download or if the running haproxy didn't accept the update.
+type Updater interface {
+	// CRLFile returns the managed CRL file of caFile and a hash that only
+	// changes when haproxy needs to be reloaded. found is false if the CRL
+	// wasn't downloaded yet. CRLFile also starts to manage caFile if needed.
+	CRLFile(caFile string, refresh time.Duration) (crlFile, hash string, found bool)
+	// Update configures the admin socket of haproxy and the CA files whose
+	// CRLs should be managed. CA files missing in the list are not managed anymore.
+	Update(socket string, caFiles []string)
+	// Refresh downloads the CRLs which are missing or need to be refreshed.
+	// Refresh should be called periodically.
+	Refresh()
+}
+
+type updater struct {
+	logger  types.Logger
+	metrics types.Metrics
+	notify  func(caFile string)
+	client  *http.Client
+	mutex   sync.Mutex
+	socket  string
+	cas     map[string]*caState
+}
+
+type caState struct {
+	refresh     time.Duration
+	nextRefresh time.Time
+	// hash of the content haproxy read on its last reload
+	hash string
+	// hash of the content of the CRL file
+	crlHash string
+}
+
+func crlFilename(caFile string) string {
+	return caFile + ".crl"
+}
+
+func (u *updater) CRLFile(caFile string, refresh time.Duration) (crlFile, hash string, found bool) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	ca, found := u.cas[caFile]
+	if !found {
+		ca = &caState{}
+		u.cas[caFile] = ca
+	}
+	if ca.refresh != refresh {
+		ca.refresh = refresh
+		ca.nextRefresh = time.Time{}
+	}
+	if ca.hash == "" {
+		return "", "", false
+	}
+	return crlFilename(caFile), ca.hash, true
+}
+
+func (u *updater) Update(socket string, caFiles []string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.socket = socket
+	inUse := make(map[string]bool, len(caFiles))
+	for _, caFile := range caFiles {
+		inUse[caFile] = true
+	}
+	for caFile := range u.cas {
+		if !inUse[caFile] {
+			delete(u.cas, caFile)
+			if err := os.Remove(crlFilename(caFile)); err != nil && !os.IsNotExist(err) {
+				u.logger.Warn("crl: error removing the CRL of %s: %v", caFile, err)
+			}
+			u.metrics.SetCRLNextUpdate(caFile, nil)
+		}
+	}
+}
+
+func (u *updater) Refresh() {
+	now := time.Now()
+	u.mutex.Lock()
+	caFiles := make([]string, 0, len(u.cas))
+	for caFile, ca := range u.cas {
+		if !ca.nextRefresh.After(now) {
+			caFiles = append(caFiles, caFile)
+		}
+	}
+	u.mutex.Unlock()
+	sort.Strings(caFiles)
+	// downloads and admin socket commands run without the lock, so the
+	// sync of the ingress objects doesn't wait for slow CRL distribution points
+	for _, caFile := range caFiles {
+		if err := u.update(caFile); err != nil {
+			u.logger.Warn("crl: error updating the CRL of %s: %v", caFile, err)
+			u.setNextRefresh(caFile, now.Add(failRetry))
+		}
+	}
+}
+
+// update downloads the CRLs of the certificates found in caFile, stores them
+// on disk and sends them to haproxy.
+func (u *updater) update(caFile string) error {
+	content, nextUpdate, err := u.fetchAll(caFile)
+	if err != nil {
+		return err
+	}
+	crlHash := fmt.Sprintf("%x", sha1.Sum(content))
+	socket, changed, err := u.store(caFile, content, crlHash, nextUpdate)
+	if err != nil || !changed {
+		return err
+	}
+	if socket != "" {
+		err := u.commitCRL(socket, crlFilename(caFile), content)
+		if err == nil {
+			u.logger.InfoV(2, "crl: CRL of %s updated", caFile)
+			return nil
+		}
+		// commands are missing on haproxy older than 2.5, updates depend on a reload
+		u.logger.InfoV(2, "crl: error sending the CRL of %s to haproxy, a reload is needed: %v", caFile, err)
+	}
+	u.mutex.Lock()
+	ca, found := u.cas[caFile]
+	if found && ca.crlHash == crlHash {
+		ca.hash = crlHash
+	}
+	u.mutex.Unlock()
+	if found {
+		u.notify(caFile)
+	}
+	return nil
+}
+
+// store writes the downloaded CRLs of caFile if they changed. It returns
+// the admin socket if the running haproxy should be updated, or an empty
+// socket if haproxy needs to be reloaded to read the CRL file.
+func (u *updater) store(caFile string, content []byte, crlHash string, nextUpdate time.Time) (socket string, changed bool, err error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	ca, found := u.cas[caFile]
+	if !found {
+		// removed by Update() during the download
+		return "", false, nil
+	}
+	if nextUpdate.IsZero() {
+		u.metrics.SetCRLNextUpdate(caFile, nil)
+	} else {
+		u.metrics.SetCRLNextUpdate(caFile, &nextUpdate)
+	}
+	ca.nextRefresh = refreshTime(ca.refresh, nextUpdate)
+	if crlHash == ca.crlHash {
+		return "", false, nil
+	}
+	if err := ioutil.WriteFile(crlFilename(caFile), content, 0644); err != nil {
+		return "", false, err
+	}
+	ca.crlHash = crlHash
+	if ca.hash != "" {
+		socket = u.socket
+	}
+	return socket, true, nil
+}
+
+func (u *updater) setNextRefresh(caFile string, nextRefresh time.Time) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if ca, found := u.cas[caFile]; found {
+		ca.nextRefresh = nextRefresh
+	}
+}
+
+func (u *updater) commitCRL(socket, crlFile string, content []byte) error {
+	payload := strings.TrimRight(string(content), "\n")
+	msg, err := hautils.HAProxyCommand(socket, u.metrics.HAProxySetSSLCRLFileTime,
+		fmt.Sprintf("set ssl crl-file %s <<\n%s\n", crlFile, payload),
+		fmt.Sprintf("commit ssl crl-file %s", crlFile),
+	)
+	if err == nil && (len(msg) < 2 || !strings.Contains(msg[1], "Success!")) {
+		err = fmt.Errorf("%s", strings.Join(msg, "; "))
+	}
+	if err != nil {
+		_, _ = hautils.HAProxyCommand(socket, u.metrics.HAProxySetSSLCRLFileTime,
+			fmt.Sprintf("abort ssl crl-file %s", crlFile))
+	}
+	return err
+}
+
+// fetchAll downloads the CRL of every CA certificate found in caFile that
+// has a distribution point. It returns the PEM encoded CRLs and the
+// earliest next update.
+func (u *updater) fetchAll(caFile string) ([]byte, time.Time, error) {
+	cas, err := readCAs(caFile)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var content bytes.Buffer
+	var nextUpdate time.Time
+	for _, ca := range cas {
+		if len(ca.CRLDistributionPoints) == 0 {
+			continue
+		}
+		der, crl, err := u.fetch(ca)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		crlNextUpdate := crl.TBSCertList.NextUpdate
+		if !crlNextUpdate.IsZero() && (nextUpdate.IsZero() || crlNextUpdate.Before(nextUpdate)) {
+			nextUpdate = crlNextUpdate
+		}
+		if err := pem.Encode(&content, &pem.Block{Type: "X509 CRL", Bytes: der}); err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+	if content.Len() == 0 {
+		return nil, time.Time{}, fmt.Errorf("CA certificates do not have a CRL distribution point")
+	}
+	return content.Bytes(), nextUpdate, nil
+}
+
+// fetch downloads the CRL of a CA certificate, using the first
+// distribution point which answers a valid and up to date CRL. It
+// returns the DER encoded CRL and its parsed content.
+func (u *updater) fetch(ca *x509.Certificate) ([]byte, *pkix.CertificateList, error) {
+	var errs []string
+	for _, url := range ca.CRLDistributionPoints {
+		der, crl, err := u.fetchURL(url, ca)
+		if err == nil {
+			return der, crl, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, nil, fmt.Errorf("error reading the CRL of '%s': %s", ca.Subject.CommonName, strings.Join(errs, "; "))
+}
+
+func (u *updater) fetchURL(url string, ca *x509.Certificate) ([]byte, *pkix.CertificateList, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, nil, fmt.Errorf("unsupported distribution point: %s", url)
+	}
+	res, err := u.client.Get(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected status code from %s: %d", url, res.StatusCode)
+	}
+	der, err := ioutil.ReadAll(io.LimitReader(res.Body, maxCRLSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	if block, _ := pem.Decode(der); block != nil && block.Type == "X509 CRL" {
+		der = block.Bytes
+	}
+	crl, err := x509.ParseDERCRL(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing CRL from %s: %v", url, err)
+	}
+	if err := ca.CheckCRLSignature(crl); err != nil {
+		return nil, nil, fmt.Errorf("invalid CRL signature from %s: %v", url, err)
+	}
+	if nextUpdate := crl.TBSCertList.NextUpdate; !nextUpdate.IsZero() && nextUpdate.Before(time.Now()) {
+		return nil, nil, fmt.Errorf("CRL from %s expired on %s", url, nextUpdate.String())
+	}
+	return der, crl, nil
+}
+
+func readCAs(caFile string) ([]*x509.Certificate, error) {
+	content, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	var cas []*x509.Certificate
+	for block, rest := pem.Decode(content); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		ca, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		cas = append(cas, ca)
+	}
+	if len(cas) == 0 {
+		return nil, fmt.Errorf("CA certificate not found")
+	}
+	return cas, nil
+}
+
+// refreshTime returns when the CRLs should be downloaded again: after the
+// configured refresh interval, or on the next update of the CRLs if it
+// happens earlier.
+func refreshTime(refresh time.Duration, nextUpdate time.Time) time.Time {
+	now := time.Now()
+	next := now.Add(refresh)
+	if !nextUpdate.IsZero() && nextUpdate.Before(next) {
+		next = nextUpdate
+	}
+	if minimum := now.Add(minRefresh); next.Before(minimum) {
+		return minimum
+	}
+	return next
+}
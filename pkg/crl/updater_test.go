@@ -0,0 +1,468 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crl
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	types_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestRefresh(t *testing.T) {
+	testCases := []struct {
+		noDistPoint bool
+		wrongSigner bool
+		expired     bool
+		httpStatus  int
+		expFound    bool
+		logging     string
+	}{
+		// 0
+		{
+			expFound: true,
+		},
+		// 1
+		{
+			noDistPoint: true,
+			logging:     `WARN crl: error updating the CRL of <dir>/ca.pem: CA certificates do not have a CRL distribution point`,
+		},
+		// 2
+		{
+			httpStatus: http.StatusNotFound,
+			logging:    `WARN crl: error updating the CRL of <dir>/ca.pem: error reading the CRL of 'Test CA': unexpected status code from <server>/ca.crl: 404`,
+		},
+		// 3
+		{
+			wrongSigner: true,
+			logging:     `WARN crl: error updating the CRL of <dir>/ca.pem: error reading the CRL of 'Test CA': invalid CRL signature from <server>/ca.crl: x509: ECDSA verification failure`,
+		},
+		// 4
+		{
+			expired: true,
+			logging: `WARN crl: error updating the CRL of <dir>/ca.pem: error reading the CRL of 'Test CA': CRL from <server>/ca.crl expired on <expired>`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.httpStatus = test.httpStatus
+		c.wrongSigner = test.wrongSigner
+		c.expired = test.expired
+		caFile := c.writeCA(!test.noDistPoint)
+		if _, _, found := c.updater.CRLFile(caFile, time.Hour); found {
+			t.Errorf("CRL should not be found before the first refresh on %d", i)
+		}
+		c.updater.Update(c.socket, []string{caFile})
+		c.updater.Refresh()
+		crlFile, hash, found := c.updater.CRLFile(caFile, time.Hour)
+		if found != test.expFound {
+			t.Errorf("found differs on %d - expected: %t, actual: %t", i, test.expFound, found)
+		}
+		var expNotify []string
+		if test.expFound {
+			expNotify = []string{caFile}
+			if crlFile != caFile+".crl" || hash == "" {
+				t.Errorf("unexpected CRL file on %d: file=%s hash=%s", i, crlFile, hash)
+			}
+			if _, found := c.metrics.nextUpdate[caFile]; !found {
+				t.Errorf("next update metric not found on %d", i)
+			}
+		}
+		if !reflect.DeepEqual(c.notified, expNotify) {
+			t.Errorf("notify differs on %d - expected: %v, actual: %v", i, expNotify, c.notified)
+		}
+		if cmds := c.commands(); len(cmds) > 0 {
+			t.Errorf("no command expected on the first download on %d, found: %v", i, cmds)
+		}
+		c.compareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestRefreshUpdate(t *testing.T) {
+	testCases := []struct {
+		commitResponse string
+		expReload      bool
+		logging        string
+	}{
+		// 0
+		{
+			commitResponse: "Committing <dir>/ca.pem.crl.\nSuccess!",
+			logging:        `INFO-V(2) crl: CRL of <dir>/ca.pem updated`,
+		},
+		// 1
+		{
+			commitResponse: "Unknown command.",
+			expReload:      true,
+			logging:        `INFO-V(2) crl: error sending the CRL of <dir>/ca.pem to haproxy, a reload is needed: response from server: <set>; response from server: Unknown command.`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		caFile := c.writeCA(true)
+		c.updater.CRLFile(caFile, time.Hour)
+		c.updater.Update(c.socket, []string{caFile})
+		c.updater.Refresh()
+		_, hash1, _ := c.updater.CRLFile(caFile, time.Hour)
+		c.notified = nil
+
+		// CRL didn't change
+		c.updater.(*updater).cas[caFile].nextRefresh = time.Time{}
+		c.updater.Refresh()
+		if len(c.notified) > 0 || len(c.commands()) > 0 {
+			t.Errorf("unchanged CRL should not be sent on %d", i)
+		}
+
+		// a new certificate was revoked
+		c.revoked = append(c.revoked, 10)
+		c.commitResponse = strings.ReplaceAll(test.commitResponse, "<dir>", c.tempdir)
+		c.updater.(*updater).cas[caFile].nextRefresh = time.Time{}
+		c.updater.Refresh()
+		_, hash2, _ := c.updater.CRLFile(caFile, time.Hour)
+		content, _ := ioutil.ReadFile(caFile + ".crl")
+		crlFile := caFile + ".crl"
+		expCmds := []string{
+			"set ssl crl-file " + crlFile + " <<\n" + strings.TrimRight(string(content), "\n"),
+			"commit ssl crl-file " + crlFile,
+		}
+		var expNotify []string
+		if test.expReload {
+			expCmds = append(expCmds, "abort ssl crl-file "+crlFile)
+			expNotify = []string{caFile}
+		}
+		if cmds := c.commands(); !reflect.DeepEqual(cmds, expCmds) {
+			t.Errorf("commands differ on %d - expected: %v, actual: %v", i, expCmds, cmds)
+		}
+		if !reflect.DeepEqual(c.notified, expNotify) {
+			t.Errorf("notify differs on %d - expected: %v, actual: %v", i, expNotify, c.notified)
+		}
+		if reload := hash1 != hash2; reload != test.expReload {
+			t.Errorf("hash change differs on %d - expected: %t, actual: %t", i, test.expReload, reload)
+		}
+		c.compareLogging(strings.ReplaceAll(test.logging, "<set>", setResponse))
+		c.teardown()
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+	caFile := c.writeCA(true)
+	c.updater.CRLFile(caFile, time.Hour)
+	c.updater.Update(c.socket, []string{caFile})
+	c.updater.Refresh()
+	if _, err := os.Stat(caFile + ".crl"); err != nil {
+		t.Errorf("CRL of %s not found: %v", caFile, err)
+	}
+
+	// CA is not used anymore
+	c.updater.Update(c.socket, nil)
+	if _, err := os.Stat(caFile + ".crl"); !os.IsNotExist(err) {
+		t.Errorf("CRL of %s should be removed", caFile)
+	}
+	if _, found := c.metrics.nextUpdate[caFile]; found {
+		t.Errorf("next update metric of %s should be removed", caFile)
+	}
+	if len(c.updater.(*updater).cas) > 0 {
+		t.Errorf("CA file %s should not be managed anymore", caFile)
+	}
+}
+
+func TestRefreshUnlocked(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+	caFile := c.writeCA(true)
+	c.updater.CRLFile(caFile, time.Hour)
+	c.updater.Update(c.socket, []string{caFile})
+
+	// the CA is not used anymore while its CRL is being downloaded,
+	// Update() would deadlock if Refresh() held the lock during the download
+	c.onDownload = func() {
+		c.onDownload = nil
+		c.updater.Update(c.socket, nil)
+	}
+	c.updater.Refresh()
+	if _, err := os.Stat(caFile + ".crl"); !os.IsNotExist(err) {
+		t.Errorf("CRL of %s should not be stored", caFile)
+	}
+	if _, found := c.metrics.nextUpdate[caFile]; found {
+		t.Errorf("next update metric of %s should not be stored", caFile)
+	}
+	if len(c.notified) > 0 {
+		t.Errorf("notify should not be called, found: %v", c.notified)
+	}
+}
+
+func TestRefreshTime(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		refresh    time.Duration
+		nextUpdate time.Time
+		expected   time.Duration
+	}{
+		// 0
+		{
+			refresh:  time.Hour,
+			expected: time.Hour,
+		},
+		// 1
+		{
+			refresh:    time.Hour,
+			nextUpdate: now.Add(30 * time.Minute),
+			expected:   30 * time.Minute,
+		},
+		// 2
+		{
+			refresh:    time.Hour,
+			nextUpdate: now.Add(2 * time.Hour),
+			expected:   time.Hour,
+		},
+		// 3
+		{
+			refresh:  time.Second,
+			expected: minRefresh,
+		},
+	}
+	for i, test := range testCases {
+		refresh := time.Until(refreshTime(test.refresh, test.nextUpdate))
+		if diff := refresh - test.expected; diff > time.Second || diff < -time.Second {
+			t.Errorf("refresh differs on %d - expected: %s, actual: %s", i, test.expected, refresh)
+		}
+	}
+}
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * *
+ *
+ *  BUILDERS
+ *
+ * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+const setResponse = "Transaction created for CRL"
+
+type config struct {
+	t              *testing.T
+	logger         *types_helper.LoggerMock
+	metrics        *metricsMock
+	updater        Updater
+	tempdir        string
+	socket         string
+	listener       net.Listener
+	server         *httptest.Server
+	ca             *x509.Certificate
+	caKey          *ecdsa.PrivateKey
+	revoked        []int64
+	crl            []byte
+	crlRevoked     int
+	wrongSigner    bool
+	expired        bool
+	expiredAt      time.Time
+	httpStatus     int
+	onDownload     func()
+	commitResponse string
+	notified       []string
+	mutex          sync.Mutex
+	cmds           []string
+}
+
+type metricsMock struct {
+	*types_helper.MetricsMock
+	nextUpdate map[string]time.Time
+}
+
+func (m *metricsMock) SetCRLNextUpdate(caFile string, nextUpdate *time.Time) {
+	if nextUpdate == nil {
+		delete(m.nextUpdate, caFile)
+		return
+	}
+	m.nextUpdate[caFile] = *nextUpdate
+}
+
+func setup(t *testing.T) *config {
+	tempdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating tempdir: %v", err)
+	}
+	logger := types_helper.NewLoggerMock(t)
+	metrics := &metricsMock{MetricsMock: types_helper.NewMetricsMock(), nextUpdate: map[string]time.Time{}}
+	c := &config{
+		t:         t,
+		logger:    logger,
+		metrics:   metrics,
+		tempdir:   tempdir,
+		socket:    filepath.Join(tempdir, "admin.sock"),
+		revoked:   []int64{5},
+		expiredAt: time.Now().Add(-time.Hour).UTC().Truncate(time.Second),
+	}
+	c.updater = NewUpdater(logger, metrics, func(caFile string) {
+		c.notified = append(c.notified, caFile)
+	})
+	c.server = httptest.NewServer(http.HandlerFunc(c.serveCRL))
+	c.listener, err = net.Listen("unix", c.socket)
+	if err != nil {
+		t.Fatalf("error listening admin socket: %v", err)
+	}
+	go c.serveAdmin()
+	return c
+}
+
+func (c *config) teardown() {
+	c.logger.CompareLogging("")
+	c.listener.Close()
+	c.server.Close()
+	if err := os.RemoveAll(c.tempdir); err != nil {
+		c.t.Errorf("error removing tempdir: %v", err)
+	}
+}
+
+func (c *config) compareLogging(expected string) {
+	expected = strings.ReplaceAll(expected, "<dir>", c.tempdir)
+	expected = strings.ReplaceAll(expected, "<server>", c.server.URL)
+	expected = strings.ReplaceAll(expected, "<expired>", c.expiredAt.String())
+	c.logger.CompareLogging(expected)
+}
+
+// serveCRL is a local CRL distribution point stand-in
+func (c *config) serveCRL(w http.ResponseWriter, r *http.Request) {
+	if c.onDownload != nil {
+		c.onDownload()
+	}
+	if c.httpStatus != 0 {
+		w.WriteHeader(c.httpStatus)
+		return
+	}
+	// ecdsa signatures differ on every call, so the same CRL is reused
+	// while the list of revoked certificates doesn't change
+	if c.crl != nil && c.crlRevoked == len(c.revoked) {
+		w.Write(c.crl)
+		return
+	}
+	signer, signerKey := c.ca, c.caKey
+	if c.wrongSigner {
+		signer, signerKey = newCert(&x509.Certificate{
+			Subject:  pkix.Name{CommonName: "Test CA"},
+			IsCA:     true,
+			KeyUsage: x509.KeyUsageCRLSign,
+		}, nil, nil)
+	}
+	thisUpdate := time.Now().Add(-time.Hour)
+	nextUpdate := time.Now().Add(24 * time.Hour)
+	if c.expired {
+		thisUpdate = c.expiredAt.Add(-time.Hour)
+		nextUpdate = c.expiredAt
+	}
+	var revoked []pkix.RevokedCertificate
+	for _, serial := range c.revoked {
+		revoked = append(revoked, pkix.RevokedCertificate{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: thisUpdate,
+		})
+	}
+	der, err := signer.CreateCRL(rand.Reader, signerKey, revoked, thisUpdate, nextUpdate)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	c.crl, c.crlRevoked = der, len(c.revoked)
+	w.Write(der)
+}
+
+// serveAdmin is a haproxy admin socket stand-in
+func (c *config) serveAdmin() {
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			return
+		}
+		reader := bufio.NewReader(conn)
+		cmd, _ := reader.ReadString('\n')
+		cmd = strings.TrimSpace(cmd)
+		response := c.commitResponse
+		if strings.HasSuffix(cmd, "<<") {
+			// payload ends with an empty line
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil || line == "\n" {
+					break
+				}
+				cmd += "\n" + strings.TrimSuffix(line, "\n")
+			}
+			response = setResponse
+		}
+		c.mutex.Lock()
+		c.cmds = append(c.cmds, cmd)
+		c.mutex.Unlock()
+		conn.Write([]byte(response + "\n\n"))
+		conn.Close()
+	}
+}
+
+func (c *config) commands() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.cmds
+}
+
+func (c *config) writeCA(withDistPoint bool) string {
+	template := &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "Test CA"},
+		IsCA:     true,
+		KeyUsage: x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	if withDistPoint {
+		template.CRLDistributionPoints = []string{c.server.URL + "/ca.crl"}
+	}
+	c.ca, c.caKey = newCert(template, nil, nil)
+	caFile := filepath.Join(c.tempdir, "ca.pem")
+	content := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.ca.Raw})
+	if err := ioutil.WriteFile(caFile, content, 0644); err != nil {
+		c.t.Fatalf("error writing CA certificate: %v", err)
+	}
+	return caFile
+}
+
+func newCert(template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if template.SerialNumber == nil {
+		template.SerialNumber = big.NewInt(1)
+	}
+	template.BasicConstraintsValid = true
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(90 * 24 * time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	crt, _ := x509.ParseCertificate(der)
+	return crt, key
+}
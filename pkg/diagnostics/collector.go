@@ -0,0 +1,101 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnostics
+
+import (
+	"sync"
+	"time"
+
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+)
+
+// EventReason is the reason of the events published by the collector.
+const EventReason = "Misconfiguration"
+
+// Recorder publishes warnings as events of ingress and service objects.
+type Recorder interface {
+	RecordObjectEvent(rtype convtypes.ResourceType, name string, warning bool, reason, message string)
+}
+
+// NewCollector ...
+func NewCollector(recorder Recorder, interval time.Duration) Collector {
+	return &collector{
+		recorder:  recorder,
+		interval:  interval,
+		now:       time.Now,
+		pending:   map[warning]bool{},
+		published: map[warning]time.Time{},
+	}
+}
+
+// Collector collects the warnings found by the converters while a
+// configuration is built, and publishes them as Kubernetes events of the
+// ingress or service that has the misconfiguration. The same warning of the
+// same object is published again only after the configured interval, so
+// syncs that parse the same objects again do not flood the event list.
+type Collector interface {
+	convtypes.Diagnostics
+	// Publish sends the warnings collected since the last call as events.
+	Publish()
+}
+
+type collector struct {
+	recorder  Recorder
+	interval  time.Duration
+	now       func() time.Time
+	mutex     sync.Mutex
+	pending   map[warning]bool
+	order     []warning
+	published map[warning]time.Time
+}
+
+type warning struct {
+	rtype   convtypes.ResourceType
+	name    string
+	message string
+}
+
+func (c *collector) Warn(rtype convtypes.ResourceType, name, message string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	w := warning{rtype: rtype, name: name, message: message}
+	if !c.pending[w] {
+		c.pending[w] = true
+		c.order = append(c.order, w)
+	}
+}
+
+func (c *collector) Publish() {
+	c.mutex.Lock()
+	pending := c.order
+	c.pending = map[warning]bool{}
+	c.order = nil
+	c.mutex.Unlock()
+	now := c.now()
+	for w, t := range c.published {
+		if now.Sub(t) >= c.interval {
+			delete(c.published, w)
+		}
+	}
+	for _, w := range pending {
+		if _, found := c.published[w]; found {
+			continue
+		}
+		c.recorder.RecordObjectEvent(w.rtype, w.name, true, EventReason, w.message)
+		c.published[w] = now
+	}
+}
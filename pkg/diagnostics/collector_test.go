@@ -0,0 +1,119 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnostics
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+)
+
+func TestPublish(t *testing.T) {
+	type sync struct {
+		elapsed  time.Duration
+		warnings []string
+		expected []string
+	}
+	testCases := []struct {
+		syncs []sync
+	}{
+		// 0
+		{
+			syncs: []sync{
+				{
+					warnings: []string{"ing1:invalid 1", "svc1:invalid 2", "ing1:invalid 1"},
+					expected: []string{
+						"ingress default/app1 Warning Misconfiguration: invalid 1",
+						"service default/app1 Warning Misconfiguration: invalid 2",
+					},
+				},
+			},
+		},
+		// 1
+		{
+			syncs: []sync{
+				{
+					warnings: []string{"ing1:invalid 1"},
+					expected: []string{"ingress default/app1 Warning Misconfiguration: invalid 1"},
+				},
+				{
+					elapsed:  30 * time.Minute,
+					warnings: []string{"ing1:invalid 1", "svc1:invalid 1"},
+					expected: []string{"service default/app1 Warning Misconfiguration: invalid 1"},
+				},
+				{
+					elapsed:  30 * time.Minute,
+					warnings: []string{"ing1:invalid 1", "svc1:invalid 1"},
+					expected: []string{"ingress default/app1 Warning Misconfiguration: invalid 1"},
+				},
+			},
+		},
+		// 2
+		{
+			syncs: []sync{
+				{
+					warnings: []string{"ing1:invalid 1"},
+					expected: []string{"ingress default/app1 Warning Misconfiguration: invalid 1"},
+				},
+				{
+					elapsed: 2 * time.Hour,
+				},
+				{
+					warnings: []string{"ing1:invalid 1"},
+					expected: []string{"ingress default/app1 Warning Misconfiguration: invalid 1"},
+				},
+			},
+		},
+	}
+	for i, test := range testCases {
+		recorder := &recorderMock{}
+		c := NewCollector(recorder, time.Hour).(*collector)
+		now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		c.now = func() time.Time { return now }
+		for j, s := range test.syncs {
+			now = now.Add(s.elapsed)
+			for _, w := range s.warnings {
+				rtype := convtypes.IngressType
+				if w[:3] == "svc" {
+					rtype = convtypes.ServiceType
+				}
+				c.Warn(rtype, "default/app1", w[5:])
+			}
+			recorder.events = nil
+			c.Publish()
+			if !reflect.DeepEqual(recorder.events, s.expected) {
+				t.Errorf("events differ on %d/%d - expected: %q, actual: %q", i, j, s.expected, recorder.events)
+			}
+		}
+	}
+}
+
+type recorderMock struct {
+	events []string
+}
+
+func (r *recorderMock) RecordObjectEvent(rtype convtypes.ResourceType, name string, warning bool, reason, message string) {
+	kind := map[convtypes.ResourceType]string{convtypes.IngressType: "ingress", convtypes.ServiceType: "service"}[rtype]
+	eventType := "Normal"
+	if warning {
+		eventType = "Warning"
+	}
+	r.events = append(r.events, fmt.Sprintf("%s %s %s %s: %s", kind, name, eventType, reason, message))
+}
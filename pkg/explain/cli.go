@@ -0,0 +1,68 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package explain
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// RunCLI queries the explain endpoint of a running controller and writes the
+// response to stdout. It returns the exit code of the command.
+func RunCLI(args []string, stdout, stderr io.Writer) int {
+	flags := pflag.NewFlagSet("explain", pflag.ContinueOnError)
+	flags.SetOutput(stderr)
+	addr := flags.String("addr", "127.0.0.1:10254", "address of the healthz port of the controller")
+	host := flags.String("host", "", "hostname to be explained, along with the backends of its paths")
+	path := flags.String("path", "", "path of the hostname, restricts the explanation to one path")
+	backend := flags.String("backend", "", "backend to be explained, eg namespace_service_port")
+	output := flags.StringP("output", "o", "text", "output format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *host == "" && *backend == "" {
+		fmt.Fprintf(stderr, "one of --host or --backend is mandatory\n")
+		return 2
+	}
+	query := url.Values{}
+	for name, value := range map[string]string{"host": *host, "path": *path, "backend": *backend} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	if *output == "text" {
+		query.Set("format", "text")
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.Get("http://" + *addr + "/explain?" + query.Encode())
+	if err != nil {
+		fmt.Fprintf(stderr, "error reading explain endpoint: %v\n", err)
+		return 1
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		_, _ = io.Copy(stderr, res.Body)
+		return 1
+	}
+	_, _ = io.Copy(stdout, res.Body)
+	return 0
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package explain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+// NewStore ...
+func NewStore() Store {
+	return &store{
+		hosts:    map[string]*convtypes.HostExplanation{},
+		backends: map[string]*convtypes.BackendExplanation{},
+	}
+}
+
+// Store keeps the explanations of the hosts and backends built by the
+// converter, and answers the queries of the explain endpoint. Queries
+// use the `host`, `path` and `backend` parameters, and the response is
+// formatted as JSON, or as plain text if the `format` parameter is `text`.
+type Store interface {
+	convtypes.Explainer
+	http.Handler
+}
+
+// Explanation is the response of a query.
+type Explanation struct {
+	Hosts    []*convtypes.HostExplanation    `json:"hosts,omitempty"`
+	Backends []*convtypes.BackendExplanation `json:"backends,omitempty"`
+}
+
+type store struct {
+	mutex    sync.RWMutex
+	hosts    map[string]*convtypes.HostExplanation
+	backends map[string]*convtypes.BackendExplanation
+}
+
+func (s *store) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.hosts = map[string]*convtypes.HostExplanation{}
+	s.backends = map[string]*convtypes.BackendExplanation{}
+}
+
+func (s *store) RemoveHosts(hostnames []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, hostname := range hostnames {
+		delete(s.hosts, hostname)
+	}
+}
+
+func (s *store) RemoveBackends(backends []hatypes.BackendID) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, backend := range backends {
+		delete(s.backends, backend.String())
+	}
+}
+
+func (s *store) AddHost(host *convtypes.HostExplanation) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.hosts[host.Hostname] = host
+}
+
+func (s *store) AddBackend(backend *convtypes.BackendExplanation) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.backends[backend.Backend] = backend
+}
+
+func (s *store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	explanation, status, err := s.explain(query.Get("host"), query.Get("path"), query.Get("backend"))
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	if query.Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain")
+		WriteText(w, explanation)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(explanation)
+}
+
+// explain returns the explanation of a backend, or the explanation of a
+// host and the backends of its paths, optionally filtered by one path.
+func (s *store) explain(hostname, path, backendID string) (*Explanation, int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if backendID != "" {
+		backend, found := s.backends[backendID]
+		if !found {
+			return nil, http.StatusNotFound, fmt.Errorf("backend not found: %s", backendID)
+		}
+		return &Explanation{Backends: []*convtypes.BackendExplanation{backend}}, http.StatusOK, nil
+	}
+	if hostname == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("one of host or backend parameters is mandatory")
+	}
+	host, found := s.hosts[hostname]
+	if !found {
+		return nil, http.StatusNotFound, fmt.Errorf("host not found: %s", hostname)
+	}
+	explanation := &Explanation{}
+	if path == "" {
+		explanation.Hosts = []*convtypes.HostExplanation{host}
+		for _, hostPath := range host.Paths {
+			if backend, found := s.backends[hostPath.Backend]; found && !hasBackend(explanation.Backends, backend) {
+				explanation.Backends = append(explanation.Backends, backend)
+			}
+		}
+		return explanation, http.StatusOK, nil
+	}
+	var hostPath *convtypes.PathExplanation
+	for _, p := range host.Paths {
+		if p.Path == path {
+			hostPath = p
+			break
+		}
+	}
+	if hostPath == nil {
+		return nil, http.StatusNotFound, fmt.Errorf("path not found on host %s: %s", hostname, path)
+	}
+	hostCopy := *host
+	hostCopy.Paths = []*convtypes.PathExplanation{hostPath}
+	explanation.Hosts = []*convtypes.HostExplanation{&hostCopy}
+	if backend, found := s.backends[hostPath.Backend]; found {
+		explanation.Backends = []*convtypes.BackendExplanation{filterBackendLink(backend, hostname+path)}
+	}
+	return explanation, http.StatusOK, nil
+}
+
+func hasBackend(backends []*convtypes.BackendExplanation, backend *convtypes.BackendExplanation) bool {
+	for _, b := range backends {
+		if b == backend {
+			return true
+		}
+	}
+	return false
+}
+
+// filterBackendLink returns a copy of backend with the backend-wide
+// configuration, and the path configuration and conflicts of a hostname
+// and path.
+func filterBackendLink(backend *convtypes.BackendExplanation, link string) *convtypes.BackendExplanation {
+	backendCopy := &convtypes.BackendExplanation{
+		Backend: backend.Backend,
+		Config:  backend.Config,
+	}
+	for _, paths := range backend.Paths {
+		for _, l := range paths.Links {
+			if l == link {
+				backendCopy.Paths = append(backendCopy.Paths, paths)
+				break
+			}
+		}
+	}
+	for _, conflict := range backend.Conflicts {
+		if conflict.Link == link {
+			backendCopy.Conflicts = append(backendCopy.Conflicts, conflict)
+		}
+	}
+	return backendCopy
+}
+
+// WriteText writes an explanation as plain text.
+func WriteText(w io.Writer, explanation *Explanation) {
+	writeConfig := func(title string, config []*convtypes.ConfigExplanation) {
+		if len(config) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "  %s:\n", title)
+		for _, cfg := range config {
+			if cfg.Link != "" {
+				fmt.Fprintf(w, "    %s: %s (%s on %s)\n", cfg.Key, cfg.Value, cfg.Source, cfg.Link)
+			} else {
+				fmt.Fprintf(w, "    %s: %s (%s)\n", cfg.Key, cfg.Value, cfg.Source)
+			}
+		}
+	}
+	for _, host := range explanation.Hosts {
+		fmt.Fprintf(w, "host %s\n", host.Hostname)
+		if len(host.Paths) > 0 {
+			fmt.Fprintf(w, "  paths:\n")
+			for _, path := range host.Paths {
+				fmt.Fprintf(w, "    %s: %s\n", path.Path, path.Backend)
+			}
+		}
+		writeConfig("config", host.Config)
+		writeConfig("conflicts", host.Conflicts)
+	}
+	for _, backend := range explanation.Backends {
+		fmt.Fprintf(w, "backend %s\n", backend.Backend)
+		writeConfig("config", backend.Config)
+		for _, paths := range backend.Paths {
+			writeConfig(fmt.Sprintf("paths %v", paths.Links), paths.Config)
+		}
+		writeConfig("conflicts", backend.Conflicts)
+	}
+}
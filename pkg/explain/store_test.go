@@ -0,0 +1,220 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package explain
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+func TestStore(t *testing.T) {
+	testCases := []struct {
+		query     string
+		expStatus int
+		expBody   string
+	}{
+		// 0
+		{
+			query:     "",
+			expStatus: http.StatusBadRequest,
+			expBody:   "one of host or backend parameters is mandatory",
+		},
+		// 1
+		{
+			query:     "host=d2.local",
+			expStatus: http.StatusNotFound,
+			expBody:   "host not found: d2.local",
+		},
+		// 2
+		{
+			query:     "host=d1.local&path=/app3",
+			expStatus: http.StatusNotFound,
+			expBody:   "path not found on host d1.local: /app3",
+		},
+		// 3
+		{
+			query:     "backend=default_app_80",
+			expStatus: http.StatusNotFound,
+			expBody:   "backend not found: default_app_80",
+		},
+		// 4
+		{
+			query:     "backend=default_echo_8080&format=text",
+			expStatus: http.StatusOK,
+			expBody: `
+backend default_echo_8080
+  config:
+    timeout-server: 10s (ingress 'default/app1')
+  paths [d1.local/app1]:
+    ssl-redirect: true (default)
+  paths [d1.local/app2]:
+    ssl-redirect: false (ingress 'default/app2')
+  conflicts:
+    timeout-server: 20s (ingress 'default/app2' on d1.local/app2)`,
+		},
+		// 5
+		{
+			query:     "host=d1.local&format=text",
+			expStatus: http.StatusOK,
+			expBody: `
+host d1.local
+  paths:
+    /app2: default_echo_8080
+    /app1: default_echo_8080
+  config:
+    app-root: /app1 (ingress 'default/app1')
+backend default_echo_8080
+  config:
+    timeout-server: 10s (ingress 'default/app1')
+  paths [d1.local/app1]:
+    ssl-redirect: true (default)
+  paths [d1.local/app2]:
+    ssl-redirect: false (ingress 'default/app2')
+  conflicts:
+    timeout-server: 20s (ingress 'default/app2' on d1.local/app2)`,
+		},
+		// 6
+		{
+			query:     "host=d1.local&path=/app1&format=text",
+			expStatus: http.StatusOK,
+			expBody: `
+host d1.local
+  paths:
+    /app1: default_echo_8080
+  config:
+    app-root: /app1 (ingress 'default/app1')
+backend default_echo_8080
+  config:
+    timeout-server: 10s (ingress 'default/app1')
+  paths [d1.local/app1]:
+    ssl-redirect: true (default)`,
+		},
+		// 7
+		{
+			query:     "host=d1.local&path=/app2",
+			expStatus: http.StatusOK,
+			expBody: `
+{
+  "hosts": [
+    {
+      "hostname": "d1.local",
+      "paths": [
+        {
+          "path": "/app2",
+          "backend": "default_echo_8080"
+        }
+      ],
+      "config": [
+        {
+          "key": "app-root",
+          "value": "/app1",
+          "source": "ingress 'default/app1'"
+        }
+      ]
+    }
+  ],
+  "backends": [
+    {
+      "backend": "default_echo_8080",
+      "config": [
+        {
+          "key": "timeout-server",
+          "value": "10s",
+          "source": "ingress 'default/app1'"
+        }
+      ],
+      "paths": [
+        {
+          "links": [
+            "d1.local/app2"
+          ],
+          "config": [
+            {
+              "key": "ssl-redirect",
+              "value": "false",
+              "source": "ingress 'default/app2'"
+            }
+          ]
+        }
+      ],
+      "conflicts": [
+        {
+          "key": "timeout-server",
+          "value": "20s",
+          "source": "ingress 'default/app2'",
+          "link": "d1.local/app2"
+        }
+      ]
+    }
+  ]
+}`,
+		},
+	}
+	s := NewStore()
+	s.AddHost(&convtypes.HostExplanation{
+		Hostname: "d1.local",
+		Paths: []*convtypes.PathExplanation{
+			{Path: "/app2", Backend: "default_echo_8080"},
+			{Path: "/app1", Backend: "default_echo_8080"},
+		},
+		Config: []*convtypes.ConfigExplanation{
+			{Key: "app-root", Value: "/app1", Source: "ingress 'default/app1'"},
+		},
+	})
+	s.AddBackend(&convtypes.BackendExplanation{
+		Backend: "default_echo_8080",
+		Config: []*convtypes.ConfigExplanation{
+			{Key: "timeout-server", Value: "10s", Source: "ingress 'default/app1'"},
+		},
+		Paths: []*convtypes.BackendPathsExplanation{
+			{
+				Links: []string{"d1.local/app1"},
+				Config: []*convtypes.ConfigExplanation{
+					{Key: "ssl-redirect", Value: "true", Source: "default"},
+				},
+			},
+			{
+				Links: []string{"d1.local/app2"},
+				Config: []*convtypes.ConfigExplanation{
+					{Key: "ssl-redirect", Value: "false", Source: "ingress 'default/app2'"},
+				},
+			},
+		},
+		Conflicts: []*convtypes.ConfigExplanation{
+			{Key: "timeout-server", Value: "20s", Source: "ingress 'default/app2'", Link: "d1.local/app2"},
+		},
+	})
+	s.AddBackend(&convtypes.BackendExplanation{Backend: "default_app_80"})
+	s.RemoveBackends([]hatypes.BackendID{{Namespace: "default", Name: "app", Port: "80"}})
+	for i, test := range testCases {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/explain?"+test.query, nil))
+		if w.Code != test.expStatus {
+			t.Errorf("status differs on %d - expected: %d, actual: %d", i, test.expStatus, w.Code)
+		}
+		expBody := strings.Trim(test.expBody, "\n")
+		actualBody := strings.Trim(w.Body.String(), "\n")
+		if actualBody != expBody {
+			t.Errorf("body differs on %d - expected:\n%s\nactual:\n%s", i, expBody, actualBody)
+		}
+	}
+}
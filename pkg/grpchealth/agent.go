@@ -0,0 +1,362 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package grpchealth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+const (
+	// probeTimeout should be shorter than the agent check timeout of haproxy,
+	// which defaults to the agent check interval
+	probeTimeout = time.Second
+	// maxResponseSize is the maximum size of a health check response
+	maxResponseSize = 4096
+	// servingStatus is the SERVING value of the HealthCheckResponse.ServingStatus enum
+	servingStatus = 1
+)
+
+// NewAgent ...
+func NewAgent(logger types.Logger, addr string, onDemand bool) Agent {
+	return &agent{
+		logger:     logger,
+		addr:       addr,
+		onDemand:   onDemand,
+		backends:   map[string]*backend{},
+		transports: map[string]*http2.Transport{},
+	}
+}
+
+// Agent answers the agent checks of haproxy with the status of gRPC servers,
+// as reported by their `grpc.health.v1.Health/Check` method. Agent checks
+// send the backend ID and the server name, and are answered with `up` if
+// the server is serving, or `down` otherwise.
+type Agent interface {
+	// Update configures the backends whose servers can be checked.
+	Update(backends []*hatypes.Backend)
+	// Listen starts to answer the agent checks of haproxy on a TCP socket.
+	// An on demand agent only binds the socket when Update receives the
+	// first backend with gRPC health check.
+	Listen(stopCh chan struct{}) error
+}
+
+type agent struct {
+	logger     types.Logger
+	addr       string
+	onDemand   bool
+	stopCh     chan struct{}
+	listener   net.Listener
+	mutex      sync.Mutex
+	backends   map[string]*backend
+	transports map[string]*http2.Transport
+}
+
+type backend struct {
+	service     string
+	secure      bool
+	crtFilename string
+	addr        string
+	port        int
+	servers     map[string]string
+}
+
+func (a *agent) Update(backends []*hatypes.Backend) {
+	newBackends := make(map[string]*backend, len(backends))
+	for _, b := range backends {
+		if !b.HealthCheck.GRPC {
+			continue
+		}
+		servers := make(map[string]string, len(b.Endpoints))
+		for _, ep := range b.Endpoints {
+			if ep.Enabled {
+				servers[ep.Name] = fmt.Sprintf("%s:%d", ep.IP, ep.Port)
+			}
+		}
+		newBackends[b.ID] = &backend{
+			service:     b.HealthCheck.GRPCService,
+			secure:      b.Server.Secure,
+			crtFilename: b.Server.CrtFilename,
+			addr:        b.HealthCheck.Addr,
+			port:        b.HealthCheck.Port,
+			servers:     servers,
+		}
+	}
+	a.mutex.Lock()
+	a.backends = newBackends
+	a.mutex.Unlock()
+	if len(newBackends) > 0 && a.onDemand && a.listener == nil && a.stopCh != nil {
+		if err := a.listen(a.stopCh); err != nil {
+			a.logger.Error("grpc health: error creating the agent listener: %v", err)
+		}
+	}
+}
+
+func (a *agent) Listen(stopCh chan struct{}) error {
+	if a.onDemand {
+		a.stopCh = stopCh
+		return nil
+	}
+	return a.listen(stopCh)
+}
+
+func (a *agent) listen(stopCh chan struct{}) error {
+	l, err := net.Listen("tcp", a.addr)
+	if err != nil {
+		return err
+	}
+	a.listener = l
+	a.logger.Info("grpc health: listening agent checks on %s", a.addr)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				select {
+				case <-stopCh:
+					return
+				default:
+				}
+				a.logger.Error("grpc health: error accepting connection: %v", err)
+				time.Sleep(time.Second)
+				continue
+			}
+			go a.serve(conn)
+		}
+	}()
+	go func() {
+		<-stopCh
+		a.logger.Info("grpc health: closing agent listener")
+		if err := a.listener.Close(); err != nil {
+			a.logger.Error("grpc health: error closing listener: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (a *agent) serve(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(2 * probeTimeout))
+	line, err := bufio.NewReader(io.LimitReader(conn, 1024)).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return
+	}
+	_, _ = conn.Write([]byte(a.answer(strings.TrimSpace(line)) + "\n"))
+}
+
+// answer returns the agent check response of a request made
+// of the backend ID and the server name.
+func (a *agent) answer(request string) string {
+	fields := strings.Fields(request)
+	if len(fields) != 2 {
+		return "down#invalid request"
+	}
+	backendID, serverName := fields[0], fields[1]
+	a.mutex.Lock()
+	b, found := a.backends[backendID]
+	a.mutex.Unlock()
+	if !found {
+		return "down#backend not found"
+	}
+	target, found := b.servers[serverName]
+	if !found {
+		return "down#server not found"
+	}
+	if b.addr != "" || b.port > 0 {
+		host, port, _ := net.SplitHostPort(target)
+		if b.addr != "" {
+			host = b.addr
+		}
+		if b.port > 0 {
+			port = strconv.Itoa(b.port)
+		}
+		target = net.JoinHostPort(host, port)
+	}
+	if err := a.check(b, target); err != nil {
+		a.logger.InfoV(2, "grpc health: server %s/%s is down: %v", backendID, serverName, err)
+		return "down#" + err.Error()
+	}
+	return "up"
+}
+
+// check calls the grpc.health.v1.Health/Check method of a gRPC server.
+func (a *agent) check(b *backend, target string) error {
+	transport, err := a.transport(b)
+	if err != nil {
+		return err
+	}
+	scheme := "http"
+	if b.secure {
+		scheme = "https"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodPost,
+		scheme+"://"+target+"/grpc.health.v1.Health/Check",
+		bytes.NewReader(encodeRequest(b.service)))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("content-type", "application/grpc")
+	req.Header.Set("te", "trailers")
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	// trailers-only responses send grpc-status in the headers
+	grpcStatus := res.Trailer.Get("grpc-status")
+	if grpcStatus == "" {
+		grpcStatus = res.Header.Get("grpc-status")
+	}
+	if grpcStatus != "0" {
+		return fmt.Errorf("unexpected grpc status: %s", grpcStatus)
+	}
+	status, err := decodeResponse(body)
+	if err != nil {
+		return err
+	}
+	if status != servingStatus {
+		return fmt.Errorf("service is not serving, status is %d", status)
+	}
+	return nil
+}
+
+func (a *agent) transport(b *backend) (*http2.Transport, error) {
+	key := "h2c"
+	if b.secure {
+		key = "tls:" + b.crtFilename
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if transport, found := a.transports[key]; found {
+		return transport, nil
+	}
+	var transport *http2.Transport
+	if b.secure {
+		// the health of the server is checked, its identity is verified by haproxy
+		tlsConfig := &tls.Config{InsecureSkipVerify: true}
+		if b.crtFilename != "" {
+			crt, err := tls.LoadX509KeyPair(b.crtFilename, b.crtFilename)
+			if err != nil {
+				return nil, fmt.Errorf("error reading client certificate: %v", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{crt}
+		}
+		transport = &http2.Transport{TLSClientConfig: tlsConfig}
+	} else {
+		transport = &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return net.DialTimeout(network, addr, probeTimeout)
+			},
+		}
+	}
+	a.transports[key] = transport
+	return transport, nil
+}
+
+// encodeRequest returns a length-prefixed gRPC message with the
+// protobuf encoding of a HealthCheckRequest{service}.
+func encodeRequest(service string) []byte {
+	var msg []byte
+	if service != "" {
+		// field 1, wire type 2 (length-delimited)
+		msg = append(msg, 0x0a)
+		msg = appendVarint(msg, uint64(len(service)))
+		msg = append(msg, service...)
+	}
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return append(frame, msg...)
+}
+
+// decodeResponse returns the serving status of a length-prefixed
+// gRPC message with the protobuf encoding of a HealthCheckResponse.
+func decodeResponse(body []byte) (uint64, error) {
+	if len(body) < 5 {
+		return 0, fmt.Errorf("response message is missing")
+	}
+	if body[0] != 0 {
+		return 0, fmt.Errorf("compressed responses are not supported")
+	}
+	size := binary.BigEndian.Uint32(body[1:5])
+	msg := body[5:]
+	if uint32(len(msg)) != size {
+		return 0, fmt.Errorf("invalid response message size")
+	}
+	var status uint64
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return 0, fmt.Errorf("invalid response message")
+		}
+		msg = msg[n:]
+		switch key & 0x7 {
+		case 0: // varint
+			value, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return 0, fmt.Errorf("invalid response message")
+			}
+			msg = msg[n:]
+			if key>>3 == 1 {
+				status = value
+			}
+		case 2: // length-delimited
+			size, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < size {
+				return 0, fmt.Errorf("invalid response message")
+			}
+			msg = msg[n+int(size):]
+		default:
+			return 0, fmt.Errorf("invalid response message")
+		}
+	}
+	return status, nil
+}
+
+func appendVarint(buf []byte, value uint64) []byte {
+	for value >= 0x80 {
+		buf = append(buf, byte(value)|0x80)
+		value >>= 7
+	}
+	return append(buf, byte(value))
+}
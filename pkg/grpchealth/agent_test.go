@@ -0,0 +1,201 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package grpchealth
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	types_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestAnswer(t *testing.T) {
+	testCases := []struct {
+		services map[string]uint64
+		service  string
+		request  string
+		expected string
+		logging  string
+	}{
+		// 0
+		{
+			services: map[string]uint64{"": 1},
+			expected: "up",
+		},
+		// 1
+		{
+			services: map[string]uint64{"": 2},
+			expected: "down#service is not serving, status is 2",
+			logging:  `INFO-V(2) grpc health: server default_app_8080/srv001 is down: service is not serving, status is 2`,
+		},
+		// 2
+		{
+			services: map[string]uint64{"app.Service": 1},
+			service:  "app.Service",
+			expected: "up",
+		},
+		// 3
+		{
+			services: map[string]uint64{"": 1},
+			service:  "app.Service",
+			expected: "down#unexpected grpc status: 5",
+			logging:  `INFO-V(2) grpc health: server default_app_8080/srv001 is down: unexpected grpc status: 5`,
+		},
+		// 4
+		{
+			request:  "default_app_8080 srv002",
+			expected: "down#server not found",
+		},
+		// 5
+		{
+			request:  "default_app_8081 srv001",
+			expected: "down#backend not found",
+		},
+		// 6
+		{
+			request:  "default_app_8080",
+			expected: "down#invalid request",
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t, test.services)
+		a := NewAgent(c.logger, "", false).(*agent)
+		noGRPC := c.newBackend("default_app_8081", "")
+		noGRPC.HealthCheck.GRPC = false
+		a.Update([]*hatypes.Backend{c.newBackend("default_app_8080", test.service), noGRPC})
+		request := test.request
+		if request == "" {
+			request = "default_app_8080 srv001"
+		}
+		if actual := a.answer(request); actual != test.expected {
+			t.Errorf("answer differs on %d - expected: %s, actual: %s", i, test.expected, actual)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	testCases := []struct {
+		service  string
+		expected []byte
+	}{
+		// 0
+		{
+			service:  "",
+			expected: []byte{0, 0, 0, 0, 0},
+		},
+		// 1
+		{
+			service:  "app.Svc",
+			expected: []byte{0, 0, 0, 0, 9, 0x0a, 7, 'a', 'p', 'p', '.', 'S', 'v', 'c'},
+		},
+	}
+	for i, test := range testCases {
+		if actual := encodeRequest(test.service); string(actual) != string(test.expected) {
+			t.Errorf("request differs on %d - expected: %v, actual: %v", i, test.expected, actual)
+		}
+	}
+	if status, err := decodeResponse([]byte{0, 0, 0, 0, 2, 0x08, 1}); err != nil || status != 1 {
+		t.Errorf("unexpected response decoding - status: %d, err: %v", status, err)
+	}
+	if _, err := decodeResponse([]byte{0, 0, 0, 0, 3, 0x08, 1}); err == nil {
+		t.Errorf("expected error decoding a response with invalid size")
+	}
+}
+
+type config struct {
+	t      *testing.T
+	logger *types_helper.LoggerMock
+	server *httptest.Server
+}
+
+func setup(t *testing.T, services map[string]uint64) *config {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var service string
+		if len(body) > 7 {
+			service = string(body[7:])
+		}
+		w.Header().Set("content-type", "application/grpc")
+		status, found := services[service]
+		if !found {
+			// trailers-only response, NOT_FOUND
+			w.Header().Set("grpc-status", "5")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("trailer", "grpc-status")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte{0, 0, 0, 0, 2, 0x08, byte(status)})
+		w.Header().Set("grpc-status", "0")
+	})
+	return &config{
+		t:      t,
+		logger: &types_helper.LoggerMock{T: t},
+		server: httptest.NewServer(h2c.NewHandler(handler, &http2.Server{})),
+	}
+}
+
+func (c *config) teardown() {
+	c.server.Close()
+	c.logger.CompareLogging("")
+}
+
+func (c *config) newBackend(id, service string) *hatypes.Backend {
+	host, port, _ := net.SplitHostPort(c.server.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return &hatypes.Backend{
+		ID: id,
+		Endpoints: []*hatypes.Endpoint{
+			{Name: "srv001", IP: host, Port: p, Enabled: true},
+			{Name: "srv002", IP: "127.0.0.1", Port: 1023, Enabled: false},
+		},
+		HealthCheck: hatypes.HealthCheck{GRPC: true, GRPCService: service},
+	}
+}
+
+func TestListenOnDemand(t *testing.T) {
+	logger := &types_helper.LoggerMock{T: t}
+	stopCh := make(chan struct{})
+	a := NewAgent(logger, "127.0.0.1:0", true).(*agent)
+	if err := a.Listen(stopCh); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	a.Update([]*hatypes.Backend{{ID: "d1_app_8080"}})
+	if a.listener != nil {
+		t.Errorf("agent should not listen without gRPC health checks")
+	}
+	a.Update([]*hatypes.Backend{{ID: "d1_app_8080", HealthCheck: hatypes.HealthCheck{GRPC: true}}})
+	if a.listener == nil {
+		t.Errorf("agent should listen after the first gRPC health check")
+	}
+	close(stopCh)
+	time.Sleep(50 * time.Millisecond)
+	logger.CompareLogging(`
+INFO grpc health: listening agent checks on 127.0.0.1:0
+INFO grpc health: closing agent listener`)
+}
@@ -96,8 +96,12 @@ func (c *config) Frontend() *hatypes.Frontend {
 // during ingress, services and endpoint parsing, but most of
 // them need to start after all objects are parsed.
 func (c *config) SyncConfig() {
-	if c.hosts.HasSSLPassthrough() {
-		// using ssl-passthrough config, so need a `mode tcp`
+	// tls-alpn-01 challenges are answered by the acme server,
+	// selected by `req.ssl_alpn` on the `mode tcp` frontend
+	c.global.Acme.TLSALPN = c.global.Acme.Enabled && c.global.Acme.TLSSocket != "" &&
+		c.acmeData.Storages().HasChallenge("tls-alpn-01")
+	if c.hosts.HasSSLPassthrough() || c.global.Acme.TLSALPN {
+		// using ssl-passthrough or tls-alpn-01 config, so need a `mode tcp`
 		// frontend with `inspect-delay` and `req.ssl_sni`
 		bindName := "_https_socket"
 		c.frontend.BindName = bindName
@@ -168,6 +172,8 @@ func (c *config) WriteFrontendMaps() error {
 		TLSNeedCrtList:        mapBuilder.AddMap(mapsDir + "/_front_tls_needcrt.list"),
 		TLSInvalidCrtPagesMap: mapBuilder.AddMap(mapsDir + "/_front_tls_invalidcrt_pages.map"),
 		TLSMissingCrtPagesMap: mapBuilder.AddMap(mapsDir + "/_front_tls_missingcrt_pages.map"),
+		TLSOCSPCheckList:      mapBuilder.AddMap(mapsDir + "/_front_tls_ocspcheck.list"),
+		TLSOCSPFailOpenList:   mapBuilder.AddMap(mapsDir + "/_front_tls_ocspfailopen.list"),
 		//
 		CrtList: mapBuilder.AddMap(mapsDir + "/_front_bind_crt.list"),
 	}
@@ -237,6 +243,12 @@ func (c *config) WriteFrontendMaps() error {
 			if !host.TLS.CAVerifyOptional {
 				fmaps.TLSNeedCrtList.AddHostnameMapping(host.Hostname, "")
 			}
+			if host.TLS.OCSPCheck {
+				fmaps.TLSOCSPCheckList.AddHostnameMapping(host.Hostname, "")
+				if host.TLS.OCSPFailOpen {
+					fmaps.TLSOCSPFailOpenList.AddHostnameMapping(host.Hostname, "")
+				}
+			}
 			page := host.TLS.CAErrorPage
 			if page != "" {
 				fmaps.TLSInvalidCrtPagesMap.AddHostnameMapping(host.Hostname, page)
@@ -257,6 +269,7 @@ func (c *config) WriteFrontendMaps() error {
 			crtFile = c.frontend.DefaultCrtFile
 		}
 		if crtFile != c.frontend.DefaultCrtFile ||
+			tls.TLSDualFilename != "" ||
 			tls.ALPN != "" ||
 			tls.CAFilename != "" ||
 			tls.Ciphers != "" ||
@@ -287,13 +300,21 @@ func (c *config) WriteFrontendMaps() error {
 				bindConf = append(bindConf, tls.Options)
 			}
 
-			var crtListEntry string
-			if len(bindConf) == 0 {
-				crtListEntry = fmt.Sprintf("%s %s", crtFile, host.Hostname)
-			} else {
-				crtListEntry = fmt.Sprintf("%s [%s] %s", crtFile, strings.Join(bindConf, " "), host.Hostname)
+			crtFiles := []string{crtFile}
+			if tls.TLSDualFilename != "" {
+				// haproxy chooses between rsa and ecdsa
+				// certificates of the same hostname
+				crtFiles = append(crtFiles, tls.TLSDualFilename)
+			}
+			for _, crtFile := range crtFiles {
+				var crtListEntry string
+				if len(bindConf) == 0 {
+					crtListEntry = fmt.Sprintf("%s %s", crtFile, host.Hostname)
+				} else {
+					crtListEntry = fmt.Sprintf("%s [%s] %s", crtFile, strings.Join(bindConf, " "), host.Hostname)
+				}
+				fmaps.CrtList.AppendItem(crtListEntry)
 			}
-			fmaps.CrtList.AppendItem(crtListEntry)
 		}
 	}
 	if err := writeMaps(mapBuilder, c.options.mapsTemplate); err != nil {
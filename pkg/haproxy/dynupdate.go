@@ -21,6 +21,7 @@ import (
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
@@ -34,6 +35,8 @@ type dynUpdater struct {
 	socket  string
 	cmd     func(socket string, observer func(duration time.Duration), commands ...string) ([]string, error)
 	cmdCnt  int
+	cmds    []string
+	reasons reloadReasons
 	metrics types.Metrics
 }
 
@@ -47,6 +50,16 @@ type epPair struct {
 	cur *hatypes.Endpoint
 }
 
+type tcpBackendPair struct {
+	old *hatypes.TCPBackend
+	cur *hatypes.TCPBackend
+}
+
+type tcpEPPair struct {
+	old *hatypes.TCPEndpoint
+	cur *hatypes.TCPEndpoint
+}
+
 func (i *instance) newDynUpdater() *dynUpdater {
 	return &dynUpdater{
 		logger:  i.logger,
@@ -58,6 +71,9 @@ func (i *instance) newDynUpdater() *dynUpdater {
 }
 
 func (d *dynUpdater) update() bool {
+	if !d.config.hasCommittedData() {
+		d.reasons.add(ReloadFullSync, "", "")
+	}
 	updated := d.config.hasCommittedData() && d.checkConfigChange()
 	if !updated {
 		// Need to reload, time to adjust empty slots according to config
@@ -78,15 +94,18 @@ func (d *dynUpdater) checkConfigChange() bool {
 	var diff []string
 	if d.config.globalOld != nil && !reflect.DeepEqual(d.config.globalOld, d.config.global) {
 		diff = append(diff, "global")
+		d.reasons.add(ReloadGlobal, "", "")
 	}
-	if d.config.tcpbackends.Changed() {
+	if d.config.tcpbackends.Changed() && !d.checkTCPBackends() {
 		diff = append(diff, "tcp-services")
 	}
 	if d.config.hosts.Changed() {
 		diff = append(diff, "hosts")
+		d.addHostsReason()
 	}
 	if d.config.userlists.Changed() {
 		diff = append(diff, "userlists")
+		d.addUserlistsReason()
 	}
 	if len(diff) > 0 {
 		d.logger.InfoV(2, "diff outside backends: %v", diff)
@@ -102,7 +121,8 @@ func (d *dynUpdater) checkConfigChange() bool {
 	for _, backend := range d.config.backends.ItemsAdd() {
 		back, found := backends[backend.ID]
 		if !found {
-			d.logger.InfoV(2, "added backend '%s'", backend.ID)
+			d.logger.InfoV(2, "added backend '%s'", types.LogBackend(backend.ID))
+			d.reasons.add(ReloadNewBackend, backend.Namespace, backend.ID)
 			updated = false
 		} else {
 			back.cur = backend
@@ -136,13 +156,15 @@ func (d *dynUpdater) checkBackendPair(pair *backendPair) bool {
 	oldBackCopy.Dynamic = curBack.Dynamic
 	oldBackCopy.Endpoints = curBack.Endpoints
 	if !reflect.DeepEqual(&oldBackCopy, curBack) {
-		d.logger.InfoV(2, "diff outside endpoints of backend '%s'", curBack.ID)
+		d.logger.InfoV(2, "diff outside endpoints of backend '%s'", types.LogBackend(curBack.ID))
+		d.reasons.add(ReloadBackendDiff, curBack.Namespace, curBack.ID)
 		updated = false
 	}
 
 	// can decrease endpoints, cannot increase
 	if len(oldBack.Endpoints) < len(curBack.Endpoints) {
-		d.logger.InfoV(2, "added endpoints on backend '%s'", curBack.ID)
+		d.logger.InfoV(2, "added endpoints on backend '%s'", types.LogBackend(curBack.ID))
+		d.reasons.add(ReloadEndpointGrowth, curBack.Namespace, curBack.ID)
 		// cannot continue -- missing empty slots in the backend
 		return false
 	}
@@ -156,7 +178,8 @@ func (d *dynUpdater) checkBackendPair(pair *backendPair) bool {
 	// TODO check if endpoints are the same and only the order differ
 	if !curBack.Dynamic.DynUpdate {
 		if updated && !reflect.DeepEqual(oldBack.Endpoints, curBack.Endpoints) {
-			d.logger.InfoV(2, "backend '%s' changed and its dynamic-scaling is 'false'", curBack.ID)
+			d.logger.InfoV(2, "backend '%s' changed and its dynamic-scaling is 'false'", types.LogBackend(curBack.ID))
+			d.reasons.add(ReloadEndpointUpdate, curBack.Namespace, curBack.ID)
 			return false
 		}
 		return updated
@@ -190,6 +213,7 @@ func (d *dynUpdater) checkBackendPair(pair *backendPair) bool {
 	// Try to dynamically remove/update/add endpoints.
 	// Targets being used here only to have predictable results (tests).
 	// Endpoint.Label != "" means use-server of blue/green config, need reload
+	epUpdated := true
 	sort.Strings(targets)
 	for _, target := range targets {
 		pair := endpoints[target]
@@ -200,20 +224,24 @@ func (d *dynUpdater) checkBackendPair(pair *backendPair) bool {
 		}
 		if pair.cur == nil {
 			if !d.execDisableEndpoint(curBack.ID, pair.old) || pair.old.Label != "" {
-				updated = false
+				epUpdated = false
 			}
 			empty = append(empty, pair.old.Name)
 		} else if !d.checkEndpointPair(curBack.ID, pair) {
-			updated = false
+			epUpdated = false
 		}
 	}
 	for i := range added {
 		// reusing empty slots from oldBack
 		added[i].Name = empty[i]
 		if !d.execEnableEndpoint(curBack.ID, nil, added[i]) || added[i].Label != "" {
-			updated = false
+			epUpdated = false
 		}
 	}
+	if !epUpdated {
+		d.reasons.add(ReloadEndpointUpdate, curBack.Namespace, curBack.ID)
+		updated = false
+	}
 
 	// copy remaining empty slots from oldBack to curBack, so it can be used in a future update
 	for i := len(added); i < len(empty); i++ {
@@ -235,42 +263,266 @@ func (d *dynUpdater) checkEndpointPair(backname string, pair *epPair) bool {
 	return true
 }
 
+func (d *dynUpdater) checkTCPBackends() bool {
+	// same approach of backends: group old and new tcp backends
+	// together and try to dynamically update each of them. A tcp
+	// service on a new public port or a removed one cannot be
+	// dynamically updated, so a reload is needed.
+	updated := true
+	backends := make(map[int]*tcpBackendPair, len(d.config.tcpbackends.ItemsDel()))
+	for port, backend := range d.config.tcpbackends.ItemsDel() {
+		backends[port] = &tcpBackendPair{old: backend}
+	}
+	for port, backend := range d.config.tcpbackends.ItemsAdd() {
+		back, found := backends[port]
+		if !found {
+			d.logger.InfoV(2, "added tcp service on port '%d'", port)
+			d.addTCPServiceReason(backend)
+			updated = false
+		} else {
+			back.cur = backend
+		}
+	}
+	ports := make([]int, 0, len(backends))
+	for port, pair := range backends {
+		if pair.cur == nil {
+			d.logger.InfoV(2, "removed tcp service on port '%d'", port)
+			d.addTCPServiceReason(pair.old)
+			updated = false
+		} else {
+			ports = append(ports, port)
+		}
+	}
+	sort.Ints(ports)
+	for _, port := range ports {
+		if !d.checkTCPBackendPair(backends[port]) {
+			d.addTCPServiceReason(backends[port].cur)
+			updated = false
+		}
+	}
+	return updated
+}
+
+func (d *dynUpdater) checkTCPBackendPair(pair *tcpBackendPair) bool {
+	oldBack := pair.old
+	curBack := pair.cur
+	backname := curBack.ProxyName()
+	updated := true
+
+	// check equality of everything but endpoints
+	oldBackCopy := *oldBack
+	oldBackCopy.Dynamic = curBack.Dynamic
+	oldBackCopy.Endpoints = curBack.Endpoints
+	if !reflect.DeepEqual(&oldBackCopy, curBack) {
+		d.logger.InfoV(2, "diff outside endpoints of tcp service '%s'", types.LogBackend(backname))
+		updated = false
+	}
+
+	// can decrease endpoints, cannot increase
+	if len(oldBack.Endpoints) < len(curBack.Endpoints) {
+		d.logger.InfoV(2, "added endpoints on tcp service '%s'", types.LogBackend(backname))
+		return false
+	}
+
+	if !curBack.Dynamic.DynUpdate {
+		if updated && !reflect.DeepEqual(oldBack.Endpoints, curBack.Endpoints) {
+			d.logger.InfoV(2, "tcp service '%s' changed and its dynamic-scaling is 'false'", types.LogBackend(backname))
+			return false
+		}
+		return updated
+	}
+
+	// map endpoints of old and new config together
+	endpoints := make(map[string]*tcpEPPair, len(oldBack.Endpoints))
+	targets := make([]string, 0, len(oldBack.Endpoints))
+	var empty []string
+	for _, endpoint := range oldBack.Endpoints {
+		if endpoint.Enabled {
+			endpoints[endpoint.Target] = &tcpEPPair{old: endpoint}
+			targets = append(targets, endpoint.Target)
+		} else {
+			empty = append(empty, endpoint.Name)
+		}
+	}
+
+	// reuse the server which has the same target endpoint
+	var added []*hatypes.TCPEndpoint
+	for _, endpoint := range curBack.Endpoints {
+		if pair, found := endpoints[endpoint.Target]; found {
+			pair.cur = endpoint
+			pair.cur.Name = pair.old.Name
+		} else {
+			added = append(added, endpoint)
+		}
+	}
+
+	sort.Strings(targets)
+	for _, target := range targets {
+		pair := endpoints[target]
+		if pair.cur == nil && len(added) > 0 {
+			pair.cur = added[0]
+			pair.cur.Name = pair.old.Name
+			added = added[1:]
+			if !d.execEnableTCPEndpoint(curBack, pair.old, pair.cur) {
+				updated = false
+			}
+		} else if pair.cur == nil {
+			if !d.execDisableTCPEndpoint(backname, pair.old) {
+				updated = false
+			}
+			empty = append(empty, pair.old.Name)
+		}
+	}
+	for i := range added {
+		// reusing empty slots from oldBack
+		added[i].Name = empty[i]
+		if !d.execEnableTCPEndpoint(curBack, nil, added[i]) {
+			updated = false
+		}
+	}
+
+	// copy remaining empty slots from oldBack to curBack, so it can be used in a future update
+	for i := len(added); i < len(empty); i++ {
+		curBack.AddEmptyEndpoint().Name = empty[i]
+	}
+	curBack.SortEndpoints()
+
+	return updated
+}
+
 func (d *dynUpdater) alignSlots() {
 	for _, back := range d.config.Backends().Items() {
 		if !back.Dynamic.DynUpdate {
 			// no need to add empty slots if won't dynamically update
 			continue
 		}
-		minFreeSlots := back.Dynamic.MinFreeSlots
-		blockSize := back.Dynamic.BlockSize
-		if blockSize < 1 {
-			blockSize = 1
-		}
-		var newFreeSlots int
-		if minFreeSlots == 0 && len(back.Endpoints) == 0 {
-			newFreeSlots = blockSize
-		} else {
-			totalFreeSlots := 0
-			for _, ep := range back.Endpoints {
-				if ep.IsEmpty() {
-					totalFreeSlots++
-				}
+		totalFreeSlots := 0
+		for _, ep := range back.Endpoints {
+			if ep.IsEmpty() {
+				totalFreeSlots++
 			}
-			for i := totalFreeSlots; i < minFreeSlots; i++ {
-				back.AddEmptyEndpoint()
+		}
+		for i := emptySlots(back.Dynamic, len(back.Endpoints), totalFreeSlots); i > 0; i-- {
+			back.AddEmptyEndpoint()
+		}
+	}
+	for _, back := range d.config.TCPBackends().Items() {
+		if !back.Dynamic.DynUpdate {
+			continue
+		}
+		totalFreeSlots := 0
+		for _, ep := range back.Endpoints {
+			if !ep.Enabled {
+				totalFreeSlots++
 			}
-			// * []endpoints == group of blocks
-			// * block == group of slots
-			// * slot == a single server
-			// newFreeSlots := blockSize - (1 <= <size-of-last-block> <= blockSize)
-			newFreeSlots = blockSize - (((len(back.Endpoints) + blockSize - 1) % blockSize) + 1)
 		}
-		for i := 0; i < newFreeSlots; i++ {
+		for i := emptySlots(back.Dynamic, len(back.Endpoints), totalFreeSlots); i > 0; i-- {
 			back.AddEmptyEndpoint()
 		}
 	}
 }
 
+// emptySlots calculates the number of empty slots that should be added
+// in a backend with totalSlots servers, totalFreeSlots of them empty.
+func emptySlots(dynamic hatypes.DynBackendConfig, totalSlots, totalFreeSlots int) int {
+	minFreeSlots := dynamic.MinFreeSlots
+	blockSize := dynamic.BlockSize
+	if blockSize < 1 {
+		blockSize = 1
+	}
+	if minFreeSlots == 0 && totalSlots == 0 {
+		return blockSize
+	}
+	var newSlots int
+	if totalFreeSlots < minFreeSlots {
+		newSlots = minFreeSlots - totalFreeSlots
+	}
+	// * []endpoints == group of blocks
+	// * block == group of slots
+	// * slot == a single server
+	// newFreeSlots := blockSize - (1 <= <size-of-last-block> <= blockSize)
+	newFreeSlots := blockSize - (((totalSlots + newSlots + blockSize - 1) % blockSize) + 1)
+	return newSlots + newFreeSlots
+}
+
+func (d *dynUpdater) addHostsReason() {
+	hostsAdd := d.config.hosts.ItemsAdd()
+	hostsDel := d.config.hosts.ItemsDel()
+	var hostnames []string
+	for hostname, host := range hostsAdd {
+		if !reflect.DeepEqual(host, hostsDel[hostname]) {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	for hostname := range hostsDel {
+		if _, found := hostsAdd[hostname]; !found {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	sort.Strings(hostnames)
+	for _, hostname := range hostnames {
+		// a host is attributed to the namespaces of the backends of its paths
+		namespaces := map[string]bool{}
+		for _, host := range []*hatypes.Host{hostsAdd[hostname], hostsDel[hostname]} {
+			if host != nil {
+				for _, path := range host.Paths {
+					if path.Backend.Namespace != "" {
+						namespaces[path.Backend.Namespace] = true
+					}
+				}
+			}
+		}
+		if len(namespaces) == 0 {
+			d.reasons.add(ReloadHosts, "", hostname)
+			continue
+		}
+		nslist := make([]string, 0, len(namespaces))
+		for ns := range namespaces {
+			nslist = append(nslist, ns)
+		}
+		sort.Strings(nslist)
+		for _, ns := range nslist {
+			d.reasons.add(ReloadHosts, ns, hostname)
+		}
+	}
+}
+
+func (d *dynUpdater) addUserlistsReason() {
+	userlistsAdd := d.config.userlists.ItemsAdd()
+	userlistsDel := d.config.userlists.ItemsDel()
+	var names []string
+	for name, userlist := range userlistsAdd {
+		if !reflect.DeepEqual(userlist, userlistsDel[name]) {
+			names = append(names, name)
+		}
+	}
+	for name := range userlistsDel {
+		if _, found := userlistsAdd[name]; !found {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// userlists are named after their secret, namespace_secretname
+		d.reasons.add(ReloadUserlists, splitNamespace(name), name)
+	}
+}
+
+func (d *dynUpdater) addTCPServiceReason(backend *hatypes.TCPBackend) {
+	// tcp services are named as namespace_servicename
+	d.reasons.add(ReloadTCPServices, splitNamespace(backend.Name), backend.Name)
+}
+
+// splitNamespace returns the namespace of a `namespace_name` formatted
+// name. Namespaces cannot have underscores, so the first one is the
+// separator.
+func splitNamespace(name string) string {
+	if i := strings.Index(name, "_"); i > 0 {
+		return name[:i]
+	}
+	return ""
+}
+
 func (d *dynUpdater) execDisableEndpoint(backname string, ep *hatypes.Endpoint) bool {
 	server := fmt.Sprintf("set server %s/%s ", backname, ep.Name)
 	cmd := []string{
@@ -280,10 +532,10 @@ func (d *dynUpdater) execDisableEndpoint(backname string, ep *hatypes.Endpoint)
 	}
 	msg, err := d.execCommand(d.metrics.HAProxySetServerResponseTime, cmd)
 	if err != nil {
-		d.logger.Error("error disabling endpoint %s/%s: %v", backname, ep.Name, err)
+		d.logger.Error("error disabling endpoint %s/%s: %v", types.LogBackend(backname), types.LogServer(ep.Name), err)
 		return false
 	}
-	d.logger.InfoV(2, "disabled endpoint '%s' on backend/server '%s/%s'", ep.Target, backname, ep.Name)
+	d.logger.InfoV(2, "disabled endpoint '%s' on backend/server '%s/%s'", ep.Target, types.LogBackend(backname), types.LogServer(ep.Name))
 	for _, m := range msg {
 		d.logger.InfoV(2, m)
 	}
@@ -300,12 +552,53 @@ func (d *dynUpdater) execEnableEndpoint(backname string, oldEP, curEP *hatypes.E
 	}
 	msg, err := d.execCommand(d.metrics.HAProxySetServerResponseTime, cmd)
 	if err != nil {
-		d.logger.Error("error adding/updating endpoint %s/%s: %v", backname, curEP.Name, err)
+		d.logger.Error("error adding/updating endpoint %s/%s: %v", types.LogBackend(backname), types.LogServer(curEP.Name), err)
 		return false
 	}
 	event := map[bool]string{true: "updated", false: "added"}[oldEP != nil]
 	d.logger.InfoV(2, "%s endpoint '%s' weight '%d' state '%s' on backend/server '%s/%s'",
-		event, curEP.Target, curEP.Weight, state, backname, curEP.Name)
+		event, curEP.Target, curEP.Weight, state, types.LogBackend(backname), types.LogServer(curEP.Name))
+	for _, m := range msg {
+		d.logger.InfoV(2, m)
+	}
+	return true
+}
+
+func (d *dynUpdater) execDisableTCPEndpoint(backname string, ep *hatypes.TCPEndpoint) bool {
+	server := fmt.Sprintf("set server %s/%s ", backname, ep.Name)
+	cmd := []string{
+		server + "state maint",
+		server + "addr 127.0.0.1 port 1023",
+	}
+	msg, err := d.execCommand(d.metrics.HAProxySetServerResponseTime, cmd)
+	if err != nil {
+		d.logger.Error("error disabling endpoint %s/%s: %v", types.LogBackend(backname), types.LogServer(ep.Name), err)
+		return false
+	}
+	d.logger.InfoV(2, "disabled endpoint '%s' on tcp service/server '%s/%s'", ep.Target, types.LogBackend(backname), types.LogServer(ep.Name))
+	for _, m := range msg {
+		d.logger.InfoV(2, m)
+	}
+	return true
+}
+
+func (d *dynUpdater) execEnableTCPEndpoint(backend *hatypes.TCPBackend, oldEP, curEP *hatypes.TCPEndpoint) bool {
+	backname := backend.ProxyName()
+	server := fmt.Sprintf("set server %s/%s ", backname, curEP.Name)
+	cmd := []string{
+		server + "addr " + curEP.IP + " port " + strconv.Itoa(curEP.Port),
+	}
+	if backend.CheckInterval != "" {
+		cmd = append(cmd, server+"check-port "+strconv.Itoa(curEP.Port))
+	}
+	cmd = append(cmd, server+"state ready")
+	msg, err := d.execCommand(d.metrics.HAProxySetServerResponseTime, cmd)
+	if err != nil {
+		d.logger.Error("error adding/updating endpoint %s/%s: %v", types.LogBackend(backname), types.LogServer(curEP.Name), err)
+		return false
+	}
+	event := map[bool]string{true: "updated", false: "added"}[oldEP != nil]
+	d.logger.InfoV(2, "%s endpoint '%s' on tcp service/server '%s/%s'", event, curEP.Target, types.LogBackend(backname), types.LogServer(curEP.Name))
 	for _, m := range msg {
 		d.logger.InfoV(2, m)
 	}
@@ -315,5 +608,6 @@ func (d *dynUpdater) execEnableEndpoint(backname string, oldEP, curEP *hatypes.E
 func (d *dynUpdater) execCommand(observer func(duration time.Duration), cmd []string) ([]string, error) {
 	msg, err := d.cmd(d.socket, observer, cmd...)
 	d.cmdCnt = d.cmdCnt + len(cmd)
+	d.cmds = append(d.cmds, cmd...)
 	return msg, err
 }
@@ -26,6 +26,7 @@ import (
 	"github.com/kylelemons/godebug/diff"
 
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
 )
 
 func TestDynUpdate(t *testing.T) {
@@ -624,7 +625,428 @@ set server default_app_8080/srv002 weight 1`,
 		if cmd != test.cmd {
 			t.Errorf("cmd differs on %d:\n%s", i, diff.Diff(test.cmd, cmd))
 		}
+		if cmds := strings.Join(dynUpdater.cmds, "\n"); cmds != cmd {
+			t.Errorf("sent commands differs on %d:\n%s", i, diff.Diff(cmd, cmds))
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestDynUpdateTCP(t *testing.T) {
+	testCases := []struct {
+		doconfig1 func(c *testConfig)
+		doconfig2 func(c *testConfig)
+		expected  []string
+		dynamic   bool
+		cmd       string
+		logging   string
+	}{
+		// 0
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("default_pg", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("default_pg", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+			},
+			expected: []string{
+				"srv001:172.17.0.2:5432",
+			},
+			dynamic: true,
+		},
+		// 1
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("default_pg", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("default_pg", 5432)
+				b.AddEndpoint("172.17.0.3", 5432)
+			},
+			expected: []string{
+				"srv001:172.17.0.3:5432",
+			},
+			dynamic: false,
+			logging: `
+INFO-V(2) tcp service '_tcp_default_pg_5432' changed and its dynamic-scaling is 'false'
+INFO-V(2) diff outside backends: [tcp-services]`,
+		},
+		// 2
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("default_pg", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.AddEndpoint("172.17.0.3", 5432)
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("default_pg", 5432)
+				b.Dynamic.DynUpdate = true
+				b.CheckInterval = "2s"
+				b.AddEndpoint("172.17.0.3", 5432)
+				b.AddEndpoint("172.17.0.4", 5432)
+			},
+			expected: []string{
+				"srv001:172.17.0.4:5432",
+				"srv002:172.17.0.3:5432",
+			},
+			dynamic: false,
+			cmd: `
+set server _tcp_default_pg_5432/srv001 addr 172.17.0.4 port 5432
+set server _tcp_default_pg_5432/srv001 check-port 5432
+set server _tcp_default_pg_5432/srv001 state ready`,
+			logging: `
+INFO-V(2) diff outside endpoints of tcp service '_tcp_default_pg_5432'
+INFO-V(2) updated endpoint '172.17.0.4:5432' on tcp service/server '_tcp_default_pg_5432/srv001'
+INFO-V(2) diff outside backends: [tcp-services]`,
+		},
+		// 3
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("default_pg", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.AddEndpoint("172.17.0.3", 5432)
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("default_pg", 5432)
+				b.Dynamic.DynUpdate = true
+				b.AddEndpoint("172.17.0.3", 5432)
+			},
+			expected: []string{
+				"srv001:127.0.0.1:1023",
+				"srv002:172.17.0.3:5432",
+			},
+			dynamic: true,
+			cmd: `
+set server _tcp_default_pg_5432/srv001 state maint
+set server _tcp_default_pg_5432/srv001 addr 127.0.0.1 port 1023`,
+			logging: `INFO-V(2) disabled endpoint '172.17.0.2:5432' on tcp service/server '_tcp_default_pg_5432/srv001'`,
+		},
+		// 4
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("default_pg", 5432)
+				b.Dynamic.DynUpdate = true
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.AddEmptyEndpoint()
+				b.AddEmptyEndpoint()
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("default_pg", 5432)
+				b.Dynamic.DynUpdate = true
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.AddEndpoint("172.17.0.3", 5432)
+			},
+			expected: []string{
+				"srv001:172.17.0.2:5432",
+				"srv002:172.17.0.3:5432",
+				"srv003:127.0.0.1:1023",
+			},
+			dynamic: true,
+			cmd: `
+set server _tcp_default_pg_5432/srv002 addr 172.17.0.3 port 5432
+set server _tcp_default_pg_5432/srv002 state ready`,
+			logging: `INFO-V(2) added endpoint '172.17.0.3:5432' on tcp service/server '_tcp_default_pg_5432/srv002'`,
+		},
+		// 5
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("default_pg", 5432)
+				b.Dynamic.DynUpdate = true
+				b.AddEndpoint("172.17.0.2", 5432)
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("default_pg", 5432)
+				b.Dynamic.DynUpdate = true
+				b.Dynamic.MinFreeSlots = 2
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.AddEndpoint("172.17.0.3", 5432)
+			},
+			expected: []string{
+				"srv001:172.17.0.2:5432",
+				"srv002:172.17.0.3:5432",
+				"srv003:127.0.0.1:1023",
+				"srv004:127.0.0.1:1023",
+			},
+			dynamic: false,
+			logging: `
+INFO-V(2) added endpoints on tcp service '_tcp_default_pg_5432'
+INFO-V(2) diff outside backends: [tcp-services]`,
+		},
+		// 6
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("default_pg", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("default_pg", 5433)
+				b.AddEndpoint("172.17.0.2", 5432)
+			},
+			expected: []string{},
+			dynamic:  false,
+			logging: `
+INFO-V(2) added tcp service on port '5433'
+INFO-V(2) removed tcp service on port '5432'
+INFO-V(2) diff outside backends: [tcp-services]`,
+		},
+		// 7
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("default_pg", 5432)
+				b.Dynamic.DynUpdate = true
+				b.AddEndpoint("127.0.0.1", 5432)
+				b.AddEmptyEndpoint()
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("default_pg", 5432)
+				b.Dynamic.DynUpdate = true
+				b.AddEndpoint("127.0.0.1", 5432)
+				b.AddEndpoint("172.17.0.3", 5432)
+			},
+			expected: []string{
+				"srv001:127.0.0.1:5432",
+				"srv002:172.17.0.3:5432",
+			},
+			dynamic: true,
+			cmd: `
+set server _tcp_default_pg_5432/srv002 addr 172.17.0.3 port 5432
+set server _tcp_default_pg_5432/srv002 state ready`,
+			logging: `INFO-V(2) added endpoint '172.17.0.3:5432' on tcp service/server '_tcp_default_pg_5432/srv002'`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		test.doconfig1(c)
+		c.instance.config.Commit()
+		c.config.TCPBackends().RemoveAll()
+		test.doconfig2(c)
+		var cmd string
+		dynUpdater := c.instance.newDynUpdater()
+		dynUpdater.cmd = func(socket string, observer func(duration time.Duration), command ...string) ([]string, error) {
+			for _, c := range command {
+				cmd = cmd + c + "\n"
+			}
+			return []string{}, nil
+		}
+		dynamic := dynUpdater.update()
+		actual := []string{}
+		if b := c.config.TCPBackends().Items()[5432]; b != nil {
+			for _, ep := range b.Endpoints {
+				actual = append(actual, fmt.Sprintf("%s:%s:%d", ep.Name, ep.IP, ep.Port))
+			}
+		}
+		if test.expected == nil {
+			test.expected = []string{}
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("endpoints expected and actual differs on %d -- expected: %v -- actual: %v",
+				i, test.expected, actual)
+		}
+		if dynamic != test.dynamic {
+			t.Errorf("dynamic expected as '%t' on %d, but was '%t'", test.dynamic, i, dynamic)
+		}
+		cmd = strings.TrimSpace(cmd)
+		test.cmd = strings.TrimSpace(test.cmd)
+		if cmd != test.cmd {
+			t.Errorf("cmd differs on %d:\n%s", i, diff.Diff(test.cmd, cmd))
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestDynUpdateReloadReasons(t *testing.T) {
+	testCases := []struct {
+		doconfig1 func(c *testConfig)
+		doconfig2 func(c *testConfig)
+		reasons   string
+		logging   string
+	}{
+		// 0
+		{
+			doconfig2: func(c *testConfig) {
+				c.config.Global().MaxConn = 1
+			},
+			reasons: "global",
+			logging: `INFO-V(2) diff outside backends: [global]`,
+		},
+		// 1
+		{
+			doconfig2: func(c *testConfig) {
+				c.config.Backends().AcquireBackend("default", "app", "8080")
+			},
+			reasons: "new-backend: default/default_app_8080",
+			logging: `INFO-V(2) added backend 'default_app_8080'`,
+		},
+		// 2
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+			},
+			reasons: "endpoint-growth: default/default_app_8080",
+			logging: `INFO-V(2) added endpoints on backend 'default_app_8080'`,
+		},
+		// 3
+		{
+			doconfig1: func(c *testConfig) {
+				c.config.Backends().AcquireBackend("default", "app", "8080")
+				c.config.Userlists().Replace("team1_users", []types.User{{Name: "usr1", Passwd: "xxx"}})
+			},
+			doconfig2: func(c *testConfig) {
+				b1 := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b2 := c.config.Backends().AcquireBackend("team1", "app", "8080")
+				h := c.config.Hosts().AcquireHost("d1.local")
+				h.AddPath(b1, "/", types.MatchBegin)
+				h.AddPath(b2, "/app", types.MatchBegin)
+				c.config.Userlists().Replace("team1_users", []types.User{{Name: "usr1", Passwd: "yyy"}})
+			},
+			reasons: "hosts: default/d1.local, team1/d1.local; userlists: team1/team1_users; new-backend: team1/team1_app_8080; backend-diff: default/default_app_8080",
+			logging: `
+INFO-V(2) diff outside backends: [hosts userlists]
+INFO-V(2) added backend 'team1_app_8080'
+INFO-V(2) diff outside endpoints of backend 'default_app_8080'`,
+		},
+		// 4
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("default_pg", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("default_pg", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				c.config.TCPBackends().Acquire("team1_redis", 6379)
+			},
+			reasons: "tcp-services: team1/team1_redis",
+			logging: `
+INFO-V(2) added tcp service on port '6379'
+INFO-V(2) diff outside backends: [tcp-services]`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		if test.doconfig1 != nil {
+			test.doconfig1(c)
+		}
+		c.instance.config.Commit()
+		backendIDs := []types.BackendID{}
+		for _, backend := range c.config.Backends().Items() {
+			if backend != c.config.Backends().DefaultBackend() {
+				backendIDs = append(backendIDs, backend.BackendID())
+			}
+		}
+		c.config.Backends().RemoveAll(backendIDs)
+		c.config.TCPBackends().RemoveAll()
+		if test.doconfig2 != nil {
+			test.doconfig2(c)
+		}
+		dynUpdater := c.instance.newDynUpdater()
+		dynUpdater.cmd = func(socket string, observer func(duration time.Duration), command ...string) ([]string, error) {
+			return []string{}, nil
+		}
+		dynUpdater.update()
+		var reasons []string
+		for _, reason := range dynUpdater.reasons.reasons {
+			var objs []string
+			for _, obj := range reason.Objects {
+				objs = append(objs, obj.Namespace+"/"+obj.Name)
+			}
+			if len(objs) > 0 {
+				reasons = append(reasons, reason.Reason+": "+strings.Join(objs, ", "))
+			} else {
+				reasons = append(reasons, reason.Reason)
+			}
+		}
+		if actual := strings.Join(reasons, "; "); actual != test.reasons {
+			t.Errorf("reasons differ on %d -- expected: %s -- actual: %s", i, test.reasons, actual)
+		}
 		c.logger.CompareLogging(test.logging)
 		c.teardown()
 	}
 }
+
+func TestReloadHistory(t *testing.T) {
+	testCases := []struct {
+		count int
+		exp   []int
+	}{
+		// 0
+		{
+			count: 0,
+			exp:   []int{},
+		},
+		// 1
+		{
+			count: 3,
+			exp:   []int{3, 2, 1},
+		},
+		// 2
+		{
+			count: reloadHistorySize + 2,
+			exp:   []int{reloadHistorySize + 2, reloadHistorySize + 1, reloadHistorySize},
+		},
+	}
+	for i, test := range testCases {
+		h := &reloadHistory{}
+		for j := 1; j <= test.count; j++ {
+			h.add(&Reload{Time: time.Unix(int64(j), 0)})
+		}
+		items := h.items()
+		expLen := test.count
+		if expLen > reloadHistorySize {
+			expLen = reloadHistorySize
+		}
+		if len(items) != expLen {
+			t.Errorf("length differs on %d -- expected: %d -- actual: %d", i, expLen, len(items))
+			continue
+		}
+		actual := []int{}
+		for _, item := range items {
+			if len(actual) == len(test.exp) {
+				break
+			}
+			actual = append(actual, int(item.Time.Unix()))
+		}
+		if !reflect.DeepEqual(actual, test.exp) {
+			t.Errorf("newest reloads differ on %d -- expected: %v -- actual: %v", i, test.exp, actual)
+		}
+	}
+}
+
+type reloadMetricsMock struct {
+	*helper_test.MetricsMock
+	namespaces []string
+}
+
+func (m *reloadMetricsMock) IncReloadNamespace(reason, namespace string) {
+	m.namespaces = append(m.namespaces, reason+":"+namespace)
+}
+
+func TestReloadMetrics(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+	metrics := &reloadMetricsMock{MetricsMock: helper_test.NewMetricsMock()}
+	c.instance.metrics = metrics
+	c.instance.addReload([]*ReloadReason{
+		{Reason: ReloadFullSync},
+		{Reason: ReloadBackendDiff, Objects: []*ReloadObject{
+			{Namespace: "team1", Name: "app1_8080"},
+			{Namespace: "team2", Name: "app_8080"},
+			{Namespace: "team1", Name: "app2_8080"},
+		}},
+	}, true)
+	expected := []string{"backend-diff:team1", "backend-diff:team2"}
+	if !reflect.DeepEqual(metrics.namespaces, expected) {
+		t.Errorf("reload namespaces differ -- expected: %v -- actual: %v", expected, metrics.namespaces)
+	}
+}
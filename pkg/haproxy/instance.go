@@ -24,11 +24,16 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/acme"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/crl"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/grpchealth"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/template"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 	hautils "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/utils"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/ocsp"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 )
@@ -38,14 +43,19 @@ type InstanceOptions struct {
 	AcmeSigner        acme.Signer
 	AcmeQueue         utils.Queue
 	BackendShards     int
+	CRLUpdater        crl.Updater
+	GRPCHealthAgent   grpchealth.Agent
 	HAProxyCmd        string
 	HAProxyCfgDir     string
 	HAProxyMapsDir    string
 	LeaderElector     types.LeaderElector
 	MaxOldConfigFiles int
 	Metrics           types.Metrics
+	OCSPChecker       ocsp.Checker
+	OCSPStapler       ocsp.Stapler
 	ReloadCmd         string
 	ReloadStrategy    string
+	StatsMaxObjects   int
 	ValidateConfig    bool
 }
 
@@ -54,7 +64,10 @@ type Instance interface {
 	AcmeCheck(source string) (int, error)
 	ParseTemplates() error
 	Config() Config
+	DynUpdateCommands() []string
+	Reloads() []*Reload
 	CalcIdleMetric()
+	CollectStats(locker sync.Locker)
 	Update(timer *utils.Timer)
 }
 
@@ -79,6 +92,15 @@ type instance struct {
 	modsecTmpl  *template.Config
 	config      Config
 	metrics     types.Metrics
+	// names of the acme storages of the last update, and the
+	// cleanup items of the orphan ones, indexed by storage name
+	acmeStorages map[string]bool
+	acmeOrphans  map[string]string
+	// commands sent to the admin socket on the last update
+	dynCommands []string
+	reloads     reloadHistory
+	// number of objects not exported by the last stats collect
+	statsDropped int
 }
 
 func (i *instance) AcmeCheck(source string) (int, error) {
@@ -114,8 +136,15 @@ func (i *instance) AcmeCheck(source string) (int, error) {
 
 func (i *instance) acmeEnsureConfig(acmeConfig *hatypes.AcmeData) bool {
 	signer := i.options.AcmeSigner
-	signer.AcmeConfig(acmeConfig.Expiring)
-	signer.AcmeAccount(acmeConfig.Endpoint, acmeConfig.Emails, acmeConfig.TermsAgreed)
+	signer.AcmeConfig(acmeConfig.Expiring, acmeConfig.ExpiringRatio, acmeConfig.PreferredChain)
+	signer.AcmeAccount(acme.Account{
+		Emails:      acmeConfig.Emails,
+		Endpoint:    acmeConfig.Endpoint,
+		TermsAgreed: acmeConfig.TermsAgreed,
+		EABKeyID:    acmeConfig.EABKeyID,
+		EABHMACKey:  acmeConfig.EABHMACKey,
+		CABundle:    acmeConfig.CABundle,
+	})
 	return signer.HasAccount()
 }
 
@@ -178,6 +207,16 @@ func (i *instance) Config() Config {
 
 var idleRegex = regexp.MustCompile(`Idle_pct: ([0-9]+)`)
 
+func (i *instance) DynUpdateCommands() []string {
+	return i.dynCommands
+}
+
+// Reloads returns the last haproxy reloads and their reasons, newest
+// first. Reloads is safe to be called concurrently with Update().
+func (i *instance) Reloads() []*Reload {
+	return i.reloads.items()
+}
+
 func (i *instance) CalcIdleMetric() {
 	if !i.up {
 		return
@@ -221,9 +260,60 @@ func (i *instance) acmeUpdate() {
 		for _, del := range storages.BuildAcmeStoragesDel() {
 			i.acmeRemoveStorage(del)
 		}
-	} else if storages.Updated() {
-		i.logger.InfoV(2, "skipping acme update check, leader is %s", le.LeaderName())
+		i.acmeUpdateOrphans(i.config.AcmeData())
+	} else {
+		// the queue is cleared when the leadership is lost, the next
+		// leadership starts the orphan candidates from the signed secrets
+		i.acmeStorages = nil
+		i.acmeOrphans = nil
+		if storages.Updated() {
+			i.logger.InfoV(2, "skipping acme update check, leader is %s", le.LeaderName())
+		}
+	}
+}
+
+// acmeUpdateOrphans schedules the orphan actions of the storages removed
+// since the last update, and cancels the ones whose storage is in use again.
+// The secrets signed by the controller are used as the former storages on
+// the first update of a leadership, so the storages removed while the
+// controller was stopped or wasn't the leader are also found.
+func (i *instance) acmeUpdateOrphans(acmeData *hatypes.AcmeData) {
+	names := map[string]bool{}
+	for _, name := range acmeData.Storages().Names() {
+		names[name] = true
+	}
+	if i.acmeStorages == nil && i.options.AcmeSigner != nil {
+		i.acmeStorages = map[string]bool{}
+		for _, name := range i.options.AcmeSigner.SignedSecrets() {
+			i.acmeStorages[name] = true
+		}
 	}
+	for name, item := range i.acmeOrphans {
+		if names[name] {
+			i.logger.Info("acme: canceling orphan actions of secret %s, it is in use again", name)
+			i.options.AcmeQueue.Remove(item)
+			delete(i.acmeOrphans, name)
+		}
+	}
+	if len(acmeData.OrphanActions) > 0 && i.acmeStorages != nil {
+		for name := range i.acmeStorages {
+			if names[name] {
+				continue
+			}
+			if i.acmeOrphans == nil {
+				i.acmeOrphans = map[string]string{}
+			}
+			// since makes the item unique, so a former removal of the same
+			// storage doesn't prevent it from being scheduled again
+			item := fmt.Sprintf("%s,orphan=%s,since=%d",
+				name, strings.Join(acmeData.OrphanActions, ",orphan="), time.Now().Unix())
+			i.logger.Info("acme: secret %s is not used anymore, scheduling orphan actions in %s: %s",
+				name, acmeData.OrphanGracePeriod, strings.Join(acmeData.OrphanActions, ","))
+			i.options.AcmeQueue.AddAfter(item, acmeData.OrphanGracePeriod)
+			i.acmeOrphans[name] = item
+		}
+	}
+	i.acmeStorages = names
 }
 
 func (i *instance) haproxyUpdate(timer *utils.Timer) {
@@ -238,6 +328,7 @@ func (i *instance) haproxyUpdate(timer *utils.Timer) {
 	//   - i.metrics.UpdateSuccessful(<bool>) should be called only if haproxy is reloaded or cfg is validated
 	//
 	defer i.config.Commit()
+	i.dynCommands = nil
 	i.config.SyncConfig()
 	i.config.Shrink()
 	if err := i.config.WriteFrontendMaps(); err != nil {
@@ -255,8 +346,13 @@ func (i *instance) haproxyUpdate(timer *utils.Timer) {
 		// TODO update tests and remove `if cmd!=""` above
 		i.logChanged()
 	}
+	i.ocspUpdate()
+	i.authTLSUpdate()
 	updater := i.newDynUpdater()
 	updated := updater.update()
+	i.dynCommands = updater.cmds
+	// dynUpdater changes the endpoints, so the agent should be updated afterwards
+	i.grpcHealthUpdate()
 	if !updated || updater.cmdCnt > 0 {
 		// only need to rewrtite config files if:
 		//   - !updated           - there are changes that cannot be dynamically applied
@@ -289,7 +385,9 @@ func (i *instance) haproxyUpdate(timer *utils.Timer) {
 	}
 	i.updateCertExpiring()
 	i.metrics.IncUpdateFull()
-	if err := i.reload(); err != nil {
+	err := i.reload()
+	i.addReload(updater.reasons.reasons, err == nil)
+	if err != nil {
 		i.logger.Error("error reloading server:\n%v", err)
 		i.metrics.UpdateSuccessful(false)
 		return
@@ -300,6 +398,60 @@ func (i *instance) haproxyUpdate(timer *utils.Timer) {
 	timer.Tick("reload_haproxy")
 }
 
+// ocspUpdate configures the stapler with the certificate files of the hosts
+// that use OCSP stapling. This should happen before a reload, so the responses
+// of changed certificates are already removed from the disk.
+func (i *instance) ocspUpdate() {
+	if i.options.OCSPStapler == nil {
+		return
+	}
+	var crtFiles []string
+	for _, host := range i.config.Hosts().Items() {
+		if host.TLS.OCSPStapling && host.TLS.HasTLS() {
+			crtFiles = append(crtFiles, host.TLS.TLSFilename)
+			if host.TLS.TLSDualFilename != "" {
+				crtFiles = append(crtFiles, host.TLS.TLSDualFilename)
+			}
+		}
+	}
+	sort.Strings(crtFiles)
+	i.options.OCSPStapler.Update(i.config.Global().AdminSocket, crtFiles)
+}
+
+// authTLSUpdate configures the CRL updater and the OCSP checker with the
+// CA files of the hosts that refresh their CRLs or check the OCSP status
+// of client certificates.
+func (i *instance) authTLSUpdate() {
+	var crlFiles, ocspFiles []string
+	for _, host := range i.config.Hosts().Items() {
+		if host.TLS.CAFilename == "" {
+			continue
+		}
+		if host.TLS.CRLRefresh > 0 {
+			crlFiles = append(crlFiles, host.TLS.CAFilename)
+		}
+		if host.TLS.OCSPCheck {
+			ocspFiles = append(ocspFiles, host.TLS.CAFilename)
+		}
+	}
+	if i.options.CRLUpdater != nil {
+		sort.Strings(crlFiles)
+		i.options.CRLUpdater.Update(i.config.Global().AdminSocket, crlFiles)
+	}
+	if i.options.OCSPChecker != nil {
+		sort.Strings(ocspFiles)
+		i.options.OCSPChecker.Update(ocspFiles)
+	}
+}
+
+// grpcHealthUpdate configures the gRPC health agent with the current
+// backends and endpoints.
+func (i *instance) grpcHealthUpdate() {
+	if i.options.GRPCHealthAgent != nil {
+		i.options.GRPCHealthAgent.Update(i.config.Backends().BuildSortedItems())
+	}
+}
+
 func (i *instance) logChanged() {
 	hostsAdd := i.config.Hosts().ItemsAdd()
 	if len(hostsAdd) < 100 {
@@ -385,6 +537,26 @@ func (i *instance) writeConfig() (err error) {
 	return err
 }
 
+func (i *instance) addReload(reasons []*ReloadReason, success bool) {
+	i.reloads.add(&Reload{
+		Time:    time.Now(),
+		Success: success,
+		Reasons: reasons,
+	})
+	for _, reason := range reasons {
+		i.metrics.IncReloadReason(reason.Reason)
+		// object names are only found in the history, they would lead
+		// to an unbounded number of time series
+		namespaces := map[string]bool{}
+		for _, obj := range reason.Objects {
+			if !namespaces[obj.Namespace] {
+				namespaces[obj.Namespace] = true
+				i.metrics.IncReloadNamespace(reason.Reason, obj.Namespace)
+			}
+		}
+	}
+}
+
 func (i *instance) updateCertExpiring() {
 	// TODO move to dynupdate when dynamic crt update is implemented
 	hostsAdd := i.config.Hosts().ItemsAdd()
@@ -24,10 +24,12 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/kylelemons/godebug/diff"
 	yaml "gopkg.in/yaml.v2"
 
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/acme"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
@@ -465,6 +467,24 @@ d1.local/ path01`,
 			},
 			srvsuffix: "agent-check agent-port 8000 agent-inter 2s",
 		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				g.GRPCHealthAgent.Addr = "127.0.0.1"
+				g.GRPCHealthAgent.Port = 10260
+				b.Server.Protocol = "h2"
+				b.Server.GRPC = true
+				b.HealthCheck.GRPC = true
+				b.HealthCheck.Interval = "2s"
+			},
+			expected: `
+    errorfile 403 /etc/haproxy/errorfiles/grpc/403.http
+    errorfile 429 /etc/haproxy/errorfiles/grpc/429.http
+    errorfile 500 /etc/haproxy/errorfiles/grpc/500.http
+    errorfile 502 /etc/haproxy/errorfiles/grpc/502.http
+    errorfile 503 /etc/haproxy/errorfiles/grpc/503.http
+    errorfile 504 /etc/haproxy/errorfiles/grpc/504.http`,
+			srvsuffix: `proto h2 check inter 2s agent-check agent-addr 127.0.0.1 agent-port 10260 agent-inter 2s agent-send "d1_app_8080 s1\n"`,
+		},
 		{
 			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
 				b.Server.Secure = true
@@ -531,6 +551,25 @@ d1.local/ path01`,
 			},
 			srvsuffix: "proto h2 alpn h2 ssl verify required ca-file /var/haproxy/ssl/ca.pem",
 		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Server.Protocol = "h2"
+				b.Server.Secure = true
+				b.Server.ALPN = "h2,http/1.1"
+				b.Server.SNI = "var(req.host)"
+			},
+			srvsuffix: "proto h2 alpn h2,http/1.1 ssl verify none sni var(req.host)",
+		},
+		{
+			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
+				b.Server.Secure = true
+				b.Server.CAFilename = "/var/haproxy/ssl/ca.pem"
+				b.Server.SNI = "str(app.default.svc.cluster.local)"
+				b.Server.CheckSNI = "app.default.svc.cluster.local"
+				b.Server.VerifyHost = "app.default.svc.cluster.local"
+			},
+			srvsuffix: "ssl verify required ca-file /var/haproxy/ssl/ca.pem verifyhost app.default.svc.cluster.local sni str(app.default.svc.cluster.local) check-sni app.default.svc.cluster.local",
+		},
 		{
 			doconfig: func(g *hatypes.Global, h *hatypes.Host, b *hatypes.Backend) {
 				b.Limit.Connections = 200
@@ -810,6 +849,7 @@ global
     hard-stop-after 15m
     lua-load /etc/haproxy/lua/auth-request.lua
     lua-load /etc/haproxy/lua/services.lua
+    lua-load /etc/haproxy/lua/ocsp-check.lua
     ssl-dh-param-file /var/haproxy/tls/dhparam.pem
     ssl-default-bind-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
     ssl-default-bind-ciphersuites TLS_AES_128_GCM_SHA256
@@ -1352,6 +1392,47 @@ listen _tcp_pq_5432
     mode tcp
     server srv001 172.17.0.2:5432 send-proxy-v2`,
 		},
+		// 6
+		{
+			doconfig: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("pq", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.AddEndpoint("172.17.0.3", 5432)
+				b.BalanceAlgorithm = "leastconn"
+				b.CheckInterval = "2s"
+				b.MaxConn = 100
+				b.Timeout.Client = "1h"
+				b.Timeout.Server = "2h"
+				b.Whitelist = []string{"10.0.0.0/8", "192.168.0.0/16"}
+			},
+			expected: `
+listen _tcp_pq_5432
+    bind :5432
+    mode tcp
+    balance leastconn
+    timeout client 1h
+    timeout server 2h
+    acl wlist_src src 10.0.0.0/8 192.168.0.0/16
+    tcp-request connection reject if !wlist_src
+    server srv001 172.17.0.2:5432 check port 5432 inter 2s maxconn 100
+    server srv002 172.17.0.3:5432 check port 5432 inter 2s maxconn 100`,
+		},
+		// 7
+		{
+			doconfig: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("pq", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				b.Dynamic.DynUpdate = true
+				b.Dynamic.BlockSize = 2
+				b.CheckInterval = "2s"
+			},
+			expected: `
+listen _tcp_pq_5432
+    bind :5432
+    mode tcp
+    server srv001 172.17.0.2:5432 check port 5432 inter 2s
+    server srv002 127.0.0.1:1023 disabled check port 1023 inter 2s`,
+		},
 	}
 	for _, test := range testCases {
 		c := setup(t)
@@ -1649,6 +1730,77 @@ d2.local/app -
 	c.logger.CompareLogging(defaultLogging)
 }
 
+func TestInstanceDualCrt(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/d1.pem"
+	h.TLS.TLSDualFilename = "/var/haproxy/ssl/certs/d1-dual.pem"
+	h.TLS.TLSHash = "1"
+
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/d2.pem"
+	h.TLS.TLSDualFilename = "/var/haproxy/ssl/certs/d2-dual.pem"
+	h.TLS.TLSHash = "2"
+	h.TLS.ALPN = "h2"
+
+	c.Update()
+	c.checkMap("_front_bind_crt.list", `
+/var/haproxy/ssl/certs/default.pem
+/var/haproxy/ssl/certs/d1.pem d1.local
+/var/haproxy/ssl/certs/d1-dual.pem d1.local
+/var/haproxy/ssl/certs/d2.pem [alpn h2] d2.local
+/var/haproxy/ssl/certs/d2-dual.pem [alpn h2] d2.local
+`)
+
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceOCSPStapling(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	stapler := &staplerMock{}
+	c.instance.options.OCSPStapler = stapler
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/d1.pem"
+	h.TLS.TLSDualFilename = "/var/haproxy/ssl/certs/d1-dual.pem"
+	h.TLS.TLSHash = "1"
+	h.TLS.OCSPStapling = true
+
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/d2.pem"
+	h.TLS.TLSHash = "2"
+
+	h = c.config.Hosts().AcquireHost("d3.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.OCSPStapling = true
+
+	c.Update()
+	expected := "/var/run/haproxy.sock: /var/haproxy/ssl/certs/d1-dual.pem,/var/haproxy/ssl/certs/d1.pem"
+	if stapler.updated != expected {
+		t.Errorf("stapler update differs - expected: %s, actual: %s", expected, stapler.updated)
+	}
+
+	c.logger.CompareLogging(defaultLogging)
+}
+
 func TestInstanceFrontendCA(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -1822,6 +1974,119 @@ d2.local http://d2.local/error.html
 	c.logger.CompareLogging(defaultLogging)
 }
 
+func TestInstanceFrontendCARevocation(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	crlUpdater := &crlUpdaterMock{}
+	ocspChecker := &ocspCheckerMock{}
+	c.instance.options.CRLUpdater = crlUpdater
+	c.instance.options.OCSPChecker = ocspChecker
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.TLS.CAFilename = "/var/haproxy/ssl/ca/d1.local.pem"
+	h.TLS.CAHash = "1"
+	h.TLS.CRLFilename = "/var/haproxy/ssl/ca/d1.local.pem.crl"
+	h.TLS.CRLHash = "1"
+	h.TLS.CRLRefresh = time.Hour
+	h.TLS.OCSPCheck = true
+
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.TLS.CAFilename = "/var/haproxy/ssl/ca/d2.local.pem"
+	h.TLS.CAHash = "2"
+
+	h = c.config.Hosts().AcquireHost("d3.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.TLS.CAFilename = "/var/haproxy/ssl/ca/d3.local.pem"
+	h.TLS.CAHash = "3"
+	h.TLS.OCSPCheck = true
+	h.TLS.OCSPFailOpen = true
+
+	c.config.Global().SSL.OCSPCheckSocket = "/var/run/haproxy/ocsp.sock"
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d_app_8080
+    mode http
+    acl local-offload ssl_fc
+    http-request set-header X-SSL-Client-CN   %{+Q}[ssl_c_s_dn(cn)]   if local-offload
+    http-request set-header X-SSL-Client-DN   %{+Q}[ssl_c_s_dn]       if local-offload
+    http-request set-header X-SSL-Client-SHA1 %{+Q}[ssl_c_sha1,hex]   if local-offload
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+<<frontend-http>>
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    <<https-headers>>
+    acl tls-has-crt ssl_c_used
+    acl tls-need-crt ssl_fc_sni -i -m str -f /etc/haproxy/maps/_front_tls_needcrt__exact.list
+    acl tls-host-need-crt var(req.host) -i -m str -f /etc/haproxy/maps/_front_tls_needcrt__exact.list
+    acl tls-has-invalid-crt ssl_c_ca_err gt 0
+    acl tls-has-invalid-crt ssl_c_err gt 0
+    acl tls-check-crt ssl_fc_sni -i -m str -f /etc/haproxy/maps/_front_tls_auth__exact.list
+    acl tls-check-ocsp ssl_fc_sni -i -m str -f /etc/haproxy/maps/_front_tls_ocspcheck__exact.list
+    acl tls-ocsp-fail-open ssl_fc_sni -i -m str -f /etc/haproxy/maps/_front_tls_ocspfailopen__exact.list
+    http-request lua.ocsp-check /var/run/haproxy/ocsp.sock if tls-has-crt !tls-has-invalid-crt tls-check-ocsp
+    http-request set-var(req.snibase) ssl_fc_sni,lower,concat(,req.path)
+    http-request set-var(req.snibackend) var(req.snibase),lower,map_beg(/etc/haproxy/maps/_front_https_sni__begin.map)
+    http-request set-var(req.snibackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_sni__begin.map) if !{ var(req.snibackend) -m found } !tls-has-crt !tls-host-need-crt
+    http-request set-var(req.tls_nocrt_redir) str(_internal) if !tls-has-crt tls-need-crt
+    http-request set-var(req.tls_invalidcrt_redir) str(_internal) if tls-has-invalid-crt tls-check-crt
+    http-request use-service lua.send-421 if tls-has-crt { ssl_fc_has_sni } !{ ssl_fc_sni,strcmp(req.host) eq 0 }
+    http-request use-service lua.send-496 if { var(req.tls_nocrt_redir) _internal }
+    http-request use-service lua.send-421 if !tls-has-crt tls-host-need-crt
+    http-request use-service lua.send-495 if { var(req.tls_invalidcrt_redir) _internal }
+    http-request use-service lua.send-495 if tls-check-ocsp { var(txn.ocsp_status) revoked }
+    http-request deny deny_status 503 if tls-check-ocsp !tls-ocsp-fail-open { var(txn.ocsp_status) error }
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    use_backend %[var(req.snibackend)] if { var(req.snibackend) -m found }
+    default_backend _error404
+<<support>>
+`)
+
+	c.checkMap("_front_bind_crt.list", `
+/var/haproxy/ssl/certs/default.pem
+/var/haproxy/ssl/certs/default.pem [ca-file /var/haproxy/ssl/ca/d1.local.pem verify optional crl-file /var/haproxy/ssl/ca/d1.local.pem.crl] d1.local
+/var/haproxy/ssl/certs/default.pem [ca-file /var/haproxy/ssl/ca/d2.local.pem verify optional] d2.local
+/var/haproxy/ssl/certs/default.pem [ca-file /var/haproxy/ssl/ca/d3.local.pem verify optional] d3.local
+`)
+	c.checkMap("_front_tls_ocspcheck__exact.list", `
+d1.local
+d3.local
+`)
+	c.checkMap("_front_tls_ocspfailopen__exact.list", `
+d3.local
+`)
+
+	expCRL := "/var/run/haproxy.sock: /var/haproxy/ssl/ca/d1.local.pem"
+	if crlUpdater.updated != expCRL {
+		t.Errorf("crl updater differs - expected: %s, actual: %s", expCRL, crlUpdater.updated)
+	}
+	expOCSP := "/var/haproxy/ssl/ca/d1.local.pem,/var/haproxy/ssl/ca/d3.local.pem"
+	if ocspChecker.updated != expOCSP {
+		t.Errorf("ocsp checker differs - expected: %s, actual: %s", expOCSP, ocspChecker.updated)
+	}
+
+	c.logger.CompareLogging(defaultLogging)
+}
+
 func TestInstanceSomePaths(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -2301,6 +2566,7 @@ global
     log-tag ingress
     lua-load /etc/haproxy/lua/auth-request.lua
     lua-load /etc/haproxy/lua/services.lua
+    lua-load /etc/haproxy/lua/ocsp-check.lua
     ssl-dh-param-file /var/haproxy/tls/dhparam.pem
     ssl-default-bind-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
     ssl-default-bind-ciphersuites TLS_AES_128_GCM_SHA256
@@ -2596,6 +2862,230 @@ backend _acme_challenge
 	}
 }
 
+func TestAcmeOrphan(t *testing.T) {
+	testCases := []struct {
+		storages []string
+		actions  []string
+		expQueue string
+		logging  string
+	}{
+		// 0
+		{
+			storages: []string{"s1", "s2"},
+			actions:  []string{"revoke", "delete"},
+		},
+		// 1
+		{
+			storages: []string{"s1"},
+			actions:  []string{"revoke", "delete"},
+			expQueue: "addafter s2,orphan=revoke,orphan=delete,since=<ts> 1h0m0s",
+			logging:  "INFO acme: secret s2 is not used anymore, scheduling orphan actions in 1h0m0s: revoke,delete",
+		},
+		// 2
+		{
+			storages: []string{"s1", "s2"},
+			actions:  []string{"revoke", "delete"},
+			expQueue: "remove s2,orphan=revoke,orphan=delete,since=<ts>",
+			logging:  "INFO acme: canceling orphan actions of secret s2, it is in use again",
+		},
+		// 3
+		{
+			storages: []string{"s2"},
+		},
+	}
+	c := setup(t)
+	defer c.teardown()
+	queue := &queueMock{}
+	c.instance.options.AcmeQueue = queue
+	for i, test := range testCases {
+		acmeData := &hatypes.AcmeData{
+			OrphanActions:     test.actions,
+			OrphanGracePeriod: time.Hour,
+		}
+		for _, storage := range test.storages {
+			acmeData.Storages().Acquire(storage)
+		}
+		queue.items = nil
+		c.instance.acmeUpdateOrphans(acmeData)
+		if actual := strings.Join(queue.items, "\n"); actual != test.expQueue {
+			t.Errorf("queue differs on %d - expected: %s, actual: %s", i, test.expQueue, actual)
+		}
+		c.logger.CompareLogging(test.logging)
+	}
+}
+
+func TestAcmeOrphanSigned(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+	queue := &queueMock{}
+	c.instance.options.AcmeQueue = queue
+	c.instance.options.AcmeSigner = &signerMock{signed: []string{"default/s1", "default/s2"}}
+
+	acmeData := &hatypes.AcmeData{
+		OrphanActions:     []string{"delete"},
+		OrphanGracePeriod: time.Hour,
+	}
+	acmeData.Storages().Acquire("default/s1")
+	c.instance.acmeUpdateOrphans(acmeData)
+	expQueue := "addafter default/s2,orphan=delete,since=<ts> 1h0m0s"
+	if actual := strings.Join(queue.items, "\n"); actual != expQueue {
+		t.Errorf("queue differs - expected: %s, actual: %s", expQueue, actual)
+	}
+	c.logger.CompareLogging("INFO acme: secret default/s2 is not used anymore, scheduling orphan actions in 1h0m0s: delete")
+
+	// signed secrets are only read on the first update
+	queue.items = nil
+	c.instance.acmeUpdateOrphans(acmeData)
+	if actual := strings.Join(queue.items, "\n"); actual != "" {
+		t.Errorf("queue differs - expected to be empty, actual: %s", actual)
+	}
+	c.logger.CompareLogging("")
+}
+
+func TestAcmeTLSALPN(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	acme := &c.config.Global().Acme
+	acme.Enabled = true
+	acme.Prefix = "/.acme"
+	acme.Socket = "/run/acme.sock"
+	acme.TLSSocket = "/run/acme-tls.sock"
+	c.config.AcmeData().Storages().Acquire("default/tls1").SetChallenge("tls-alpn-01", "")
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+backend _acme_challenge
+    mode http
+    server _acme_server unix@/run/acme.sock
+backend _acme_tls_alpn
+    mode tcp
+    server _acme_tls_server unix@/run/acme-tls.sock
+<<backends-default>>
+listen _front__tls
+    mode tcp
+    bind :443
+    tcp-request inspect-delay 5s
+    tcp-request content accept if { req.ssl_hello_type 1 }
+    use_backend %[var(req.sslpassback)] if { var(req.sslpassback) -m found }
+    use_backend _acme_tls_alpn if { req.ssl_alpn acme-tls/1 }
+    server _default_server_https_socket unix@/var/run/haproxy/_https_socket.sock send-proxy-v2
+frontend _front_http
+    mode http
+    bind :80
+    acl acme-challenge path_beg /.acme
+    <<set-req-base>>
+    http-request set-var(req.redir) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_redir_tohttps__begin.map)
+    http-request redirect scheme https if !acme-challenge { var(req.redir) yes }
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    use_backend _acme_challenge if acme-challenge
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind unix@/var/run/haproxy/_https_socket.sock accept-proxy ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+<<support>>
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestAcmeTLSALPNSSLPassthrough(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b *hatypes.Backend
+
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	b = c.config.Backends().AcquireBackend("d2", "app-ssl", "8443")
+	b.Endpoints = []*hatypes.Endpoint{endpointS41s}
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.SetSSLPassthrough(true)
+
+	acme := &c.config.Global().Acme
+	acme.Enabled = true
+	acme.Prefix = "/.acme"
+	acme.Socket = "/run/acme.sock"
+	acme.TLSSocket = "/run/acme-tls.sock"
+	c.config.AcmeData().Storages().Acquire("default/tls1").SetChallenge("tls-alpn-01", "")
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app-ssl_8443
+    mode http
+    server s41s 172.17.0.141:8443 weight 100
+backend _acme_challenge
+    mode http
+    server _acme_server unix@/run/acme.sock
+backend _acme_tls_alpn
+    mode tcp
+    server _acme_tls_server unix@/run/acme-tls.sock
+<<backends-default>>
+listen _front__tls
+    mode tcp
+    bind :443
+    tcp-request inspect-delay 5s
+    tcp-request content set-var(req.sslpassback) req.ssl_sni,lower,map_str(/etc/haproxy/maps/_front_sslpassthrough__exact.map)
+    tcp-request content accept if { req.ssl_hello_type 1 }
+    use_backend %[var(req.sslpassback)] if { var(req.sslpassback) -m found }
+    use_backend _acme_tls_alpn if { req.ssl_alpn acme-tls/1 }
+    server _default_server_https_socket unix@/var/run/haproxy/_https_socket.sock send-proxy-v2
+frontend _front_http
+    mode http
+    bind :80
+    acl acme-challenge path_beg /.acme
+    <<set-req-base>>
+    http-request set-var(req.redir) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_redir_tohttps__begin.map)
+    http-request redirect scheme https if !acme-challenge { var(req.redir) yes }
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    use_backend _acme_challenge if acme-challenge
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind unix@/var/run/haproxy/_https_socket.sock accept-proxy ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+<<support>>
+`)
+	c.checkMap("_front_sslpassthrough__exact.map", `
+d2.local d2_app-ssl_8443`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
 func TestStats(t *testing.T) {
 	testCases := []struct {
 		stats          hatypes.StatsConfig
@@ -3034,6 +3524,80 @@ type testOptions struct {
 	shardCount int
 }
 
+type staplerMock struct {
+	updated string
+}
+
+func (s *staplerMock) Update(socket string, crtFiles []string) {
+	s.updated = socket + ": " + strings.Join(crtFiles, ",")
+}
+
+func (s *staplerMock) Refresh() {}
+
+type crlUpdaterMock struct {
+	updated string
+}
+
+func (u *crlUpdaterMock) CRLFile(caFile string, refresh time.Duration) (crlFile, hash string, found bool) {
+	return "", "", false
+}
+
+func (u *crlUpdaterMock) Update(socket string, caFiles []string) {
+	u.updated = socket + ": " + strings.Join(caFiles, ",")
+}
+
+func (u *crlUpdaterMock) Refresh() {}
+
+type ocspCheckerMock struct {
+	updated string
+}
+
+func (c *ocspCheckerMock) Update(caFiles []string) {
+	c.updated = strings.Join(caFiles, ",")
+}
+
+func (c *ocspCheckerMock) Listen(stopCh chan struct{}) error {
+	return nil
+}
+
+type queueMock struct {
+	items []string
+}
+
+type signerMock struct {
+	acme.Signer
+	signed []string
+}
+
+func (s *signerMock) SignedSecrets() []string {
+	return s.signed
+}
+
+var sinceRegex = regexp.MustCompile(`since=[0-9]+`)
+
+func (q *queueMock) record(action string, item interface{}, extra ...string) {
+	rec := append([]string{action, sinceRegex.ReplaceAllString(item.(string), "since=<ts>")}, extra...)
+	q.items = append(q.items, strings.Join(rec, " "))
+}
+
+func (q *queueMock) Add(item interface{}) {
+	q.record("add", item)
+}
+
+func (q *queueMock) AddAfter(item interface{}, duration time.Duration) {
+	q.record("addafter", item, duration.String())
+}
+
+func (q *queueMock) Remove(item interface{}) {
+	q.record("remove", item)
+}
+
+func (q *queueMock) Clear()             {}
+func (q *queueMock) Notify()            {}
+func (q *queueMock) Run()               {}
+func (q *queueMock) ShuttingDown() bool { return false }
+func (q *queueMock) ShutDown()          {}
+
 func setup(t *testing.T) *testConfig {
 	return setupOptions(testOptions{t: t})
 }
@@ -3211,6 +3775,7 @@ func (c *testConfig) checkConfigFile(expected, fileName string) {
     hard-stop-after 15m
     lua-load /etc/haproxy/lua/auth-request.lua
     lua-load /etc/haproxy/lua/services.lua
+    lua-load /etc/haproxy/lua/ocsp-check.lua
     ssl-dh-param-file /var/haproxy/tls/dhparam.pem
     ssl-default-bind-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
     ssl-default-bind-ciphersuites TLS_AES_128_GCM_SHA256
@@ -0,0 +1,118 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"sync"
+	"time"
+)
+
+// Reason categories of a haproxy reload
+const (
+	ReloadFullSync       = "full-sync"
+	ReloadGlobal         = "global"
+	ReloadHosts          = "hosts"
+	ReloadUserlists      = "userlists"
+	ReloadTCPServices    = "tcp-services"
+	ReloadNewBackend     = "new-backend"
+	ReloadEndpointGrowth = "endpoint-growth"
+	ReloadBackendDiff    = "backend-diff"
+	ReloadEndpointUpdate = "endpoint-update"
+)
+
+// reloadHistorySize is the number of reloads kept by the reload history.
+const reloadHistorySize = 64
+
+// Reload has the reasons of a haproxy reload.
+type Reload struct {
+	Time    time.Time       `json:"time"`
+	Success bool            `json:"success"`
+	Reasons []*ReloadReason `json:"reasons"`
+}
+
+// ReloadReason is a reason category of a reload, and the objects that
+// lead to it. Objects is empty if the reason isn't related with objects,
+// eg changes in the global config.
+type ReloadReason struct {
+	Reason  string          `json:"reason"`
+	Objects []*ReloadObject `json:"objects,omitempty"`
+}
+
+// ReloadObject is a host, backend, tcp service or userlist. Namespace is
+// empty if the object doesn't belong to a namespace, eg a host whose
+// paths point only to the default backend.
+type ReloadObject struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+type reloadReasons struct {
+	reasons []*ReloadReason
+}
+
+func (r *reloadReasons) add(reason, namespace, name string) {
+	var rr *ReloadReason
+	for _, item := range r.reasons {
+		if item.Reason == reason {
+			rr = item
+			break
+		}
+	}
+	if rr == nil {
+		rr = &ReloadReason{Reason: reason}
+		r.reasons = append(r.reasons, rr)
+	}
+	if name == "" {
+		return
+	}
+	for _, obj := range rr.Objects {
+		if obj.Namespace == namespace && obj.Name == name {
+			return
+		}
+	}
+	rr.Objects = append(rr.Objects, &ReloadObject{Namespace: namespace, Name: name})
+}
+
+// reloadHistory is a ring buffer of the last reloads.
+type reloadHistory struct {
+	mutex   sync.Mutex
+	reloads []*Reload
+	next    int
+}
+
+func (h *reloadHistory) add(reload *Reload) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.reloads) < reloadHistorySize {
+		h.reloads = append(h.reloads, reload)
+	} else {
+		h.reloads[h.next] = reload
+	}
+	h.next = (h.next + 1) % reloadHistorySize
+}
+
+// items returns the reloads, newest first.
+func (h *reloadHistory) items() []*Reload {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	items := make([]*Reload, len(h.reloads))
+	for i := range items {
+		j := (h.next - 1 - i + reloadHistorySize) % reloadHistorySize
+		items[i] = h.reloads[j]
+	}
+	return items
+}
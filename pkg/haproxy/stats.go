@@ -0,0 +1,236 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	hautils "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/utils"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// stats types, see the CSV format in the haproxy management guide
+const (
+	statsTypeBackend = "1"
+	statsTypeServer  = "2"
+)
+
+// statsTimeout is the maximum time to read the output of a stats command
+const statsTimeout = 10 * time.Second
+
+var statsResponseCodes = []string{"1xx", "2xx", "3xx", "4xx", "5xx", "other"}
+
+func (i *instance) CollectStats(locker sync.Locker) {
+	locker.Lock()
+	up := i.up
+	socket := i.config.Global().AdminSocket
+	locker.Unlock()
+	if !up {
+		return
+	}
+	// the admin socket is read without the lock, so a slow haproxy
+	// doesn't delay the next sync
+	info, err := hautils.HAProxyCommandOutput(socket, i.metrics.HAProxyShowInfoResponseTime, statsTimeout, "show info")
+	if err != nil {
+		i.logger.Error("error reading admin socket: %v", err)
+		return
+	}
+	stat, err := hautils.HAProxyCommandOutput(socket, i.metrics.HAProxyShowStatResponseTime, statsTimeout, "show stat")
+	if err != nil {
+		i.logger.Error("error reading admin socket: %v", err)
+		return
+	}
+	locker.Lock()
+	stats, err := buildStats(i.config, info, stat, i.options.StatsMaxObjects)
+	locker.Unlock()
+	if err != nil {
+		i.logger.Error("error parsing haproxy stats: %v", err)
+		return
+	}
+	if stats.Dropped > 0 && stats.Dropped != i.statsDropped {
+		i.logger.Warn("stats exporter limit of %d objects reached, %d backends, servers or paths were not exported",
+			i.options.StatsMaxObjects, stats.Dropped)
+	}
+	i.statsDropped = stats.Dropped
+	i.metrics.SetHAProxyStats(stats)
+}
+
+// buildStats labels the output of the `show info` and `show stat` commands
+// with the Kubernetes objects found in config. Backends and servers not
+// created from Kubernetes objects, eg the acme and the error pages ones,
+// are ignored. maxObjects limits the number of backends, servers and paths,
+// in this order, zero means unlimited.
+func buildStats(config Config, info, stat string, maxObjects int) (*types.HAProxyStats, error) {
+	stats := &types.HAProxyStats{}
+	stats.Process = parseStatsInfo(info)
+	rows, err := parseStatsCSV(stat)
+	if err != nil {
+		return nil, err
+	}
+	var count int
+	canAdd := func() bool {
+		if maxObjects > 0 && count >= maxObjects {
+			stats.Dropped++
+			return false
+		}
+		count++
+		return true
+	}
+	backends := config.Backends().Items()
+	exported := map[string]bool{}
+	var servers []*types.StatsServer
+	for _, row := range rows {
+		backend := backends[row["pxname"]]
+		if backend == nil {
+			continue
+		}
+		statsBackend := types.StatsBackend{
+			Backend:   backend.ID,
+			Namespace: backend.Namespace,
+			Service:   backend.Name,
+			Port:      backend.Port,
+			Counters:  parseStatsCounters(row),
+		}
+		switch row["type"] {
+		case statsTypeBackend:
+			if canAdd() {
+				stats.Backends = append(stats.Backends, &statsBackend)
+				exported[backend.ID] = true
+			}
+		case statsTypeServer:
+			var ep *hatypes.Endpoint
+			for _, endpoint := range backend.Endpoints {
+				if endpoint.Name == row["svname"] {
+					ep = endpoint
+					break
+				}
+			}
+			if ep == nil || !ep.Enabled {
+				// empty slots of the dynamic update
+				continue
+			}
+			var pod string
+			if j := strings.Index(ep.TargetRef, "/"); j >= 0 {
+				pod = ep.TargetRef[j+1:]
+			}
+			status := row["status"]
+			servers = append(servers, &types.StatsServer{
+				StatsBackend: statsBackend,
+				Server:       ep.Name,
+				Pod:          pod,
+				Up:           strings.HasPrefix(status, "UP") || status == "no check",
+			})
+		}
+	}
+	// servers are added only after all the backends, so a limit reached
+	// drops servers before backends
+	for _, server := range servers {
+		if exported[server.Backend] && canAdd() {
+			stats.Servers = append(stats.Servers, server)
+		}
+	}
+	for _, host := range config.Hosts().BuildSortedItems() {
+		for _, path := range host.Paths {
+			if exported[path.Backend.ID] && canAdd() {
+				stats.Paths = append(stats.Paths, &types.StatsPath{
+					Backend:   path.Backend.ID,
+					Namespace: path.Backend.Namespace,
+					Service:   path.Backend.Name,
+					Port:      path.Backend.Port,
+					Ingress:   path.Ingress,
+					Host:      host.Hostname,
+					Path:      path.Path,
+				})
+			}
+		}
+	}
+	return stats, nil
+}
+
+func parseStatsInfo(info string) types.StatsProcess {
+	var process types.StatsProcess
+	for _, line := range strings.Split(info, "\n") {
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value, _ := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 64)
+		switch kv[0] {
+		case "CurrConns":
+			process.CurrentConnections = value
+		case "CumConns":
+			process.ConnectionsTotal = value
+		case "CumReq":
+			process.RequestsTotal = value
+		}
+	}
+	return process
+}
+
+func parseStatsCSV(stat string) ([]map[string]string, error) {
+	if !strings.HasPrefix(stat, "# ") {
+		return nil, fmt.Errorf("missing header of the show stat output")
+	}
+	reader := csv.NewReader(strings.NewReader(stat[2:]))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	header := records[0]
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for j, value := range record {
+			if j < len(header) {
+				row[header[j]] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseStatsCounters(row map[string]string) types.StatsCounters {
+	field := func(name string) int64 {
+		value, _ := strconv.ParseInt(row[name], 10, 64)
+		return value
+	}
+	counters := types.StatsCounters{
+		CurrentSessions: field("scur"),
+		SessionsTotal:   field("stot"),
+		BytesIn:         field("bin"),
+		BytesOut:        field("bout"),
+		ResponseTime:    time.Duration(field("rtime")) * time.Millisecond,
+	}
+	for _, code := range statsResponseCodes {
+		// hrsp fields are empty on tcp mode
+		if value, found := row["hrsp_"+code]; found && value != "" {
+			if counters.Responses == nil {
+				counters.Responses = make(map[string]int64, len(statsResponseCodes))
+			}
+			counters.Responses[code] = field("hrsp_" + code)
+		}
+	}
+	return counters
+}
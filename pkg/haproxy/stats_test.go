@@ -0,0 +1,130 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kylelemons/godebug/diff"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+const statsInfo = `Name: HAProxy
+Version: 2.1.4
+CurrConns: 12
+CumConns: 3400
+CumReq: 5600
+`
+
+const statsCSV = `# pxname,svname,scur,stot,bin,bout,status,type,rtime,hrsp_1xx,hrsp_2xx,hrsp_3xx,hrsp_4xx,hrsp_5xx,hrsp_other,
+_front_http,FRONTEND,10,3000,100000,200000,OPEN,0,,0,2500,100,50,2,0,
+default_app_8080,srv001,3,1000,30000,60000,UP,2,12,0,900,20,10,1,0,
+default_app_8080,srv002,2,500,20000,40000,DOWN,2,30,0,400,30,5,0,0,
+default_app_8080,srv003,0,0,0,0,MAINT,2,0,0,0,0,0,0,0,
+default_app_8080,BACKEND,5,1500,50000,100000,UP,1,18,0,1300,50,15,1,0,
+team1_db_5432,srv001,1,20,1000,2000,no check,2,0,,,,,,,
+team1_db_5432,BACKEND,1,20,1000,2000,UP,1,0,,,,,,,
+_error404,BACKEND,0,10,0,0,UP,1,0,0,0,0,10,0,0,
+`
+
+func TestBuildStats(t *testing.T) {
+	testCases := []struct {
+		maxObjects int
+		expected   string
+	}{
+		// 0
+		{
+			expected: `
+process: conns=12 conns_total=3400 reqs_total=5600
+backend default_app_8080 ns=default svc=app port=8080: cur=5 sessions=1500 in=50000 out=100000 rtime=18ms responses=map[1xx:0 2xx:1300 3xx:50 4xx:15 5xx:1 other:0]
+backend team1_db_5432 ns=team1 svc=db port=5432: cur=1 sessions=20 in=1000 out=2000 rtime=0s responses=map[]
+server default_app_8080/srv001 pod=app-1 up=true: cur=3 sessions=1000 in=30000 out=60000 rtime=12ms responses=map[1xx:0 2xx:900 3xx:20 4xx:10 5xx:1 other:0]
+server default_app_8080/srv002 pod=app-2 up=false: cur=2 sessions=500 in=20000 out=40000 rtime=30ms responses=map[1xx:0 2xx:400 3xx:30 4xx:5 5xx:0 other:0]
+server team1_db_5432/srv001 pod= up=true: cur=1 sessions=20 in=1000 out=2000 rtime=0s responses=map[]
+path d1.local/ backend=default_app_8080 ingress=default/app1
+path d2.local/db backend=team1_db_5432 ingress=team1/db
+path d2.local/app backend=default_app_8080 ingress=default/app2
+dropped: 0`,
+		},
+		// 1
+		{
+			maxObjects: 3,
+			expected: `
+process: conns=12 conns_total=3400 reqs_total=5600
+backend default_app_8080 ns=default svc=app port=8080: cur=5 sessions=1500 in=50000 out=100000 rtime=18ms responses=map[1xx:0 2xx:1300 3xx:50 4xx:15 5xx:1 other:0]
+backend team1_db_5432 ns=team1 svc=db port=5432: cur=1 sessions=20 in=1000 out=2000 rtime=0s responses=map[]
+server default_app_8080/srv001 pod=app-1 up=true: cur=3 sessions=1000 in=30000 out=60000 rtime=12ms responses=map[1xx:0 2xx:900 3xx:20 4xx:10 5xx:1 other:0]
+dropped: 5`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		b1 := c.config.Backends().AcquireBackend("default", "app", "8080")
+		b1.AcquireEndpoint("172.17.0.11", 8080, "default/app-1")
+		b1.AcquireEndpoint("172.17.0.12", 8080, "default/app-2")
+		b1.AddEmptyEndpoint()
+		b2 := c.config.Backends().AcquireBackend("team1", "db", "5432")
+		b2.AcquireEndpoint("172.17.0.21", 5432, "")
+		h1 := c.config.Hosts().AcquireHost("d1.local")
+		h1.AddPath(b1, "/", hatypes.MatchBegin)
+		h1.FindPath("/").Ingress = "default/app1"
+		h2 := c.config.Hosts().AcquireHost("d2.local")
+		h2.AddPath(b1, "/app", hatypes.MatchBegin)
+		h2.FindPath("/app").Ingress = "default/app2"
+		h2.AddPath(b2, "/db", hatypes.MatchBegin)
+		h2.FindPath("/db").Ingress = "team1/db"
+		stats, err := buildStats(c.config, statsInfo, statsCSV, test.maxObjects)
+		if err != nil {
+			t.Errorf("unexpected error on %d: %v", i, err)
+			c.teardown()
+			continue
+		}
+		expected := strings.Trim(test.expected, "\n")
+		actual := formatStats(stats)
+		if actual != expected {
+			t.Errorf("stats differ on %d:\n%s", i, diff.Diff(expected, actual))
+		}
+		c.teardown()
+	}
+}
+
+func formatStats(stats *types.HAProxyStats) string {
+	counters := func(c *types.StatsCounters) string {
+		return fmt.Sprintf("cur=%d sessions=%d in=%d out=%d rtime=%s responses=%v",
+			c.CurrentSessions, c.SessionsTotal, c.BytesIn, c.BytesOut, c.ResponseTime, c.Responses)
+	}
+	p := stats.Process
+	out := []string{fmt.Sprintf("process: conns=%d conns_total=%d reqs_total=%d",
+		p.CurrentConnections, p.ConnectionsTotal, p.RequestsTotal)}
+	for _, b := range stats.Backends {
+		out = append(out, fmt.Sprintf("backend %s ns=%s svc=%s port=%s: %s",
+			b.Backend, b.Namespace, b.Service, b.Port, counters(&b.Counters)))
+	}
+	for _, s := range stats.Servers {
+		out = append(out, fmt.Sprintf("server %s/%s pod=%s up=%t: %s",
+			s.Backend, s.Server, s.Pod, s.Up, counters(&s.Counters)))
+	}
+	for _, p := range stats.Paths {
+		out = append(out, fmt.Sprintf("path %s%s backend=%s ingress=%s", p.Host, p.Path, p.Backend, p.Ingress))
+	}
+	out = append(out, fmt.Sprintf("dropped: %d", stats.Dropped))
+	return strings.Join(out, "\n")
+}
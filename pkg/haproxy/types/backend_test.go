@@ -30,47 +30,47 @@ func TestAddPath(t *testing.T) {
 		{
 			input: []string{"/"},
 			expected: []*BackendPath{
-				{"path01", PathLink{"d1.local", "/"}},
+				{"path01", CreatePathLink("d1.local", "/")},
 			},
 		},
 		// 1
 		{
 			input: []string{"/app", "/app"},
 			expected: []*BackendPath{
-				{"path01", PathLink{"d1.local", "/app"}},
+				{"path01", CreatePathLink("d1.local", "/app")},
 			},
 		},
 		// 2
 		{
 			input: []string{"/app", "/root"},
 			expected: []*BackendPath{
-				{"path02", PathLink{"d1.local", "/root"}},
-				{"path01", PathLink{"d1.local", "/app"}},
+				{"path02", CreatePathLink("d1.local", "/root")},
+				{"path01", CreatePathLink("d1.local", "/app")},
 			},
 		},
 		// 3
 		{
 			input: []string{"/app", "/root", "/root"},
 			expected: []*BackendPath{
-				{"path02", PathLink{"d1.local", "/root"}},
-				{"path01", PathLink{"d1.local", "/app"}},
+				{"path02", CreatePathLink("d1.local", "/root")},
+				{"path01", CreatePathLink("d1.local", "/app")},
 			},
 		},
 		// 4
 		{
 			input: []string{"/app", "/root", "/app"},
 			expected: []*BackendPath{
-				{"path02", PathLink{"d1.local", "/root"}},
-				{"path01", PathLink{"d1.local", "/app"}},
+				{"path02", CreatePathLink("d1.local", "/root")},
+				{"path01", CreatePathLink("d1.local", "/app")},
 			},
 		},
 		// 5
 		{
 			input: []string{"/", "/app", "/root"},
 			expected: []*BackendPath{
-				{"path03", PathLink{"d1.local", "/root"}},
-				{"path02", PathLink{"d1.local", "/app"}},
-				{"path01", PathLink{"d1.local", "/"}},
+				{"path03", CreatePathLink("d1.local", "/root")},
+				{"path02", CreatePathLink("d1.local", "/app")},
+				{"path01", CreatePathLink("d1.local", "/")},
 			},
 		},
 	}
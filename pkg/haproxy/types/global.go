@@ -48,6 +48,16 @@ func (c *AcmeStorages) Acquire(name string) *AcmeCerts {
 	return storage
 }
 
+// Names returns the name of all the storages, sorted.
+func (c *AcmeStorages) Names() []string {
+	names := make([]string, 0, len(c.items))
+	for name := range c.items {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Updated ...
 func (c *AcmeStorages) Updated() bool {
 	c.shrink()
@@ -76,14 +86,34 @@ func buildAcmeStorages(items map[string]*AcmeCerts) []string {
 	i := 0
 	for name := range items {
 		item := items[name]
-		certs := make([]string, len(item.certs))
-		j := 0
+		certs := make([]string, 0, len(item.certs))
 		for cert := range item.certs {
-			certs[j] = cert
-			j++
+			// a wildcard cert in the same storage already covers
+			// this domain, no need to authorize it again. Wildcards
+			// of other storages don't count, every storage is a
+			// distinct certificate which should have all its domains
+			if dot := strings.Index(cert, "."); dot > 0 && cert[0] != '*' {
+				if _, found := item.certs["*"+cert[dot:]]; found {
+					continue
+				}
+			}
+			certs = append(certs, cert)
 		}
 		sort.Strings(certs)
-		storages[i] = name + "," + strings.Join(certs, ",")
+		var options string
+		if item.challenge != "" {
+			options += "challenge=" + item.challenge + ","
+		}
+		if item.dnsProvider != "" {
+			options += "provider=" + item.dnsProvider + ","
+		}
+		if item.keyType != "" {
+			options += "keytype=" + item.keyType + ","
+		}
+		if item.dualKeyType != "" {
+			options += "dualkeytype=" + item.dualKeyType + ","
+		}
+		storages[i] = name + "," + options + strings.Join(certs, ",")
 		i++
 	}
 	return storages
@@ -98,6 +128,29 @@ func (c *AcmeStorages) shrink() {
 	}
 }
 
+// SetKeyType configures the key type of the certificate and, if not
+// empty, the key type of a dual certificate. The first call wins: returns
+// false if distinct key types were already configured, including a
+// missing or a new dual key type.
+func (c *AcmeCerts) SetKeyType(keyType, dualKeyType string) bool {
+	if c.keyType != "" {
+		return c.keyType == keyType && c.dualKeyType == dualKeyType
+	}
+	c.keyType = keyType
+	c.dualKeyType = dualKeyType
+	return true
+}
+
+// HasChallenge ...
+func (c *AcmeStorages) HasChallenge(challenge string) bool {
+	for _, item := range c.items {
+		if item.challenge == challenge {
+			return true
+		}
+	}
+	return false
+}
+
 // RemoveAll ...
 func (c *AcmeStorages) RemoveAll(names []string) {
 	for _, name := range names {
@@ -121,6 +174,18 @@ func (c *AcmeCerts) AddDomains(domains []string) {
 	}
 }
 
+// SetChallenge configures the challenge type and its dns provider, if
+// the challenge is dns-01. Returns false if a distinct challenge or
+// provider was already configured.
+func (c *AcmeCerts) SetChallenge(challenge, dnsProvider string) bool {
+	if (c.challenge != "" && c.challenge != challenge) || (c.dnsProvider != "" && c.dnsProvider != dnsProvider) {
+		return false
+	}
+	c.challenge = challenge
+	c.dnsProvider = dnsProvider
+	return true
+}
+
 func (dns *DNSConfig) String() string {
 	return fmt.Sprintf("%+v", *dns)
 }
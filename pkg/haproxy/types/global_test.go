@@ -24,8 +24,9 @@ import (
 
 func TestBuildAcmeStorages(t *testing.T) {
 	testCases := []struct {
-		certs    [][]string
-		expected []string
+		certs     [][]string
+		challenge map[string][]string
+		expected  []string
 	}{
 		// 0
 		{
@@ -57,12 +58,51 @@ func TestBuildAcmeStorages(t *testing.T) {
 				"cert2,d2.local,d3.local",
 			},
 		},
+		// 3
+		{
+			certs: [][]string{
+				{"cert1", "d1.local"},
+				{"cert2", "d2.local"},
+			},
+			challenge: map[string][]string{
+				"cert1": {"http-01", ""},
+				"cert2": {"dns-01", "rfc2136:ns1/dns"},
+			},
+			expected: []string{
+				"cert1,challenge=http-01,d1.local",
+				"cert2,challenge=dns-01,provider=rfc2136:ns1/dns,d2.local",
+			},
+		},
+		// 4
+		{
+			certs: [][]string{
+				{"cert1", "*.d1.local", "d1.local", "www.d1.local"},
+				{"cert1", "app.d1.local", "www.sub.d1.local"},
+			},
+			expected: []string{
+				"cert1,*.d1.local,d1.local,www.sub.d1.local",
+			},
+		},
+		// 5
+		{
+			certs: [][]string{
+				{"cert1", "*.d1.local"},
+				{"cert2", "www.d1.local"},
+			},
+			expected: []string{
+				"cert1,*.d1.local",
+				"cert2,www.d1.local",
+			},
+		},
 	}
 	for i, test := range testCases {
 		acme := AcmeData{}
 		for _, cert := range test.certs {
 			acme.Storages().Acquire(cert[0]).AddDomains(cert[1:])
 		}
+		for name, challenge := range test.challenge {
+			acme.Storages().Acquire(name).SetChallenge(challenge[0], challenge[1])
+		}
 		storages := acme.Storages().BuildAcmeStorages()
 		sort.Strings(storages)
 		if !reflect.DeepEqual(storages, test.expected) {
@@ -71,6 +111,67 @@ func TestBuildAcmeStorages(t *testing.T) {
 	}
 }
 
+func TestAcmeSetKeyType(t *testing.T) {
+	testCases := []struct {
+		keyTypes [][]string
+		expected []bool
+		expOpts  string
+	}{
+		// 0
+		{
+			keyTypes: [][]string{{"rsa2048", ""}},
+			expected: []bool{true},
+			expOpts:  "keytype=rsa2048,",
+		},
+		// 1
+		{
+			keyTypes: [][]string{{"rsa2048", "ec256"}, {"rsa2048", "ec256"}},
+			expected: []bool{true, true},
+			expOpts:  "keytype=rsa2048,dualkeytype=ec256,",
+		},
+		// 2
+		{
+			keyTypes: [][]string{{"rsa2048", ""}, {"ec256", ""}},
+			expected: []bool{true, false},
+			expOpts:  "keytype=rsa2048,",
+		},
+		// 3
+		{
+			keyTypes: [][]string{{"rsa2048", ""}, {"rsa2048", "ec256"}},
+			expected: []bool{true, false},
+			expOpts:  "keytype=rsa2048,",
+		},
+		// 4
+		{
+			keyTypes: [][]string{{"rsa2048", "ec256"}, {"rsa2048", ""}},
+			expected: []bool{true, false},
+			expOpts:  "keytype=rsa2048,dualkeytype=ec256,",
+		},
+		// 5
+		{
+			keyTypes: [][]string{{"rsa2048", "ec256"}, {"rsa2048", "ec384"}, {"rsa2048", "ec256"}},
+			expected: []bool{true, false, true},
+			expOpts:  "keytype=rsa2048,dualkeytype=ec256,",
+		},
+	}
+	for i, test := range testCases {
+		acme := AcmeData{}
+		storage := acme.Storages().Acquire("cert1")
+		storage.AddDomains([]string{"d1.local"})
+		var actual []bool
+		for _, keyType := range test.keyTypes {
+			actual = append(actual, storage.SetKeyType(keyType[0], keyType[1]))
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("set key type differs on %d - expected: %v, actual: %v", i, test.expected, actual)
+		}
+		expStorages := []string{"cert1," + test.expOpts + "d1.local"}
+		if storages := acme.Storages().BuildAcmeStorages(); !reflect.DeepEqual(storages, expStorages) {
+			t.Errorf("acme certs differs on %d - expected: %+v, actual: %+v", i, expStorages, storages)
+		}
+	}
+}
+
 func TestShrink(t *testing.T) {
 	d1 := map[string]struct{}{"d1.local": {}}
 	d2 := map[string]struct{}{"d2.local": {}}
@@ -85,45 +186,45 @@ func TestShrink(t *testing.T) {
 		},
 		// 1
 		{
-			itemAdd: map[string]*AcmeCerts{"cert1": {d1}},
-			expAdd:  map[string]*AcmeCerts{"cert1": {d1}},
+			itemAdd: map[string]*AcmeCerts{"cert1": {certs: d1}},
+			expAdd:  map[string]*AcmeCerts{"cert1": {certs: d1}},
 			expDel:  map[string]*AcmeCerts{},
 		},
 		// 2
 		{
-			itemAdd: map[string]*AcmeCerts{"cert1": {d1}},
-			itemDel: map[string]*AcmeCerts{"cert1": {d1}},
+			itemAdd: map[string]*AcmeCerts{"cert1": {certs: d1}},
+			itemDel: map[string]*AcmeCerts{"cert1": {certs: d1}},
 			expAdd:  map[string]*AcmeCerts{},
 			expDel:  map[string]*AcmeCerts{},
 		},
 		// 3
 		{
 			itemAdd: map[string]*AcmeCerts{
-				"cert1": {d1},
-				"cert2": {d1},
+				"cert1": {certs: d1},
+				"cert2": {certs: d1},
 			},
 			itemDel: map[string]*AcmeCerts{
-				"cert1": {d1},
-				"cert2": {d2},
+				"cert1": {certs: d1},
+				"cert2": {certs: d2},
 			},
 			expAdd: map[string]*AcmeCerts{
-				"cert2": {d1},
+				"cert2": {certs: d1},
 			},
 			expDel: map[string]*AcmeCerts{
-				"cert2": {d2},
+				"cert2": {certs: d2},
 			},
 		},
 		// 4
 		{
 			itemAdd: map[string]*AcmeCerts{
-				"cert1": {d1},
-				"cert2": {d1},
+				"cert1": {certs: d1},
+				"cert2": {certs: d1},
 			},
 			itemDel: map[string]*AcmeCerts{
-				"cert1": {d1},
+				"cert1": {certs: d1},
 			},
 			expAdd: map[string]*AcmeCerts{
-				"cert2": {d1},
+				"cert2": {certs: d1},
 			},
 			expDel: map[string]*AcmeCerts{},
 		},
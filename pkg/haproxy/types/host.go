@@ -39,6 +39,13 @@ func CreatePathLink(hostname, path string) PathLink {
 	}
 }
 
+// CreateTCPServiceLink ...
+func CreateTCPServiceLink(port int) PathLink {
+	return PathLink{
+		tcpPort: port,
+	}
+}
+
 // AcquireHost ...
 func (h *Hosts) AcquireHost(hostname string) *Host {
 	if host := h.FindHost(hostname); host != nil {
@@ -224,7 +231,25 @@ func (h *Host) SetSSLPassthrough(value bool) {
 
 // IsEmpty ...
 func (l *PathLink) IsEmpty() bool {
-	return l.hostname == "" && l.path == ""
+	return l.hostname == "" && l.path == "" && l.tcpPort == 0
+}
+
+// String ...
+func (l *PathLink) String() string {
+	if l.tcpPort > 0 {
+		return fmt.Sprintf(":%d", l.tcpPort)
+	}
+	return l.hostname + l.path
+}
+
+// Hostname ...
+func (l *PathLink) Hostname() string {
+	return l.hostname
+}
+
+// MarshalText ...
+func (l PathLink) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
 }
 
 // Less ...
@@ -46,6 +46,21 @@ func (b *TCPBackends) Acquire(servicename string, port int) *TCPBackend {
 	return backend
 }
 
+// Items ...
+func (b *TCPBackends) Items() map[int]*TCPBackend {
+	return b.items
+}
+
+// ItemsAdd ...
+func (b *TCPBackends) ItemsAdd() map[int]*TCPBackend {
+	return b.itemsAdd
+}
+
+// ItemsDel ...
+func (b *TCPBackends) ItemsDel() map[int]*TCPBackend {
+	return b.itemsDel
+}
+
 // BuildSortedItems ...
 func (b *TCPBackends) BuildSortedItems() []*TCPBackend {
 	items := make([]*TCPBackend, len(b.items))
@@ -79,6 +94,16 @@ func (b *TCPBackends) Commit() {
 	b.itemsDel = map[int]*TCPBackend{}
 }
 
+// Remove ...
+func (b *TCPBackends) Remove(ports []int) {
+	for _, port := range ports {
+		if item, found := b.items[port]; found {
+			b.itemsDel[port] = item
+			delete(b.items, port)
+		}
+	}
+}
+
 // RemoveAll ...
 func (b *TCPBackends) RemoveAll() {
 	for port, item := range b.items {
@@ -87,14 +112,34 @@ func (b *TCPBackends) RemoveAll() {
 	}
 }
 
+// ProxyName ...
+func (b *TCPBackend) ProxyName() string {
+	return fmt.Sprintf("_tcp_%s_%d", b.Name, b.Port)
+}
+
 // AddEndpoint ...
 func (b *TCPBackend) AddEndpoint(ip string, port int) *TCPEndpoint {
 	ep := &TCPEndpoint{
-		Name:   fmt.Sprintf("srv%03d", len(b.Endpoints)+1),
-		IP:     ip,
-		Port:   port,
-		Target: fmt.Sprintf("%s:%d", ip, port),
+		Enabled: true,
+		Name:    fmt.Sprintf("srv%03d", len(b.Endpoints)+1),
+		IP:      ip,
+		Port:    port,
+		Target:  fmt.Sprintf("%s:%d", ip, port),
 	}
 	b.Endpoints = append(b.Endpoints, ep)
 	return ep
 }
+
+// AddEmptyEndpoint ...
+func (b *TCPBackend) AddEmptyEndpoint() *TCPEndpoint {
+	ep := b.AddEndpoint("127.0.0.1", 1023)
+	ep.Enabled = false
+	return ep
+}
+
+// SortEndpoints ...
+func (b *TCPBackend) SortEndpoints() {
+	sort.SliceStable(b.Endpoints, func(i, j int) bool {
+		return b.Endpoints[i].Name < b.Endpoints[j].Name
+	})
+}
@@ -22,11 +22,22 @@ import (
 
 // AcmeData ...
 type AcmeData struct {
-	storages    *AcmeStorages
-	Emails      string
-	Endpoint    string
-	Expiring    time.Duration
-	TermsAgreed bool
+	storages      *AcmeStorages
+	CABundle      []byte
+	EABHMACKey    []byte
+	EABKeyID      string
+	Emails        string
+	Endpoint      string
+	Expiring      time.Duration
+	ExpiringRatio float64
+	// PreferredChain is the issuer common name of the topmost certificate
+	// of the alternate chain that should be used if offered by the CA.
+	PreferredChain string
+	TermsAgreed    bool
+	// OrphanActions are applied to the certificate of a storage that
+	// isn't used anymore, after OrphanGracePeriod. See acme.OrphanAction*.
+	OrphanActions     []string
+	OrphanGracePeriod time.Duration
 }
 
 // AcmeStorages ...
@@ -36,15 +47,21 @@ type AcmeStorages struct {
 
 // AcmeCerts ...
 type AcmeCerts struct {
-	certs map[string]struct{}
+	certs       map[string]struct{}
+	challenge   string
+	dnsProvider string
+	keyType     string
+	dualKeyType string
 }
 
 // Acme ...
 type Acme struct {
-	Enabled bool
-	Prefix  string
-	Shared  bool
-	Socket  string
+	Enabled   bool
+	Prefix    string
+	Shared    bool
+	Socket    string
+	TLSALPN   bool
+	TLSSocket string
 }
 
 // Global ...
@@ -59,7 +76,9 @@ type Global struct {
 	ModSecurity     ModSecurityConfig
 	Cookie          CookieConfig
 	DrainSupport    DrainConfig
+	DynamicTCP      DynBackendConfig
 	Acme            Acme
+	GRPCHealthAgent GRPCHealthAgentConfig
 	ForwardFor      string
 	LoadServerState bool
 	AdminSocket     string
@@ -86,6 +105,12 @@ type GlobalBindConfig struct {
 	FrontingUseProto bool
 }
 
+// GRPCHealthAgentConfig ...
+type GRPCHealthAgentConfig struct {
+	Addr string
+	Port int
+}
+
 // ProcsConfig ...
 type ProcsConfig struct {
 	Nbproc          int
@@ -127,6 +152,7 @@ type SSLConfig struct {
 	Engine              string
 	HeadersPrefix       string
 	ModeAsync           bool
+	OCSPCheckSocket     string
 	Options             string
 	RedirectCode        int
 }
@@ -216,20 +242,27 @@ type TCPBackends struct {
 
 // TCPBackend ...
 type TCPBackend struct {
-	Name          string
-	Port          int
-	Endpoints     []*TCPEndpoint
-	CheckInterval string
-	SSL           TCPSSL
-	ProxyProt     TCPProxyProt
+	Name             string
+	Port             int
+	Endpoints        []*TCPEndpoint
+	Annotated        bool
+	BalanceAlgorithm string
+	CheckInterval    string
+	Dynamic          DynBackendConfig
+	MaxConn          int
+	SSL              TCPSSL
+	ProxyProt        TCPProxyProt
+	Timeout          TCPTimeoutConfig
+	Whitelist        []string
 }
 
 // TCPEndpoint ...
 type TCPEndpoint struct {
-	Name   string
-	IP     string
-	Port   int
-	Target string
+	Enabled bool
+	Name    string
+	IP      string
+	Port    int
+	Target  string
 }
 
 // TCPSSL ...
@@ -245,6 +278,12 @@ type TCPProxyProt struct {
 	EncodeVersion string
 }
 
+// TCPTimeoutConfig ...
+type TCPTimeoutConfig struct {
+	Client string
+	Server string
+}
+
 // HostsMapEntry ...
 type HostsMapEntry struct {
 	hostname string
@@ -282,6 +321,8 @@ type FrontendMaps struct {
 	TLSNeedCrtList        *HostsMap
 	TLSInvalidCrtPagesMap *HostsMap
 	TLSMissingCrtPagesMap *HostsMap
+	TLSOCSPCheckList      *HostsMap
+	TLSOCSPFailOpenList   *HostsMap
 	//
 	CrtList *HostsMap
 }
@@ -349,6 +390,9 @@ const (
 type PathLink struct {
 	hostname string
 	path     string
+	// tcpPort is the public port of a TCP service,
+	// which has neither hostname nor path
+	tcpPort int
 }
 
 // HostPath ...
@@ -362,6 +406,8 @@ type HostPath struct {
 	Link    PathLink
 	Match   MatchType
 	Backend HostBackend
+	// Ingress is the namespace/name of the ingress that declared the path
+	Ingress string
 }
 
 // HostBackend ...
@@ -389,8 +435,13 @@ type HostTLSConfig struct {
 	CipherSuites     string
 	CRLFilename      string
 	CRLHash          string
+	CRLRefresh       time.Duration
+	OCSPCheck        bool
+	OCSPFailOpen     bool
+	OCSPStapling     bool
 	Options          string
 	TLSCommonName    string
+	TLSDualFilename  string
 	TLSFilename      string
 	TLSHash          string
 	TLSNotAfter      time.Time
@@ -591,12 +642,14 @@ type DynBackendConfig struct {
 
 // HealthCheck ...
 type HealthCheck struct {
-	Addr      string
-	FallCount int
-	Interval  string
-	Port      int
-	RiseCount int
-	URI       string
+	Addr        string
+	FallCount   int
+	GRPC        bool
+	GRPCService string
+	Interval    string
+	Port        int
+	RiseCount   int
+	URI         string
 }
 
 // BackendLimit ...
@@ -616,14 +669,17 @@ type OAuthConfig struct {
 
 // ServerConfig ...
 type ServerConfig struct {
+	ALPN          string
 	CAFilename    string
 	CAHash        string
+	CheckSNI      string
 	Ciphers       string // TLS up to 1.2
 	CipherSuites  string // TLS 1.3
 	CRLFilename   string
 	CRLHash       string
 	CrtFilename   string
 	CrtHash       string
+	GRPC          bool
 	InitialWeight int
 	MaxConn       int
 	MaxQueue      int
@@ -631,6 +687,8 @@ type ServerConfig struct {
 	Protocol      string
 	Secure        bool
 	SendProxy     string
+	SNI           string // sample expression
+	VerifyHost    string
 }
 
 // BackendTimeoutConfig ...
@@ -67,6 +67,16 @@ func (u *Userlists) BuildSortedItems() []*Userlist {
 	return items
 }
 
+// ItemsAdd ...
+func (u *Userlists) ItemsAdd() map[string]*Userlist {
+	return u.itemsAdd
+}
+
+// ItemsDel ...
+func (u *Userlists) ItemsDel() map[string]*Userlist {
+	return u.itemsDel
+}
+
 // RemoveAll ...
 func (u *Userlists) RemoveAll(userlists []string) {
 	for _, userlist := range userlists {
@@ -18,7 +18,11 @@ package utils
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
+	"os/user"
+	"strconv"
 	"time"
 )
 
@@ -48,3 +52,54 @@ func HAProxyCommand(socket string, observer func(duration time.Duration), comman
 	}
 	return msg, nil
 }
+
+// HAProxyCommandOutput sends a command to the admin socket and returns
+// its whole response. haproxy closes non interactive connections after
+// the response is sent, so the socket is read until EOF or until timeout.
+func HAProxyCommandOutput(socket string, observer func(duration time.Duration), timeout time.Duration, command string) (string, error) {
+	start := time.Now()
+	c, err := net.DialTimeout("unix", socket, timeout)
+	if err != nil {
+		return "", fmt.Errorf("error connecting to unix socket %s: %v", socket, err)
+	}
+	defer c.Close()
+	if err := c.SetDeadline(start.Add(timeout)); err != nil {
+		return "", fmt.Errorf("error configuring deadline of unix socket %s: %v", socket, err)
+	}
+	command = command + "\n"
+	if sent, err := c.Write([]byte(command)); err != nil {
+		return "", fmt.Errorf("error sending to unix socket %s: %v", socket, err)
+	} else if sent != len(command) {
+		return "", fmt.Errorf("incomplete data sent to unix socket %s", socket)
+	}
+	out, err := ioutil.ReadAll(c)
+	if err != nil {
+		return "", fmt.Errorf("error reading response: %v", err)
+	}
+	observer(time.Since(start))
+	return string(out), nil
+}
+
+// ListenUnix creates a listener on a unix socket which can be
+// used by the haproxy user, if such user exists.
+func ListenUnix(socket string) (net.Listener, error) {
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	if user, err := user.Lookup("haproxy"); err == nil {
+		uid, e1 := strconv.Atoi(user.Uid)
+		gid, e2 := strconv.Atoi(user.Gid)
+		if e1 == nil && e2 == nil {
+			if err := os.Chown(socket, uid, gid); err != nil {
+				l.Close()
+				return nil, err
+			}
+			if err := os.Chmod(socket, 0600); err != nil {
+				l.Close()
+				return nil, err
+			}
+		}
+	}
+	return l, nil
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package introspect
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+// Prefix is the URI prefix of the introspection API.
+const Prefix = "/introspect/"
+
+const redacted = "<redacted>"
+
+// ReadFunc provides the state read by the introspection API. It should
+// call fn while the state cannot be changed by a running sync.
+type ReadFunc func(fn func(config haproxy.Config, sync *Sync))
+
+// Sync has the changes of the last sync.
+type Sync struct {
+	ID             int      `json:"id"`
+	FullSync       bool     `json:"fullSync"`
+	ChangedObjects []string `json:"changedObjects"`
+	Commands       []string `json:"commands"`
+}
+
+// Filter restricts the hosts, backends, tcp backends and userlists of a
+// response. Empty fields do not filter.
+type Filter struct {
+	Namespace string
+	Host      string
+	Backend   string
+}
+
+// NewHandler ...
+func NewHandler(read ReadFunc) http.Handler {
+	return &handler{read: read}
+}
+
+type handler struct {
+	read ReadFunc
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	filter := &Filter{
+		Namespace: query.Get("namespace"),
+		Host:      query.Get("host"),
+		Backend:   query.Get("backend"),
+	}
+	section := strings.TrimPrefix(r.URL.Path, Prefix)
+	// the model is serialized while the sync is locked, the response
+	// is written afterwards so a slow client doesn't hold the lock
+	var buf bytes.Buffer
+	var readErr, encErr error
+	h.read(func(config haproxy.Config, sync *Sync) {
+		var out interface{}
+		out, readErr = Read(config, sync, section, filter)
+		if readErr == nil {
+			enc := json.NewEncoder(&buf)
+			enc.SetIndent("", "  ")
+			enc.SetEscapeHTML(false)
+			encErr = enc.Encode(out)
+		}
+	})
+	if readErr != nil {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "%v\n", readErr)
+		return
+	}
+	if encErr != nil {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "%v\n", encErr)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// Read returns a section of the model: global, acme, hosts, backends,
+// tcpbackends, userlists or sync. Secrets like passwords and keys are
+// redacted. Read should be called while config cannot be changed, and
+// the returned value should be serialized before config is changed.
+func Read(config haproxy.Config, sync *Sync, section string, filter *Filter) (interface{}, error) {
+	switch section {
+	case "global":
+		global := *config.Global()
+		if global.Cookie.Key != "" {
+			global.Cookie.Key = redacted
+		}
+		if global.Stats.Auth != "" {
+			global.Stats.Auth = redacted
+		}
+		return &global, nil
+	case "acme":
+		acme := *config.AcmeData()
+		if acme.EABHMACKey != nil {
+			acme.EABHMACKey = []byte(redacted)
+		}
+		return &acme, nil
+	case "hosts":
+		return readHosts(config, filter), nil
+	case "backends":
+		return readBackends(config, filter), nil
+	case "tcpbackends":
+		return readTCPBackends(config, filter), nil
+	case "userlists":
+		return readUserlists(config, filter), nil
+	case "sync":
+		if sync == nil {
+			sync = &Sync{}
+		}
+		return sync, nil
+	}
+	return nil, fmt.Errorf("section not found: '%s'", section)
+}
+
+func readHosts(config haproxy.Config, filter *Filter) []*hatypes.Host {
+	hosts := []*hatypes.Host{}
+	for _, host := range config.Hosts().BuildSortedItems() {
+		if filter.Host != "" && host.Hostname != filter.Host {
+			continue
+		}
+		if filter.Namespace != "" || filter.Backend != "" {
+			var match bool
+			for _, path := range host.Paths {
+				if matchBackend(filter, path.Backend.Namespace, path.Backend.ID) {
+					match = true
+					break
+				}
+			}
+			if !match {
+				continue
+			}
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+func readBackends(config haproxy.Config, filter *Filter) []*hatypes.Backend {
+	backends := []*hatypes.Backend{}
+	// BuildSortedItems() doesn't list backends if shards are configured
+	items := config.Backends().Items()
+	ids := make([]string, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		backend := items[id]
+		if !matchBackend(filter, backend.Namespace, backend.ID) {
+			continue
+		}
+		if filter.Host != "" {
+			var match bool
+			for _, path := range backend.Paths {
+				if path.Link.Hostname() == filter.Host {
+					match = true
+					break
+				}
+			}
+			if !match {
+				continue
+			}
+		}
+		backends = append(backends, backend)
+	}
+	return backends
+}
+
+func readTCPBackends(config haproxy.Config, filter *Filter) []*hatypes.TCPBackend {
+	backends := []*hatypes.TCPBackend{}
+	if filter.Host != "" || filter.Backend != "" {
+		// tcp backends have neither hostnames nor ingress backend IDs
+		return backends
+	}
+	for _, backend := range config.TCPBackends().BuildSortedItems() {
+		if filter.Namespace != "" && !strings.HasPrefix(backend.Name, filter.Namespace+"_") {
+			continue
+		}
+		backends = append(backends, backend)
+	}
+	return backends
+}
+
+func readUserlists(config haproxy.Config, filter *Filter) []*hatypes.Userlist {
+	userlists := []*hatypes.Userlist{}
+	if filter.Host != "" || filter.Backend != "" {
+		return userlists
+	}
+	for _, userlist := range config.Userlists().BuildSortedItems() {
+		// userlists are named after their secret, namespace_secretname
+		if filter.Namespace != "" && !strings.HasPrefix(userlist.Name, filter.Namespace+"_") {
+			continue
+		}
+		users := make([]hatypes.User, len(userlist.Users))
+		for i, user := range userlist.Users {
+			users[i] = user
+			users[i].Passwd = redacted
+		}
+		userlists = append(userlists, &hatypes.Userlist{
+			Name:  userlist.Name,
+			Users: users,
+		})
+	}
+	return userlists
+}
+
+func matchBackend(filter *Filter, namespace, backendID string) bool {
+	if filter.Namespace != "" && namespace != filter.Namespace {
+		return false
+	}
+	if filter.Backend != "" && backendID != filter.Backend {
+		return false
+	}
+	return true
+}
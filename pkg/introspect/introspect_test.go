@@ -0,0 +1,231 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package introspect
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestRead(t *testing.T) {
+	testCases := []struct {
+		section string
+		filter  Filter
+		expIDs  []string
+	}{
+		// 0
+		{
+			section: "hosts",
+			expIDs:  []string{"d1.local", "d2.local"},
+		},
+		// 1
+		{
+			section: "hosts",
+			filter:  Filter{Namespace: "team2"},
+			expIDs:  []string{"d2.local"},
+		},
+		// 2
+		{
+			section: "hosts",
+			filter:  Filter{Backend: "team1_app_8080"},
+			expIDs:  []string{"d1.local"},
+		},
+		// 3
+		{
+			section: "backends",
+			expIDs:  []string{"team1_app_8080", "team1_echo_8080", "team2_app_8080"},
+		},
+		// 4
+		{
+			section: "backends",
+			filter:  Filter{Namespace: "team1"},
+			expIDs:  []string{"team1_app_8080", "team1_echo_8080"},
+		},
+		// 5
+		{
+			section: "backends",
+			filter:  Filter{Host: "d2.local"},
+			expIDs:  []string{"team1_echo_8080", "team2_app_8080"},
+		},
+		// 6
+		{
+			section: "backends",
+			filter:  Filter{Host: "d2.local", Namespace: "team2"},
+			expIDs:  []string{"team2_app_8080"},
+		},
+		// 7
+		{
+			section: "tcpbackends",
+			filter:  Filter{Namespace: "team2"},
+			expIDs:  []string{"team2_pg"},
+		},
+		// 8
+		{
+			section: "tcpbackends",
+			filter:  Filter{Host: "d1.local"},
+			expIDs:  []string{},
+		},
+		// 9
+		{
+			section: "userlists",
+			expIDs:  []string{"team1_users", "team2_users"},
+		},
+		// 10
+		{
+			section: "userlists",
+			filter:  Filter{Namespace: "team1"},
+			expIDs:  []string{"team1_users"},
+		},
+	}
+	config := setupConfig(t)
+	for i, test := range testCases {
+		out, err := Read(config, nil, test.section, &test.filter)
+		if err != nil {
+			t.Errorf("unexpected error on %d: %v", i, err)
+			continue
+		}
+		ids := []string{}
+		switch items := out.(type) {
+		case []*hatypes.Host:
+			for _, host := range items {
+				ids = append(ids, host.Hostname)
+			}
+		case []*hatypes.Backend:
+			for _, backend := range items {
+				ids = append(ids, backend.ID)
+			}
+		case []*hatypes.TCPBackend:
+			for _, backend := range items {
+				ids = append(ids, backend.Name)
+			}
+		case []*hatypes.Userlist:
+			for _, userlist := range items {
+				ids = append(ids, userlist.Name)
+			}
+		}
+		if !reflect.DeepEqual(ids, test.expIDs) {
+			t.Errorf("items differ on %d - expected: %v, actual: %v", i, test.expIDs, ids)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	testCases := []struct {
+		uri       string
+		expStatus int
+		expBody   string
+	}{
+		// 0
+		{
+			uri:       "/introspect/frontend",
+			expStatus: http.StatusNotFound,
+			expBody:   "section not found: 'frontend'",
+		},
+		// 1
+		{
+			uri:       "/introspect/sync",
+			expStatus: http.StatusOK,
+			expBody: `
+{
+  "id": 2,
+  "fullSync": false,
+  "changedObjects": [
+    "update/endpoint:team1/app"
+  ],
+  "commands": [
+    "set server team1_app_8080/srv001 addr 172.17.0.12 port 8080"
+  ]
+}`,
+		},
+		// 2
+		{
+			uri:       "/introspect/userlists?namespace=team2",
+			expStatus: http.StatusOK,
+			expBody: `
+[
+  {
+    "Name": "team2_users",
+    "Users": [
+      {
+        "Name": "admin",
+        "Passwd": "<redacted>",
+        "Encrypted": true
+      }
+    ]
+  }
+]`,
+		},
+		// 3
+		{
+			uri:       "/introspect/hosts?namespace=team3",
+			expStatus: http.StatusOK,
+			expBody:   "[]",
+		},
+	}
+	config := setupConfig(t)
+	sync := &Sync{
+		ID:             2,
+		ChangedObjects: []string{"update/endpoint:team1/app"},
+		Commands:       []string{"set server team1_app_8080/srv001 addr 172.17.0.12 port 8080"},
+	}
+	var reads int
+	handler := NewHandler(func(fn func(config haproxy.Config, sync *Sync)) {
+		reads++
+		lastSync := *sync
+		fn(config, &lastSync)
+		// a sync running after the lock is released should not change the response
+		lastSync = Sync{ID: 3}
+	})
+	for i, test := range testCases {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.uri, nil))
+		if w.Code != test.expStatus {
+			t.Errorf("status differs on %d - expected: %d, actual: %d", i, test.expStatus, w.Code)
+		}
+		expBody := strings.Trim(test.expBody, "\n")
+		actualBody := strings.Trim(w.Body.String(), "\n")
+		if actualBody != expBody {
+			t.Errorf("body differs on %d - expected:\n%s\nactual:\n%s", i, expBody, actualBody)
+		}
+	}
+	if reads != len(testCases) {
+		t.Errorf("expected %d reads, actual: %d", len(testCases), reads)
+	}
+}
+
+func setupConfig(t *testing.T) haproxy.Config {
+	config := haproxy.CreateInstance(&helper_test.LoggerMock{T: t}, haproxy.InstanceOptions{}).Config()
+	b1 := config.Backends().AcquireBackend("team1", "app", "8080")
+	b2 := config.Backends().AcquireBackend("team1", "echo", "8080")
+	b3 := config.Backends().AcquireBackend("team2", "app", "8080")
+	config.Hosts().AcquireHost("d1.local").AddPath(b1, "/", hatypes.MatchPrefix)
+	h2 := config.Hosts().AcquireHost("d2.local")
+	h2.AddPath(b3, "/", hatypes.MatchPrefix)
+	h2.AddPath(b2, "/echo", hatypes.MatchPrefix)
+	config.TCPBackends().Acquire("team1_pg", 5432)
+	config.TCPBackends().Acquire("team2_pg", 5433)
+	config.Userlists().Replace("team1_users", []hatypes.User{{Name: "user1", Passwd: "secret1"}})
+	config.Userlists().Replace("team2_users", []hatypes.User{{Name: "admin", Passwd: "$5$xxx", Encrypted: true}})
+	return config
+}
@@ -24,9 +24,14 @@ import (
 	"github.com/golang/glog"
 
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/explain"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "explain" {
+		// explain command-line, queries the explain endpoint of a running controller
+		os.Exit(explain.RunCLI(os.Args[2:], os.Stdout, os.Stderr))
+	}
 	hc := controller.NewHAProxyController()
 	errCh := make(chan error)
 	go handleSignal(hc, errCh)
@@ -0,0 +1,204 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ocsp
+
+import (
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	hautils "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/utils"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// ClientCertHeader is the header used by haproxy to send the client
+// certificate to the checker, DER encoded and base64 encoded.
+const ClientCertHeader = "X-SSL-Client-Cert"
+
+// NewChecker ...
+func NewChecker(logger types.Logger, socket string) Checker {
+	return &checker{
+		logger:    logger,
+		socket:    socket,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		cas:       map[string]*caFile{},
+		responses: map[string]*checkResponse{},
+	}
+}
+
+// Checker verifies the OCSP status of client certificates on behalf of
+// haproxy. Requests are made by the `ocsp-check` lua action and answered
+// with 200 if the certificate is good, 403 if it was revoked, or 503 if its
+// status could not be verified. Responses are cached up to their next update.
+type Checker interface {
+	// Update configures the CA files whose issued certificates can be checked.
+	Update(caFiles []string)
+	// Listen starts to answer the requests of haproxy on a unix socket.
+	Listen(stopCh chan struct{}) error
+}
+
+type checker struct {
+	logger    types.Logger
+	socket    string
+	client    *http.Client
+	server    *http.Server
+	mutex     sync.Mutex
+	cas       map[string]*caFile
+	responses map[string]*checkResponse
+}
+
+type caFile struct {
+	hash  string
+	certs []*x509.Certificate
+}
+
+type checkResponse struct {
+	status int
+	expire time.Time
+}
+
+func (c *checker) Update(caFiles []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cas := make(map[string]*caFile, len(caFiles))
+	for _, filename := range caFiles {
+		if _, found := cas[filename]; found {
+			continue
+		}
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			c.logger.Warn("ocsp: error reading CA file %s: %v", filename, err)
+			continue
+		}
+		hash := fmt.Sprintf("%x", sha1.Sum(content))
+		ca, found := c.cas[filename]
+		if !found || ca.hash != hash {
+			ca = &caFile{hash: hash}
+			for block, rest := pem.Decode(content); block != nil; block, rest = pem.Decode(rest) {
+				if block.Type != "CERTIFICATE" {
+					continue
+				}
+				if crt, err := x509.ParseCertificate(block.Bytes); err == nil {
+					ca.certs = append(ca.certs, crt)
+				}
+			}
+		}
+		cas[filename] = ca
+	}
+	c.cas = cas
+	now := time.Now()
+	for key, resp := range c.responses {
+		if resp.expire.Before(now) {
+			delete(c.responses, key)
+		}
+	}
+}
+
+func (c *checker) Listen(stopCh chan struct{}) error {
+	l, err := hautils.ListenUnix(c.socket)
+	if err != nil {
+		return err
+	}
+	c.server = &http.Server{Addr: c.socket, Handler: c}
+	c.logger.Info("ocsp: listening client certificate checks on unix socket: %s", c.socket)
+	go c.server.Serve(l)
+	go func() {
+		<-stopCh
+		c.logger.Info("ocsp: closing unix socket")
+		if err := c.server.Close(); err != nil {
+			c.logger.Error("ocsp: error closing socket: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (c *checker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	der, err := base64.StdEncoding.DecodeString(r.Header.Get(ClientCertHeader))
+	if err != nil || len(der) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(c.check(crt))
+}
+
+// check returns the OCSP status of crt as an HTTP status code
+func (c *checker) check(crt *x509.Certificate) int {
+	issuer := c.findIssuer(crt)
+	if issuer == nil {
+		c.logger.Warn("ocsp: issuer of the client certificate '%s' not found", crt.Subject.CommonName)
+		return http.StatusServiceUnavailable
+	}
+	key := fmt.Sprintf("%x:%x", sha1.Sum(issuer.Raw), crt.SerialNumber)
+	now := time.Now()
+	c.mutex.Lock()
+	resp, found := c.responses[key]
+	c.mutex.Unlock()
+	if found && resp.expire.After(now) {
+		return resp.status
+	}
+	resp = &checkResponse{}
+	if _, ocspResp, err := fetch(c.client, crt, issuer); err != nil {
+		c.logger.Warn("ocsp: error checking the client certificate '%s': %v", crt.Subject.CommonName, err)
+		resp.status = http.StatusServiceUnavailable
+		resp.expire = now.Add(minRefresh)
+	} else {
+		switch ocspResp.Status {
+		case ocsp.Good:
+			resp.status = http.StatusOK
+		case ocsp.Revoked:
+			c.logger.InfoV(2, "ocsp: client certificate '%s' was revoked at %s", crt.Subject.CommonName, ocspResp.RevokedAt.String())
+			resp.status = http.StatusForbidden
+		default:
+			c.logger.Warn("ocsp: status of the client certificate '%s' is unknown by the responder", crt.Subject.CommonName)
+			resp.status = http.StatusServiceUnavailable
+		}
+		resp.expire = ocspResp.NextUpdate
+		if resp.expire.IsZero() {
+			resp.expire = now.Add(noNextUpdateRefresh)
+		}
+	}
+	c.mutex.Lock()
+	c.responses[key] = resp
+	c.mutex.Unlock()
+	return resp.status
+}
+
+func (c *checker) findIssuer(crt *x509.Certificate) *x509.Certificate {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, ca := range c.cas {
+		for _, issuer := range ca.certs {
+			if crt.CheckSignatureFrom(issuer) == nil {
+				return issuer
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ocsp
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		status     int
+		httpStatus int
+		noCA       bool
+		header     string
+		expStatus  int
+		logging    string
+	}{
+		// 0
+		{
+			status:    ocsp.Good,
+			expStatus: http.StatusOK,
+		},
+		// 1
+		{
+			status:    ocsp.Revoked,
+			expStatus: http.StatusForbidden,
+			logging:   `INFO-V(2) ocsp: client certificate 'client1' was revoked at 2020-01-01 00:00:00 +0000 UTC`,
+		},
+		// 2
+		{
+			status:    ocsp.Unknown,
+			expStatus: http.StatusServiceUnavailable,
+			logging:   `WARN ocsp: status of the client certificate 'client1' is unknown by the responder`,
+		},
+		// 3
+		{
+			httpStatus: http.StatusInternalServerError,
+			expStatus:  http.StatusServiceUnavailable,
+			logging:    `WARN ocsp: error checking the client certificate 'client1': unexpected status code from <responder>: 500`,
+		},
+		// 4
+		{
+			noCA:      true,
+			expStatus: http.StatusServiceUnavailable,
+			logging: `
+WARN ocsp: issuer of the client certificate 'client1' not found
+WARN ocsp: issuer of the client certificate 'client1' not found`,
+		},
+		// 5
+		{
+			header:    "invalid",
+			expStatus: http.StatusBadRequest,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.status = test.status
+		c.httpStatus = test.httpStatus
+		checker := NewChecker(c.logger, "")
+		if !test.noCA {
+			checker.Update([]string{c.writeCA("ca.pem")})
+		}
+		header := test.header
+		if header == "" {
+			header = c.clientCert(1)
+		}
+		for j := 0; j < 2; j++ {
+			// the second request should be answered from the cache
+			if status := c.check(checker, header); status != test.expStatus {
+				t.Errorf("status differs on %d/%d - expected: %d, actual: %d", i, j, test.expStatus, status)
+			}
+		}
+		expRequests := 1
+		if test.noCA || test.header != "" {
+			expRequests = 0
+		}
+		if c.requests != expRequests {
+			t.Errorf("requests differ on %d - expected: %d, actual: %d", i, expRequests, c.requests)
+		}
+		c.compareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func (c *config) writeCA(name string) string {
+	caFile := filepath.Join(c.tempdir, name)
+	content := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.ca.Raw})
+	if err := ioutil.WriteFile(caFile, content, 0644); err != nil {
+		c.t.Fatalf("error writing CA certificate: %v", err)
+	}
+	return caFile
+}
+
+func (c *config) clientCert(serial int64) string {
+	crt, _ := newCert(&x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "client1"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		OCSPServer:   []string{c.responder.URL},
+	}, c.ca, c.caKey)
+	return base64.StdEncoding.EncodeToString(crt.Raw)
+}
+
+func (c *config) check(checker Checker, header string) int {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(ClientCertHeader, header)
+	w := httptest.NewRecorder()
+	checker.(http.Handler).ServeHTTP(w, req)
+	return w.Code
+}
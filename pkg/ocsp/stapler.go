@@ -0,0 +1,283 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ocsp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	hautils "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/utils"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+const (
+	// maxResponseSize is the maximum size of an OCSP response read from a responder
+	maxResponseSize = 64 * 1024
+	// failRetry is the time to wait before trying to fetch a response again after a failure
+	failRetry = 5 * time.Minute
+	// noNextUpdateRefresh is the refresh interval of responses without a next update
+	noNextUpdateRefresh = time.Hour
+	// minRefresh is the shortest time between two requests of the same certificate
+	minRefresh = time.Minute
+)
+
+// NewStapler ...
+func NewStapler(logger types.Logger, metrics types.Metrics) Stapler {
+	return &stapler{
+		logger:  logger,
+		metrics: metrics,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		crts:    map[string]*crtState{},
+	}
+}
+
+// Stapler manages the OCSP responses of certificate files, they are stored
+// next to the certificate with the `.ocsp` extension, where haproxy reads them
+// on start, and are also sent to the running haproxy via its admin socket.
+type Stapler interface {
+	// Update configures the admin socket of haproxy and the PEM files whose
+	// OCSP responses should be managed. Files missing from a former update
+	// are not managed anymore. Update should be called before haproxy reloads,
+	// so stale responses of changed certificates are removed in time.
+	Update(socket string, crtFiles []string)
+	// Refresh fetches the OCSP responses which are missing or need to be
+	// refreshed. Refresh should be called periodically.
+	Refresh()
+}
+
+type stapler struct {
+	logger  types.Logger
+	metrics types.Metrics
+	client  *http.Client
+	mutex   sync.Mutex
+	socket  string
+	crts    map[string]*crtState
+}
+
+type crtState struct {
+	hash        string
+	nextRefresh time.Time
+}
+
+func (s *stapler) Update(socket string, crtFiles []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.socket = socket
+	crts := make(map[string]*crtState, len(crtFiles))
+	for _, crtFile := range crtFiles {
+		if _, found := crts[crtFile]; found {
+			continue
+		}
+		content, err := ioutil.ReadFile(crtFile)
+		if err != nil {
+			s.logger.Warn("ocsp: error reading certificate file %s: %v", crtFile, err)
+			continue
+		}
+		hash := fmt.Sprintf("%x", sha1.Sum(content))
+		crt, found := s.crts[crtFile]
+		if !found || crt.hash != hash {
+			if found {
+				// the response of the former certificate would be
+				// refused by haproxy on the next reload
+				s.removeResponse(crtFile)
+			}
+			crt = &crtState{hash: hash}
+		}
+		crts[crtFile] = crt
+	}
+	for crtFile := range s.crts {
+		if _, found := crts[crtFile]; !found {
+			s.metrics.SetOCSPNextUpdate(crtFile, nil)
+		}
+	}
+	s.crts = crts
+}
+
+func (s *stapler) Refresh() {
+	now := time.Now()
+	s.mutex.Lock()
+	crts := make(map[string]string, len(s.crts))
+	crtFiles := make([]string, 0, len(s.crts))
+	for crtFile, crt := range s.crts {
+		if !crt.nextRefresh.After(now) {
+			crts[crtFile] = crt.hash
+			crtFiles = append(crtFiles, crtFile)
+		}
+	}
+	s.mutex.Unlock()
+	sort.Strings(crtFiles)
+	// requests to the responders and admin socket commands run without
+	// the lock, so Update() doesn't wait for slow OCSP responders
+	for _, crtFile := range crtFiles {
+		if err := s.staple(crtFile, crts[crtFile]); err != nil {
+			s.logger.Warn("ocsp: error updating the response of %s: %v", crtFile, err)
+			s.setNextRefresh(crtFile, crts[crtFile], now.Add(failRetry))
+		}
+	}
+}
+
+// staple fetches a new OCSP response of crtFile, stores it on disk and sends
+// it to haproxy. hash is the hash of crtFile when the refresh started, the
+// response is discarded if the certificate changed in the mean time.
+func (s *stapler) staple(crtFile, hash string) error {
+	leaf, issuer, err := readChain(crtFile)
+	if err != nil {
+		return err
+	}
+	der, resp, err := fetch(s.client, leaf, issuer)
+	if err != nil {
+		return err
+	}
+	switch resp.Status {
+	case ocsp.Good:
+	case ocsp.Revoked:
+		s.logger.Warn("ocsp: certificate %s was revoked at %s", crtFile, resp.RevokedAt.String())
+	default:
+		return fmt.Errorf("status of the certificate is unknown by the responder")
+	}
+	socket, stored, err := s.store(crtFile, hash, der, resp)
+	if err != nil || !stored || socket == "" {
+		return err
+	}
+	cmd := "set ssl ocsp-response " + base64.StdEncoding.EncodeToString(der)
+	msg, err := hautils.HAProxyCommand(socket, s.metrics.HAProxySetSSLOCSPResponseTime, cmd)
+	if err != nil {
+		// the response is already on disk and will be used on the next
+		// reload, this is also the case of a certificate that didn't
+		// have a response when haproxy started
+		s.logger.Warn("ocsp: error sending the response of %s to haproxy: %v", crtFile, err)
+	} else {
+		s.logger.InfoV(2, "ocsp: response of %s updated: %v", crtFile, msg)
+	}
+	return nil
+}
+
+// store writes the OCSP response of crtFile if it is still managed and
+// didn't change during the request. It returns the admin socket of haproxy.
+func (s *stapler) store(crtFile, hash string, der []byte, resp *ocsp.Response) (socket string, stored bool, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	crt, found := s.crts[crtFile]
+	if !found || crt.hash != hash {
+		return "", false, nil
+	}
+	if err := ioutil.WriteFile(crtFile+".ocsp", der, 0644); err != nil {
+		return "", false, err
+	}
+	if resp.NextUpdate.IsZero() {
+		s.metrics.SetOCSPNextUpdate(crtFile, nil)
+	} else {
+		s.metrics.SetOCSPNextUpdate(crtFile, &resp.NextUpdate)
+	}
+	crt.nextRefresh = refreshTime(resp)
+	return s.socket, true, nil
+}
+
+func (s *stapler) setNextRefresh(crtFile, hash string, nextRefresh time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if crt, found := s.crts[crtFile]; found && crt.hash == hash {
+		crt.nextRefresh = nextRefresh
+	}
+}
+
+// fetch requests the OCSP status of leaf to its responder
+func fetch(client *http.Client, leaf, issuer *x509.Certificate) ([]byte, *ocsp.Response, error) {
+	if len(leaf.OCSPServer) == 0 {
+		return nil, nil, fmt.Errorf("certificate does not have an OCSP responder URL")
+	}
+	req, err := ocsp.CreateRequest(leaf, issuer, &ocsp.RequestOptions{Hash: crypto.SHA1})
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := client.Post(leaf.OCSPServer[0], "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected status code from %s: %d", leaf.OCSPServer[0], res.StatusCode)
+	}
+	der, err := ioutil.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := ocsp.ParseResponseForCert(der, leaf, issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+	return der, resp, nil
+}
+
+func (s *stapler) removeResponse(crtFile string) {
+	if err := os.Remove(crtFile + ".ocsp"); err != nil && !os.IsNotExist(err) {
+		s.logger.Warn("ocsp: error removing the stale response of %s: %v", crtFile, err)
+	}
+	s.metrics.SetOCSPNextUpdate(crtFile, nil)
+}
+
+// readChain returns the leaf certificate of a PEM file and its issuer,
+// which should be the next certificate of the chain.
+func readChain(crtFile string) (leaf, issuer *x509.Certificate, err error) {
+	content, err := ioutil.ReadFile(crtFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	var chain []*x509.Certificate
+	for block, rest := pem.Decode(content); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		crt, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		chain = append(chain, crt)
+	}
+	if len(chain) < 2 {
+		return nil, nil, fmt.Errorf("issuer certificate not found, the chain should have the leaf and its issuer")
+	}
+	return chain[0], chain[1], nil
+}
+
+// refreshTime returns when a response should be refreshed: on the half
+// of its validity, so a failing responder has time to recover.
+func refreshTime(resp *ocsp.Response) time.Time {
+	now := time.Now()
+	if resp.NextUpdate.IsZero() {
+		return now.Add(noNextUpdateRefresh)
+	}
+	refresh := resp.ThisUpdate.Add(resp.NextUpdate.Sub(resp.ThisUpdate) / 2)
+	if minimum := now.Add(minRefresh); refresh.Before(minimum) {
+		return minimum
+	}
+	return refresh
+}
@@ -0,0 +1,400 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ocsp
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	types_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestRefresh(t *testing.T) {
+	testCases := []struct {
+		status     int
+		httpStatus int
+		noIssuer   bool
+		expStapled bool
+		logging    string
+	}{
+		// 0
+		{
+			status:     ocsp.Good,
+			expStapled: true,
+			logging:    `INFO-V(2) ocsp: response of <dir>/crt1.pem updated: [response from server: OCSP Response updated!]`,
+		},
+		// 1
+		{
+			status:     ocsp.Revoked,
+			expStapled: true,
+			logging: `
+WARN ocsp: certificate <dir>/crt1.pem was revoked at 2020-01-01 00:00:00 +0000 UTC
+INFO-V(2) ocsp: response of <dir>/crt1.pem updated: [response from server: OCSP Response updated!]`,
+		},
+		// 2
+		{
+			status:  ocsp.Unknown,
+			logging: `WARN ocsp: error updating the response of <dir>/crt1.pem: status of the certificate is unknown by the responder`,
+		},
+		// 3
+		{
+			httpStatus: http.StatusInternalServerError,
+			logging:    `WARN ocsp: error updating the response of <dir>/crt1.pem: unexpected status code from <responder>: 500`,
+		},
+		// 4
+		{
+			noIssuer: true,
+			logging:  `WARN ocsp: error updating the response of <dir>/crt1.pem: issuer certificate not found, the chain should have the leaf and its issuer`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.status = test.status
+		c.httpStatus = test.httpStatus
+		crtFile := c.writeCrt("crt1.pem", 1, !test.noIssuer)
+		c.stapler.Update(c.socket, []string{crtFile})
+		c.stapler.Refresh()
+		der, _ := ioutil.ReadFile(crtFile + ".ocsp")
+		if stapled := der != nil; stapled != test.expStapled {
+			t.Errorf("stapled differs on %d - expected: %t, actual: %t", i, test.expStapled, stapled)
+		}
+		var expCmds []string
+		if test.expStapled {
+			expCmds = []string{"set ssl ocsp-response " + base64.StdEncoding.EncodeToString(der)}
+			if _, found := c.metrics.nextUpdate[crtFile]; !found {
+				t.Errorf("next update metric not found on %d", i)
+			}
+		}
+		if cmds := c.commands(); !reflect.DeepEqual(cmds, expCmds) {
+			t.Errorf("commands differ on %d - expected: %v, actual: %v", i, expCmds, cmds)
+		}
+		c.compareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestRefreshSchedule(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+	crtFile := c.writeCrt("crt1.pem", 1, true)
+	c.stapler.Update(c.socket, []string{crtFile})
+
+	c.stapler.Refresh()
+	c.compareLogging(`INFO-V(2) ocsp: response of <dir>/crt1.pem updated: [response from server: OCSP Response updated!]`)
+	if requests := c.requests; requests != 1 {
+		t.Errorf("expected 1 request, found %d", requests)
+	}
+
+	// response is fresh, nothing to do
+	c.stapler.Update(c.socket, []string{crtFile})
+	c.stapler.Refresh()
+	if requests := c.requests; requests != 1 {
+		t.Errorf("expected 1 request, found %d", requests)
+	}
+
+	// half of the validity has passed
+	c.stapler.(*stapler).crts[crtFile].nextRefresh = time.Now().Add(-time.Second)
+	c.stapler.Refresh()
+	c.compareLogging(`INFO-V(2) ocsp: response of <dir>/crt1.pem updated: [response from server: OCSP Response updated!]`)
+	if requests := c.requests; requests != 2 {
+		t.Errorf("expected 2 requests, found %d", requests)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+	crtFile1 := c.writeCrt("crt1.pem", 1, true)
+	crtFile2 := c.writeCrt("crt2.pem", 2, true)
+	c.stapler.Update(c.socket, []string{crtFile1, crtFile2})
+	c.stapler.Refresh()
+	c.logger.Logging = nil
+	for _, crtFile := range []string{crtFile1, crtFile2} {
+		if _, err := os.Stat(crtFile + ".ocsp"); err != nil {
+			t.Errorf("response of %s not found: %v", crtFile, err)
+		}
+	}
+
+	// crt1 changes and crt2 is not used anymore
+	c.writeCrt("crt1.pem", 3, true)
+	c.stapler.Update(c.socket, []string{crtFile1})
+	if _, err := os.Stat(crtFile1 + ".ocsp"); !os.IsNotExist(err) {
+		t.Errorf("stale response of %s should be removed", crtFile1)
+	}
+	if _, found := c.metrics.nextUpdate[crtFile2]; found {
+		t.Errorf("next update metric of %s should be removed", crtFile2)
+	}
+	c.stapler.Refresh()
+	c.compareLogging(`INFO-V(2) ocsp: response of <dir>/crt1.pem updated: [response from server: OCSP Response updated!]`)
+	if _, err := os.Stat(crtFile1 + ".ocsp"); err != nil {
+		t.Errorf("response of %s not found: %v", crtFile1, err)
+	}
+}
+
+func TestRefreshUnlocked(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+	crtFile := c.writeCrt("crt1.pem", 1, true)
+	c.stapler.Update(c.socket, []string{crtFile})
+
+	// the certificate changes while its response is being requested,
+	// Update() would deadlock if Refresh() held the lock during the request
+	c.onRequest = func() {
+		c.onRequest = nil
+		c.writeCrt("crt1.pem", 2, true)
+		c.stapler.Update(c.socket, []string{crtFile})
+	}
+	c.stapler.Refresh()
+	if _, err := os.Stat(crtFile + ".ocsp"); !os.IsNotExist(err) {
+		t.Errorf("response of the former certificate of %s should not be stored", crtFile)
+	}
+	if cmds := c.commands(); len(cmds) > 0 {
+		t.Errorf("no command expected, found: %v", cmds)
+	}
+
+	// the new certificate is still due
+	c.stapler.Refresh()
+	c.compareLogging(`INFO-V(2) ocsp: response of <dir>/crt1.pem updated: [response from server: OCSP Response updated!]`)
+	if _, err := os.Stat(crtFile + ".ocsp"); err != nil {
+		t.Errorf("response of %s not found: %v", crtFile, err)
+	}
+}
+
+func TestRefreshTime(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		thisUpdate time.Time
+		nextUpdate time.Time
+		expected   time.Duration
+	}{
+		// 0
+		{
+			thisUpdate: now.Add(-time.Hour),
+			nextUpdate: now.Add(47 * time.Hour),
+			expected:   23 * time.Hour,
+		},
+		// 1
+		{
+			thisUpdate: now.Add(-time.Hour),
+		},
+		// 2
+		{
+			thisUpdate: now.Add(-time.Hour),
+			nextUpdate: now.Add(time.Minute),
+			expected:   minRefresh,
+		},
+	}
+	for i, test := range testCases {
+		expected := test.expected
+		if test.nextUpdate.IsZero() {
+			expected = noNextUpdateRefresh
+		}
+		refresh := time.Until(refreshTime(&ocsp.Response{ThisUpdate: test.thisUpdate, NextUpdate: test.nextUpdate}))
+		if diff := refresh - expected; diff > time.Second || diff < -time.Second {
+			t.Errorf("refresh differs on %d - expected: %s, actual: %s", i, expected, refresh)
+		}
+	}
+}
+
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * *
+ *
+ *  BUILDERS
+ *
+ * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+type config struct {
+	t          *testing.T
+	logger     *types_helper.LoggerMock
+	metrics    *metricsMock
+	stapler    Stapler
+	tempdir    string
+	socket     string
+	listener   net.Listener
+	responder  *httptest.Server
+	ca         *x509.Certificate
+	caKey      *ecdsa.PrivateKey
+	status     int
+	httpStatus int
+	onRequest  func()
+	requests   int
+	mutex      sync.Mutex
+	cmds       []string
+}
+
+type metricsMock struct {
+	*types_helper.MetricsMock
+	nextUpdate map[string]time.Time
+}
+
+func (m *metricsMock) SetOCSPNextUpdate(crtFile string, nextUpdate *time.Time) {
+	if nextUpdate == nil {
+		delete(m.nextUpdate, crtFile)
+		return
+	}
+	m.nextUpdate[crtFile] = *nextUpdate
+}
+
+func setup(t *testing.T) *config {
+	tempdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating tempdir: %v", err)
+	}
+	logger := types_helper.NewLoggerMock(t)
+	metrics := &metricsMock{MetricsMock: types_helper.NewMetricsMock(), nextUpdate: map[string]time.Time{}}
+	c := &config{
+		t:       t,
+		logger:  logger,
+		metrics: metrics,
+		stapler: NewStapler(logger, metrics),
+		tempdir: tempdir,
+		socket:  filepath.Join(tempdir, "admin.sock"),
+	}
+	c.ca, c.caKey = newCert(&x509.Certificate{Subject: pkix.Name{CommonName: "Test CA"}, IsCA: true}, nil, nil)
+	c.responder = httptest.NewServer(http.HandlerFunc(c.respond))
+	c.listener, err = net.Listen("unix", c.socket)
+	if err != nil {
+		t.Fatalf("error listening admin socket: %v", err)
+	}
+	go c.serveAdmin()
+	return c
+}
+
+func (c *config) teardown() {
+	c.logger.CompareLogging("")
+	c.listener.Close()
+	c.responder.Close()
+	if err := os.RemoveAll(c.tempdir); err != nil {
+		c.t.Errorf("error removing tempdir: %v", err)
+	}
+}
+
+func (c *config) compareLogging(expected string) {
+	expected = strings.ReplaceAll(expected, "<dir>", c.tempdir)
+	expected = strings.ReplaceAll(expected, "<responder>", c.responder.URL)
+	c.logger.CompareLogging(expected)
+}
+
+// respond is a local OCSP responder stand-in
+func (c *config) respond(w http.ResponseWriter, r *http.Request) {
+	c.requests++
+	if c.onRequest != nil {
+		c.onRequest()
+	}
+	if c.httpStatus != 0 {
+		w.WriteHeader(c.httpStatus)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	req, err := ocsp.ParseRequest(body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	resp, err := ocsp.CreateResponse(c.ca, c.ca, ocsp.Response{
+		Status:           c.status,
+		SerialNumber:     req.SerialNumber,
+		ThisUpdate:       now.Add(-time.Hour),
+		NextUpdate:       now.Add(7 * 24 * time.Hour),
+		RevokedAt:        time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		RevocationReason: ocsp.Unspecified,
+	}, c.caKey)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(resp)
+}
+
+// serveAdmin is a haproxy admin socket stand-in
+func (c *config) serveAdmin() {
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			return
+		}
+		cmd, _ := bufio.NewReader(conn).ReadString('\n')
+		c.mutex.Lock()
+		c.cmds = append(c.cmds, strings.TrimSpace(cmd))
+		c.mutex.Unlock()
+		conn.Write([]byte("OCSP Response updated!\n\n"))
+		conn.Close()
+	}
+}
+
+func (c *config) commands() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.cmds
+}
+
+func (c *config) writeCrt(name string, serial int64, withIssuer bool) string {
+	leaf, leafKey := newCert(&x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "d1.local"},
+		DNSNames:     []string{"d1.local"},
+		OCSPServer:   []string{c.responder.URL},
+	}, c.ca, c.caKey)
+	content := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})
+	if withIssuer {
+		content = append(content, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.ca.Raw})...)
+	}
+	der, _ := x509.MarshalECPrivateKey(leafKey)
+	content = append(content, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})...)
+	crtFile := filepath.Join(c.tempdir, name)
+	if err := ioutil.WriteFile(crtFile, content, 0644); err != nil {
+		c.t.Fatalf("error writing certificate: %v", err)
+	}
+	return crtFile
+}
+
+func newCert(template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if template.SerialNumber == nil {
+		template.SerialNumber = big.NewInt(1)
+	}
+	template.BasicConstraintsValid = true
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(90 * 24 * time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	crt, _ := x509.ParseCertificate(der)
+	return crt, key
+}
@@ -19,6 +19,8 @@ package helper_test
 import (
 	"testing"
 	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 )
 
 // MetricsMock ...
@@ -36,10 +38,22 @@ func NewMetricsMock() *MetricsMock {
 func (m *MetricsMock) HAProxyShowInfoResponseTime(duration time.Duration) {
 }
 
+// HAProxyShowStatResponseTime ...
+func (m *MetricsMock) HAProxyShowStatResponseTime(duration time.Duration) {
+}
+
 // HAProxySetServerResponseTime ...
 func (m *MetricsMock) HAProxySetServerResponseTime(duration time.Duration) {
 }
 
+// HAProxySetSSLOCSPResponseTime ...
+func (m *MetricsMock) HAProxySetSSLOCSPResponseTime(duration time.Duration) {
+}
+
+// HAProxySetSSLCRLFileTime ...
+func (m *MetricsMock) HAProxySetSSLCRLFileTime(duration time.Duration) {
+}
+
 // ControllerProcTime ...
 func (m *MetricsMock) ControllerProcTime(task string, duration time.Duration) {
 
@@ -65,6 +79,14 @@ func (m *MetricsMock) IncUpdateFull() {
 func (m *MetricsMock) UpdateSuccessful(success bool) {
 }
 
+// IncReloadReason ...
+func (m *MetricsMock) IncReloadReason(reason string) {
+}
+
+// IncReloadNamespace ...
+func (m *MetricsMock) IncReloadNamespace(reason, namespace string) {
+}
+
 // SetCertExpireDate ...
 func (m *MetricsMock) SetCertExpireDate(domain, cn string, notAfter *time.Time) {
 }
@@ -80,3 +102,15 @@ func (m *MetricsMock) IncCertSigningExpiring(domains string, success bool) {
 // IncCertSigningOutdated ...
 func (m *MetricsMock) IncCertSigningOutdated(domains string, success bool) {
 }
+
+// SetOCSPNextUpdate ...
+func (m *MetricsMock) SetOCSPNextUpdate(crtFile string, nextUpdate *time.Time) {
+}
+
+// SetCRLNextUpdate ...
+func (m *MetricsMock) SetCRLNextUpdate(caFile string, nextUpdate *time.Time) {
+}
+
+// SetHAProxyStats ...
+func (m *MetricsMock) SetHAProxyStats(stats *types.HAProxyStats) {
+}
@@ -16,6 +16,10 @@ limitations under the License.
 
 package types
 
+import (
+	"strings"
+)
+
 // Logger ...
 type Logger interface {
 	InfoV(v int, msg string, args ...interface{})
@@ -24,3 +28,85 @@ type Logger interface {
 	Error(msg string, args ...interface{})
 	Fatal(msg string, args ...interface{})
 }
+
+// LogField is a contextual field of a log message, eg a hostname or the
+// name of a backend. Text loggers use its value as a formatting argument,
+// structured loggers also add it as a field of the message.
+type LogField struct {
+	Key   string
+	Value string
+}
+
+// LogFielder is implemented by formatting arguments of a log message that
+// have contextual fields. Pointer receivers should handle a nil receiver.
+type LogFielder interface {
+	LogFields() []LogField
+}
+
+// LogObject is a namespaced object used as an argument of a log message.
+// Its text representation is its full name, `namespace/name`.
+type LogObject struct {
+	Kind     string
+	FullName string
+}
+
+// LogHost ...
+func LogHost(hostname string) LogField {
+	return LogField{Key: "host", Value: hostname}
+}
+
+// LogBackend ...
+func LogBackend(backend string) LogField {
+	return LogField{Key: "backend", Value: backend}
+}
+
+// LogServer ...
+func LogServer(server string) LogField {
+	return LogField{Key: "server", Value: server}
+}
+
+// LogNamespace ...
+func LogNamespace(namespace string) LogField {
+	return LogField{Key: "namespace", Value: namespace}
+}
+
+// LogIngress ...
+func LogIngress(fullName string) LogObject {
+	return LogObject{Kind: "ingress", FullName: fullName}
+}
+
+// LogService ...
+func LogService(fullName string) LogObject {
+	return LogObject{Kind: "service", FullName: fullName}
+}
+
+// LogSecret ...
+func LogSecret(fullName string) LogObject {
+	return LogObject{Kind: "secret", FullName: fullName}
+}
+
+// String ...
+func (f LogField) String() string {
+	return f.Value
+}
+
+// LogFields ...
+func (f LogField) LogFields() []LogField {
+	return []LogField{f}
+}
+
+// String ...
+func (o LogObject) String() string {
+	return o.FullName
+}
+
+// LogFields ...
+func (o LogObject) LogFields() []LogField {
+	if i := strings.Index(o.FullName, "/"); i >= 0 {
+		return []LogField{
+			{Key: "namespace", Value: o.FullName[:i]},
+			{Key: o.Kind, Value: o.FullName[i+1:]},
+		}
+	}
+	return []LogField{{Key: o.Kind, Value: o.FullName}}
+}
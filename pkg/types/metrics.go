@@ -23,15 +23,84 @@ import (
 // Metrics ...
 type Metrics interface {
 	HAProxyShowInfoResponseTime(duration time.Duration)
+	HAProxyShowStatResponseTime(duration time.Duration)
 	HAProxySetServerResponseTime(duration time.Duration)
+	HAProxySetSSLOCSPResponseTime(duration time.Duration)
+	HAProxySetSSLCRLFileTime(duration time.Duration)
 	ControllerProcTime(task string, duration time.Duration)
 	AddIdleFactor(idle int)
 	IncUpdateNoop()
 	IncUpdateDynamic()
 	IncUpdateFull()
 	UpdateSuccessful(success bool)
+	IncReloadReason(reason string)
+	IncReloadNamespace(reason, namespace string)
 	SetCertExpireDate(domain, cn string, notAfter *time.Time)
 	IncCertSigningMissing(domains string, success bool)
 	IncCertSigningExpiring(domains string, success bool)
 	IncCertSigningOutdated(domains string, success bool)
+	SetOCSPNextUpdate(crtFile string, nextUpdate *time.Time)
+	SetCRLNextUpdate(caFile string, nextUpdate *time.Time)
+	SetHAProxyStats(stats *HAProxyStats)
+}
+
+// HAProxyStats has the counters read from the haproxy stats, labeled with
+// the Kubernetes objects they belong to.
+type HAProxyStats struct {
+	Process  StatsProcess
+	Backends []*StatsBackend
+	Servers  []*StatsServer
+	Paths    []*StatsPath
+	// Dropped is the number of backends, servers and paths not exported
+	// due to the configured limit.
+	Dropped int
+}
+
+// StatsProcess has the counters of the haproxy process.
+type StatsProcess struct {
+	CurrentConnections int64
+	ConnectionsTotal   int64
+	RequestsTotal      int64
+}
+
+// StatsCounters has the traffic counters of a backend or a server.
+type StatsCounters struct {
+	CurrentSessions int64
+	SessionsTotal   int64
+	BytesIn         int64
+	BytesOut        int64
+	// Responses has the number of http responses by status code class:
+	// 1xx, 2xx, 3xx, 4xx, 5xx and other. Empty on tcp mode.
+	Responses map[string]int64
+	// ResponseTime is the average response time of the last 1024 requests.
+	ResponseTime time.Duration
+}
+
+// StatsBackend ...
+type StatsBackend struct {
+	Backend   string
+	Namespace string
+	Service   string
+	Port      string
+	Counters  StatsCounters
+}
+
+// StatsServer ...
+type StatsServer struct {
+	StatsBackend
+	Server string
+	Pod    string
+	Up     bool
+}
+
+// StatsPath links a backend to a host and path, and the ingress that
+// declared it. The haproxy stats don't have counters per host or path.
+type StatsPath struct {
+	Backend   string
+	Namespace string
+	Service   string
+	Port      string
+	Ingress   string
+	Host      string
+	Path      string
 }
@@ -27,6 +27,7 @@ import (
 // Queue ...
 type Queue interface {
 	Add(item interface{})
+	AddAfter(item interface{}, duration time.Duration)
 	Clear()
 	Notify()
 	Remove(item interface{})
@@ -97,6 +98,17 @@ func (q *queue) Add(item interface{}) {
 	q.workqueue.Add(item)
 }
 
+// AddAfter adds item after the given duration. An item that was removed
+// and wasn't added back isn't scheduled.
+func (q *queue) AddAfter(item interface{}, duration time.Duration) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if _, forget := q.forget[item]; forget {
+		return
+	}
+	q.workqueue.AddAfter(item, duration)
+}
+
 func (q *queue) Notify() {
 	// When using with rateLimiter, `nil` will be deduplicated
 	// and `queue.Get()` will release call to `sync()` just once
@@ -19,6 +19,7 @@ package utils
 import (
 	"fmt"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -240,6 +241,37 @@ func TestAddRemoved(t *testing.T) {
 	q.ShutDown()
 }
 
+func TestAddAfter(t *testing.T) {
+	var mutex sync.Mutex
+	var items []interface{}
+	q := NewFailureRateLimitingQueue(20*time.Millisecond, 1*time.Second, func(item interface{}) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		items = append(items, item)
+		return nil
+	})
+	checkItems := func(expected []interface{}) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if !reflect.DeepEqual(items, expected) {
+			t.Errorf("items differ, expected: %+v; actual: %+v", expected, items)
+		}
+	}
+	go q.Run()
+	q.AddAfter(1, 100*time.Millisecond)
+	q.AddAfter(2, 100*time.Millisecond)
+	q.Remove(2)
+	q.AddAfter(2, 50*time.Millisecond)
+	q.AddAfter(3, 50*time.Millisecond)
+	time.Sleep(70 * time.Millisecond)
+	// 70ms
+	checkItems([]interface{}{3})
+	time.Sleep(80 * time.Millisecond)
+	// 150ms
+	checkItems([]interface{}{3, 1})
+	q.ShutDown()
+}
+
 func TestBackoffQueue(t *testing.T) {
 	var count int
 	// retries on 30ms, +60ms(90ms), +120ms(210ms), +240ms(450ms) ... up to 2s
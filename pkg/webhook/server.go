@@ -0,0 +1,165 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	admission "k8s.io/api/admission/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// maxRequestSize is the maximum size of an AdmissionReview request
+const maxRequestSize = 3 * 1024 * 1024
+
+// ValidateFunc returns the problems found on an ingress object,
+// or an empty list if the object is valid.
+type ValidateFunc func(ing *networking.Ingress) []string
+
+// NewServer ...
+func NewServer(logger types.Logger, addr, certFile, keyFile string, validate ValidateFunc) Server {
+	return &server{
+		logger:   logger,
+		addr:     addr,
+		certFile: certFile,
+		keyFile:  keyFile,
+		validate: validate,
+	}
+}
+
+// Server answers the AdmissionReview requests of a ValidatingWebhookConfiguration,
+// rejecting ingress objects whose configuration would be ignored by the controller.
+// Only networking.k8s.io/v1beta1 and extensions/v1beta1 ingress are validated.
+type Server interface {
+	// Listen starts to answer the requests of the API server on a TLS socket.
+	// An error is returned if the key pair cannot be read or the address
+	// cannot be bound, the requests are answered in the background.
+	Listen(stopCh chan struct{}) error
+}
+
+type server struct {
+	logger   types.Logger
+	addr     string
+	certFile string
+	keyFile  string
+	validate ValidateFunc
+	server   *http.Server
+}
+
+func (s *server) Listen(stopCh chan struct{}) error {
+	cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		return err
+	}
+	l, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.server = &http.Server{
+		Addr:      s.addr,
+		Handler:   s,
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+	s.logger.Info("webhook: listening validation requests on %s", s.addr)
+	go func() {
+		if err := s.server.ServeTLS(l, "", ""); err != http.ErrServerClosed {
+			s.logger.Error("webhook: error serving validation requests: %v", err)
+		}
+	}()
+	go func() {
+		<-stopCh
+		s.logger.Info("webhook: closing listener")
+		if err := s.server.Close(); err != nil {
+			s.logger.Error("webhook: error closing listener: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	review := &admission.AdmissionReview{}
+	if err := json.Unmarshal(body, review); err != nil || review.Request == nil {
+		s.logger.Warn("webhook: ignoring invalid admission review request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	review.Response = s.review(review.Request)
+	review.Request = nil
+	out, err := json.Marshal(review)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	_, _ = w.Write(out)
+}
+
+func (s *server) review(req *admission.AdmissionRequest) *admission.AdmissionResponse {
+	resp := &admission.AdmissionResponse{UID: req.UID, Allowed: true}
+	if req.Operation != admission.Create && req.Operation != admission.Update {
+		return resp
+	}
+	if req.Kind.Kind != "Ingress" || req.Kind.Version != "v1beta1" ||
+		(req.Kind.Group != "networking.k8s.io" && req.Kind.Group != "extensions") {
+		return resp
+	}
+	ing := &networking.Ingress{}
+	if err := json.Unmarshal(req.Object.Raw, ing); err != nil {
+		resp.Allowed = false
+		resp.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Reason:  metav1.StatusReasonBadRequest,
+			Message: fmt.Sprintf("error reading ingress: %v", err),
+		}
+		return resp
+	}
+	if ing.Namespace == "" {
+		ing.Namespace = req.Namespace
+	}
+	problems := s.validate(ing)
+	if len(problems) == 0 {
+		return resp
+	}
+	s.logger.InfoV(2, "webhook: rejecting ingress '%s/%s': %s", ing.Namespace, ing.Name, strings.Join(problems, "; "))
+	resp.Allowed = false
+	resp.Result = &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Reason:  metav1.StatusReasonInvalid,
+		Code:    http.StatusUnprocessableEntity,
+		Message: "invalid ingress configuration:\n- " + strings.Join(problems, "\n- "),
+	}
+	return resp
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2020 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	admission "k8s.io/api/admission/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	types_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestReview(t *testing.T) {
+	testCases := []struct {
+		kind       metav1.GroupVersionKind
+		operation  admission.Operation
+		object     string
+		problems   []string
+		expAllowed bool
+		expMessage string
+		logging    string
+	}{
+		// 0
+		{
+			expAllowed: true,
+		},
+		// 1
+		{
+			problems:   []string{"unknown annotation 'ingress.kubernetes.io/timeout'"},
+			expMessage: "invalid ingress configuration:\n- unknown annotation 'ingress.kubernetes.io/timeout'",
+			logging:    `INFO-V(2) webhook: rejecting ingress 'default/app': unknown annotation 'ingress.kubernetes.io/timeout'`,
+		},
+		// 2
+		{
+			operation:  admission.Delete,
+			problems:   []string{"invalid"},
+			expAllowed: true,
+		},
+		// 3
+		{
+			kind:       metav1.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"},
+			problems:   []string{"invalid"},
+			expAllowed: true,
+		},
+		// 4
+		{
+			kind:       metav1.GroupVersionKind{Group: "extensions", Version: "v1beta1", Kind: "Ingress"},
+			problems:   []string{"invalid 1", "invalid 2"},
+			expMessage: "invalid ingress configuration:\n- invalid 1\n- invalid 2",
+			logging:    `INFO-V(2) webhook: rejecting ingress 'default/app': invalid 1; invalid 2`,
+		},
+		// 5
+		{
+			object:     `{"metadata":"invalid"}`,
+			expMessage: "error reading ingress: json: cannot unmarshal string into Go struct field Ingress.metadata of type v1.ObjectMeta",
+		},
+	}
+	for i, test := range testCases {
+		logger := &types_helper.LoggerMock{T: t}
+		var validated *networking.Ingress
+		s := NewServer(logger, "", "", "", func(ing *networking.Ingress) []string {
+			validated = ing
+			return test.problems
+		}).(*server)
+		kind := test.kind
+		if kind.Kind == "" {
+			kind = metav1.GroupVersionKind{Group: "networking.k8s.io", Version: "v1beta1", Kind: "Ingress"}
+		}
+		operation := test.operation
+		if operation == "" {
+			operation = admission.Create
+		}
+		object := test.object
+		if object == "" {
+			object = `{"metadata":{"name":"app"}}`
+		}
+		resp := s.review(&admission.AdmissionRequest{
+			UID:       "1234",
+			Kind:      kind,
+			Namespace: "default",
+			Operation: operation,
+			Object:    runtime.RawExtension{Raw: []byte(object)},
+		})
+		if resp.UID != "1234" {
+			t.Errorf("uid differs on %d - expected: 1234, actual: %s", i, resp.UID)
+		}
+		if resp.Allowed != test.expAllowed {
+			t.Errorf("allowed differs on %d - expected: %v, actual: %v", i, test.expAllowed, resp.Allowed)
+		}
+		var message string
+		if resp.Result != nil {
+			message = resp.Result.Message
+		}
+		if message != test.expMessage {
+			t.Errorf("message differs on %d - expected: %q, actual: %q", i, test.expMessage, message)
+		}
+		if validated != nil && validated.Namespace != "default" {
+			t.Errorf("namespace differs on %d - expected: default, actual: %s", i, validated.Namespace)
+		}
+		logger.CompareLogging(test.logging)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	logger := &types_helper.LoggerMock{T: t}
+	s := NewServer(logger, "", "", "", func(ing *networking.Ingress) []string {
+		return []string{"invalid"}
+	}).(*server)
+
+	body := `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":{"uid":"1234",` +
+		`"kind":{"group":"networking.k8s.io","version":"v1beta1","kind":"Ingress"},` +
+		`"namespace":"default","operation":"CREATE","object":{"metadata":{"name":"app"}}}}`
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: %d", w.Code)
+	}
+	review := &admission.AdmissionReview{}
+	if err := json.Unmarshal(w.Body.Bytes(), review); err != nil {
+		t.Fatalf("error reading response: %v", err)
+	}
+	if review.APIVersion != "admission.k8s.io/v1" || review.Kind != "AdmissionReview" {
+		t.Errorf("unexpected type: %s %s", review.APIVersion, review.Kind)
+	}
+	if review.Request != nil || review.Response == nil || review.Response.UID != "1234" || review.Response.Allowed {
+		t.Errorf("unexpected response: %+v", review.Response)
+	}
+	logger.CompareLogging(`INFO-V(2) webhook: rejecting ingress 'default/app': invalid`)
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}")))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unexpected status code: %d", w.Code)
+	}
+	logger.CompareLogging(`WARN webhook: ignoring invalid admission review request`)
+}
+
+func TestListen(t *testing.T) {
+	logger := &types_helper.LoggerMock{T: t}
+	dir, err := ioutil.TempDir("", "webhook")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeKeyPair(t, certFile, keyFile)
+
+	s := NewServer(logger, "127.0.0.1:0", filepath.Join(dir, "none.crt"), keyFile, nil)
+	if err := s.Listen(nil); err == nil {
+		t.Errorf("expected an error reading a missing certificate")
+	}
+	logger.CompareLogging(``)
+
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error creating listener: %v", err)
+	}
+	defer busy.Close()
+	s = NewServer(logger, busy.Addr().String(), certFile, keyFile, nil)
+	if err := s.Listen(nil); err == nil {
+		t.Errorf("expected an error binding a busy address")
+	}
+	logger.CompareLogging(``)
+
+	stopCh := make(chan struct{})
+	s = NewServer(logger, "127.0.0.1:0", certFile, keyFile, nil)
+	if err := s.Listen(stopCh); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	close(stopCh)
+	time.Sleep(50 * time.Millisecond)
+	logger.CompareLogging(`
+INFO webhook: listening validation requests on 127.0.0.1:0
+INFO webhook: closing listener`)
+}
+
+func writeKeyPair(t *testing.T, certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "webhook"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("error encoding key: %v", err)
+	}
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("error writing certificate: %v", err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("error writing key: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+-- Copyright 2020 The HAProxy Ingress Controller Authors.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- ocsp-check asks the controller for the OCSP status of the client
+-- certificate, and stores the result in the txn.ocsp_status variable:
+-- `good`, `revoked` or `error`. The controller caches the responses
+-- of the OCSP responders up to their next update.
+--
+-- The client certificate doesn't change during a TLS session, so the
+-- controller is asked once and the status is cached in the
+-- sess.ocsp_status variable. Errors are not cached, so the next
+-- request of the same session asks the controller again.
+
+local function ocsp_status(txn, socket)
+    local der = txn.f:ssl_c_der()
+    if der == nil or der == "" then
+        return "error"
+    end
+    local conn = core.tcp()
+    conn:settimeout(15)
+    if conn:connect(socket) == nil then
+        return "error"
+    end
+    conn:send("GET / HTTP/1.0\r\nX-SSL-Client-Cert: " .. txn.c:base64(der) .. "\r\n\r\n")
+    local line = conn:receive("*l")
+    conn:close()
+    if line == nil then
+        return "error"
+    end
+    local code = line:match("^HTTP/%d%.%d (%d+)")
+    if code == "200" then
+        return "good"
+    elseif code == "403" then
+        return "revoked"
+    end
+    return "error"
+end
+
+core.register_action("ocsp-check", { "http-req" }, function(txn, socket)
+    local status = txn:get_var("sess.ocsp_status")
+    if status == nil then
+        status = ocsp_status(txn, socket)
+        if status ~= "error" then
+            txn:set_var("sess.ocsp_status", status)
+        end
+    end
+    txn:set_var("txn.ocsp_status", status)
+end, 1)
@@ -16,11 +16,14 @@
 
 set -e
 
-if [ $# -gt 0 ] && [ "$(echo $1 | cut -b1-2)" != "--" ]; then
+if [ "$1" = "explain" ]; then
+    # Controller command, queries the explain endpoint of a running controller
+    exec /haproxy-ingress-controller "$@"
+elif [ $# -gt 0 ] && [ "$(echo $1 | cut -b1-2)" != "--" ]; then
     # Probably a `docker run -ti`, so exec and exit
     exec "$@"
 else
     # Copy static files to /etc/haproxy, which cannot have static content
-    cp -R -p /etc/lua /etc/haproxy/
+    cp -R -p /etc/lua /etc/errorfiles /etc/haproxy/
     exec /haproxy-ingress-controller "$@"
 fi